@@ -1,10 +1,26 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/edgedelta/otelgen/pkg/otelgen"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -20,32 +36,262 @@ func init() {
 var (
 	otlpEndpoint  string
 	serviceName   string
-	rate          int
+	rate          string
+	rateBurst     int
+	rampUp        time.Duration
+	rampDown      time.Duration
+	steps         string
+	burst         string
+	ratePattern   string
+	throughput    string
+	findMax       bool
+	probeDuration time.Duration
+	maxErrorRate  string
+	maxLatency    time.Duration
+	arrival       string
+	warmup        time.Duration
+	payloadPool   int
+	workers       int
+	fast          bool
+	adaptive      bool
+	pprofAddr     string
+	healthAddr    string
+	outputMode    string
+	latencyAlert  time.Duration
+	memLimit      string
+	seed          int64
+	count         int64
+	eventStream   *otelgen.EventStream
+	soak          bool
+	soakInterval  time.Duration
+	soakFile      string
+	statsInterval time.Duration
+	statsFormat   string
+	reportFile    string
+	assertRate    string
+	assertErrors  string
+	assertP99     time.Duration
+	progressBar   bool
+	expectDeliv   int64
+	within        time.Duration
+	deliverySink  string
 	duration      string
 	size          string
 	batchSize     int
+	tracesRate    string
+	metricsRate   string
+	logsRate      string
+	mix           string
+	scenarioFile  string
 	headers       map[string]string
 	verbose       bool
 	insecureSkip  bool
+	tlsServerName string
+	bearerToken   string
+	bearerFile    string
+	basicAuth     string
+	oauthURL      string
+	oauthID       string
+	oauthSecret   string
+	oauthScopes   []string
+	authPreset    string
+	apiKey        string
+	hmacSecret    string
+	hmacHeader    string
+	compression   string
+	httpPath      string
+	httpVersion   string
+	encoding      string
+	grpcMaxMsg    string
+	grpcTimeout   time.Duration
+	connectTO     time.Duration
+	exportTO      time.Duration
+	connections   int
+	resolveAddrs  []string
+	lbStrategy    string
+	lbWeights     []int
+	retryEnabled  bool
+	retryInitial  time.Duration
+	retryMax      time.Duration
+	retryElapsed  time.Duration
+	preset        string
+	realMetrics   bool
+	resetEvery    time.Duration
+	pattern       string
+	patternPeriod time.Duration
+	patternAmp    float64
+	sparse        string
+	adversarial   bool
+	histBuckets   []float64
+	inconsistent  bool
+	churn         string
+	metricsFile   string
+	unitVariety   bool
+	hosts         int
+	metadataEdge  bool
+	staleMarkers  bool
+	rateUnit      string
+	conflictRate  string
+	activeSeries  int
+	valueType     string
+	backfill      time.Duration
+	backfillStep  time.Duration
+	checkpoint    string
+	resume        string
+	exporterKind  string
+	exporterAddr  string
+	brokers       []string
+	kafkaTopic    string
+	fluentTag     string
+	syslogNet     string
+	syslogFraming string
+	fileDir       string
+	outFormat     string
+	rotateSize    string
+	rotateEvery   time.Duration
+	rotateGzip    bool
+	dlqDir        string
+	exportLogPath string
+	controlSocket string
+	benchSignal   string
+	benchComps    []string
+	benchBatches  []int
+	benchConc     int
+	stampEmit     bool
+	sequence      bool
+	selfEndpoint  string
+	traceSelf     bool
+	sinkGRPC      string
+	sinkHTTP      string
+	sinkDuration  time.Duration
+	sinkInterval  time.Duration
+	sinkCapture   string
+	diffIgnore    []string
+	diffStrict    bool
+	diffExamples  int
+	coordListen   string
+	coordWorkers  int
+	coordInterval time.Duration
+	joinAddr      string
+	workerName    string
+	// runProgress and runStop are set for a run a coordinator assigned to this worker
+	runProgress *otelgen.Progress
+	runStop     <-chan struct{}
 )
 
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "otelgen",
 		Short: "Generate telemetry data (traces, metrics, logs) for testing OTEL endpoints",
+		// main prints the error
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The flags parsed, so errors from here on are the run's, such as a missed
+			// --assert-* threshold, and the usage wouldn't help
+			cmd.SilenceUsage = true
+			if err := startOutput(); err != nil {
+				return err
+			}
+			return startPprof()
+		},
+	}
+
+	// Flags for how exports reach the OTLP endpoint
+	addTransportFlags := func(cmd *cobra.Command) {
+		cmd.Flags().BoolVar(&insecureSkip, "insecure-skip-verify", false, "Skip TLS certificate verification (insecure)")
+		cmd.Flags().StringVar(&tlsServerName, "tls-server-name", "", "Server name to send as SNI and verify the certificate against, instead of the endpoint host")
+		cmd.Flags().StringVar(&bearerToken, "bearer-token", "", "Bearer token sent in the Authorization header of every export")
+		cmd.Flags().StringVar(&bearerFile, "bearer-token-file", "", "File containing the bearer token; re-read when it changes, so rotated tokens are picked up")
+		cmd.Flags().StringVar(&basicAuth, "basic-auth", "", "Credentials in user:password form, sent as a Basic Authorization header on every export")
+		cmd.Flags().StringVar(&oauthURL, "oauth2-token-url", "", "OAuth2 token endpoint; access tokens are fetched with the client-credentials flow and refreshed before they expire")
+		cmd.Flags().StringVar(&oauthID, "oauth2-client-id", "", "OAuth2 client ID")
+		cmd.Flags().StringVar(&oauthSecret, "oauth2-client-secret", "", "OAuth2 client secret")
+		cmd.Flags().StringSliceVar(&oauthScopes, "oauth2-scopes", nil, "OAuth2 scopes to request (e.g., ingest.write,metrics)")
+		cmd.Flags().StringVar(&authPreset, "auth-preset", "", "Send --api-key in the header the vendor expects (edgedelta, datadog, honeycomb, newrelic)")
+		cmd.Flags().StringVar(&apiKey, "api-key", "", "API key for --auth-preset")
+		cmd.MarkFlagsMutuallyExclusive("bearer-token", "bearer-token-file", "basic-auth", "oauth2-token-url", "auth-preset")
+		cmd.MarkFlagsRequiredTogether("auth-preset", "api-key")
+		cmd.Flags().StringVar(&hmacSecret, "hmac-sign", "", "Secret to sign every export with, sending HMAC-SHA256 of the request body as sha256=<hex> in --hmac-header")
+		cmd.Flags().StringVar(&hmacHeader, "hmac-header", "X-Signature", "Header carrying the --hmac-sign signature")
+		cmd.Flags().StringVar(&compression, "compression", "none", "Export compression (none, gzip, zstd for gRPC)")
+		cmd.Flags().StringVar(&grpcMaxMsg, "grpc-max-msg-size", "", "Largest gRPC message sent or received (e.g., 16mb); servers default to 4mb")
+		cmd.Flags().DurationVar(&grpcTimeout, "grpc-timeout", 0, "Deadline for each gRPC export call (e.g., 30s); defaults to --export-timeout")
+		cmd.Flags().DurationVar(&connectTO, "connect-timeout", 10*time.Second, "Time allowed to create the exporter and establish each connection")
+		cmd.Flags().DurationVar(&exportTO, "export-timeout", 30*time.Second, "Time allowed for each export, including its retries")
+		cmd.Flags().StringArrayVar(&resolveAddrs, "resolve", nil, "Dial this IP for an endpoint host and port, keeping the host for TLS, as host:port:ip[,ip...] (repeatable)")
+		cmd.Flags().StringVar(&lbStrategy, "lb-strategy", "round-robin", "How exports are spread across several endpoints or resolved IPs (round-robin, random, weighted)")
+		cmd.Flags().IntSliceVar(&lbWeights, "lb-weights", nil, "Weight of each endpoint or resolved IP, in order, for --lb-strategy weighted (e.g., 3,1)")
+		cmd.Flags().IntVar(&connections, "connections", 1, "Number of independent gRPC channels or HTTP clients to spread exports across round-robin")
+		cmd.Flags().StringVar(&httpVersion, "http-version", "", "Force HTTP/1.1 or HTTP/2 for HTTP endpoints (1.1, 2); by default HTTP/2 is negotiated over TLS")
+		cmd.Flags().StringVar(&encoding, "encoding", "protobuf", "HTTP export encoding (protobuf, json)")
+		cmd.Flags().StringVar(&httpPath, "http-path", "", "URL path to export to instead of /v1/<signal> (HTTP endpoints only, e.g., /custom/v1/traces)")
+		cmd.MarkFlagsRequiredTogether("oauth2-token-url", "oauth2-client-id", "oauth2-client-secret")
+	}
+
+	// Flags for retrying failed exports
+	addRetryFlags := func(cmd *cobra.Command) {
+		cmd.Flags().BoolVar(&retryEnabled, "retry-enabled", true, "Retry failed exports with exponential backoff, honoring RetryInfo and Retry-After")
+		cmd.Flags().DurationVar(&retryInitial, "retry-initial-interval", 5*time.Second, "Wait after the first failed export before retrying")
+		cmd.Flags().DurationVar(&retryMax, "retry-max-interval", 30*time.Second, "Longest wait between retries")
+		cmd.Flags().DurationVar(&retryElapsed, "retry-max-elapsed", time.Minute, "Total time spent retrying one export before it is dropped")
 	}
 
 	// Common flags for all commands
 	addCommonFlags := func(cmd *cobra.Command) {
-		cmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP endpoint (e.g., grpcs://host:443, http://host:80)")
+		cmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP endpoint (e.g., grpcs://host:443, http://host:80); several comma-separated endpoints are load-balanced")
 		cmd.Flags().StringVar(&serviceName, "service", "otelgen", "Service name")
-		cmd.Flags().IntVar(&rate, "rate", 1, "Rate of telemetry generation per second")
-		cmd.Flags().StringVar(&duration, "duration", "10s", "Duration to generate telemetry (e.g., 10s, 1m)")
+		cmd.Flags().StringVar(&rate, "rate", "1", "Rate of telemetry generation per second, which may be fractional or per minute or hour (e.g., 500, 0.2, 10/min)")
+		cmd.Flags().IntVar(&rateBurst, "rate-burst", 0, "Most events emitted at once when generation falls behind --rate (default 50ms worth)")
+		cmd.Flags().DurationVar(&rampUp, "ramp-up", 0, "Scale the rate linearly from zero to --rate over the start of the run (e.g., 2m)")
+		cmd.Flags().DurationVar(&rampDown, "ramp-down", 0, "Scale the rate linearly from --rate to zero over the end of the run (e.g., 1m)")
+		cmd.Flags().StringVar(&ratePattern, "rate-pattern", "", "Make the rate follow a cycle instead of --rate: sine:period=D:min=R:max=R or diurnal:min=R:max=R[:peak=14h] (e.g., sine:period=24h:min=10:max=500)")
+		cmd.Flags().StringVar(&burst, "burst", "", "Send bursts of events on top of the rate, as count=N,every=D (e.g., count=5000,every=60s)")
+		cmd.Flags().StringVar(&arrival, "arrival", "fixed", "How events are spaced around the rate: fixed (evenly), poisson (a Poisson process), or uniform (gaps uniform between zero and twice the mean)")
+		cmd.Flags().StringVar(&steps, "steps", "", "Run a sequence of rate plateaus instead of --rate and --duration (e.g., 10/s:5m,100/s:5m,1000/s:5m)")
+		cmd.Flags().StringVar(&duration, "duration", "10s", "Duration to generate telemetry (e.g., 10s, 1m); 0 or infinite runs until interrupted")
+		cmd.Flags().DurationVar(&warmup, "warmup", 0, "Send but don't count items during this start of --duration, so connection setup doesn't skew the summary (e.g., 30s)")
+		cmd.Flags().BoolVar(&soak, "soak", false, "Print rolling stats and otelgen's own memory and GC activity at every --soak-interval, for long runs")
+		cmd.Flags().DurationVar(&soakInterval, "soak-interval", 5*time.Minute, "Time between --soak checkpoints")
+		cmd.Flags().StringVar(&soakFile, "soak-file", "", "Append every --soak checkpoint to this file as a line of JSON")
+		cmd.Flags().DurationVar(&statsInterval, "stats-interval", 0, "Print the achieved rate, exports, bytes sent, and export latency percentiles at this interval during the run (e.g., 10s)")
+		cmd.Flags().StringVar(&statsFormat, "stats-format", "text", "Format of --stats-interval lines (text, ndjson)")
+		cmd.Flags().BoolVar(&progressBar, "progress", false, "Draw the run's progress, ETA, and achieved rate against the target on stderr, warning when the rate falls behind")
+		cmd.Flags().StringVar(&reportFile, "report", "", "Write a report of the run and its --assert-* thresholds to this file: JUnit XML for .xml, JSON for .json")
+		cmd.Flags().StringVar(&assertRate, "assert-min-rate", "", "Fail the run if it achieves less than this rate (e.g., 1000, 1000/s, 60000/min)")
+		cmd.Flags().StringVar(&assertErrors, "assert-max-error-rate", "", "Fail the run if more than this fraction of exports fail (e.g., 1%, 0.01)")
+		cmd.Flags().DurationVar(&assertP99, "assert-max-p99-export-latency", 0, "Fail the run if the p99 export latency exceeds this (e.g., 500ms)")
+		cmd.Flags().DurationVar(&latencyAlert, "latency-alert", 0, "Warn as soon as an export attempt takes longer than this, and count such attempts in the summary (e.g., 2s)")
+		cmd.Flags().Int64Var(&count, "count", 0, "Stop after exactly this many traces, metric events, or log records, however long it takes, or within --duration if it is also given")
+		cmd.Flags().Int64Var(&seed, "seed", 0, "Seed everything generated at random, so runs with the same seed send the same payloads apart from timestamps (0 draws a new seed every run)")
+		cmd.Flags().StringVar(&memLimit, "mem-limit", "", "Abort the run once otelgen's own resident memory passes this size (e.g., 512MB)")
+		cmd.Flags().Int64Var(&expectDeliv, "expect-delivered", 0, "End the run once this many spans, data points, or log records are confirmed delivered, and fail it unless they are --within the window")
+		cmd.Flags().DurationVar(&within, "within", 2*time.Minute, "Time allowed for --expect-delivered items to be delivered, and the longest the run lasts")
+		cmd.Flags().StringVar(&deliverySink, "delivery-sink", "", "Confirm --expect-delivered items with the counts of the otelgen sink whose HTTP receiver is at this URL (e.g., http://sink:4318), instead of the exports the endpoint accepted")
+		cmd.MarkFlagsMutuallyExclusive("expect-delivered", "duration")
+		cmd.MarkFlagsMutuallyExclusive("expect-delivered", "steps")
+		cmd.MarkFlagsMutuallyExclusive("expect-delivered", "count")
 		cmd.Flags().StringVar(&size, "size", "", "Payload size (e.g., 1kb, 1mb, 500b)")
-		cmd.Flags().StringToStringVar(&headers, "headers", nil, "Additional headers (e.g., key1=value1,key2=value2)")
+		cmd.Flags().StringToStringVar(&headers, "headers", nil, "Additional headers (e.g., key1=value1,key2=value2); values with {{.Timestamp}}, {{.TimestampMillis}}, {{.RFC3339}}, {{.Nonce}}, or {{.UUID}} are evaluated for every export")
 		cmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
-		cmd.Flags().BoolVar(&insecureSkip, "insecure-skip-verify", false, "Skip TLS certificate verification (insecure)")
-		cmd.MarkFlagRequired("otlp-endpoint")
+		cmd.Flags().StringVar(&controlSocket, "control-socket", "", "Accept rate changes on this Unix socket while the run goes on (commands: rate, set <rate>, double, halve, scale <factor>, reset)")
+		cmd.Flags().StringVar(&selfEndpoint, "self-telemetry-endpoint", "", "OTLP endpoint to export otelgen's own metrics to, without the run's transport settings (e.g., grpc://localhost:4317)")
+		cmd.Flags().BoolVar(&traceSelf, "trace-self", false, "Also export a span for every export, with its batch size, compression, and result, to the self-telemetry endpoint")
+		cmd.Flags().StringVar(&outputMode, "output", "text", "What to print on stdout: text; ndjson for a line of JSON for the run's start, end, and phases and every export attempt; or json for the run's result as it ends; with ndjson and json the text moves to stderr")
+		cmd.Flags().StringVar(&healthAddr, "health-listen", "", "Serve /healthz and /readyz probes on this address, and end the run with its usual flush and summary on SIGTERM, for running otelgen in Kubernetes (e.g., :8080)")
+		cmd.Flags().StringVar(&pprofAddr, "pprof", "", "Serve Go profiles of otelgen itself on this address under /debug/pprof/, for when otelgen rather than the endpoint is the bottleneck (e.g., :6060)")
+		addTransportFlags(cmd)
+		addRetryFlags(cmd)
+		cmd.MarkFlagsMutuallyExclusive("steps", "rate")
+		cmd.MarkFlagsMutuallyExclusive("steps", "duration")
+		cmd.MarkFlagsMutuallyExclusive("rate-pattern", "rate")
+		cmd.MarkFlagsMutuallyExclusive("rate-pattern", "steps")
+		cmd.Flags().StringVar(&dlqDir, "dlq", "", "Write every export that fails for good to this directory, to be re-sent later with otelgen retry")
+		cmd.Flags().StringVar(&exportLogPath, "log-exports", "", "Append a line of JSON for every export attempt (items, bytes, duration, gRPC code or HTTP status, rejected items) to this file, or - for stdout")
+		cmd.Flags().StringVar(&exporterKind, "exporter", "otlp", "Output format (otlp, kafka, stdout; statsd for metrics only; fluentforward, syslog, tcp, udp, file for logs only)")
+		cmd.Flags().StringVar(&exporterAddr, "exporter-endpoint", "", "Endpoint for non-OTLP exporters (e.g., localhost:8125, tcp://localhost:8125, localhost:24224, localhost:5140)")
+		cmd.Flags().StringSliceVar(&brokers, "brokers", nil, "Kafka seed brokers for --exporter kafka (e.g., b1:9092,b2:9092)")
+		cmd.Flags().StringVar(&kafkaTopic, "topic", "", "Kafka topic for --exporter kafka (default otlp_spans, otlp_metrics, or otlp_logs)")
+		cmd.Flags().StringVar(&outFormat, "format", "", "Output format for --exporter stdout (pretty, json) or, for logs, --exporter file (json, plain)")
 	}
 
 	// Traces command
@@ -55,6 +301,20 @@ func main() {
 		RunE:  runTraces,
 	}
 	addCommonFlags(tracesCmd)
+	addThroughputFlag(tracesCmd)
+	addPayloadPoolFlag(tracesCmd, "span attribute sets")
+	addFastFlag(tracesCmd, "spans")
+	addWorkersFlag(tracesCmd, "traces")
+	addAdaptiveFlag(tracesCmd)
+	addStampEmitTimeFlag(tracesCmd, "span")
+	addSequenceFlag(tracesCmd, "span")
+	tracesCmd.Flags().BoolVar(&findMax, "find-max", false, "Search for the highest rate the endpoint sustains, starting from --rate, instead of running for --duration")
+	tracesCmd.Flags().DurationVar(&probeDuration, "probe-duration", 30*time.Second, "How long --find-max holds each rate")
+	tracesCmd.Flags().StringVar(&maxErrorRate, "max-error-rate", "1%", "Most failed exports or undelivered spans a rate may cause under --find-max")
+	tracesCmd.Flags().DurationVar(&maxLatency, "max-latency", time.Second, "Highest p99 export latency a rate may cause under --find-max")
+	for _, flag := range []string{"duration", "steps", "rate-pattern", "throughput", "ramp-up", "ramp-down", "burst", "soak", "stats-interval", "progress", "expect-delivered", "report", "assert-min-rate", "assert-max-error-rate", "assert-max-p99-export-latency", "warmup", "adaptive", "control-socket", "count", "workers"} {
+		tracesCmd.MarkFlagsMutuallyExclusive("find-max", flag)
+	}
 
 	// Metrics command
 	metricsCmd := &cobra.Command{
@@ -63,6 +323,33 @@ func main() {
 		RunE:  runMetrics,
 	}
 	addCommonFlags(metricsCmd)
+	metricsCmd.Flags().StringVar(&preset, "preset", "", "Metric preset to emit instead of the default metrics (jvm, goruntime)")
+	metricsCmd.Flags().BoolVar(&realMetrics, "real", false, "Report the actual host's CPU, memory, disk, and network values")
+	metricsCmd.Flags().DurationVar(&resetEvery, "reset-every", 0, "Simulate a process restart at this interval, resetting counters (e.g., 5m)")
+	metricsCmd.Flags().StringVar(&pattern, "pattern", "random", "Gauge value pattern (random, sine, sawtooth, step, random-walk, constant)")
+	metricsCmd.Flags().DurationVar(&patternPeriod, "pattern-period", time.Minute, "Length of one gauge pattern cycle")
+	metricsCmd.Flags().Float64Var(&patternAmp, "pattern-amplitude", 50, "How far the gauge swings around its center of 50")
+	metricsCmd.Flags().StringVar(&sparse, "sparse", "", "Fraction of series that stop reporting for random intervals (e.g., 20%)")
+	metricsCmd.Flags().BoolVar(&adversarial, "adversarial-values", false, "Occasionally emit NaN/Inf, negative counters, and other out-of-spec values")
+	metricsCmd.Flags().Float64SliceVar(&histBuckets, "histogram-buckets", nil, "Explicit bucket boundaries for the duration histogram (e.g., 10,50,100,500)")
+	metricsCmd.Flags().BoolVar(&inconsistent, "inconsistent-histograms", false, "Occasionally emit histograms whose count, sum, and buckets disagree")
+	metricsCmd.Flags().StringVar(&churn, "churn", "", "Rate of brand-new series introduced (e.g., 1000/min)")
+	metricsCmd.Flags().StringVar(&metricsFile, "metrics-file", "", "YAML file defining the metrics to generate instead of the defaults, and SDK views")
+	metricsCmd.Flags().BoolVar(&unitVariety, "unit-variety", false, "Cycle through gauges using a broad set of UCUM units and invalid unit strings")
+	metricsCmd.Flags().BoolVar(&metadataEdge, "metadata-edge-cases", false, "Rotate through metrics with extreme names and descriptions (over-long, unicode, missing)")
+	metricsCmd.Flags().BoolVar(&staleMarkers, "staleness-markers", false, "Report short-lived series that end with a NO_RECORDED_VALUE datapoint")
+	metricsCmd.Flags().StringVar(&conflictRate, "conflict-rate", "", "Chance per tick of re-emitting a metric name with a different type or unit (e.g., 5%)")
+	metricsCmd.Flags().IntVar(&activeSeries, "active-series", 0, "Report exactly this many series at every export instead of the default metrics")
+	metricsCmd.Flags().StringVar(&valueType, "value-type", "mixed", "Number type of counter and gauge datapoints (int, double, mixed)")
+	metricsCmd.Flags().StringVar(&rateUnit, "rate-unit", "events", "What --rate counts: events (Add/Record calls) or datapoints (datapoints/s on the wire)")
+	metricsCmd.Flags().DurationVar(&backfill, "backfill", 0, "Send this much past data as fast as the endpoint accepts it, instead of live data (e.g., 24h)")
+	metricsCmd.Flags().DurationVar(&backfillStep, "step", 15*time.Second, "Spacing between backfilled timestamps")
+	metricsCmd.Flags().StringVar(&checkpoint, "checkpoint", "", "Save --backfill progress to this file after every request, so an interrupted backfill can be resumed with --resume")
+	metricsCmd.Flags().StringVar(&resume, "resume", "", "Resume the backfill saved in this --checkpoint file, continuing to save progress to it")
+	metricsCmd.MarkFlagsMutuallyExclusive("checkpoint", "resume")
+	metricsCmd.MarkFlagsMutuallyExclusive("backfill", "count")
+	metricsCmd.MarkFlagsMutuallyExclusive("resume", "count")
+	metricsCmd.Flags().IntVar(&hosts, "hosts", 1, "Number of simulated hosts batched as separate resources into each export request")
 
 	// Logs command
 	logsCmd := &cobra.Command{
@@ -71,115 +358,1585 @@ func main() {
 		RunE:  runLogs,
 	}
 	addCommonFlags(logsCmd)
+	addThroughputFlag(logsCmd)
+	addPayloadPoolFlag(logsCmd, "log bodies and attributes")
+	addFastFlag(logsCmd, "log records")
+	addWorkersFlag(logsCmd, "log records")
+	addAdaptiveFlag(logsCmd)
+	addStampEmitTimeFlag(logsCmd, "log record")
+	addSequenceFlag(logsCmd, "log record")
 	logsCmd.Flags().IntVar(&batchSize, "batch-size", 512, "Maximum number of logs to batch before sending")
+	logsCmd.Flags().StringVar(&fluentTag, "tag", "otelgen", "Event tag for --exporter fluentforward")
+	logsCmd.Flags().StringVar(&syslogNet, "transport", "tcp", "Transport for --exporter syslog (tcp, udp, tls)")
+	logsCmd.Flags().StringVar(&syslogFraming, "framing", "octet-counting", "Message framing for --exporter syslog over tcp or tls (octet-counting, non-transparent)")
+	logsCmd.Flags().StringVar(&fileDir, "dir", "", "Directory for --exporter file; logs are written to otelgen.log in it")
+	logsCmd.Flags().StringVar(&rotateSize, "rotate-size", "", "Rotate the --exporter file log once it reaches this size (e.g., 100mb)")
+	logsCmd.Flags().DurationVar(&rotateEvery, "rotate-every", 0, "Rotate the --exporter file log at this interval (e.g., 1m)")
+	logsCmd.Flags().BoolVar(&rotateGzip, "rotate-compress", false, "Gzip rotated --exporter file logs")
 
-	rootCmd.AddCommand(tracesCmd, metricsCmd, logsCmd)
+	// All command
+	allCmd := &cobra.Command{
+		Use:   "all",
+		Short: "Generate traces, metrics, and logs together, as one service would",
+		RunE:  runAll,
+	}
+	allCmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP endpoint (e.g., grpcs://host:443, http://host:80); several comma-separated endpoints are load-balanced")
+	allCmd.Flags().StringVar(&serviceName, "service", "otelgen", "Service name")
+	allCmd.Flags().StringVar(&tracesRate, "traces-rate", "1", "Traces per second, or per minute or hour (e.g., 10/min; 0 for none)")
+	allCmd.Flags().StringVar(&metricsRate, "metrics-rate", "1", "Metric events per second, or per minute or hour (e.g., 10/min; 0 for none)")
+	allCmd.Flags().StringVar(&logsRate, "logs-rate", "1", "Log records per second, or per minute or hour (e.g., 10/min; 0 for none)")
+	allCmd.Flags().StringVar(&rate, "rate", "1", "Total rate of telemetry split across the signals by --mix, in the same form as for the other commands")
+	allCmd.Flags().StringVar(&mix, "mix", "", "Percentage of --rate each signal gets instead of its own rate, adding up to 100 (e.g., logs=70,metrics=20,traces=10)")
+	allCmd.Flags().StringVar(&duration, "duration", "10s", "Duration to generate telemetry (e.g., 10s, 1m); 0 or infinite runs until interrupted")
+	allCmd.Flags().StringVar(&size, "size", "", "Payload size (e.g., 1kb, 1mb, 500b)")
+	allCmd.Flags().IntVar(&batchSize, "batch-size", 512, "Maximum number of logs to batch before sending")
+	allCmd.Flags().StringToStringVar(&headers, "headers", nil, "Additional headers (e.g., key1=value1,key2=value2); values with {{.Timestamp}}, {{.TimestampMillis}}, {{.RFC3339}}, {{.Nonce}}, or {{.UUID}} are evaluated for every export")
+	allCmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	allCmd.Flags().StringVar(&outputMode, "output", "text", "What to print on stdout: text; ndjson for a line of JSON for every generator's start, end, and phases and every export attempt; or json for the result of every generator as the run ends; with ndjson and json the text moves to stderr")
+	allCmd.Flags().StringVar(&scenarioFile, "config", "", "Run the jobs of this scenario file (YAML or JSON) instead, with the other flags as their defaults")
+	allCmd.MarkFlagsMutuallyExclusive("config", "traces-rate")
+	allCmd.MarkFlagsMutuallyExclusive("config", "metrics-rate")
+	allCmd.MarkFlagsMutuallyExclusive("config", "logs-rate")
+	allCmd.MarkFlagsMutuallyExclusive("config", "mix")
+	allCmd.MarkFlagsRequiredTogether("mix", "rate")
+	for _, flag := range []string{"traces-rate", "metrics-rate", "logs-rate"} {
+		allCmd.MarkFlagsMutuallyExclusive("mix", flag)
+	}
+	addTransportFlags(allCmd)
+	addRetryFlags(allCmd)
+
+	// Coordinator command
+	coordinatorCmd := &cobra.Command{
+		Use:   "coordinator [flags] -- traces|metrics|logs [flags]",
+		Short: "Split a run's rate across workers on other hosts, starting and stopping them together",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runCoordinator,
+	}
+	coordinatorCmd.Flags().StringVar(&coordListen, "listen", ":7070", "Address workers join with --join")
+	coordinatorCmd.Flags().IntVar(&coordWorkers, "workers", 1, "Number of workers to wait for before starting the run")
+	coordinatorCmd.Flags().DurationVar(&coordInterval, "report-interval", 10*time.Second, "Time between aggregated progress reports")
+	coordinatorCmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 
-	if err := rootCmd.Execute(); err != nil {
+	// Worker command
+	workerCmd := &cobra.Command{
+		Use:   "worker",
+		Short: "Join a coordinator and run the share of the load it assigns",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWorker(rootCmd)
+		},
+	}
+	workerCmd.Flags().StringVar(&joinAddr, "join", "", "Coordinator address to join (e.g., coordinator:7070)")
+	workerCmd.Flags().StringVar(&workerName, "name", "", "Name the coordinator reports this worker by (default the hostname)")
+	workerCmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	workerCmd.MarkFlagRequired("join")
+
+	// Retry command
+	retryCmd := &cobra.Command{
+		Use:   "retry",
+		Short: "Re-send the exports a run wrote to its --dlq directory",
+		RunE:  runRetry,
+	}
+	addTransportFlags(retryCmd)
+	retryCmd.Flags().StringVar(&dlqDir, "dlq", "", "Directory of failed exports written by a run with --dlq")
+	retryCmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP endpoint (e.g., grpcs://host:443, http://host:80); several comma-separated endpoints are load-balanced")
+	retryCmd.Flags().StringToStringVar(&headers, "headers", nil, "Additional headers (e.g., key1=value1,key2=value2)")
+	retryCmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	retryCmd.MarkFlagRequired("dlq")
+	retryCmd.MarkFlagRequired("otlp-endpoint")
+
+	// Bench command
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Compare the throughput, latency, and error rates of transport configurations against one endpoint",
+		RunE:  runBench,
+	}
+	addTransportFlags(benchCmd)
+	benchCmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "Comma-separated OTLP endpoints to compare (e.g., grpc://localhost:4317,http://localhost:4318)")
+	benchCmd.Flags().StringVar(&serviceName, "service", "otelgen", "Service name")
+	benchCmd.Flags().StringVar(&benchSignal, "signal", "traces", "What to send (traces, logs)")
+	benchCmd.Flags().StringSliceVar(&benchComps, "compressions", []string{"none", "gzip"}, "Export compressions to compare (none, gzip, zstd for gRPC)")
+	benchCmd.Flags().IntSliceVar(&benchBatches, "batch-sizes", []int{128, 512, 2048}, "Spans or log records per request to compare")
+	benchCmd.Flags().StringVar(&duration, "duration", "10s", "How long each configuration runs")
+	benchCmd.Flags().IntVar(&benchConc, "concurrency", 0, "Requests in flight (default 4 per connection)")
+	benchCmd.Flags().StringVar(&size, "size", "", "Payload size of each span or log record (e.g., 1kb)")
+	benchCmd.Flags().StringToStringVar(&headers, "headers", nil, "Additional headers (e.g., key1=value1,key2=value2)")
+	benchCmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	benchCmd.MarkFlagRequired("otlp-endpoint")
+	benchCmd.MarkFlagsMutuallyExclusive("compression", "compressions")
+
+	// Sink command
+	sinkCmd := &cobra.Command{
+		Use:   "sink",
+		Short: "Receive OTLP exports, counting them and measuring the end-to-end latency of stamped items",
+		RunE:  runSink,
+	}
+	sinkCmd.Flags().StringVar(&sinkGRPC, "grpc-listen", ":4317", "Address of the OTLP gRPC receiver (empty to disable)")
+	sinkCmd.Flags().StringVar(&sinkHTTP, "http-listen", ":4318", "Address of the OTLP HTTP receiver (empty to disable)")
+	sinkCmd.Flags().DurationVar(&sinkDuration, "duration", 0, "Stop receiving after this long and print the summary (default until interrupted)")
+	sinkCmd.Flags().DurationVar(&sinkInterval, "report-interval", 10*time.Second, "Time between progress reports")
+	sinkCmd.Flags().StringVar(&sinkCapture, "capture", "", "Write every request received to this file, one line of OTLP/JSON per request with the time it was received (e.g., received.otlp.json)")
+
+	// Diff command
+	diffCmd := &cobra.Command{
+		Use:   "diff SENT RECEIVED",
+		Short: "Compare the OTLP/JSON sent with what was received, reporting missing, extra, and mutated items",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runDiff,
+	}
+	diffCmd.Flags().StringSliceVar(&diffIgnore, "ignore-attributes", nil, "Resource and item attributes the pipeline is expected to add, change, or remove, left out of the comparison")
+	diffCmd.Flags().BoolVar(&diffStrict, "strict", false, "Count resource attributes added in transit as mutations, instead of ignoring them as enrichment")
+	diffCmd.Flags().IntVar(&diffExamples, "examples", 5, "Number of items of each kind of difference to list")
+
+	// Doctor command
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose an endpoint in depth: DNS, TCP, TLS chain and ALPN, OTLP services or paths, credentials, and compression",
+		RunE:  runDoctor,
+	}
+	addTransportFlags(doctorCmd)
+	doctorCmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP endpoint (e.g., grpcs://host:443, http://host:80); several comma-separated endpoints are diagnosed one after the other")
+	doctorCmd.Flags().StringToStringVar(&headers, "headers", nil, "Additional headers (e.g., key1=value1,key2=value2)")
+	doctorCmd.MarkFlagRequired("otlp-endpoint")
+
+	rootCmd.AddCommand(tracesCmd, metricsCmd, logsCmd, allCmd, coordinatorCmd, workerCmd, retryCmd, benchCmd, sinkCmd, diffCmd, doctorCmd)
+
+	err := rootCmd.Execute()
+	printResults()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func runTraces(cmd *cobra.Command, args []string) error {
-	endpoint, err := otelgen.ParseEndpoint(otlpEndpoint)
+// results are the generators' results by name, printed under --output json; a
+// command running a single generator keeps it under the empty name
+var (
+	resultsMu sync.Mutex
+	results   = make(map[string]*otelgen.Result)
+	resultOut *os.File
+)
+
+// startOutput moves otelgen's text to stderr under --output ndjson or json, leaving
+// stdout to the run's events or results
+func startOutput() error {
+	switch outputMode {
+	case "", "text":
+		return nil
+	case "ndjson", "json":
+	default:
+		return fmt.Errorf("unsupported output: %s (supported: text, ndjson, json)", outputMode)
+	}
+	if exporterKind == "stdout" {
+		return fmt.Errorf("--output %s cannot be combined with --exporter stdout, which prints to stdout", outputMode)
+	}
+	if outputMode == "ndjson" {
+		eventStream = otelgen.NewEventStream(os.Stdout)
+	} else {
+		resultOut = os.Stdout
+	}
+	os.Stdout = os.Stderr
+	return nil
+}
+
+// printResults prints the generators' results under --output json, whether or not
+// the run failed: one object for a single generator, or an object of them by name
+func printResults() {
+	if resultOut == nil || len(results) == 0 {
+		return
+	}
+	var v any = results
+	if r, ok := results[""]; ok {
+		v = r
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to encode results: %v\n", err)
+		return
+	}
+	fmt.Fprintln(resultOut, string(out))
+}
+
+// startPprof serves otelgen's own profiles when --pprof is set
+func startPprof() error {
+	if pprofAddr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", pprofAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for pprof: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go http.Serve(ln, mux)
+	fmt.Printf("Serving pprof on http://%s/debug/pprof/\n", ln.Addr())
+	return nil
+}
+
+// addThroughputFlag adds --throughput, for the commands whose export size follows the rate
+func addThroughputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&throughput, "throughput", "", "Target uncompressed OTLP bytes per second instead of --rate (e.g., 50mb/s)")
+	cmd.MarkFlagsMutuallyExclusive("throughput", "rate")
+	cmd.MarkFlagsMutuallyExclusive("throughput", "steps")
+	cmd.MarkFlagsMutuallyExclusive("throughput", "rate-pattern")
+}
+
+// addPayloadPoolFlag adds --payload-pool, for the commands whose payloads are costly to generate
+func addPayloadPoolFlag(cmd *cobra.Command, payloads string) {
+	cmd.Flags().IntVar(&payloadPool, "payload-pool", 0, "Generate this many "+payloads+" up front and cycle through them, for rates where generating each one is the bottleneck (e.g., 10000)")
+}
+
+// addFastFlag adds --fast, for the commands that can build their requests directly
+func addFastFlag(cmd *cobra.Command, items string) {
+	cmd.Flags().BoolVar(&fast, "fast", false, "Build OTLP requests directly and send them over raw gRPC/HTTP clients, bypassing the SDK, for rates of 100k+ "+items+"/s; failed exports are not retried")
+}
+
+// addWorkersFlag adds --workers, for the commands whose events are slow to emit one
+// at a time
+func addWorkersFlag(cmd *cobra.Command, items string) {
+	cmd.Flags().IntVar(&workers, "workers", 1, "Number of goroutines emitting "+items+", for rates a single one cannot reach")
+}
+
+// addAdaptiveFlag adds --adaptive, for the commands whose rate sets the export volume
+func addAdaptiveFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&adaptive, "adaptive", false, "Slow down when the endpoint throttles exports (429, RESOURCE_EXHAUSTED), honoring Retry-After and RetryInfo, and speed back up afterwards; reports offered and accepted rates")
+}
+
+// addStampEmitTimeFlag adds --stamp-emit-time, for the commands a sink can measure the
+// latency of
+func addStampEmitTimeFlag(cmd *cobra.Command, item string) {
+	cmd.Flags().BoolVar(&stampEmit, "stamp-emit-time", false, "Add the time each "+item+" was emitted as the "+otelgen.EmitTimeAttribute+" attribute, for otelgen sink to measure end-to-end latency")
+}
+
+// addSequenceFlag adds --sequence, for the commands a sink can reconcile
+func addSequenceFlag(cmd *cobra.Command, item string) {
+	cmd.Flags().BoolVar(&sequence, "sequence", false, "Number each "+item+" in the "+otelgen.SequenceAttribute+" attribute, and tag the resource with the run's "+otelgen.RunIDAttribute+", for otelgen sink to report lost, duplicated, and reordered items")
+}
+
+// transportOptions collects the connection flags shared by all commands
+func transportOptions() (otelgen.TransportOptions, error) {
+	maxMsgSize, err := otelgen.ParseSize(grpcMaxMsg)
+	if err != nil {
+		return otelgen.TransportOptions{}, fmt.Errorf("invalid gRPC max message size: %w", err)
+	}
+	resolve, err := otelgen.ParseResolve(resolveAddrs)
+	if err != nil {
+		return otelgen.TransportOptions{}, err
+	}
+
+	return otelgen.TransportOptions{
+		InsecureSkipVerify: insecureSkip,
+		TLSServerName:      tlsServerName,
+		BearerToken:        bearerToken,
+		BearerTokenFile:    bearerFile,
+		BasicAuth:          basicAuth,
+		OAuth2TokenURL:     oauthURL,
+		OAuth2ClientID:     oauthID,
+		OAuth2ClientSecret: oauthSecret,
+		OAuth2Scopes:       oauthScopes,
+		HMACSecret:         hmacSecret,
+		HMACHeader:         hmacHeader,
+		Compression:        compression,
+		HTTPPath:           httpPath,
+		HTTPVersion:        httpVersion,
+		Encoding:           encoding,
+		GRPCMaxMessageSize: maxMsgSize,
+		GRPCTimeout:        grpcTimeout,
+		ConnectTimeout:     connectTO,
+		ExportTimeout:      exportTO,
+		Resolve:            resolve,
+		LBStrategy:         lbStrategy,
+		LBWeights:          lbWeights,
+		Connections:        connections,
+		DeadLetterDir:      dlqDir,
+		ExportLog:          exportLogPath,
+		Retry: &otelgen.RetryOptions{
+			Enabled:         retryEnabled,
+			InitialInterval: retryInitial,
+			MaxInterval:     retryMax,
+			MaxElapsedTime:  retryElapsed,
+		},
+	}, nil
+}
+
+// loadOptions collects the load shaping flags shared by all commands
+func loadOptions() (otelgen.LoadOptions, error) {
+	loadSteps, err := otelgen.ParseSteps(steps)
+	if err != nil {
+		return otelgen.LoadOptions{}, fmt.Errorf("invalid steps: %w", err)
+	}
+	loadBurst, err := otelgen.ParseBurst(burst)
+	if err != nil {
+		return otelgen.LoadOptions{}, fmt.Errorf("invalid burst: %w", err)
+	}
+	pattern, err := otelgen.ParseRatePattern(ratePattern)
+	if err != nil {
+		return otelgen.LoadOptions{}, fmt.Errorf("invalid rate pattern: %w", err)
+	}
+	bytesPerSecond, err := otelgen.ParseThroughput(throughput)
+	if err != nil {
+		return otelgen.LoadOptions{}, fmt.Errorf("invalid throughput: %w", err)
+	}
+	var search *otelgen.FindMaxOptions
+	if findMax {
+		errorRate, err := otelgen.ParsePercentage(maxErrorRate)
+		if err != nil {
+			return otelgen.LoadOptions{}, fmt.Errorf("invalid max error rate: %w", err)
+		}
+		search = &otelgen.FindMaxOptions{Probe: probeDuration, MaxErrorRate: errorRate, MaxLatency: maxLatency}
+	}
+	var soakOpts *otelgen.SoakOptions
+	if soak {
+		soakOpts = &otelgen.SoakOptions{Interval: soakInterval, File: soakFile}
+	}
+	var self *otelgen.Endpoint
+	if selfEndpoint != "" {
+		if self, err = otelgen.ParseEndpoint(selfEndpoint); err != nil {
+			return otelgen.LoadOptions{}, fmt.Errorf("invalid self-telemetry endpoint: %w", err)
+		}
+	} else if traceSelf {
+		return otelgen.LoadOptions{}, fmt.Errorf("--trace-self needs --self-telemetry-endpoint")
+	}
+	var statsOpts *otelgen.StatsOptions
+	if statsInterval > 0 {
+		statsOpts = &otelgen.StatsOptions{Interval: statsInterval, Format: statsFormat}
+	}
+	var report *otelgen.ReportOptions
+	if reportFile != "" || assertRate != "" || assertErrors != "" || assertP99 > 0 {
+		report = &otelgen.ReportOptions{File: reportFile, MaxP99Latency: assertP99}
+		if report.MinRate, err = otelgen.ParsePerSecond(assertRate); err != nil {
+			return otelgen.LoadOptions{}, fmt.Errorf("invalid minimum rate: %w", err)
+		}
+		if assertErrors != "" {
+			errorRate, err := otelgen.ParsePercentage(assertErrors)
+			if err != nil {
+				return otelgen.LoadOptions{}, fmt.Errorf("invalid max error rate: %w", err)
+			}
+			report.MaxErrorRate = &errorRate
+		}
+	}
+	if latencyAlert < 0 {
+		return otelgen.LoadOptions{}, fmt.Errorf("latency alert must be positive")
+	}
+	if latencyAlert > 0 && exporterKind != "otlp" {
+		return otelgen.LoadOptions{}, fmt.Errorf("--latency-alert needs --exporter otlp")
+	}
+	var seedOpt *int64
+	if seed != 0 {
+		seedOpt = &seed
+	}
+	var memLimitBytes int64
+	if memLimit != "" {
+		if memLimitBytes, err = otelgen.ParseSize(memLimit); err != nil {
+			return otelgen.LoadOptions{}, fmt.Errorf("invalid memory limit: %w", err)
+		}
+		if memLimitBytes <= 0 {
+			return otelgen.LoadOptions{}, fmt.Errorf("memory limit must be positive")
+		}
+	}
+	if count < 0 {
+		return otelgen.LoadOptions{}, fmt.Errorf("count cannot be negative")
+	}
+	var delivery *otelgen.DeliveryOptions
+	if expectDeliv != 0 {
+		delivery = &otelgen.DeliveryOptions{Expected: expectDeliv, Within: within, Sink: deliverySink}
+	} else if deliverySink != "" {
+		return otelgen.LoadOptions{}, fmt.Errorf("--delivery-sink needs --expect-delivered")
+	}
+	return otelgen.LoadOptions{
+		RateBurst:     rateBurst,
+		RampUp:        rampUp,
+		RampDown:      rampDown,
+		Steps:         loadSteps,
+		Burst:         loadBurst,
+		Pattern:       pattern,
+		Throughput:    bytesPerSecond,
+		FindMax:       search,
+		PayloadPool:   payloadPool,
+		Workers:       workers,
+		Fast:          fast,
+		Adaptive:      adaptive,
+		Warmup:        warmup,
+		Arrival:       arrival,
+		Soak:          soakOpts,
+		Stats:         statsOpts,
+		ControlSocket: controlSocket,
+		StampEmitTime: stampEmit,
+		Sequence:      sequence,
+		SelfTelemetry: self,
+		TraceSelf:     traceSelf,
+		Report:        report,
+		ProgressBar:   progressBar,
+		Delivery:      delivery,
+		LatencyAlert:  latencyAlert,
+		MemLimit:      memLimitBytes,
+		Seed:          seedOpt,
+		Events:        eventStream,
+		Count:         count,
+	}, nil
+}
+
+// runHooks are what the process's signals and probes drive: the channel an interrupt
+// closes, the progress the health probes read, and the rate SIGUSR1 and SIGUSR2 scale
+type runHooks struct {
+	progress *otelgen.Progress
+	stop     <-chan struct{}
+	control  *otelgen.RateControl
+}
+
+var (
+	hooksOnce sync.Once
+	hooks     runHooks
+	hooksErr  error
+)
+
+// startRun hands the run the signals and probes that drive it, setting them up the
+// first time only, so a run started again in the same process, such as a worker's
+// assigned run, doesn't register the handlers twice or serve the probes on a taken port
+func startRun(load *otelgen.LoadOptions) error {
+	hooksOnce.Do(func() {
+		// An interrupt or SIGTERM ends the run early, flushing what was generated and
+		// printing the summary; a search for the maximum rate can't be ended early, so
+		// it still exits at once
+		hooks.progress, hooks.stop = runProgress, runStop
+		if hooks.stop == nil && !findMax {
+			hooks.stop = interrupted()
+		}
+		// Probes read the run's progress
+		if healthAddr != "" {
+			if hooks.progress == nil {
+				hooks.progress = &otelgen.Progress{}
+			}
+			hooksErr = otelgen.ServeHealth(healthAddr, hooks.progress, hooks.stop)
+		}
+		hooks.control = rateControl()
+	})
+	load.Progress, load.Stop, load.Control = hooks.progress, hooks.stop, hooks.control
+	return hooksErr
+}
+
+// rateControl returns the control that SIGUSR1 and SIGUSR2 double and halve the rate
+// through, or nil for runs without a rate to control
+func rateControl() *otelgen.RateControl {
+	if findMax || backfill > 0 || resume != "" {
+		return nil
+	}
+	control := otelgen.NewRateControl()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 {
+				fmt.Printf("SIGUSR1: rate doubled to %g/s\n", control.Scale(2))
+			} else {
+				fmt.Printf("SIGUSR2: rate halved to %g/s\n", control.Scale(0.5))
+			}
+		}
+	}()
+	return control
+}
+
+// runLength describes how long a run of the duration goes on
+func runLength(duration string) string {
+	if duration == "0s" {
+		return "until interrupted"
+	}
+	return "for " + duration
+}
+
+// loadSummary describes the rate and duration of the run
+func loadSummary(rate float64) string {
+	if findMax {
+		return fmt.Sprintf("searching for the maximum rate from %g/s", rate)
+	}
+	if steps != "" {
+		return "in steps " + steps
+	}
+	// A delivery check ends the run once the items are delivered
+	length := runLength(duration)
+	if expectDeliv != 0 {
+		length = fmt.Sprintf("until %d items are delivered, for up to %s", expectDeliv, within)
+	}
+	// A count ends the run on its own, or when the duration is up if that comes first
+	if count > 0 {
+		length = fmt.Sprintf("until %d are generated", count)
+		if duration != "0s" {
+			length += ", for up to " + duration
+		}
+	}
+	if ratePattern != "" {
+		return fmt.Sprintf("following %s %s", ratePattern, length)
+	}
+	if throughput != "" {
+		return fmt.Sprintf("at %s %s", throughput, length)
+	}
+	return fmt.Sprintf("at %g/s %s", rate, length)
+}
+
+// openDestination checks --exporter against the command's supported exporters and
+// returns the OTLP endpoint, or sets up the payload writer that replaces it; target
+// describes where the signal goes
+func openDestination(signal string, transport *otelgen.TransportOptions, supported ...string) (*otelgen.Endpoint, string, error) {
+	if !slices.Contains(supported, exporterKind) {
+		return nil, "", fmt.Errorf("unknown exporter %q (supported: %s)", exporterKind, strings.Join(supported, ", "))
+	}
+
+	switch exporterKind {
+	case "kafka":
+		if len(brokers) == 0 {
+			return nil, "", fmt.Errorf("--brokers is required with --exporter kafka")
+		}
+		writer, err := otelgen.NewKafkaWriter(otelgen.KafkaOptions{
+			Brokers:     brokers,
+			Topic:       kafkaTopic,
+			DialTimeout: connectTO,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		transport.Writer = writer
+		return nil, writer.Destination(signal), nil
+	case "fluentforward":
+		if err := requireLogEvents("exporter-endpoint", exporterAddr); err != nil {
+			return nil, "", err
+		}
+		writer, err := otelgen.NewFluentForwardWriter(otelgen.FluentForwardOptions{
+			Address:     exporterAddr,
+			Tag:         fluentTag,
+			DialTimeout: connectTO,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		transport.Writer = writer
+		return nil, writer.Destination(), nil
+	case "syslog":
+		if err := requireLogEvents("exporter-endpoint", exporterAddr); err != nil {
+			return nil, "", err
+		}
+		writer, err := otelgen.NewSyslogWriter(otelgen.SyslogOptions{
+			Address:            exporterAddr,
+			Network:            syslogNet,
+			Framing:            syslogFraming,
+			InsecureSkipVerify: insecureSkip,
+			TLSServerName:      tlsServerName,
+			DialTimeout:        connectTO,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		transport.Writer = writer
+		return nil, writer.Destination(), nil
+	case "tcp", "udp":
+		if err := requireLogEvents("exporter-endpoint", exporterAddr); err != nil {
+			return nil, "", err
+		}
+		writer, err := otelgen.NewLineWriter(otelgen.LineOptions{
+			Address:     exporterAddr,
+			Network:     exporterKind,
+			DialTimeout: connectTO,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		transport.Writer = writer
+		return nil, writer.Destination(), nil
+	case "file":
+		if err := requireLogEvents("dir", fileDir); err != nil {
+			return nil, "", err
+		}
+		maxSize, err := otelgen.ParseSize(rotateSize)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid rotate size: %w", err)
+		}
+		writer, err := otelgen.NewFileWriter(otelgen.FileOptions{
+			Dir:         fileDir,
+			Format:      outFormat,
+			RotateSize:  maxSize,
+			RotateEvery: rotateEvery,
+			Compress:    rotateGzip,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		transport.Writer = writer
+		return nil, writer.Destination(), nil
+	case "stdout":
+		writer, err := otelgen.NewStdoutWriter(outFormat)
+		if err != nil {
+			return nil, "", err
+		}
+		transport.Writer = writer
+		// The writer has stdout to itself, so the payloads can be piped to other tools;
+		// the text moves to stderr, as for --output
+		os.Stdout = os.Stderr
+		return nil, "stdout", nil
+	default:
+		if otlpEndpoint == "" {
+			return nil, "", fmt.Errorf("required flag(s) \"otlp-endpoint\" not set")
+		}
+		return openEndpoints(otlpEndpoint, transport)
+	}
+}
+
+// openEndpoints parses comma-separated OTLP endpoints, balancing exports across them
+// past the first
+func openEndpoints(list string, transport *otelgen.TransportOptions) (*otelgen.Endpoint, string, error) {
+	var endpoints []*otelgen.Endpoint
+	var names []string
+	for _, s := range strings.Split(list, ",") {
+		endpoint, err := otelgen.ParseEndpoint(strings.TrimSpace(s))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+		names = append(names, endpoint.String())
+	}
+	transport.Endpoints = endpoints[1:]
+	return endpoints[0], strings.Join(names, ", "), nil
+}
+
+// requireLogEvents checks the flags of exporters that write log events instead of OTLP;
+// dest is the value of the flag naming where they go
+func requireLogEvents(flag, dest string) error {
+	if dest == "" {
+		return fmt.Errorf("--%s is required with --exporter %s", flag, exporterKind)
+	}
+	if encoding != "protobuf" {
+		return fmt.Errorf("--encoding applies only to the otlp and kafka exporters")
+	}
+	return nil
+}
+
+// applyAuthPreset adds the vendor's API key header to the export headers
+func applyAuthPreset() error {
+	if authPreset == "" {
+		return nil
+	}
+	name, value, err := otelgen.APIKeyHeader(authPreset, apiKey)
+	if err != nil {
+		return fmt.Errorf("invalid auth preset: %w", err)
+	}
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers[name] = value
+	return nil
+}
+
+// runGenerator runs the library's generator of a signal, with the command line's
+// duration, batch size, and verbosity, and keeps its result under name for --output
+// json
+func runGenerator(name, signal string, endpoint *otelgen.Endpoint, service string, rate float64, duration string, payloadSize int64, headers map[string]string, transport otelgen.TransportOptions, load otelgen.LoadOptions, opts otelgen.MetricsOptions) error {
+	d, err := time.ParseDuration(duration)
 	if err != nil {
-		return fmt.Errorf("invalid endpoint: %w", err)
+		return fmt.Errorf("invalid duration: %w", err)
 	}
+	load.Name = name
+	cfg := otelgen.NewConfig(endpoint,
+		otelgen.WithServiceName(service),
+		otelgen.WithRate(rate),
+		otelgen.WithDuration(d),
+		otelgen.WithPayloadSize(payloadSize),
+		otelgen.WithBatchSize(batchSize),
+		otelgen.WithHeaders(headers),
+		otelgen.WithVerbose(verbose),
+		otelgen.WithTransport(transport),
+		otelgen.WithLoad(load),
+		otelgen.WithMetricsOptions(opts),
+	)
+	ctx := context.Background()
+	var result *otelgen.Result
+	switch signal {
+	case "traces":
+		result, err = otelgen.NewTraceGenerator(cfg).Run(ctx)
+	case "metrics":
+		result, err = otelgen.NewMetricGenerator(cfg).Run(ctx)
+	default:
+		result, err = otelgen.NewLogGenerator(cfg).Run(ctx)
+	}
+	if result != nil {
+		resultsMu.Lock()
+		results[name] = result
+		resultsMu.Unlock()
+	}
+	return err
+}
 
+// warnMessageSize warns when a single payload cannot fit in a gRPC message
+func warnMessageSize(transport otelgen.TransportOptions, payloadSize int64) {
+	if transport.GRPCMaxMessageSize > 0 && payloadSize > transport.GRPCMaxMessageSize {
+		fmt.Printf("Warning: payload size %d exceeds the gRPC max message size %d; exports will be rejected\n",
+			payloadSize, transport.GRPCMaxMessageSize)
+	}
+}
+
+// printTransportOptions prints the connection settings in verbose mode
+func printTransportOptions() {
+	fmt.Printf("Insecure Skip Verify: %v\n", insecureSkip)
+	fmt.Printf("Compression: %s\n", compression)
+	fmt.Printf("Encoding: %s\n", encoding)
+	if httpPath != "" {
+		fmt.Printf("HTTP Path: %s\n", httpPath)
+	}
+	if httpVersion != "" {
+		fmt.Printf("HTTP Version: %s\n", httpVersion)
+	}
+	if grpcMaxMsg != "" {
+		fmt.Printf("gRPC Max Message Size: %s\n", grpcMaxMsg)
+	}
+	if grpcTimeout > 0 {
+		fmt.Printf("gRPC Timeout: %s\n", grpcTimeout)
+	}
+	fmt.Printf("Connect Timeout: %s\n", connectTO)
+	fmt.Printf("Export Timeout: %s\n", exportTO)
+	for _, entry := range resolveAddrs {
+		fmt.Printf("Resolve: %s\n", entry)
+	}
+	if len(lbWeights) > 0 {
+		fmt.Printf("Load Balancing: %s, weights %v\n", lbStrategy, lbWeights)
+	} else if strings.Contains(otlpEndpoint, ",") || strings.Contains(strings.Join(resolveAddrs, " "), ",") {
+		fmt.Printf("Load Balancing: %s\n", lbStrategy)
+	}
+	if connections > 1 {
+		fmt.Printf("Connections: %d\n", connections)
+	}
+	if retryEnabled {
+		fmt.Printf("Retry: initial %s, max %s, max elapsed %s\n", retryInitial, retryMax, retryElapsed)
+	} else {
+		fmt.Println("Retry: disabled")
+	}
+	if dlqDir != "" {
+		fmt.Printf("Dead-Letter Directory: %s\n", dlqDir)
+	}
+	if exportLogPath != "" {
+		fmt.Printf("Export Log: %s\n", exportLogPath)
+	}
+	if tlsServerName != "" {
+		fmt.Printf("TLS Server Name: %s\n", tlsServerName)
+	}
+	if bearerToken != "" {
+		fmt.Println("Authorization: bearer token")
+	}
+	if bearerFile != "" {
+		fmt.Printf("Authorization: bearer token from %s\n", bearerFile)
+	}
+	if basicAuth != "" {
+		user, _, _ := strings.Cut(basicAuth, ":")
+		fmt.Printf("Authorization: basic auth as %s\n", user)
+	}
+	if oauthURL != "" {
+		fmt.Printf("Authorization: OAuth2 client %s via %s\n", oauthID, oauthURL)
+		if len(oauthScopes) > 0 {
+			fmt.Printf("OAuth2 Scopes: %v\n", oauthScopes)
+		}
+	}
+	if authPreset != "" {
+		fmt.Printf("Authorization: %s API key\n", authPreset)
+	}
+	if hmacSecret != "" {
+		fmt.Printf("Request Signing: HMAC-SHA256 in %s\n", hmacHeader)
+	}
+}
+
+// parseRate reads a rate flag, per second unless it names a unit, and fractional
+// rates included, so a heartbeat of one event every few seconds can be expressed
+func parseRate(s string) (float64, error) {
+	r, err := otelgen.ParsePerSecond(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %w", err)
+	}
+	return r, nil
+}
+
+// applyDuration reads --duration infinite, or any zero duration, as 0s, which runs
+// until interrupted, and leaves the end of the run to --count when no --duration is
+// given
+func applyDuration(cmd *cobra.Command) {
+	if d, err := time.ParseDuration(duration); duration == "infinite" || (err == nil && d == 0) {
+		duration = "0s"
+	}
+	if count > 0 && !cmd.Flags().Changed("duration") {
+		duration = "0s"
+	}
+}
+
+func runTraces(cmd *cobra.Command, args []string) (err error) {
+	applyDuration(cmd)
 	payloadSize, err := otelgen.ParseSize(size)
 	if err != nil {
 		return fmt.Errorf("invalid size: %w", err)
 	}
+	eventRate, err := parseRate(rate)
+	if err != nil {
+		return err
+	}
+
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	warnMessageSize(transport, payloadSize)
+
+	load, err := loadOptions()
+	if err != nil {
+		return err
+	}
+	if err := startRun(&load); err != nil {
+		return err
+	}
+
+	endpoint, target, err := openDestination("traces", &transport, "otlp", "kafka", "stdout")
+	if err != nil {
+		return err
+	}
+	defer closeWriter(transport, &err)
 
 	if verbose {
-		fmt.Printf("Endpoint: %s\n", endpoint.String())
+		fmt.Printf("Endpoint: %s\n", target)
+		fmt.Printf("Exporter: %s\n", exporterKind)
 		fmt.Printf("Service: %s\n", serviceName)
-		fmt.Printf("Rate: %d/s\n", rate)
+		fmt.Printf("Rate: %g/s\n", eventRate)
 		fmt.Printf("Duration: %s\n", duration)
 		if payloadSize > 0 {
 			fmt.Printf("Payload Size: %d bytes\n", payloadSize)
 		}
-		fmt.Printf("Secure: %v\n", endpoint.Secure)
-		fmt.Printf("Protocol: %s\n", endpoint.Protocol)
-		fmt.Printf("Insecure Skip Verify: %v\n", insecureSkip)
+		if endpoint != nil {
+			fmt.Printf("Secure: %v\n", endpoint.Secure)
+			fmt.Printf("Protocol: %s\n", endpoint.Protocol)
+			printTransportOptions()
+		}
 		if len(headers) > 0 {
 			fmt.Printf("Headers: %v\n", headers)
 		}
 		fmt.Println()
 	}
 
-	fmt.Printf("Generating traces to %s for service %s at %d/s for %s\n",
-		endpoint.String(), serviceName, rate, duration)
+	fmt.Printf("Generating traces to %s for service %s %s\n",
+		target, serviceName, loadSummary(eventRate))
 
-	return otelgen.GenerateTraces(endpoint, serviceName, rate, duration, payloadSize, headers, verbose, insecureSkip)
+	return runGenerator("", "traces", endpoint, serviceName, eventRate, duration, payloadSize, headers, transport, load, otelgen.MetricsOptions{})
 }
 
-func runMetrics(cmd *cobra.Command, args []string) error {
-	endpoint, err := otelgen.ParseEndpoint(otlpEndpoint)
-	if err != nil {
-		return fmt.Errorf("invalid endpoint: %w", err)
+// closeWriter closes the run's payload writer, if any, failing the run with its error:
+// a file writer reports the writes that failed as it closes
+func closeWriter(transport otelgen.TransportOptions, err *error) {
+	if transport.Writer != nil {
+		*err = errors.Join(*err, transport.Writer.Close())
 	}
+}
 
+// runAll generates every signal at once against one endpoint, from one service
+// instance, so a pipeline's traces, metrics, and logs paths are exercised together
+func runAll(cmd *cobra.Command, args []string) error {
+	applyDuration(cmd)
+	if scenarioFile != "" {
+		return runScenario()
+	}
+	tracesPerSecond, metricsPerSecond, logsPerSecond, err := signalRates()
+	if err != nil {
+		return err
+	}
+	if tracesPerSecond == 0 && metricsPerSecond == 0 && logsPerSecond == 0 {
+		return fmt.Errorf("at least one of --traces-rate, --metrics-rate, and --logs-rate must be positive")
+	}
 	payloadSize, err := otelgen.ParseSize(size)
 	if err != nil {
 		return fmt.Errorf("invalid size: %w", err)
 	}
 
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	warnMessageSize(transport, payloadSize)
+
+	load, err := loadOptions()
+	if err != nil {
+		return err
+	}
+	if err := startRun(&load); err != nil {
+		return err
+	}
+	// The signals share a resource, as they would coming from one instance
+	load.InstanceID = uuid.NewString()
+
+	endpoint, target, err := openDestination("all", &transport, "otlp")
+	if err != nil {
+		return err
+	}
+
 	if verbose {
-		fmt.Printf("Endpoint: %s\n", endpoint.String())
-		fmt.Printf("Service: %s\n", serviceName)
-		fmt.Printf("Rate: %d/s\n", rate)
+		fmt.Printf("Endpoint: %s\n", target)
+		fmt.Printf("Service: %s (instance %s)\n", serviceName, load.InstanceID)
 		fmt.Printf("Duration: %s\n", duration)
 		if payloadSize > 0 {
 			fmt.Printf("Payload Size: %d bytes\n", payloadSize)
 		}
 		fmt.Printf("Secure: %v\n", endpoint.Secure)
 		fmt.Printf("Protocol: %s\n", endpoint.Protocol)
-		fmt.Printf("Insecure Skip Verify: %v\n", insecureSkip)
+		printTransportOptions()
 		if len(headers) > 0 {
 			fmt.Printf("Headers: %v\n", headers)
 		}
 		fmt.Println()
 	}
 
-	fmt.Printf("Generating metrics to %s for service %s at %d/s for %s\n",
-		endpoint.String(), serviceName, rate, duration)
+	fmt.Printf("Generating %g traces/s, %g metric events/s, and %g log records/s to %s for service %s %s\n",
+		tracesPerSecond, metricsPerSecond, logsPerSecond, target, serviceName, runLength(duration))
 
-	return otelgen.GenerateMetrics(endpoint, serviceName, rate, duration, payloadSize, headers, verbose, insecureSkip)
+	generators := map[string]func() error{}
+	if tracesPerSecond > 0 {
+		generators["traces"] = func() error {
+			return runGenerator("traces", "traces", endpoint, serviceName, tracesPerSecond, duration, payloadSize, headers, transport, load, otelgen.MetricsOptions{})
+		}
+	}
+	if metricsPerSecond > 0 {
+		generators["metrics"] = func() error {
+			return runGenerator("metrics", "metrics", endpoint, serviceName, metricsPerSecond, duration, payloadSize, headers, transport, load, otelgen.MetricsOptions{})
+		}
+	}
+	if logsPerSecond > 0 {
+		generators["logs"] = func() error {
+			return runGenerator("logs", "logs", endpoint, serviceName, logsPerSecond, duration, payloadSize, headers, transport, load, otelgen.MetricsOptions{})
+		}
+	}
+
+	return runTogether(generators)
 }
 
-func runLogs(cmd *cobra.Command, args []string) error {
-	endpoint, err := otelgen.ParseEndpoint(otlpEndpoint)
+// signalRates returns the rate of each signal for all: its own rate, or its share of
+// the total --rate under --mix
+func signalRates() (traces, metrics, logs float64, err error) {
+	if mix != "" {
+		m, err := otelgen.ParseMix(mix)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid mix: %w", err)
+		}
+		total, err := parseRate(rate)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if total <= 0 {
+			return 0, 0, 0, fmt.Errorf("rate must be positive")
+		}
+		share := func(weight int) float64 { return total * float64(weight) / 100 }
+		return share(m.Traces), share(m.Metrics), share(m.Logs), nil
+	}
+	if traces, err = parseRate(tracesRate); err != nil {
+		return 0, 0, 0, fmt.Errorf("--traces-rate: %w", err)
+	}
+	if metrics, err = parseRate(metricsRate); err != nil {
+		return 0, 0, 0, fmt.Errorf("--metrics-rate: %w", err)
+	}
+	if logs, err = parseRate(logsRate); err != nil {
+		return 0, 0, 0, fmt.Errorf("--logs-rate: %w", err)
+	}
+	return traces, metrics, logs, nil
+}
+
+// runTogether runs the named generators at once, failing with the errors of those
+// that fail
+func runTogether(generators map[string]func() error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for name, generate := range generators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := generate(); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// runScenario runs the jobs of a scenario file together; the flags give the settings
+// the jobs leave out, and every transport setting
+func runScenario() error {
+	scenario, err := otelgen.LoadScenario(scenarioFile)
+	if err != nil {
+		return err
+	}
+	defaultSize, err := otelgen.ParseSize(size)
 	if err != nil {
-		return fmt.Errorf("invalid endpoint: %w", err)
+		return fmt.Errorf("invalid size: %w", err)
+	}
+
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+
+	base, err := transportOptions()
+	if err != nil {
+		return err
+	}
+
+	load, err := loadOptions()
+	if err != nil {
+		return err
+	}
+	if err := startRun(&load); err != nil {
+		return err
 	}
 
+	generators := make(map[string]func() error, len(scenario.Jobs))
+	for _, job := range scenario.Jobs {
+		transport := base
+		list := cmp.Or(job.Endpoint, otlpEndpoint)
+		if list == "" {
+			return fmt.Errorf("job %s has no endpoint; set one in the scenario or with --otlp-endpoint", job.Name)
+		}
+		endpoint, target, err := openEndpoints(list, &transport)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		service := cmp.Or(job.Service, serviceName)
+		jobRate := cmp.Or(job.Rate, 1)
+		jobDuration := duration
+		if job.Duration > 0 {
+			jobDuration = job.Duration.String()
+		}
+		payloadSize := defaultSize
+		if job.Size != "" {
+			// The scenario was validated, sizes included
+			payloadSize, _ = otelgen.ParseSize(job.Size)
+		}
+		warnMessageSize(transport, payloadSize)
+		jobHeaders := maps.Clone(headers)
+		if jobHeaders == nil {
+			jobHeaders = make(map[string]string)
+		}
+		maps.Copy(jobHeaders, job.Headers)
+		jobLoad := load
+		jobLoad.ResourceAttributes = job.Attributes
+
+		fmt.Printf("Job %s: generating %s to %s for service %s at %g/s %s\n",
+			job.Name, job.Signal, target, service, jobRate, runLength(jobDuration))
+		name, signal := job.Name, job.Signal
+		generators["job "+job.Name] = func() error {
+			return runGenerator(name, signal, endpoint, service, jobRate, jobDuration, payloadSize, jobHeaders, transport, jobLoad, otelgen.MetricsOptions{})
+		}
+	}
+	return runTogether(generators)
+}
+
+func runMetrics(cmd *cobra.Command, args []string) (err error) {
+	applyDuration(cmd)
 	payloadSize, err := otelgen.ParseSize(size)
 	if err != nil {
 		return fmt.Errorf("invalid size: %w", err)
 	}
+	eventRate, err := parseRate(rate)
+	if err != nil {
+		return err
+	}
+
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	warnMessageSize(transport, payloadSize)
+
+	load, err := loadOptions()
+	if err != nil {
+		return err
+	}
+	if err := startRun(&load); err != nil {
+		return err
+	}
+
+	// A resumed backfill keeps the window and step it started with, unless they are given
+	backfillFile, backfillWindow, step := checkpoint, backfill, backfillStep
+	if resume != "" {
+		backfillFile = resume
+		if !cmd.Flags().Changed("step") {
+			step = 0
+		}
+	}
+	var endpoint *otelgen.Endpoint
+	var statsdEndpoint *otelgen.StatsDEndpoint
+	var target string
+	if exporterKind == "statsd" {
+		if exporterAddr == "" {
+			return fmt.Errorf("--exporter-endpoint is required with --exporter %s", exporterKind)
+		}
+		if outputMode == "json" {
+			return fmt.Errorf("--output json is not supported with --exporter %s", exporterKind)
+		}
+		statsdEndpoint, err = otelgen.ParseStatsDEndpoint(exporterAddr)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint: %w", err)
+		}
+		target = statsdEndpoint.String()
+	} else {
+		endpoint, target, err = openDestination("metrics", &transport, "otlp", "kafka", "stdout", "statsd")
+		if err != nil {
+			return err
+		}
+		defer closeWriter(transport, &err)
+	}
+
+	sparseFraction, err := otelgen.ParsePercentage(sparse)
+	if err != nil {
+		return fmt.Errorf("invalid sparse: %w", err)
+	}
+
+	churnRate, err := otelgen.ParsePerSecond(churn)
+	if err != nil {
+		return fmt.Errorf("invalid churn: %w", err)
+	}
+
+	conflictFraction, err := otelgen.ParsePercentage(conflictRate)
+	if err != nil {
+		return fmt.Errorf("invalid conflict rate: %w", err)
+	}
+
+	if hosts < 1 {
+		return fmt.Errorf("hosts must be at least 1")
+	}
+
+	var definitions []otelgen.MetricDefinition
+	var views []otelgen.ViewDefinition
+	if metricsFile != "" {
+		file, err := otelgen.LoadMetricsFile(metricsFile)
+		if err != nil {
+			return err
+		}
+		definitions = file.Metrics
+		views = file.Views
+	}
 
 	if verbose {
-		fmt.Printf("Endpoint: %s\n", endpoint.String())
+		fmt.Printf("Endpoint: %s\n", target)
+		fmt.Printf("Exporter: %s\n", exporterKind)
 		fmt.Printf("Service: %s\n", serviceName)
-		fmt.Printf("Rate: %d/s\n", rate)
+		fmt.Printf("Rate: %g %s/s\n", eventRate, rateUnit)
+		fmt.Printf("Duration: %s\n", duration)
+		if payloadSize > 0 {
+			fmt.Printf("Payload Size: %d bytes\n", payloadSize)
+		}
+		if preset != "" {
+			fmt.Printf("Preset: %s\n", preset)
+		}
+		if realMetrics {
+			fmt.Printf("Real Host Metrics: %v\n", realMetrics)
+		}
+		if resetEvery > 0 {
+			fmt.Printf("Reset Every: %s\n", resetEvery)
+		}
+		fmt.Printf("Gauge Pattern: %s (period %s, amplitude %g)\n", pattern, patternPeriod, patternAmp)
+		if sparseFraction > 0 {
+			fmt.Printf("Sparse: %g%% of series silent\n", sparseFraction*100)
+		}
+		if adversarial {
+			fmt.Printf("Adversarial Values: %v\n", adversarial)
+		}
+		if len(histBuckets) > 0 {
+			fmt.Printf("Histogram Buckets: %v\n", histBuckets)
+		}
+		if inconsistent {
+			fmt.Printf("Inconsistent Histograms: %v\n", inconsistent)
+		}
+		if churnRate > 0 {
+			fmt.Printf("Churn: %g new series/s\n", churnRate)
+		}
+		if metricsFile != "" {
+			fmt.Printf("Metrics File: %s (%d metrics, %d views)\n", metricsFile, len(definitions), len(views))
+		}
+		if unitVariety {
+			fmt.Printf("Unit Variety: %v\n", unitVariety)
+		}
+		if metadataEdge {
+			fmt.Printf("Metadata Edge Cases: %v\n", metadataEdge)
+		}
+		if staleMarkers {
+			fmt.Printf("Staleness Markers: %v\n", staleMarkers)
+		}
+		if valueType != "mixed" {
+			fmt.Printf("Value Type: %s\n", valueType)
+		}
+		if activeSeries > 0 {
+			fmt.Printf("Active Series: %d\n", activeSeries)
+		}
+		if conflictFraction > 0 {
+			fmt.Printf("Conflict Rate: %g%% of ticks\n", conflictFraction*100)
+		}
+		if backfill > 0 {
+			fmt.Printf("Backfill: %s (step %s)\n", backfill, backfillStep)
+		}
+		if hosts > 1 {
+			fmt.Printf("Hosts: %d\n", hosts)
+		}
+		if endpoint != nil {
+			fmt.Printf("Secure: %v\n", endpoint.Secure)
+			fmt.Printf("Protocol: %s\n", endpoint.Protocol)
+			printTransportOptions()
+		}
+		if len(headers) > 0 {
+			fmt.Printf("Headers: %v\n", headers)
+		}
+		fmt.Println()
+	}
+
+	if resume != "" {
+		fmt.Printf("Resuming the backfill in %s to %s for service %s\n", resume, target, serviceName)
+	} else if backfill > 0 {
+		fmt.Printf("Backfilling %s of metrics to %s for service %s\n", backfill, target, serviceName)
+	} else {
+		fmt.Printf("Generating metrics to %s for service %s %s\n",
+			target, serviceName, loadSummary(eventRate))
+	}
+
+	opts := otelgen.MetricsOptions{
+		Preset:     preset,
+		Real:       realMetrics,
+		ResetEvery: resetEvery,
+
+		Pattern:          pattern,
+		PatternPeriod:    patternPeriod,
+		PatternAmplitude: patternAmp,
+		Sparse:           sparseFraction,
+
+		AdversarialValues:      adversarial,
+		HistogramBuckets:       histBuckets,
+		InconsistentHistograms: inconsistent,
+		Churn:                  churnRate,
+		Definitions:            definitions,
+		Views:                  views,
+		UnitVariety:            unitVariety,
+		MetadataEdgeCases:      metadataEdge,
+		StalenessMarkers:       staleMarkers,
+		ConflictRate:           conflictFraction,
+		ActiveSeries:           activeSeries,
+		ValueType:              valueType,
+		RateUnit:               rateUnit,
+		Backfill:               backfillWindow,
+		BackfillStep:           step,
+		BackfillCheckpoint:     backfillFile,
+		BackfillResume:         resume != "",
+		Hosts:                  hosts,
+	}
+
+	if statsdEndpoint != nil {
+		return otelgen.GenerateStatsD(statsdEndpoint, serviceName, eventRate, duration, payloadSize, verbose, load, opts)
+	}
+	return runGenerator("", "metrics", endpoint, serviceName, eventRate, duration, payloadSize, headers, transport, load, opts)
+}
+
+func runLogs(cmd *cobra.Command, args []string) (err error) {
+	applyDuration(cmd)
+	payloadSize, err := otelgen.ParseSize(size)
+	if err != nil {
+		return fmt.Errorf("invalid size: %w", err)
+	}
+	eventRate, err := parseRate(rate)
+	if err != nil {
+		return err
+	}
+
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	warnMessageSize(transport, payloadSize)
+
+	load, err := loadOptions()
+	if err != nil {
+		return err
+	}
+	if err := startRun(&load); err != nil {
+		return err
+	}
+
+	endpoint, target, err := openDestination("logs", &transport, "otlp", "kafka", "stdout", "fluentforward", "syslog", "tcp", "udp", "file")
+	if err != nil {
+		return err
+	}
+	defer closeWriter(transport, &err)
+
+	if verbose {
+		fmt.Printf("Endpoint: %s\n", target)
+		fmt.Printf("Exporter: %s\n", exporterKind)
+		fmt.Printf("Service: %s\n", serviceName)
+		fmt.Printf("Rate: %g/s\n", eventRate)
 		fmt.Printf("Duration: %s\n", duration)
 		if payloadSize > 0 {
 			fmt.Printf("Payload Size: %d bytes\n", payloadSize)
 		}
 		fmt.Printf("Batch Size: %d\n", batchSize)
-		fmt.Printf("Secure: %v\n", endpoint.Secure)
-		fmt.Printf("Protocol: %s\n", endpoint.Protocol)
-		fmt.Printf("Insecure Skip Verify: %v\n", insecureSkip)
+		if endpoint != nil {
+			fmt.Printf("Secure: %v\n", endpoint.Secure)
+			fmt.Printf("Protocol: %s\n", endpoint.Protocol)
+			printTransportOptions()
+		}
 		if len(headers) > 0 {
 			fmt.Printf("Headers: %v\n", headers)
 		}
 		fmt.Println()
 	}
 
-	fmt.Printf("Generating logs to %s for service %s at %d/s for %s\n",
-		endpoint.String(), serviceName, rate, duration)
+	fmt.Printf("Generating logs to %s for service %s %s\n",
+		target, serviceName, loadSummary(eventRate))
+
+	err = runGenerator("", "logs", endpoint, serviceName, eventRate, duration, payloadSize, headers, transport, load, otelgen.MetricsOptions{})
+	if fw, ok := transport.Writer.(*otelgen.FileWriter); ok {
+		fmt.Printf("Rotated %s %d times\n", target, fw.Rotations())
+	}
+	return err
+}
+
+// coordinatedItems names the events of each command a coordinator can split
+var coordinatedItems = map[string]string{
+	"traces":  "traces",
+	"metrics": "metric events",
+	"logs":    "log records",
+}
+
+// runCoordinator splits the run given after -- across the workers that join
+func runCoordinator(cmd *cobra.Command, args []string) error {
+	items, ok := coordinatedItems[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q to coordinate (supported: traces, metrics, logs)", args[0])
+	}
+	for _, flag := range []string{"steps", "rate-pattern", "find-max"} {
+		if _, ok := flagValue(args, flag); ok {
+			return fmt.Errorf("--%s cannot be coordinated; only --rate and --throughput are split across workers", flag)
+		}
+	}
+
+	// The global rate or throughput is split evenly across the workers
+	var share func(worker int) (string, string)
+	if value, ok := flagValue(args, "throughput"); ok {
+		bytesPerSecond, err := otelgen.ParseThroughput(value)
+		if err != nil {
+			return fmt.Errorf("invalid throughput: %w", err)
+		}
+		share = func(worker int) (string, string) {
+			return "throughput", fmt.Sprintf("%db/s", int64(bytesPerSecond)/int64(coordWorkers))
+		}
+	} else if value, ok := flagValue(args, "rate"); ok {
+		total, err := parseRate(value)
+		if err != nil {
+			return err
+		}
+		if total <= 0 {
+			return fmt.Errorf("rate must be positive")
+		}
+		share = func(worker int) (string, string) {
+			return "rate", strconv.FormatFloat(total/float64(coordWorkers), 'f', -1, 64)
+		}
+	} else {
+		return fmt.Errorf("the coordinated command must set --rate or --throughput, which is split across the workers")
+	}
+
+	stop := interrupted()
+	fmt.Printf("Coordinating: otelgen %s\n", strings.Join(args, " "))
+	return otelgen.Coordinate(otelgen.CoordinatorOptions{
+		Listen:   coordListen,
+		Workers:  coordWorkers,
+		Interval: coordInterval,
+		Items:    items,
+		Stop:     stop,
+		Verbose:  verbose,
+	}, func(worker, workers int) []string {
+		flag, value := share(worker)
+		return replaceFlag(args, flag, value)
+	})
+}
+
+// runWorker joins the coordinator and runs the command it assigns through root
+func runWorker(root *cobra.Command) error {
+	name := workerName
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	return otelgen.Join(joinAddr, name, verbose, func(args []string, progress *otelgen.Progress, stop <-chan struct{}) error {
+		if len(args) == 0 || coordinatedItems[args[0]] == "" {
+			return fmt.Errorf("coordinator assigned an unsupported command: %v", args)
+		}
+		runProgress, runStop = progress, stop
+		// The run's error is reported to the coordinator and printed once by main
+		root.SilenceErrors, root.SilenceUsage = true, true
+		root.SetArgs(args)
+		return root.Execute()
+	})
+}
+
+// runRetry re-sends the exports kept in the --dlq directory
+func runRetry(cmd *cobra.Command, args []string) error {
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	// The dead-letter directory is read here, not written to
+	transport.DeadLetterDir = ""
+	endpoint, target, err := openDestination("", &transport, "otlp")
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Endpoint: %s\n", target)
+	}
+	return otelgen.RetryDeadLetters(endpoint, dlqDir, headers, transport, verbose)
+}
+
+// runDoctor diagnoses the endpoint
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	transport.DeadLetterDir = ""
+	endpoint, _, err := openDestination("", &transport, "otlp")
+	if err != nil {
+		return err
+	}
+	return otelgen.Doctor(endpoint, headers, transport)
+}
+
+// runBench runs every configuration of the benchmark matrix against the endpoints
+func runBench(cmd *cobra.Command, args []string) error {
+	payloadSize, err := otelgen.ParseSize(size)
+	if err != nil {
+		return fmt.Errorf("invalid size: %w", err)
+	}
+	each, err := time.ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	if err := applyAuthPreset(); err != nil {
+		return err
+	}
+	transport, err := transportOptions()
+	if err != nil {
+		return err
+	}
+	transport.DeadLetterDir = ""
+	compressions := benchComps
+	if cmd.Flags().Changed("compression") {
+		compressions = []string{compression}
+	}
+	endpoint, _, err := openDestination("", &transport, "otlp")
+	if err != nil {
+		return err
+	}
+	return otelgen.Bench(serviceName, headers, transport, otelgen.BenchOptions{
+		Signal:       benchSignal,
+		Endpoints:    append([]*otelgen.Endpoint{endpoint}, transport.Endpoints...),
+		Compressions: compressions,
+		BatchSizes:   benchBatches,
+		Duration:     each,
+		Concurrency:  benchConc,
+		PayloadSize:  payloadSize,
+	}, verbose)
+}
+
+func runSink(cmd *cobra.Command, args []string) error {
+	stop := make(chan struct{})
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+	go func() {
+		<-interrupts
+		close(stop)
+	}()
+	return otelgen.RunSink(otelgen.SinkOptions{
+		GRPCListen: sinkGRPC,
+		HTTPListen: sinkHTTP,
+		Duration:   sinkDuration,
+		Interval:   sinkInterval,
+		Stop:       stop,
+		Capture:    sinkCapture,
+	})
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	return otelgen.Diff(otelgen.DiffOptions{
+		Sent:             args[0],
+		Received:         args[1],
+		IgnoreAttributes: diffIgnore,
+		Strict:           diffStrict,
+		Examples:         diffExamples,
+	})
+}
+
+// flagValue returns the value of --name in args, given as --name value or --name=value;
+// a bool flag given alone has the value "true"
+func flagValue(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			return value, true
+		}
+		if arg == "--"+name {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				return args[i+1], true
+			}
+			return "true", true
+		}
+	}
+	return "", false
+}
+
+// replaceFlag returns a copy of args with the value of --name replaced
+func replaceFlag(args []string, name, value string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], "--"+name+"="):
+			out = append(out, "--"+name+"="+value)
+		case args[i] == "--"+name:
+			out = append(out, "--"+name+"="+value)
+			i++
+		default:
+			out = append(out, args[i])
+		}
+	}
+	return out
+}
 
-	return otelgen.GenerateLogs(endpoint, serviceName, rate, duration, payloadSize, batchSize, headers, verbose, insecureSkip)
+// interrupted returns a channel closed on the first interrupt or SIGTERM, for runs
+// that stop cleanly rather than exit
+func interrupted() <-chan struct{} {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		// A second interrupt exits at once
+		signal.Stop(signals)
+		fmt.Println("Interrupted, stopping (interrupt again to exit at once)")
+		close(stop)
+	}()
+	return stop
 }
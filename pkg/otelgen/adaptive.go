@@ -0,0 +1,132 @@
+package otelgen
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveDecrease is the factor the rate is scaled by when the endpoint pushes back
+	adaptiveDecrease = 0.5
+	// adaptiveCooldown is the least time between decreases, so one overload answered by
+	// many concurrent exports halves the rate once
+	adaptiveCooldown = time.Second
+	// adaptiveRecovery is how much of the target rate is regained per second once the
+	// endpoint stops pushing back
+	adaptiveRecovery = 0.1
+	// adaptiveMinScale is the smallest fraction of the target rate offered
+	adaptiveMinScale = 0.01
+)
+
+// adaptivePacer slows the rate when the endpoint throttles exports and speeds it back
+// up afterwards, like a well-behaved SDK client under pressure: every throttle halves
+// the rate, which is held for any delay the endpoint asked for and then regained
+// gradually
+type adaptivePacer struct {
+	items string
+	// stats counts the items offered to the endpoint and the items it accepted
+	stats targetStats
+
+	mu sync.Mutex
+	// scale is the fraction of the target rate currently offered
+	scale    float64
+	lowest   float64
+	updated  time.Time
+	hold     time.Time
+	lastDrop time.Time
+	throttle int
+}
+
+func newAdaptivePacer(items string) *adaptivePacer {
+	return &adaptivePacer{items: items, scale: 1, lowest: 1, updated: time.Now()}
+}
+
+// pace scales the phases of the profile by the pacer's current scale
+func (a *adaptivePacer) pace(p *loadProfile) {
+	for _, ph := range p.phases {
+		rate := ph.rate
+		ph.rate = func(elapsed time.Duration) float64 {
+			return rate(elapsed) * a.current()
+		}
+	}
+}
+
+// current returns the fraction of the target rate to offer now, regaining what the
+// time since the last throttle, or the end of its hinted delay, allows
+func (a *adaptivePacer) current() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if now.After(a.hold) && a.scale < 1 {
+		a.scale = min(1, a.scale+adaptiveRecovery*now.Sub(later(a.updated, a.hold)).Seconds())
+		if a.scale == 1 {
+			fmt.Println("Endpoint stopped pushing back, back to the full target rate")
+		}
+	}
+	a.updated = now
+	return a.scale
+}
+
+// throttled slows the rate after the endpoint throttled an export, holding it for
+// the delay the endpoint asked for, if any
+func (a *adaptivePacer) throttled(reason string, delay time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	a.throttle++
+	a.hold = later(a.hold, now.Add(delay))
+	if now.Sub(a.lastDrop) < adaptiveCooldown {
+		return
+	}
+	a.lastDrop = now
+	a.scale = max(adaptiveMinScale, a.scale*adaptiveDecrease)
+	a.lowest = min(a.lowest, a.scale)
+	a.updated = now
+	if delay > 0 {
+		fmt.Printf("Endpoint pushed back (%s), slowing to %.0f%% of the target rate for at least %s\n", reason, a.scale*100, delay)
+		return
+	}
+	fmt.Printf("Endpoint pushed back (%s), slowing to %.0f%% of the target rate\n", reason, a.scale*100)
+}
+
+// later returns the later of two times
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// endWarmup leaves the items sent so far out of the summary; it does nothing without
+// a pacer
+func (a *adaptivePacer) endWarmup() {
+	if a == nil {
+		return
+	}
+	a.stats.reset()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.throttle, a.lowest = 0, a.scale
+}
+
+// printSummary prints the throttles and the offered and accepted rates over the run,
+// or over the part of it after the warmup
+func (a *adaptivePacer) printSummary(duration time.Duration) {
+	a.mu.Lock()
+	throttles, lowest := a.throttle, a.lowest
+	a.mu.Unlock()
+	if throttles == 0 {
+		fmt.Println("Adaptive pacing: the endpoint never pushed back")
+	} else {
+		fmt.Printf("Adaptive pacing: throttled %d times, slowed to as low as %.0f%% of the target rate\n", throttles, lowest*100)
+	}
+	accepted := a.stats.items.Load()
+	offered := accepted + a.stats.failedItems.Load()
+	seconds := duration.Seconds()
+	ratio := 0.0
+	if offered > 0 {
+		ratio = float64(accepted) / float64(offered) * 100
+	}
+	fmt.Printf("Offered %.1f %s/s, accepted %.1f %s/s (%.1f%%)\n", float64(offered)/seconds, a.items, float64(accepted)/seconds, a.items, ratio)
+}
@@ -0,0 +1,104 @@
+package otelgen
+
+import (
+	"math"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// injectionProbability is the chance that a tick emits a deliberately broken datapoint
+const injectionProbability = 0.1
+
+// adversarialValues occasionally emits out-of-spec datapoints (NaN, ±Inf, negative
+// monotonic sums, inverted timestamps) that the SDK would never produce
+type adversarialValues struct {
+	start time.Time
+	rnd   *runRandom
+}
+
+func newAdversarialValues(rnd *runRandom) *adversarialValues {
+	return &adversarialValues{start: time.Now(), rnd: rnd}
+}
+
+// Metrics returns one adversarial metric on roughly one tick in ten
+func (a *adversarialValues) Metrics(now time.Time) []*metricspb.Metric {
+	if a.rnd.Float64() >= injectionProbability {
+		return nil
+	}
+
+	start, ts := unixNano(a.start), unixNano(now)
+	kind := a.rnd.Intn(7)
+	attrs := []*commonpb.KeyValue{stringAttr("adversarial.kind", adversarialKinds[kind])}
+
+	switch kind {
+	case 0, 1, 2:
+		// NaN, +Inf, -Inf gauge values
+		value := []float64{math.NaN(), math.Inf(1), math.Inf(-1)}[kind]
+		return []*metricspb.Metric{gaugeMetric("otelgen.adversarial.gauge", attrs, ts, value)}
+	case 3:
+		// Negative value on a monotonic cumulative counter
+		return []*metricspb.Metric{monotonicSumMetric("otelgen.adversarial.counter", attrs, start, ts, -float64(a.rnd.Intn(1000)+1))}
+	case 4:
+		// Start time after the datapoint timestamp
+		return []*metricspb.Metric{monotonicSumMetric("otelgen.adversarial.counter", attrs, ts+uint64(time.Hour), ts, float64(a.rnd.Intn(1000)))}
+	case 5:
+		// Missing timestamp
+		return []*metricspb.Metric{gaugeMetric("otelgen.adversarial.gauge", attrs, 0, a.rnd.Float64()*100)}
+	default:
+		// Histogram with NaN sum and infinite min/max
+		sum, lo, hi := math.NaN(), math.Inf(-1), math.Inf(1)
+		return []*metricspb.Metric{{
+			Name: "otelgen.adversarial.histogram",
+			Unit: "ms",
+			Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricspb.HistogramDataPoint{{
+					Attributes:        attrs,
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					Count:             3,
+					Sum:               &sum,
+					Min:               &lo,
+					Max:               &hi,
+					ExplicitBounds:    []float64{10, 100},
+					BucketCounts:      []uint64{1, 1, 1},
+				}},
+			}},
+		}}
+	}
+}
+
+var adversarialKinds = []string{"nan", "pos_inf", "neg_inf", "negative_counter", "start_after_time", "zero_timestamp", "nan_histogram"}
+
+// gaugeMetric builds a single-datapoint double gauge
+func gaugeMetric(name string, attrs []*commonpb.KeyValue, ts uint64, value float64) *metricspb.Metric {
+	return &metricspb.Metric{
+		Name: name,
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+			DataPoints: []*metricspb.NumberDataPoint{{
+				Attributes:   attrs,
+				TimeUnixNano: ts,
+				Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			}},
+		}},
+	}
+}
+
+// monotonicSumMetric builds a single-datapoint cumulative monotonic double sum
+func monotonicSumMetric(name string, attrs []*commonpb.KeyValue, start, ts uint64, value float64) *metricspb.Metric {
+	return &metricspb.Metric{
+		Name: name,
+		Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+			DataPoints: []*metricspb.NumberDataPoint{{
+				Attributes:        attrs,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      ts,
+				Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			}},
+		}},
+	}
+}
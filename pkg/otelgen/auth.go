@@ -0,0 +1,208 @@
+package otelgen
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenFileCheckInterval limits how often a token file is checked for changes
+const tokenFileCheckInterval = time.Second
+
+// apiKeyPresets maps each vendor to the header its OTLP ingest reads the API key from
+var apiKeyPresets = map[string]struct {
+	header string
+	prefix string
+}{
+	"edgedelta": {"Authorization", "Bearer "},
+	"datadog":   {"DD-API-KEY", ""},
+	"honeycomb": {"x-honeycomb-team", ""},
+	"newrelic":  {"api-key", ""},
+}
+
+// APIKeyHeader returns the header name and value that carry an API key for a vendor preset
+func APIKeyHeader(preset, apiKey string) (string, string, error) {
+	p, ok := apiKeyPresets[strings.ToLower(preset)]
+	if !ok {
+		return "", "", fmt.Errorf("unknown auth preset %q (supported: edgedelta, datadog, honeycomb, newrelic)", preset)
+	}
+	if apiKey == "" {
+		return "", "", fmt.Errorf("auth preset %s requires an API key", preset)
+	}
+	return p.header, p.prefix + apiKey, nil
+}
+
+// authorization supplies the Authorization header value for each export; the value
+// may change during a run, e.g. when a token is rotated
+type authorization interface {
+	header(ctx context.Context) (string, error)
+}
+
+// staticAuthorization is an Authorization header value that never changes
+type staticAuthorization string
+
+func (a staticAuthorization) header(ctx context.Context) (string, error) {
+	return string(a), nil
+}
+
+// bearerTokenFile reads a bearer token from a file and reloads it whenever the file
+// changes, so tokens rotated on disk are picked up by long runs
+type bearerTokenFile struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	checked time.Time
+}
+
+func newBearerTokenFile(path string) (*bearerTokenFile, error) {
+	f := &bearerTokenFile{path: path}
+	if _, err := f.header(context.Background()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *bearerTokenFile) header(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.token != "" && time.Since(f.checked) < tokenFileCheckInterval {
+		return "Bearer " + f.token, nil
+	}
+	f.checked = time.Now()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	if f.token != "" && info.ModTime().Equal(f.modTime) {
+		return "Bearer " + f.token, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("bearer token file %s is empty", f.path)
+	}
+	f.token = token
+	f.modTime = info.ModTime()
+	return "Bearer " + f.token, nil
+}
+
+// oauth2Authorization fetches access tokens with the OAuth2 client-credentials flow,
+// fetching a new one shortly before the current token expires
+type oauth2Authorization struct {
+	source oauth2.TokenSource
+}
+
+func newOAuth2Authorization(t TransportOptions) (*oauth2Authorization, error) {
+	if t.OAuth2ClientID == "" || t.OAuth2ClientSecret == "" {
+		return nil, fmt.Errorf("OAuth2 requires a client ID and client secret")
+	}
+	config := clientcredentials.Config{
+		ClientID:     t.OAuth2ClientID,
+		ClientSecret: t.OAuth2ClientSecret,
+		TokenURL:     t.OAuth2TokenURL,
+		Scopes:       t.OAuth2Scopes,
+	}
+
+	// The token endpoint honors --insecure-skip-verify, but not the export server name
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Timeout:   t.exportTimeout(),
+		Transport: base,
+	})
+
+	a := &oauth2Authorization{source: config.TokenSource(ctx)}
+	if _, err := a.header(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *oauth2Authorization) header(ctx context.Context) (string, error) {
+	token, err := a.source.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch OAuth2 token: %w", err)
+	}
+	return token.Type() + " " + token.AccessToken, nil
+}
+
+// newAuthorization returns the configured Authorization source, or nil if none is set
+func (t TransportOptions) newAuthorization() (authorization, error) {
+	set := 0
+	for _, v := range []string{t.BearerToken, t.BearerTokenFile, t.BasicAuth, t.OAuth2TokenURL} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("only one of bearer token, bearer token file, basic auth, and OAuth2 can be used")
+	}
+
+	if t.OAuth2TokenURL != "" {
+		return newOAuth2Authorization(t)
+	}
+	if t.BasicAuth != "" {
+		if !strings.Contains(t.BasicAuth, ":") {
+			return nil, fmt.Errorf("basic auth must be in user:password form")
+		}
+		return staticAuthorization("Basic " + base64.StdEncoding.EncodeToString([]byte(t.BasicAuth))), nil
+	}
+	if t.BearerToken != "" {
+		return staticAuthorization("Bearer " + t.BearerToken), nil
+	}
+	if t.BearerTokenFile != "" {
+		return newBearerTokenFile(t.BearerTokenFile)
+	}
+	return nil, nil
+}
+
+// perRPCAuthorization adds the Authorization header to every gRPC call
+type perRPCAuthorization struct {
+	auth authorization
+}
+
+func (p perRPCAuthorization) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	value, err := p.auth.header(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": value}, nil
+}
+
+// RequireTransportSecurity is false so plaintext test endpoints can be authenticated too
+func (p perRPCAuthorization) RequireTransportSecurity() bool {
+	return false
+}
+
+// authTransport adds the Authorization header to every HTTP request
+type authTransport struct {
+	base http.RoundTripper
+	auth authorization
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value, err := t.auth.header(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", value)
+	return t.base.RoundTrip(req)
+}
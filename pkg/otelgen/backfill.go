@@ -0,0 +1,314 @@
+package otelgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+const (
+	// backfillStepsPerRequest is how many timestamps are packed into one export request
+	backfillStepsPerRequest = 100
+	// backfillSamplesPerStep is how many duration samples each step adds to the histogram
+	backfillSamplesPerStep = 10
+)
+
+// defaultHistogramBounds are the SDK's default explicit bucket boundaries
+var defaultHistogramBounds = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// backfill walks forward through a past window, producing the default metric set at
+// every step with historical timestamps
+type backfill struct {
+	from  time.Time
+	to    time.Time
+	step  time.Duration
+	attrs []*commonpb.KeyValue
+	gauge *waveform
+	rnd   *runRandom
+
+	requests float64
+	bounds   []float64
+	counts   []uint64
+	count    uint64
+	sum      float64
+	min, max float64
+}
+
+func newBackfill(window, step time.Duration, src *metricsSource) *backfill {
+	to := time.Now()
+	b := &backfill{
+		from:   to.Add(-window),
+		to:     to,
+		step:   step,
+		gauge:  src.gauge,
+		rnd:    src.rnd,
+		bounds: src.opts.HistogramBuckets,
+		min:    math.Inf(1),
+		max:    math.Inf(-1),
+	}
+	if len(b.bounds) == 0 {
+		b.bounds = defaultHistogramBounds
+	}
+	b.counts = make([]uint64, len(b.bounds)+1)
+
+	// Anchor the gauge waveform to the start of the window
+	b.gauge.start = b.from
+
+	b.attrs = []*commonpb.KeyValue{stringAttr("method", "GET"), stringAttr("endpoint", "/api/test")}
+	if src.payloadSize > 0 {
+		b.attrs = append(b.attrs, stringAttr("payload.data", GeneratePadding(src.payloadSize)))
+	}
+	return b
+}
+
+// Steps returns the number of timestamps in the window
+func (b *backfill) Steps() int {
+	return int(b.to.Sub(b.from)/b.step) + 1
+}
+
+// Metrics advances through steps [first, last) and returns their datapoints
+func (b *backfill) Metrics(first, last int) []*metricspb.Metric {
+	start := unixNano(b.from)
+	counter := &metricspb.Sum{
+		AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		IsMonotonic:            true,
+	}
+	histogram := &metricspb.Histogram{
+		AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+	}
+	gauge := &metricspb.Gauge{}
+
+	for i := first; i < last; i++ {
+		ts := b.from.Add(time.Duration(i) * b.step)
+
+		b.requests += float64(b.rnd.Intn(10) + 1)
+		counter.DataPoints = append(counter.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:        b.attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      unixNano(ts),
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: b.requests},
+		})
+
+		for j := 0; j < backfillSamplesPerStep; j++ {
+			b.observe(b.rnd.Float64() * 1000)
+		}
+		sum, lo, hi := b.sum, b.min, b.max
+		histogram.DataPoints = append(histogram.DataPoints, &metricspb.HistogramDataPoint{
+			Attributes:        b.attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      unixNano(ts),
+			Count:             b.count,
+			Sum:               &sum,
+			Min:               &lo,
+			Max:               &hi,
+			ExplicitBounds:    b.bounds,
+			BucketCounts:      append([]uint64(nil), b.counts...),
+		})
+
+		gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   []*commonpb.KeyValue{stringAttr("host", "localhost")},
+			TimeUnixNano: unixNano(ts),
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: b.gauge.Value(ts)},
+		})
+	}
+
+	return []*metricspb.Metric{
+		{Name: "otelgen.requests", Description: "Number of requests", Data: &metricspb.Metric_Sum{Sum: counter}},
+		{Name: "otelgen.duration", Description: "Request duration", Unit: "ms", Data: &metricspb.Metric_Histogram{Histogram: histogram}},
+		{Name: "otelgen.cpu_usage", Description: "CPU usage percentage", Data: &metricspb.Metric_Gauge{Gauge: gauge}},
+	}
+}
+
+func (b *backfill) observe(v float64) {
+	b.counts[sort.SearchFloat64s(b.bounds, v)]++
+	b.count++
+	b.sum += v
+	b.min = math.Min(b.min, v)
+	b.max = math.Max(b.max, v)
+}
+
+// backfillCheckpoint is the progress of a backfill, saved after every request so an
+// interrupted backfill resumes at the next step, with the same window and cumulative
+// values, instead of starting over or sending steps twice
+type backfillCheckpoint struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Step is the spacing between timestamps, in nanoseconds
+	Step time.Duration `json:"step_ns"`
+	// Next is the first step not yet sent
+	Next  int `json:"next_step"`
+	Steps int `json:"steps"`
+
+	// Requests is the cumulative value of the request counter
+	Requests float64 `json:"requests_total"`
+	// The cumulative duration histogram
+	Bounds []float64 `json:"histogram_bounds"`
+	Counts []uint64  `json:"histogram_counts"`
+	Count  uint64    `json:"histogram_count"`
+	Sum    float64   `json:"histogram_sum"`
+	Min    float64   `json:"histogram_min"`
+	Max    float64   `json:"histogram_max"`
+
+	// Datapoints and Rejected total the datapoints sent and the requests rejected,
+	// across every run of the backfill
+	Datapoints int       `json:"datapoints_sent"`
+	Rejected   int       `json:"requests_rejected"`
+	Updated    time.Time `json:"updated"`
+}
+
+// loadBackfillCheckpoint reads the checkpoint saved in path
+func loadBackfillCheckpoint(path string) (*backfillCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backfill checkpoint: %w", err)
+	}
+	var c backfillCheckpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse backfill checkpoint %s: %w", path, err)
+	}
+	if c.Step <= 0 || !c.To.After(c.From) || c.Next < 0 || len(c.Counts) != len(c.Bounds)+1 {
+		return nil, fmt.Errorf("invalid backfill checkpoint %s", path)
+	}
+	return &c, nil
+}
+
+// save writes the checkpoint to path, replacing the previous one only once the new one
+// is complete
+func (c *backfillCheckpoint) save(path string) error {
+	c.Updated = time.Now()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backfill checkpoint: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write backfill checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write backfill checkpoint: %w", err)
+	}
+	return nil
+}
+
+// checkpoint returns the backfill's progress with next as the first step not yet sent
+func (b *backfill) checkpoint(next int) *backfillCheckpoint {
+	return &backfillCheckpoint{
+		From:     b.from,
+		To:       b.to,
+		Step:     b.step,
+		Next:     next,
+		Steps:    b.Steps(),
+		Requests: b.requests,
+		Bounds:   b.bounds,
+		Counts:   b.counts,
+		Count:    b.count,
+		Sum:      b.sum,
+		Min:      b.min,
+		Max:      b.max,
+	}
+}
+
+// restore continues the backfill from a checkpoint, which must be for the same step
+// and histogram buckets
+func (b *backfill) restore(c *backfillCheckpoint) error {
+	if c.Step != b.step {
+		return fmt.Errorf("backfill checkpoint is for %s steps, not %s", c.Step, b.step)
+	}
+	if window := c.To.Sub(c.From); window != b.to.Sub(b.from) {
+		return fmt.Errorf("backfill checkpoint is for a %s window, not %s", window, b.to.Sub(b.from))
+	}
+	if !slices.Equal(c.Bounds, b.bounds) {
+		return fmt.Errorf("backfill checkpoint has histogram buckets %v, not %v", c.Bounds, b.bounds)
+	}
+	b.from, b.to = c.From, c.To
+	b.gauge.start = b.from
+	b.requests = c.Requests
+	b.counts = append([]uint64(nil), c.Counts...)
+	b.count, b.sum, b.min, b.max = c.Count, c.Sum, c.Min, c.Max
+	return nil
+}
+
+// runBackfill sends the whole window as fast as the endpoint accepts it, or the rest
+// of it after resume, saving the progress to the checkpoint file if there is one. Once
+// stop is closed, it stops after the request in flight.
+func runBackfill(ctx context.Context, raw *rawClient, res *resource.Resource, src *metricsSource, resume *backfillCheckpoint, stop <-chan struct{}, verbose bool) error {
+	b := newBackfill(src.opts.Backfill, src.opts.BackfillStep, src)
+	path := src.opts.BackfillCheckpoint
+	begin, total, totalRejected := 0, 0, 0
+	if resume != nil {
+		if err := b.restore(resume); err != nil {
+			return err
+		}
+		if resume.Next >= b.Steps() {
+			fmt.Printf("Backfill in %s is already complete (%d datapoints sent)\n", path, resume.Datapoints)
+			return nil
+		}
+		begin, total, totalRejected = resume.Next, resume.Datapoints, resume.Rejected
+	}
+	steps := b.Steps()
+	if begin > 0 {
+		fmt.Printf("Resuming backfill at step %d of %d (%s), from %s to %s\n", begin+1, steps, b.from.Add(time.Duration(begin)*b.step).Format(time.RFC3339), b.from.Format(time.RFC3339), b.to.Format(time.RFC3339))
+	} else {
+		fmt.Printf("Backfilling %d steps of %s from %s to %s\n", steps, b.step, b.from.Format(time.RFC3339), b.to.Format(time.RFC3339))
+	}
+	if path != "" && verbose {
+		fmt.Printf("[VERBOSE] Saving progress to %s after every request\n", path)
+	}
+
+	began := time.Now()
+	requests, rejected, points := 0, 0, 0
+	first := begin
+	for ; first < steps; first += backfillStepsPerRequest {
+		select {
+		case <-stop:
+			elapsed := time.Since(began)
+			fmt.Printf("Stopped at step %d of %d after backfilling %d datapoints in %d requests over %s\n", first+1, steps, points, requests, elapsed.Round(time.Millisecond))
+			if path != "" {
+				fmt.Printf("Progress is saved in %s\n", path)
+			}
+			return nil
+		default:
+		}
+
+		last := min(first+backfillStepsPerRequest, steps)
+		metrics := b.Metrics(first, last)
+
+		requests++
+		if err := raw.ExportMetrics(ctx, rawMetricsRequest(res, "otelgen", metrics)); err != nil {
+			rejected++
+			fmt.Printf("Error sending backfill steps %d-%d: %v\n", first, last-1, err)
+		} else {
+			points += countDataPoints(metrics)
+		}
+
+		if path != "" {
+			c := b.checkpoint(last)
+			c.Datapoints, c.Rejected = total+points, totalRejected+rejected
+			if err := c.save(path); err != nil {
+				return err
+			}
+		}
+		if verbose {
+			fmt.Printf("[VERBOSE] Backfilled through %s (%d/%d steps)\n", b.from.Add(time.Duration(last-1)*b.step).Format(time.RFC3339), last, steps)
+		}
+	}
+
+	elapsed := time.Since(began)
+	fmt.Printf("Backfilled %d datapoints in %d requests over %s (%.0f datapoints/s)\n", points, requests, elapsed.Round(time.Millisecond), float64(points)/elapsed.Seconds())
+	if rejected > 0 {
+		fmt.Printf("Rejected %d of %d requests\n", rejected, requests)
+	}
+	if begin > 0 {
+		fmt.Printf("Backfill complete: %d datapoints across every run, %d requests rejected\n", total+points, totalRejected+rejected)
+	}
+	return nil
+}
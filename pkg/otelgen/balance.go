@@ -0,0 +1,352 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// targetLatencySamples bounds the export latencies kept per target for percentiles;
+// beyond it, a uniform sample of every latency seen is kept
+const targetLatencySamples = 100000
+
+// target is one destination that exports are balanced across: an endpoint, pinned to
+// one of its resolved addresses when Resolve gives it several
+type target struct {
+	name      string
+	endpoint  *Endpoint
+	transport TransportOptions // with Resolve narrowed to the target's address
+	weight    int
+	stats     targetStats
+	latency   latencySample
+	// start is when the target's stats start, after any warmup
+	start time.Time
+}
+
+func newTarget(name string, endpoint *Endpoint, transport TransportOptions) *target {
+	tg := &target{name: name, endpoint: endpoint, transport: transport, weight: 1, start: time.Now()}
+	tg.stats.latency = &tg.latency
+	return tg
+}
+
+// reset restarts the target's stats, for the summary to leave out the warmup
+func (tg *target) reset() {
+	tg.stats.reset()
+	tg.latency.reset()
+	tg.start = time.Now()
+}
+
+// targetStats counts the exports sent to a target
+type targetStats struct {
+	exports atomic.Int64
+	failed  atomic.Int64
+	items   atomic.Int64
+	// failedItems counts the items of the failed exports
+	failedItems atomic.Int64
+	// latency, when set, samples the latency of the exports
+	latency *latencySample
+}
+
+// latencySample keeps a uniform sample of export latencies for percentiles
+type latencySample struct {
+	mu      sync.Mutex
+	seen    int64
+	samples []time.Duration
+}
+
+func (s *latencySample) add(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen++
+	if len(s.samples) < targetLatencySamples {
+		s.samples = append(s.samples, d)
+	} else if i := rand.Int63n(s.seen); i < targetLatencySamples {
+		s.samples[i] = d
+	}
+}
+
+func (s *latencySample) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen, s.samples = 0, s.samples[:0]
+}
+
+// sorted returns the sampled latencies in order
+func (s *latencySample) sorted() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sorted := slices.Clone(s.samples)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// reset zeroes the counts
+func (s *targetStats) reset() {
+	s.exports.Store(0)
+	s.failed.Store(0)
+	s.items.Store(0)
+	s.failedItems.Store(0)
+}
+
+func (s *targetStats) record(items int, latency time.Duration, err error) {
+	if s.latency != nil {
+		s.latency.add(latency)
+	}
+	s.exports.Add(1)
+	if err != nil {
+		s.failed.Add(1)
+		s.failedItems.Add(int64(items))
+		return
+	}
+	s.items.Add(int64(items))
+}
+
+// targets returns the endpoint and the extra Endpoints, each split into one target
+// per resolved address when it has several
+func (t TransportOptions) targets(endpoint *Endpoint) ([]*target, error) {
+	if t.Writer != nil {
+		return []*target{newTarget(endpoint.String(), endpoint, t)}, nil
+	}
+
+	var targets []*target
+	for _, ep := range append([]*Endpoint{endpoint}, t.Endpoints...) {
+		key := resolveKey(ep.Address())
+		addrs := t.Resolve[key]
+		if len(addrs) <= 1 {
+			targets = append(targets, newTarget(ep.String(), ep, t))
+			continue
+		}
+		for _, addr := range addrs {
+			tt := t
+			tt.Resolve = make(map[string][]string, len(t.Resolve))
+			for k, v := range t.Resolve {
+				tt.Resolve[k] = v
+			}
+			tt.Resolve[key] = []string{addr}
+			targets = append(targets, newTarget(fmt.Sprintf("%s (%s)", ep, addr), ep, tt))
+		}
+	}
+
+	switch t.LBStrategy {
+	case "", "round-robin", "random":
+		if len(t.LBWeights) > 0 {
+			return nil, fmt.Errorf("weights can only be used with the weighted strategy")
+		}
+	case "weighted":
+		if len(t.LBWeights) != len(targets) {
+			return nil, fmt.Errorf("the weighted strategy needs one weight per target (%d targets, %d weights)", len(targets), len(t.LBWeights))
+		}
+		for i, w := range t.LBWeights {
+			if w <= 0 {
+				return nil, fmt.Errorf("weights must be positive")
+			}
+			targets[i].weight = w
+		}
+	default:
+		return nil, fmt.Errorf("unknown load-balancing strategy %q (supported: round-robin, random, weighted)", t.LBStrategy)
+	}
+	return targets, nil
+}
+
+// resolveKey normalizes host:port for looking up Resolve
+func resolveKey(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(strings.ToLower(host), port)
+}
+
+// balancer picks which of n shards receives the next item or export
+type balancer interface {
+	pick(n int) int
+}
+
+// newBalancer returns the strategy's balancer over shards, each belonging to a target
+func (t TransportOptions) newBalancer(shards []*target) balancer {
+	switch t.LBStrategy {
+	case "random":
+		return randomBalancer{}
+	case "weighted":
+		b := &weightedBalancer{}
+		for _, tg := range shards {
+			b.total += tg.weight
+			b.cumulative = append(b.cumulative, b.total)
+		}
+		return b
+	default:
+		return &roundRobin{}
+	}
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) pick(n int) int {
+	return rand.Intn(n)
+}
+
+// weightedBalancer picks shards at random in proportion to their targets' weights
+type weightedBalancer struct {
+	cumulative []int
+	total      int
+}
+
+func (b *weightedBalancer) pick(n int) int {
+	return sort.SearchInts(b.cumulative, rand.Intn(b.total)+1)
+}
+
+// openTargets creates an exporter for each connection to each target, returning them
+// with the target each belongs to; only the first describes its settings when verbose
+func openTargets[E interface{ Shutdown(context.Context) error }](targets []*target, connections int, verbose bool, newExporter func(tg *target, verbose bool) (E, error)) ([]E, []*target, error) {
+	var exporters []E
+	var owners []*target
+	for _, tg := range targets {
+		for i := 0; i < connections; i++ {
+			e, err := newExporter(tg, verbose && len(exporters) == 0)
+			if err != nil {
+				for _, e := range exporters {
+					e.Shutdown(context.Background())
+				}
+				return nil, nil, err
+			}
+			exporters = append(exporters, e)
+			owners = append(owners, tg)
+		}
+	}
+	return exporters, owners, nil
+}
+
+// closeTargets returns a function shutting the exporters down, for the errors returned
+// before a provider takes them over; once provided is set, the provider shuts every
+// one of them down itself
+func closeTargets[E interface{ Shutdown(context.Context) error }](exporters []E, provided *bool) func() {
+	exporters = slices.Clone(exporters)
+	return func() {
+		if *provided {
+			return
+		}
+		for _, e := range exporters {
+			e.Shutdown(context.Background())
+		}
+	}
+}
+
+// printTargetStats prints each target's exports, error rate, throughput, and export
+// latency when there is more than one, so the targets can be compared
+func printTargetStats(targets []*target, signal string) {
+	if len(targets) < 2 {
+		return
+	}
+	for _, tg := range targets {
+		exports, failed, items := tg.stats.exports.Load(), tg.stats.failed.Load(), tg.stats.items.Load()
+		line := fmt.Sprintf("Target %s: %d exports (%d failed", tg.name, exports, failed)
+		if exports > 0 {
+			line += fmt.Sprintf(", %.2f%%", float64(failed)/float64(exports)*100)
+		}
+		line += fmt.Sprintf("), %d %s (%.1f/s)", items, signalItems[signal], float64(items)/time.Since(tg.start).Seconds())
+		if sorted := tg.latency.sorted(); len(sorted) > 0 {
+			line += fmt.Sprintf(", export latency p50 %s, p95 %s, p99 %s",
+				percentile(sorted, 0.5).Round(10*time.Microsecond), percentile(sorted, 0.95).Round(10*time.Microsecond),
+				percentile(sorted, 0.99).Round(10*time.Microsecond))
+		}
+		fmt.Println(line)
+	}
+}
+
+// countingSpanExporter records each export in its target's stats
+type countingSpanExporter struct {
+	sdktrace.SpanExporter
+	stats *targetStats
+}
+
+func (e countingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.stats.record(len(spans), time.Since(start), err)
+	return err
+}
+
+// countingLogExporter records each export in its target's stats
+type countingLogExporter struct {
+	sdklog.Exporter
+	stats *targetStats
+}
+
+func (e countingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.stats.record(len(records), time.Since(start), err)
+	return err
+}
+
+// countingMetricExporter records each export in its target's stats
+type countingMetricExporter struct {
+	sdkmetric.Exporter
+	stats *targetStats
+}
+
+func (e countingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.stats.record(countSDKDataPoints(rm), time.Since(start), err)
+	return err
+}
+
+// countSDKDataPoints returns the number of datapoints in an SDK export
+func countSDKDataPoints(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch d := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(d.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(d.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Summary:
+				n += len(d.DataPoints)
+			}
+		}
+	}
+	return n
+}
+
+// describeTargets prints how items are spread across the targets and connections
+func (t TransportOptions) describeTargets(targets []*target, shards int, items string) {
+	if len(targets) > 1 {
+		strategy := t.LBStrategy
+		if strategy == "" {
+			strategy = "round-robin"
+		}
+		fmt.Printf("[VERBOSE] Balancing %s across %d targets (%s):\n", items, len(targets), strategy)
+		for _, tg := range targets {
+			fmt.Printf("[VERBOSE]   %s, weight %d\n", tg.name, tg.weight)
+		}
+	}
+	if shards > len(targets) {
+		fmt.Printf("[VERBOSE] Spreading %s across %d connections\n", items, shards)
+	}
+}
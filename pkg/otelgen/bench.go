@@ -0,0 +1,281 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// benchRequests is the number of distinct requests built for each batch size and
+// cycled through, so every configuration sends the same data
+const benchRequests = 16
+
+// BenchOptions is the matrix of transport configurations a benchmark compares
+type BenchOptions struct {
+	// Signal is what is sent: traces (default) or logs
+	Signal string
+	// Endpoints are benchmarked one after the other, e.g. the gRPC and HTTP receivers
+	// of one collector
+	Endpoints []*Endpoint
+	// Compressions are the export compressions compared (default none and gzip)
+	Compressions []string
+	// BatchSizes are the spans or log records per request compared (default 128, 512,
+	// and 2048)
+	BatchSizes []int
+	// Duration is how long each configuration runs (default 10s)
+	Duration time.Duration
+	// Concurrency is the number of requests in flight (default 4 per connection)
+	Concurrency int
+	// PayloadSize pads each span or log record, as for the generators
+	PayloadSize int64
+}
+
+// benchRequest is one prebuilt export request
+type benchRequest struct {
+	size int
+	send func(context.Context, *rawClient) error
+}
+
+// benchResult is the outcome of one configuration of the matrix
+type benchResult struct {
+	endpoint    *Endpoint
+	compression string
+	batch       int
+	skipped     error
+
+	elapsed       time.Duration
+	exports       int
+	failed        int
+	items         int64
+	bytes         int64
+	wireBytes     int64
+	p50, p99, max time.Duration
+	firstError    error
+}
+
+// Bench sends identical data through every configuration in the matrix, each for the
+// same duration and as fast as the endpoint accepts it, and prints a table comparing
+// their throughput, export latency, and error rates
+func Bench(serviceName string, headers map[string]string, transport TransportOptions, opts BenchOptions, verbose bool) error {
+	if opts.Signal == "" {
+		opts.Signal = "traces"
+	}
+	if opts.Signal != "traces" && opts.Signal != "logs" {
+		return fmt.Errorf("unknown signal %q (supported: traces, logs)", opts.Signal)
+	}
+	if len(opts.Endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint is required")
+	}
+	if len(opts.Compressions) == 0 {
+		opts.Compressions = []string{"none", "gzip"}
+	}
+	if len(opts.BatchSizes) == 0 {
+		opts.BatchSizes = []int{128, 512, 2048}
+	}
+	for _, n := range opts.BatchSizes {
+		if n <= 0 {
+			return fmt.Errorf("batch sizes must be positive")
+		}
+	}
+	if opts.Duration == 0 {
+		opts.Duration = 10 * time.Second
+	}
+	if opts.Duration < 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if opts.Concurrency < 0 {
+		return fmt.Errorf("concurrency cannot be negative")
+	}
+	if transport.Writer != nil {
+		return fmt.Errorf("a benchmark needs OTLP endpoints")
+	}
+	transport.Endpoints = nil
+
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion("1.0.0"),
+		),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	// The requests for each batch size are built once and shared by every configuration
+	requests := make(map[int][]benchRequest, len(opts.BatchSizes))
+	for _, n := range opts.BatchSizes {
+		requests[n] = buildBenchRequests(opts.Signal, res, n, opts.PayloadSize)
+	}
+
+	configs := len(opts.Endpoints) * len(opts.Compressions) * len(opts.BatchSizes)
+	fmt.Printf("Benchmarking %d configurations for %s each, sending %s\n", configs, opts.Duration, signalItems[opts.Signal])
+	var results []benchResult
+	for _, endpoint := range opts.Endpoints {
+		for _, compression := range opts.Compressions {
+			t := transport
+			t.Compression = compression
+			invalid := t.validate(endpoint)
+			if invalid != nil {
+				fmt.Printf("Skipping %s with %s compression: %v\n", endpoint, compression, invalid)
+			}
+			for _, n := range opts.BatchSizes {
+				r := benchResult{endpoint: endpoint, compression: compression, batch: n, skipped: invalid}
+				if invalid == nil {
+					fmt.Printf("Running %s, %s compression, %d %s per request\n", endpoint, compression, n, signalItems[opts.Signal])
+					if r.skipped = runBenchConfig(&r, endpoint, headers, t, opts, requests[n]); r.skipped != nil {
+						fmt.Printf("Skipping %s with %s compression: %v\n", endpoint, compression, r.skipped)
+					} else if verbose && r.firstError != nil {
+						fmt.Printf("[VERBOSE] First export error: %v\n", r.firstError)
+					}
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	fmt.Println()
+	printBenchResults(results, signalItems[opts.Signal])
+	return nil
+}
+
+// buildBenchRequests builds the requests of batch items each, timestamped now
+func buildBenchRequests(signal string, res *resource.Resource, batch int, payloadSize int64) []benchRequest {
+	resource := fastResource(res)
+	scope := &commonpb.InstrumentationScope{Name: "otelgen"}
+	now := time.Now()
+	rnd := LoadOptions{}.newRandom()
+	out := make([]benchRequest, 0, benchRequests)
+	for range benchRequests {
+		var req proto.Message
+		var send func(context.Context, *rawClient) error
+		if signal == "traces" {
+			spans := make([]*tracepb.Span, 0, batch+4)
+			for len(spans) < batch {
+				spans = newFastTrace(newTraceShape(rnd, payloadSize)).appendSpans(rnd, spans, now)
+			}
+			r := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+				Resource:   resource,
+				ScopeSpans: []*tracepb.ScopeSpans{{Scope: scope, Spans: spans[:batch]}},
+				SchemaUrl:  res.SchemaURL(),
+			}}}
+			req, send = r, func(ctx context.Context, c *rawClient) error { return c.ExportTraces(ctx, r) }
+		} else {
+			records := make([]*logspb.LogRecord, 0, batch)
+			for len(records) < batch {
+				records = append(records, newFastLog(newLogContent(rnd, payloadSize)).record(now))
+			}
+			r := &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+				Resource:  resource,
+				ScopeLogs: []*logspb.ScopeLogs{{Scope: scope, LogRecords: records}},
+				SchemaUrl: res.SchemaURL(),
+			}}}
+			req, send = r, func(ctx context.Context, c *rawClient) error { return c.ExportLogs(ctx, r) }
+		}
+		out = append(out, benchRequest{size: proto.Size(req), send: send})
+	}
+	return out
+}
+
+// runBenchConfig sends the requests over a fresh client with the configuration's
+// transport for the benchmark's duration, recording the outcome in r
+func runBenchConfig(r *benchResult, endpoint *Endpoint, headers map[string]string, transport TransportOptions, opts BenchOptions, requests []benchRequest) error {
+	targets, err := transport.targets(endpoint)
+	if err != nil {
+		return err
+	}
+	obs := newExportObserver(opts.Signal, false)
+	raw, err := newRawClient(targets, headers, transport, obs)
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+
+	// The first request sets up the connection, and is not counted
+	ctx := context.Background()
+	requests[0].send(ctx, raw)
+	setupWire := obs.wireBytes.Load()
+
+	var stats probeStats
+	var bytes atomic.Int64
+	var firstError atomic.Pointer[error]
+	raw.time(&stats)
+	concurrency := opts.Concurrency
+	if concurrency == 0 {
+		concurrency = len(raw.shards) * fastInFlight
+	}
+
+	var next atomic.Int64
+	deadline := time.Now().Add(opts.Duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				req := requests[int(next.Add(1))%len(requests)]
+				if err := req.send(ctx, raw); err != nil {
+					firstError.CompareAndSwap(nil, &err)
+					continue
+				}
+				bytes.Add(int64(req.size))
+			}
+		}()
+	}
+	wg.Wait()
+	r.elapsed = time.Since(start)
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	r.exports, r.failed = stats.exports, stats.failed
+	r.items, r.bytes = stats.delivered.Load(), bytes.Load()
+	r.wireBytes = obs.wireBytes.Load() - setupWire
+	if len(stats.latencies) > 0 {
+		sorted := slices.Clone(stats.latencies)
+		slices.Sort(sorted)
+		r.p50, r.p99, r.max = percentile(sorted, 0.5), percentile(sorted, 0.99), sorted[len(sorted)-1]
+	}
+	if err := firstError.Load(); err != nil {
+		r.firstError = *err
+	}
+	return nil
+}
+
+// printBenchResults prints one row per configuration; throughput counts the items
+// and uncompressed OTLP bytes the endpoint accepted, and the wire rate every byte
+// written, compressed, including those of failed exports
+func printBenchResults(results []benchResult, items string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "ENDPOINT\tCOMPRESSION\tBATCH\t%s/S\tMB/S\tWIRE MB/S\tP50\tP99\tMAX\tERRORS\t\n", strings.ToUpper(items))
+	for _, r := range results {
+		if r.skipped != nil {
+			fmt.Fprintf(w, "%s\t%s\t%d\t-\t-\t-\t-\t-\t-\tskipped\t\n", r.endpoint, r.compression, r.batch)
+			continue
+		}
+		seconds := r.elapsed.Seconds()
+		errorRate := 0.0
+		if r.exports > 0 {
+			errorRate = float64(r.failed) / float64(r.exports) * 100
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.0f\t%.1f\t%.1f\t%s\t%s\t%s\t%.1f%%\t\n",
+			r.endpoint, r.compression, r.batch,
+			float64(r.items)/seconds, float64(r.bytes)/seconds/1e6, float64(r.wireBytes)/seconds/1e6,
+			r.p50.Round(10*time.Microsecond), r.p99.Round(10*time.Microsecond), r.max.Round(10*time.Microsecond),
+			errorRate)
+	}
+	w.Flush()
+}
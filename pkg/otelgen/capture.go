@@ -0,0 +1,89 @@
+package otelgen
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// sinkCapture writes every request the sink receives to a file, one line of JSON per
+// request, so the received dataset can be archived and compared with what was sent
+type sinkCapture struct {
+	path string
+
+	mu       sync.Mutex
+	file     *os.File
+	w        *bufio.Writer
+	requests int64
+	err      error // the first failure to write
+}
+
+// capturedRequest is one line of a capture: the request as OTLP/JSON, as the
+// collector's file exporter writes it, along with when and as what it was received
+type capturedRequest struct {
+	Received time.Time       `json:"received"`
+	Signal   string          `json:"signal"`
+	Request  json.RawMessage `json:"request"`
+}
+
+// newSinkCapture creates or truncates the capture file, or returns nil when nothing
+// is captured
+func newSinkCapture(path string) (*sinkCapture, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+	return &sinkCapture{path: path, file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// write captures a request received at the given time; it does nothing without a
+// capture
+func (c *sinkCapture) write(signal string, req proto.Message, received time.Time) {
+	if c == nil {
+		return
+	}
+	payload, err := marshalOTLPJSON(req)
+	if err == nil {
+		payload, err = json.Marshal(capturedRequest{Received: received, Signal: signal, Request: payload})
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		_, err = c.w.Write(append(payload, '\n'))
+	}
+	if err != nil {
+		if c.err == nil {
+			c.err = fmt.Errorf("failed to capture %s request: %w", signal, err)
+		}
+		return
+	}
+	c.requests++
+}
+
+// close flushes and closes the capture file, returning the first failure to write it
+func (c *sinkCapture) close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.w.Flush()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	if c.err == nil && err != nil {
+		c.err = fmt.Errorf("failed to write capture file: %w", err)
+	}
+	if c.err == nil {
+		fmt.Printf("Captured %d requests to %s\n", c.requests, c.path)
+	}
+	return c.err
+}
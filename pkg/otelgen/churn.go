@@ -0,0 +1,79 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// dueCounter tracks how many occurrences of a fixed-rate event are due
+type dueCounter struct {
+	mu        sync.Mutex
+	perSecond float64
+	start     time.Time
+	count     int
+}
+
+func newDueCounter(perSecond float64) *dueCounter {
+	return &dueCounter{perSecond: perSecond, start: time.Now()}
+}
+
+// due returns the range of occurrence indexes that became due since the last call
+func (c *dueCounter) due(now time.Time) (from, to int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from = c.count
+	c.count = int(now.Sub(c.start).Seconds() * c.perSecond)
+	return from, c.count
+}
+
+// Count returns the number of occurrences handed out so far
+func (c *dueCounter) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// churnRecorder wraps a metric set and records each new series on a dedicated counter,
+// so the active-series count grows at a controlled pace
+type churnRecorder struct {
+	metricRecorder
+	state   *dueCounter
+	counter metric.Int64Counter
+	rnd     *runRandom
+}
+
+func newChurnRecorder(meter metric.Meter, base metricRecorder, state *dueCounter, rnd *runRandom) (*churnRecorder, error) {
+	counter, err := meter.Int64Counter(
+		"otelgen.churn.requests",
+		metric.WithDescription("Requests labeled with continuously churning pod names and request IDs"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create churn counter: %w", err)
+	}
+
+	return &churnRecorder{
+		metricRecorder: base,
+		state:          state,
+		counter:        counter,
+		rnd:            rnd,
+	}, nil
+}
+
+// Record records the wrapped metric set, then introduces any series that are due
+func (r *churnRecorder) Record(ctx context.Context) {
+	r.metricRecorder.Record(ctx)
+
+	from, to := r.state.due(time.Now())
+	for i := from; i < to; i++ {
+		r.counter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("k8s.pod.name", fmt.Sprintf("otelgen-%s-%d", randomString(r.rnd, 5), i)),
+			attribute.String("request.id", fmt.Sprintf("req-%s", randomString(r.rnd, 16))),
+		))
+	}
+}
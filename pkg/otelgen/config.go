@@ -0,0 +1,256 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Config is what a generator sends, where, and how fast, for programs embedding
+// otelgen; NewConfig fills in the defaults of the command line.
+//
+// A generator's Run lasts until the duration passes or ctx is done, which ends the
+// run early with its usual flush and summary; a context done before the run starts
+// fails it, and one done while connecting cuts the connection short. The result
+// counts what the run sent, even when it fails
+type Config struct {
+	// Endpoint is the OTLP endpoint the telemetry is sent to; it may be nil when
+	// Transport.Writer receives the exports instead
+	Endpoint *Endpoint
+	// ServiceName is the service.name of the generated resource
+	ServiceName string
+	// Rate is the number of spans, log records, or metric updates per second, which
+	// may be fractional, e.g. 0.2 for one every 5 seconds
+	Rate float64
+	// Duration is how long the generator runs, unless the load replaces it; zero runs
+	// until ctx is done or the load's count is reached
+	Duration time.Duration
+	// PayloadSize, when positive, pads each span or log record to this many bytes
+	PayloadSize int64
+	// BatchSize is the most log records sent in one export
+	BatchSize int
+	// Headers are sent with every export
+	Headers map[string]string
+	// Verbose prints the exporters' connection details and activity
+	Verbose bool
+	// Transport sets how the exports reach the endpoint
+	Transport TransportOptions
+	// Load shapes how fast events are generated
+	Load LoadOptions
+	// Metrics shapes the generated metrics
+	Metrics MetricsOptions
+}
+
+// Option changes a Config
+type Option func(*Config)
+
+// NewConfig returns a config sending to the endpoint with the defaults of the command
+// line, changed by the options
+func NewConfig(endpoint *Endpoint, opts ...Option) Config {
+	cfg := Config{
+		Endpoint:    endpoint,
+		ServiceName: "otelgen",
+		Rate:        1,
+		Duration:    10 * time.Second,
+		BatchSize:   512,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithServiceName sets the service name
+func WithServiceName(name string) Option {
+	return func(c *Config) { c.ServiceName = name }
+}
+
+// WithRate sets the rate per second
+func WithRate(rate float64) Option {
+	return func(c *Config) { c.Rate = rate }
+}
+
+// WithDuration sets how long the generator runs
+func WithDuration(d time.Duration) Option {
+	return func(c *Config) { c.Duration = d }
+}
+
+// WithPayloadSize pads each span or log record to size bytes
+func WithPayloadSize(size int64) Option {
+	return func(c *Config) { c.PayloadSize = size }
+}
+
+// WithBatchSize sets the most log records sent in one export
+func WithBatchSize(n int) Option {
+	return func(c *Config) { c.BatchSize = n }
+}
+
+// WithHeaders sets the headers sent with every export
+func WithHeaders(headers map[string]string) Option {
+	return func(c *Config) { c.Headers = headers }
+}
+
+// WithVerbose prints the exporters' connection details and activity
+func WithVerbose(verbose bool) Option {
+	return func(c *Config) { c.Verbose = verbose }
+}
+
+// WithTransport sets how the exports reach the endpoint
+func WithTransport(transport TransportOptions) Option {
+	return func(c *Config) { c.Transport = transport }
+}
+
+// WithLoad sets how fast events are generated
+func WithLoad(load LoadOptions) Option {
+	return func(c *Config) { c.Load = load }
+}
+
+// WithMetricsOptions sets the shape of the generated metrics
+func WithMetricsOptions(opts MetricsOptions) Option {
+	return func(c *Config) { c.Metrics = opts }
+}
+
+// validate reports a config no generator can run
+func (c Config) validate() error {
+	// A payload writer receives the exports in place of an endpoint
+	if c.Endpoint == nil && c.Transport.Writer == nil {
+		return fmt.Errorf("config has no endpoint")
+	}
+	if c.Rate < 0 {
+		return fmt.Errorf("rate cannot be negative")
+	}
+	if c.Duration < 0 {
+		return fmt.Errorf("duration cannot be negative")
+	}
+	return nil
+}
+
+// load returns the config's load options ending the run once ctx is done, as well as
+// when their own Stop is closed
+func (c Config) load(ctx context.Context) LoadOptions {
+	load := c.Load
+	done := ctx.Done()
+	if done == nil {
+		return load
+	}
+	if load.Stop == nil {
+		load.Stop = done
+		return load
+	}
+	stop := make(chan struct{})
+	go func(own <-chan struct{}) {
+		select {
+		case <-own:
+		case <-done:
+		}
+		close(stop)
+	}(load.Stop)
+	load.Stop = stop
+	return load
+}
+
+// run runs generate as the generators' Run does, with a result for signal
+func (c Config) run(ctx context.Context, signal string, generate func(LoadOptions, *Result) error) (*Result, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := &Result{Signal: signal}
+	err := generate(c.load(ctx), result)
+	return result, err
+}
+
+// TraceGenerator sends spans as its config describes
+type TraceGenerator struct {
+	cfg Config
+}
+
+// NewTraceGenerator returns a generator of the config's spans
+func NewTraceGenerator(cfg Config) *TraceGenerator {
+	return &TraceGenerator{cfg: cfg}
+}
+
+// Run generates spans as the config describes, until the duration passes or ctx
+// is done
+func (g *TraceGenerator) Run(ctx context.Context) (*Result, error) {
+	c := g.cfg
+	return c.run(ctx, "traces", func(load LoadOptions, result *Result) error {
+		return generateTraces(ctx, c.Endpoint, c.ServiceName, c.Rate, c.Duration, c.PayloadSize, c.Headers, c.Verbose, c.Transport, load, result)
+	})
+}
+
+// LogGenerator sends log records as its config describes
+type LogGenerator struct {
+	cfg Config
+}
+
+// NewLogGenerator returns a generator of the config's log records
+func NewLogGenerator(cfg Config) *LogGenerator {
+	return &LogGenerator{cfg: cfg}
+}
+
+// Run generates log records as the config describes, until the duration passes or
+// ctx is done
+func (g *LogGenerator) Run(ctx context.Context) (*Result, error) {
+	c := g.cfg
+	return c.run(ctx, "logs", func(load LoadOptions, result *Result) error {
+		return generateLogs(ctx, c.Endpoint, c.ServiceName, c.Rate, c.Duration, c.PayloadSize, c.BatchSize, c.Headers, c.Verbose, c.Transport, load, result)
+	})
+}
+
+// MetricGenerator sends metrics as its config describes
+type MetricGenerator struct {
+	cfg Config
+}
+
+// NewMetricGenerator returns a generator of the config's metrics
+func NewMetricGenerator(cfg Config) *MetricGenerator {
+	return &MetricGenerator{cfg: cfg}
+}
+
+// Run generates metrics as the config describes, until the duration passes or ctx
+// is done
+func (g *MetricGenerator) Run(ctx context.Context) (*Result, error) {
+	c := g.cfg
+	return c.run(ctx, "metrics", func(load LoadOptions, result *Result) error {
+		return generateMetrics(ctx, c.Endpoint, c.ServiceName, c.Rate, c.Duration, c.PayloadSize, c.Headers, c.Verbose, c.Transport, load, c.Metrics, result)
+	})
+}
+
+// GenerateTraces generates trace data and sends it to the specified OTLP endpoint,
+// with the default transport and load apart from insecureSkip
+//
+// Deprecated: use NewTraceGenerator.
+func GenerateTraces(endpoint *Endpoint, serviceName string, rate int, durationStr string, payloadSize int64, headers map[string]string, verbose bool, insecureSkip bool) error {
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	return generateTraces(context.Background(), endpoint, serviceName, float64(rate), duration, payloadSize, headers, verbose, TransportOptions{InsecureSkipVerify: insecureSkip}, LoadOptions{}, nil)
+}
+
+// GenerateLogs generates log data and sends it to the specified OTLP endpoint,
+// with the default transport and load apart from insecureSkip
+//
+// Deprecated: use NewLogGenerator.
+func GenerateLogs(endpoint *Endpoint, serviceName string, rate int, durationStr string, payloadSize int64, batchSize int, headers map[string]string, verbose bool, insecureSkip bool) error {
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	return generateLogs(context.Background(), endpoint, serviceName, float64(rate), duration, payloadSize, batchSize, headers, verbose, TransportOptions{InsecureSkipVerify: insecureSkip}, LoadOptions{}, nil)
+}
+
+// GenerateMetrics generates the default metric set and sends it to the specified OTLP
+// endpoint, with the default transport and load apart from insecureSkip
+//
+// Deprecated: use NewMetricGenerator.
+func GenerateMetrics(endpoint *Endpoint, serviceName string, rate int, durationStr string, payloadSize int64, headers map[string]string, verbose bool, insecureSkip bool) error {
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	return generateMetrics(context.Background(), endpoint, serviceName, float64(rate), duration, payloadSize, headers, verbose, TransportOptions{InsecureSkipVerify: insecureSkip}, LoadOptions{}, MetricsOptions{}, nil)
+}
@@ -0,0 +1,104 @@
+package otelgen
+
+import (
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// schemaConflict re-describes one of the default metrics with a different type or unit
+type schemaConflict struct {
+	kind  string
+	build func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric
+}
+
+// schemaConflicts lists the conflicting definitions; the SDK reports otelgen.requests
+// as a unitless int sum, otelgen.duration as a histogram in ms, and otelgen.cpu_usage
+// as a unitless gauge
+var schemaConflicts = []schemaConflict{
+	{"requests_as_gauge", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		return gaugeMetric("otelgen.requests", attrs, ts, rnd.Float64()*100)
+	}},
+	{"requests_as_histogram", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		sum := 42.0
+		return &metricspb.Metric{
+			Name: "otelgen.requests",
+			Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricspb.HistogramDataPoint{{
+					Attributes:        attrs,
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					Count:             2,
+					Sum:               &sum,
+					ExplicitBounds:    []float64{10},
+					BucketCounts:      []uint64{0, 2},
+				}},
+			}},
+		}
+	}},
+	{"requests_unit_changed", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		m := monotonicSumMetric("otelgen.requests", attrs, start, ts, float64(rnd.Intn(1000)))
+		m.Unit = "{request}"
+		return m
+	}},
+	{"requests_non_monotonic", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		m := monotonicSumMetric("otelgen.requests", attrs, start, ts, float64(rnd.Intn(1000)))
+		m.GetSum().IsMonotonic = false
+		return m
+	}},
+	{"requests_delta", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		m := monotonicSumMetric("otelgen.requests", attrs, start, ts, float64(rnd.Intn(10)))
+		m.GetSum().AggregationTemporality = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		return m
+	}},
+	{"duration_as_sum", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		m := monotonicSumMetric("otelgen.duration", attrs, start, ts, rnd.Float64()*1000)
+		m.Unit = "ms"
+		return m
+	}},
+	{"duration_unit_changed", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		m := gaugeMetric("otelgen.duration", attrs, ts, rnd.Float64())
+		m.Unit = "s"
+		return m
+	}},
+	{"cpu_usage_as_counter", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		return monotonicSumMetric("otelgen.cpu_usage", attrs, start, ts, rnd.Float64()*100)
+	}},
+	{"cpu_usage_unit_changed", func(rnd *runRandom, attrs []*commonpb.KeyValue, start, ts uint64) *metricspb.Metric {
+		m := gaugeMetric("otelgen.cpu_usage", attrs, ts, rnd.Float64())
+		m.Unit = "1"
+		return m
+	}},
+}
+
+// conflictInjector occasionally re-emits an existing metric name with a different
+// type, unit, monotonicity, or temporality
+type conflictInjector struct {
+	probability float64
+	start       time.Time
+	injected    int
+	rnd         *runRandom
+}
+
+func newConflictInjector(probability float64, rnd *runRandom) *conflictInjector {
+	return &conflictInjector{probability: probability, start: time.Now(), rnd: rnd}
+}
+
+// Metrics returns one conflicting metric with the configured probability per tick
+func (c *conflictInjector) Metrics(now time.Time) []*metricspb.Metric {
+	if c.rnd.Float64() >= c.probability {
+		return nil
+	}
+
+	conflict := schemaConflicts[c.rnd.Intn(len(schemaConflicts))]
+	attrs := []*commonpb.KeyValue{stringAttr("conflict.kind", conflict.kind)}
+	c.injected++
+	return []*metricspb.Metric{conflict.build(c.rnd, attrs, unixNano(c.start), unixNano(now))}
+}
+
+// Injected returns the number of conflicting metrics produced so far
+func (c *conflictInjector) Injected() int {
+	return c.injected
+}
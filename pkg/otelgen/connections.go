@@ -0,0 +1,144 @@
+package otelgen
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// connections returns the number of independent exporter connections
+func (t TransportOptions) connections() int {
+	if t.Connections > 1 {
+		return t.Connections
+	}
+	return 1
+}
+
+// roundRobin picks the next of n shards in turn
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (r *roundRobin) pick(n int) int {
+	return int((r.next.Add(1) - 1) % uint64(n))
+}
+
+// newSpanProcessor batches spans for each exporter, spreading spans across the batchers
+// so every connection exports its own batches concurrently
+func newSpanProcessor(exporters []sdktrace.SpanExporter, bal balancer, opts ...sdktrace.BatchSpanProcessorOption) sdktrace.SpanProcessor {
+	if len(exporters) == 1 {
+		return sdktrace.NewBatchSpanProcessor(exporters[0], opts...)
+	}
+	p := &shardedSpanProcessor{bal: bal}
+	for _, e := range exporters {
+		p.shards = append(p.shards, sdktrace.NewBatchSpanProcessor(e, opts...))
+	}
+	return p
+}
+
+type shardedSpanProcessor struct {
+	shards []sdktrace.SpanProcessor
+	bal    balancer
+}
+
+func (p *shardedSpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *shardedSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.shards[p.bal.pick(len(p.shards))].OnEnd(s)
+}
+
+func (p *shardedSpanProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, s := range p.shards {
+		errs = append(errs, s.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *shardedSpanProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range p.shards {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// newLogProcessor batches records for each exporter, spreading records across the
+// batchers so every connection exports its own batches concurrently
+func newLogProcessor(exporters []sdklog.Exporter, bal balancer, opts ...sdklog.BatchProcessorOption) sdklog.Processor {
+	if len(exporters) == 1 {
+		return sdklog.NewBatchProcessor(exporters[0], opts...)
+	}
+	p := &shardedLogProcessor{bal: bal}
+	for _, e := range exporters {
+		p.shards = append(p.shards, sdklog.NewBatchProcessor(e, opts...))
+	}
+	return p
+}
+
+type shardedLogProcessor struct {
+	shards []sdklog.Processor
+	bal    balancer
+}
+
+func (p *shardedLogProcessor) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	return p.shards[p.bal.pick(len(p.shards))].OnEmit(ctx, record)
+}
+
+func (p *shardedLogProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, s := range p.shards {
+		errs = append(errs, s.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *shardedLogProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range p.shards {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// newShardedMetricExporter spreads metric exports across the exporters; metrics are
+// aggregated before export, so each export goes out whole on one connection
+func newShardedMetricExporter(exporters []sdkmetric.Exporter, bal balancer) sdkmetric.Exporter {
+	if len(exporters) == 1 {
+		return exporters[0]
+	}
+	return &shardedMetricExporter{Exporter: exporters[0], shards: exporters, bal: bal}
+}
+
+// shardedMetricExporter takes its temporality and aggregation from the first exporter,
+// since all of them are configured alike
+type shardedMetricExporter struct {
+	sdkmetric.Exporter
+	shards []sdkmetric.Exporter
+	bal    balancer
+}
+
+func (e *shardedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.shards[e.bal.pick(len(e.shards))].Export(ctx, rm)
+}
+
+func (e *shardedMetricExporter) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, s := range e.shards {
+		errs = append(errs, s.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (e *shardedMetricExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range e.shards {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
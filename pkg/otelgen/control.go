@@ -0,0 +1,182 @@
+package otelgen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateControl changes the rate of a running generator: the target rate, or every
+// phase of a shaped one, is scaled by a factor that starts at one, unless a fixed
+// set-point replaces it
+type RateControl struct {
+	mu       sync.Mutex
+	scale    float64
+	setPoint float64
+	fixed    bool
+	// base is the unscaled rate the limiter last asked for
+	base    float64
+	started bool
+}
+
+// NewRateControl creates a rate control that leaves the rate unchanged until told
+// otherwise
+func NewRateControl() *RateControl {
+	return &RateControl{scale: 1}
+}
+
+// control makes the phases of the profile follow the control
+func (c *RateControl) control(p *loadProfile) {
+	for _, ph := range p.phases {
+		rate := ph.rate
+		ph.rate = func(elapsed time.Duration) float64 {
+			return c.apply(rate(elapsed))
+		}
+	}
+}
+
+// apply returns the rate to offer in place of the profile's rate
+func (c *RateControl) apply(rate float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.base, c.started = rate, true
+	if c.fixed {
+		return c.setPoint
+	}
+	return rate * c.scale
+}
+
+// Rate returns the rate currently offered, in events per second
+func (c *RateControl) Rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate()
+}
+
+func (c *RateControl) rate() float64 {
+	if c.fixed {
+		return c.setPoint
+	}
+	return c.base * c.scale
+}
+
+// Scale multiplies the rate currently offered by factor and returns the new rate
+func (c *RateControl) Scale(factor float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fixed {
+		c.setPoint *= factor
+	} else {
+		c.scale *= factor
+	}
+	return c.rate()
+}
+
+// Set replaces the rate with a fixed set-point, in events per second, until Reset
+func (c *RateControl) Set(rate float64) error {
+	if rate < 0 {
+		return fmt.Errorf("rate cannot be negative")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setPoint, c.fixed = rate, true
+	return nil
+}
+
+// Reset returns to the rate the run was started with and returns it
+func (c *RateControl) Reset() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scale, c.fixed = 1, false
+	return c.rate()
+}
+
+// serve accepts rate changes on a Unix socket at path, one command per line, until
+// the returned function is called
+func (c *RateControl) serve(path string) (func(), error) {
+	// A socket left behind by an earlier run would make the listen fail
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open control socket: %w", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					fmt.Printf("Error accepting control connection: %v\n", err)
+				}
+				return
+			}
+			go c.handle(conn)
+		}
+	}()
+	return func() { ln.Close() }, nil
+}
+
+// handle answers the commands of one control connection
+func (c *RateControl) handle(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		reply, err := c.command(line)
+		if err != nil {
+			fmt.Fprintf(conn, "error: %v\n", err)
+			continue
+		}
+		fmt.Fprintln(conn, reply)
+	}
+}
+
+// command runs one control command: rate, set <rate>, double, halve, scale <factor>,
+// or reset
+func (c *RateControl) command(line string) (string, error) {
+	fields := strings.Fields(line)
+	var rate float64
+	switch {
+	case fields[0] == "rate" && len(fields) == 1:
+		c.mu.Lock()
+		started := c.started
+		c.mu.Unlock()
+		if !started {
+			return "", fmt.Errorf("the run has not started yet")
+		}
+		return fmt.Sprintf("rate %g/s", c.Rate()), nil
+	case fields[0] == "set" && len(fields) == 2:
+		r, err := ParsePerSecond(fields[1])
+		if err != nil {
+			return "", err
+		}
+		c.Set(r)
+		rate = r
+	case fields[0] == "double" && len(fields) == 1:
+		rate = c.Scale(2)
+	case fields[0] == "halve" && len(fields) == 1:
+		rate = c.Scale(0.5)
+	case fields[0] == "scale" && len(fields) == 2:
+		factor, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || factor < 0 {
+			return "", fmt.Errorf("invalid factor %q", fields[1])
+		}
+		rate = c.Scale(factor)
+	case fields[0] == "reset" && len(fields) == 1:
+		rate = c.Reset()
+	default:
+		return "", fmt.Errorf("unknown command %q (supported: rate, set <rate>, double, halve, scale <factor>, reset)", line)
+	}
+	fmt.Printf("Control socket: %s, rate now %g/s\n", line, rate)
+	return fmt.Sprintf("rate %g/s", rate), nil
+}
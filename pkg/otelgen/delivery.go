@@ -0,0 +1,198 @@
+package otelgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// deliveryInterval is the time between checks of the items delivered
+	deliveryInterval = time.Second
+	// defaultDeliveryWindow is how long a run may take to confirm its delivery
+	defaultDeliveryWindow = 2 * time.Minute
+)
+
+// DeliveryOptions turns the run into an end-to-end smoke test: it ends as soon as
+// enough items are confirmed delivered, and fails unless that happens in time
+type DeliveryOptions struct {
+	// Expected is the spans, data points, or log records that must be delivered
+	Expected int64
+	// Within is how long they may take to be delivered, and the longest the run lasts
+	// (default 2m)
+	Within time.Duration
+	// Sink, when set, is the URL of an otelgen sink's HTTP receiver, whose count of
+	// the items it received confirms delivery; otherwise the items of the exports the
+	// endpoint accepted, less those it rejected in partial successes, do
+	Sink string
+}
+
+// window returns how long delivery may take
+func (o *DeliveryOptions) window() time.Duration {
+	if o.Within > 0 {
+		return o.Within
+	}
+	return defaultDeliveryWindow
+}
+
+// deliveryCheck counts the items confirmed delivered since the run started
+type deliveryCheck struct {
+	opts   DeliveryOptions
+	signal string
+	// stats counts the items of the exports the endpoint accepted, and observers the
+	// items it rejected, when the sink doesn't confirm delivery
+	stats     targetStats
+	observers []*exportObserver
+
+	client *http.Client
+	// received is the sink's count of the signal's items, and baseline that count as
+	// the run started
+	received atomic.Int64
+	baseline int64
+
+	start     time.Time
+	ticker    *time.Ticker
+	done      chan struct{}
+	confirmed time.Duration
+}
+
+// newDeliveryCheck returns the delivery check of the run, or nil when it has none; obs
+// sees the partial successes of the run's exports
+func (l LoadOptions) newDeliveryCheck(signal string, obs *exportObserver) (*deliveryCheck, error) {
+	if l.Delivery == nil {
+		return nil, nil
+	}
+	if l.Delivery.Expected <= 0 {
+		return nil, fmt.Errorf("expected deliveries must be positive")
+	}
+	if l.Delivery.Within < 0 {
+		return nil, fmt.Errorf("delivery window must be positive")
+	}
+	d := &deliveryCheck{opts: *l.Delivery, signal: signal, observers: []*exportObserver{obs}, done: make(chan struct{})}
+	if d.opts.Sink != "" {
+		d.opts.Sink = strings.TrimSuffix(d.opts.Sink, "/")
+		d.client = &http.Client{Timeout: deliveryInterval}
+		baseline, err := d.query()
+		if err != nil {
+			return nil, err
+		}
+		d.baseline = baseline
+		d.received.Store(baseline)
+	}
+	return d, nil
+}
+
+// query returns the sink's count of the items of the signal it received
+func (d *deliveryCheck) query() (int64, error) {
+	resp, err := d.client.Get(d.opts.Sink + sinkReceivedPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query the sink: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to query the sink: %s", resp.Status)
+	}
+	var counts map[string]sinkCount
+	if err := json.NewDecoder(resp.Body).Decode(&counts); err != nil {
+		return 0, fmt.Errorf("failed to decode the sink's counts: %w", err)
+	}
+	return counts[d.signal].Items, nil
+}
+
+// watch also counts the exports of a raw client; it does nothing without a check
+func (d *deliveryCheck) watch(raw *rawClient) {
+	if d == nil || raw == nil {
+		return
+	}
+	raw.count(&d.stats)
+	if !slices.Contains(d.observers, raw.obs) {
+		d.observers = append(d.observers, raw.obs)
+	}
+}
+
+// begin starts checking as generation starts; the sink, if any, is polled in the
+// background so a slow sink doesn't hold up generation
+func (d *deliveryCheck) begin() {
+	if d == nil {
+		return
+	}
+	d.start = time.Now()
+	d.ticker = time.NewTicker(deliveryInterval)
+	if d.client == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(deliveryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := d.query(); err == nil {
+					d.received.Store(n)
+				}
+			case <-d.done:
+				return
+			}
+		}
+	}()
+}
+
+// tick delivers the times to check delivery; it never fires without a check
+func (d *deliveryCheck) tick() <-chan time.Time {
+	if d == nil {
+		return nil
+	}
+	return d.ticker.C
+}
+
+// delivered returns the items confirmed delivered so far
+func (d *deliveryCheck) delivered() int64 {
+	if d.client != nil {
+		return d.received.Load() - d.baseline
+	}
+	delivered := d.stats.items.Load()
+	for _, obs := range d.observers {
+		delivered -= obs.runRejected.Load()
+	}
+	return delivered
+}
+
+// check reports whether the expected items have been delivered, so the run can end
+func (d *deliveryCheck) check() bool {
+	if d.confirmed > 0 {
+		return true
+	}
+	if d.delivered() < d.opts.Expected {
+		return false
+	}
+	d.confirmed = time.Since(d.start)
+	return true
+}
+
+// finish stops checking and reports whether the expected items were delivered in
+// time, returning an error when they weren't
+func (d *deliveryCheck) finish() error {
+	if d == nil {
+		return nil
+	}
+	d.ticker.Stop()
+	close(d.done)
+	delivered := d.delivered()
+	by := "accepted by the endpoint"
+	if d.client != nil {
+		by = "received by the sink"
+	}
+	if d.confirmed == 0 && delivered >= d.opts.Expected {
+		d.confirmed = time.Since(d.start)
+	}
+	if d.confirmed == 0 {
+		fmt.Printf("FAIL delivery: %d of %d %s %s within %s\n", delivered, d.opts.Expected, signalItems[d.signal], by, d.opts.window())
+		return fmt.Errorf("only %d of %d %s were delivered within %s", delivered, d.opts.Expected, signalItems[d.signal], d.opts.window())
+	}
+	fmt.Printf("PASS delivery: %d of %d %s %s after %s\n", delivered, d.opts.Expected, signalItems[d.signal], by, d.confirmed.Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,428 @@
+package otelgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultDiffExamples is how many items of each kind of difference are listed
+const defaultDiffExamples = 5
+
+// DiffOptions configures a comparison of two OTLP/JSON datasets
+type DiffOptions struct {
+	// Sent and Received are files of OTLP/JSON export requests, one after another, as
+	// --exporter stdout, the collector's file exporter, or a sink capture writes them
+	Sent, Received string
+	// IgnoreAttributes are resource and item attributes left out of the comparison,
+	// for those a pipeline is expected to add, change, or remove
+	IgnoreAttributes []string
+	// Strict also counts the resource attributes added in transit as mutations, which
+	// are otherwise taken as expected enrichment
+	Strict bool
+	// Examples is how many items of each kind of difference are listed (default 5)
+	Examples int
+}
+
+// diffItem is one span, log record, or data point, flattened into its fields
+type diffItem struct {
+	// key identifies the item: trace and span IDs for a span, the run and sequence
+	// number or the time and body for a log record, and the metric, attributes, and
+	// time for a data point; items sharing a key are told apart by their fields
+	key   string
+	label string
+	// fields maps the path of every field, including resource.<attribute> and
+	// scope.<field>, to its value as JSON
+	fields map[string]string
+}
+
+// diffSignal is the comparison of one signal's items
+type diffSignal struct {
+	sent, received, identical int
+	missing, extra            []*diffItem
+	// duplicates are the extra items sharing a key with a sent item
+	duplicates int
+	mutated    []diffMutation
+	// enriched counts the items each resource attribute was added to in transit
+	enriched map[string]int
+}
+
+// diffMutation is a received item that differs from the sent item it matches
+type diffMutation struct {
+	item    *diffItem
+	changes []string
+}
+
+// Diff compares the spans, log records, and data points sent with those received,
+// printing those missing, extra, and mutated, and returns an error when they differ
+func Diff(opts DiffOptions) error {
+	if opts.Examples <= 0 {
+		opts.Examples = defaultDiffExamples
+	}
+	sent, err := readDiffItems(opts.Sent)
+	if err != nil {
+		return err
+	}
+	received, err := readDiffItems(opts.Received)
+	if err != nil {
+		return err
+	}
+	ignored := map[string]bool{}
+	for _, key := range opts.IgnoreAttributes {
+		ignored[key] = true
+	}
+
+	differences := 0
+	for _, signal := range []string{"traces", "logs", "metrics"} {
+		if len(sent[signal]) == 0 && len(received[signal]) == 0 {
+			continue
+		}
+		d := compareItems(sent[signal], received[signal], ignored, opts.Strict)
+		differences += len(d.missing) + len(d.extra) + len(d.mutated)
+		d.print(signal, opts.Examples)
+	}
+	if differences > 0 {
+		return fmt.Errorf("%d items differ", differences)
+	}
+	fmt.Println("No differences")
+	return nil
+}
+
+// compareItems matches the received items to the sent ones by key, pairing identical
+// items first, so items sharing a key only count as mutated when nothing matches them
+func compareItems(sent, received []*diffItem, ignored map[string]bool, strict bool) *diffSignal {
+	d := &diffSignal{sent: len(sent), received: len(received), enriched: map[string]int{}}
+	byKey := map[string][]*diffItem{}
+	for _, item := range received {
+		byKey[item.key] = append(byKey[item.key], item)
+	}
+
+	var unmatched []*diffItem
+	for _, item := range sent {
+		candidates := byKey[item.key]
+		i := slices.IndexFunc(candidates, func(r *diffItem) bool {
+			return len(diffFields(item, r, ignored, strict, nil)) == 0
+		})
+		if i < 0 {
+			unmatched = append(unmatched, item)
+			continue
+		}
+		diffFields(item, candidates[i], ignored, strict, d.enriched)
+		byKey[item.key] = slices.Delete(candidates, i, i+1)
+		d.identical++
+	}
+	for _, item := range unmatched {
+		candidates := byKey[item.key]
+		if len(candidates) == 0 {
+			d.missing = append(d.missing, item)
+			continue
+		}
+		changes := diffFields(item, candidates[0], ignored, strict, d.enriched)
+		d.mutated = append(d.mutated, diffMutation{item: candidates[0], changes: changes})
+		byKey[item.key] = candidates[1:]
+	}
+
+	sentKeys := map[string]bool{}
+	for _, item := range sent {
+		sentKeys[item.key] = true
+	}
+	for _, item := range received {
+		if slices.Contains(byKey[item.key], item) {
+			d.extra = append(d.extra, item)
+			if sentKeys[item.key] {
+				d.duplicates++
+			}
+		}
+	}
+	return d
+}
+
+// diffFields returns the changes from a sent item to a received one, leaving out the
+// ignored attributes and, unless strict, counting the resource attributes added in
+// enriched, when it is set
+func diffFields(sent, received *diffItem, ignored map[string]bool, strict bool, enriched map[string]int) []string {
+	var changes []string
+	for _, path := range slices.Sorted(maps.Keys(sent.fields)) {
+		if ignoredField(path, ignored) {
+			continue
+		}
+		value, ok := received.fields[path]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s removed", path))
+		case value != sent.fields[path]:
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", path, sent.fields[path], value))
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(received.fields)) {
+		if _, ok := sent.fields[path]; ok || ignoredField(path, ignored) {
+			continue
+		}
+		if attr, ok := strings.CutPrefix(path, "resource."); ok && !strict {
+			if enriched != nil {
+				enriched[attr]++
+			}
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s added: %s", path, received.fields[path]))
+	}
+	return changes
+}
+
+// ignoredField reports whether a field path is an ignored attribute
+func ignoredField(path string, ignored map[string]bool) bool {
+	if len(ignored) == 0 {
+		return false
+	}
+	if attr, ok := strings.CutPrefix(path, "resource."); ok {
+		return ignored[attr]
+	}
+	_, attr, ok := strings.Cut(path, "attributes.")
+	return ok && ignored[attr]
+}
+
+// print prints the comparison of a signal, with examples of each difference
+func (d *diffSignal) print(signal string, examples int) {
+	items := signalItems[signal]
+	fmt.Printf("%s: %d sent, %d received: %d identical, %d mutated, %d missing, %d extra (%d duplicates)\n",
+		strings.ToUpper(items[:1])+items[1:], d.sent, d.received, d.identical, len(d.mutated), len(d.missing), len(d.extra), d.duplicates)
+	for i, item := range d.missing[:min(examples, len(d.missing))] {
+		if i == 0 {
+			fmt.Println("  Missing:")
+		}
+		fmt.Printf("    %s\n", item.label)
+	}
+	for i, item := range d.extra[:min(examples, len(d.extra))] {
+		if i == 0 {
+			fmt.Println("  Extra:")
+		}
+		fmt.Printf("    %s\n", item.label)
+	}
+	for i, m := range d.mutated[:min(examples, len(d.mutated))] {
+		if i == 0 {
+			fmt.Println("  Mutated:")
+		}
+		fmt.Printf("    %s\n", m.item.label)
+		for _, change := range m.changes {
+			fmt.Printf("      %s\n", change)
+		}
+	}
+	if len(d.enriched) > 0 {
+		var added []string
+		for _, attr := range slices.Sorted(maps.Keys(d.enriched)) {
+			added = append(added, fmt.Sprintf("%s (%d)", attr, d.enriched[attr]))
+		}
+		fmt.Printf("  Resource attributes added in transit, ignored: %s\n", strings.Join(added, ", "))
+	}
+}
+
+// readDiffItems reads the items of every export request in an OTLP/JSON file, by
+// signal; a sink capture's lines hold the request alongside when it was received
+func readDiffItems(path string) (map[string][]*diffItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	// --exporter stdout prints otelgen's own lines among the requests; JSON never
+	// starts a line with a capital letter
+	var kept [][]byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 && trimmed[0] >= 'A' && trimmed[0] <= 'Z' {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	items := map[string][]*diffItem{}
+	dec := json.NewDecoder(bytes.NewReader(bytes.Join(kept, nil)))
+	dec.UseNumber()
+	for n := 1; ; n++ {
+		var doc map[string]any
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return items, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read request %d of %s: %w", n, path, err)
+		}
+		if req, ok := doc["request"].(map[string]any); ok {
+			doc = req
+		}
+		for _, rs := range objects(doc["resourceSpans"]) {
+			resource := flattenResource(rs)
+			for _, ss := range objects(rs["scopeSpans"]) {
+				scope := flattenScope(ss)
+				for _, span := range objects(ss["spans"]) {
+					items["traces"] = append(items["traces"], spanItem(span, resource, scope))
+				}
+			}
+		}
+		for _, rl := range objects(doc["resourceLogs"]) {
+			resource := flattenResource(rl)
+			for _, sl := range objects(rl["scopeLogs"]) {
+				scope := flattenScope(sl)
+				for _, record := range objects(sl["logRecords"]) {
+					items["logs"] = append(items["logs"], logItem(record, resource, scope))
+				}
+			}
+		}
+		for _, rm := range objects(doc["resourceMetrics"]) {
+			resource := flattenResource(rm)
+			for _, sm := range objects(rm["scopeMetrics"]) {
+				scope := flattenScope(sm)
+				for _, metric := range objects(sm["metrics"]) {
+					items["metrics"] = append(items["metrics"], dataPointItems(metric, resource, scope)...)
+				}
+			}
+		}
+	}
+}
+
+// spanItem identifies a span by its trace and span IDs
+func spanItem(span map[string]any, resource, scope map[string]string) *diffItem {
+	item := newDiffItem(span, resource, scope)
+	item.key = fmt.Sprint(span["traceId"], "/", span["spanId"])
+	item.label = fmt.Sprintf("span %s %s", item.key, item.fields["name"])
+	return item
+}
+
+// logItem identifies a log record by the run and sequence number otelgen --sequence
+// gave it, or else by its time and body
+func logItem(record map[string]any, resource, scope map[string]string) *diffItem {
+	item := newDiffItem(record, resource, scope)
+	if seq, ok := item.fields["attributes."+SequenceAttribute]; ok {
+		item.key = resource[RunIDAttribute] + "/" + seq
+	} else {
+		item.key = fmt.Sprint(record["timeUnixNano"], "/", item.fields["body.stringValue"])
+	}
+	value, _ := record["body"].(map[string]any)
+	body, _ := value["stringValue"].(string)
+	if len(body) > 60 {
+		body = body[:57] + "..."
+	}
+	item.label = fmt.Sprintf("log record %s %s", record["timeUnixNano"], body)
+	return item
+}
+
+// dataPointItems returns the data points of a metric, each identified by the metric,
+// its attributes, and its time; the metric's fields are kept as metric.<field>
+func dataPointItems(metric map[string]any, resource, scope map[string]string) []*diffItem {
+	var items []*diffItem
+	for _, kind := range []string{"gauge", "sum", "histogram", "exponentialHistogram", "summary"} {
+		data, ok := metric[kind].(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, dp := range objects(data["dataPoints"]) {
+			item := newDiffItem(dp, resource, scope)
+			item.fields["metric.type"] = strconv.Quote(kind)
+			for field, value := range metric {
+				if field != kind {
+					flattenJSON("metric."+field, value, item.fields)
+				}
+			}
+			for field, value := range data {
+				if field != "dataPoints" {
+					flattenJSON("metric."+field, value, item.fields)
+				}
+			}
+			var attrs []string
+			for path, value := range item.fields {
+				if strings.HasPrefix(path, "attributes.") {
+					attrs = append(attrs, path+"="+value)
+				}
+			}
+			slices.Sort(attrs)
+			item.key = fmt.Sprint(metric["name"], "{", strings.Join(attrs, ","), "}/", dp["timeUnixNano"])
+			item.label = fmt.Sprintf("data point %s %s", metric["name"], dp["timeUnixNano"])
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newDiffItem flattens an item's fields along with those of its resource and scope
+func newDiffItem(fields map[string]any, resource, scope map[string]string) *diffItem {
+	item := &diffItem{fields: map[string]string{}}
+	flattenJSON("", fields, item.fields)
+	for path, value := range resource {
+		item.fields["resource."+path] = value
+	}
+	for path, value := range scope {
+		item.fields["scope."+path] = value
+	}
+	return item
+}
+
+// flattenResource flattens the attributes of a request's resource
+func flattenResource(container map[string]any) map[string]string {
+	fields := map[string]string{}
+	if resource, ok := container["resource"].(map[string]any); ok {
+		flattenAttributes("", resource["attributes"], fields)
+	}
+	return fields
+}
+
+// flattenScope flattens a request's instrumentation scope
+func flattenScope(container map[string]any) map[string]string {
+	fields := map[string]string{}
+	if scope, ok := container["scope"].(map[string]any); ok {
+		flattenJSON("", scope, fields)
+	}
+	return fields
+}
+
+// flattenJSON adds the path of every value in a decoded JSON document to fields,
+// joining object fields with dots and array indexes in brackets; attribute lists are
+// keyed by the attributes' keys, so their order doesn't matter
+func flattenJSON(prefix string, v any, fields map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			if k == "attributes" {
+				flattenAttributes(path+".", field, fields)
+				continue
+			}
+			flattenJSON(path, field, fields)
+		}
+	case []any:
+		for i, item := range v {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), item, fields)
+		}
+	default:
+		b, _ := json.Marshal(v)
+		fields[prefix] = string(b)
+	}
+}
+
+// flattenAttributes adds the value of each of a list of attributes to fields, as
+// JSON under the prefix followed by its key
+func flattenAttributes(prefix string, attrs any, fields map[string]string) {
+	for _, kv := range objects(attrs) {
+		key, _ := kv["key"].(string)
+		b, _ := json.Marshal(kv["value"])
+		fields[prefix+key] = string(b)
+	}
+}
+
+// objects returns the objects in a decoded JSON array
+func objects(v any) []map[string]any {
+	list, _ := v.([]any)
+	out := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if obj, ok := item.(map[string]any); ok {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
@@ -0,0 +1,430 @@
+package otelgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// coordinatorStartDelay is how far ahead of sending the start the coordinator
+	// schedules it, so every worker begins at the same moment
+	coordinatorStartDelay = 2 * time.Second
+	// workerReportInterval is how often a worker reports its progress
+	workerReportInterval = time.Second
+	// joinTimeout is how long a worker keeps trying to reach the coordinator, so
+	// workers can be started before it
+	joinTimeout = time.Minute
+)
+
+// Progress counts a run's events and exports while it runs, warmup included, so they
+// can be reported elsewhere before the run ends
+type Progress struct {
+	events atomic.Int64
+	stats  targetStats
+}
+
+// Snapshot returns the events generated and the exports sent and failed so far
+func (p *Progress) Snapshot() (events, exports, failed int64) {
+	return p.events.Load(), p.stats.exports.Load(), p.stats.failed.Load()
+}
+
+// add counts n events; it does nothing without progress
+func (p *Progress) add(n int) {
+	if p != nil {
+		p.events.Add(int64(n))
+	}
+}
+
+// written sets the exports to the packets a client has written so far, for signals
+// that count their own; it does nothing without progress
+func (p *Progress) written(packets, errors int) {
+	if p != nil {
+		p.stats.exports.Store(int64(packets))
+		p.stats.failed.Store(int64(errors))
+	}
+}
+
+// endOnStop fires the run's timer as soon as Stop is closed, so the run ends early
+// with its usual summary; the returned function releases it
+func (l LoadOptions) endOnStop(timer *time.Timer) func() {
+	if l.Stop == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-l.Stop:
+			timer.Reset(0)
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// CoordinatorOptions configures a coordinator
+type CoordinatorOptions struct {
+	// Listen is the address the workers join
+	Listen string
+	// Workers is how many workers to wait for before starting them together
+	Workers int
+	// Interval is the time between aggregated progress reports
+	Interval time.Duration
+	// Items names the events the workers generate, e.g. "traces"
+	Items string
+	// Stop, when closed, stops every worker
+	Stop <-chan struct{}
+	// Verbose prints every worker report
+	Verbose bool
+}
+
+// coordMessage is one line of the coordinator protocol, JSON over TCP: a worker sends
+// join, then stats until done; the coordinator sends start, then stop to end the run
+// early
+type coordMessage struct {
+	Type    string    `json:"type"`
+	Name    string    `json:"name,omitempty"`
+	Args    []string  `json:"args,omitempty"`
+	At      time.Time `json:"at"`
+	Events  int64     `json:"events,omitempty"`
+	Exports int64     `json:"exports,omitempty"`
+	Failed  int64     `json:"failed,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// coordWorker is a worker that joined the coordinator, with its latest report
+type coordWorker struct {
+	name string
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+
+	latest coordMessage
+	done   bool
+	err    string
+}
+
+// workerReport is a message from a worker, or the loss of its connection
+type workerReport struct {
+	worker *coordWorker
+	msg    coordMessage
+}
+
+// Coordinate waits for the workers to join, starts them together with the arguments
+// assign returns for each, prints their aggregated progress, and stops them all when
+// one fails or Stop is closed. It returns once every worker is done.
+func Coordinate(opts CoordinatorOptions, assign func(worker, workers int) []string) error {
+	if opts.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+
+	ln, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", opts.Listen, err)
+	}
+	defer ln.Close()
+	fmt.Printf("Waiting for %d workers on %s\n", opts.Workers, ln.Addr())
+
+	// Stopping while the workers join closes the listener
+	joined := make(chan struct{})
+	go func() {
+		select {
+		case <-opts.Stop:
+			ln.Close()
+		case <-joined:
+		}
+	}()
+	workers, err := acceptWorkers(ln, opts)
+	close(joined)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, w := range workers {
+			w.conn.Close()
+		}
+	}()
+
+	// Every worker is told to start at the same moment, a little ahead of now
+	at := time.Now().Add(coordinatorStartDelay)
+	for i, w := range workers {
+		start := coordMessage{Type: "start", Args: assign(i, len(workers)), At: at}
+		if err := w.enc.Encode(start); err != nil {
+			return fmt.Errorf("failed to start worker %s: %w", w.name, err)
+		}
+		if opts.Verbose {
+			fmt.Printf("[VERBOSE] Worker %s runs: otelgen %v\n", w.name, start.Args)
+		}
+	}
+	fmt.Printf("Starting %d workers at %s\n", len(workers), at.Format(time.TimeOnly))
+
+	reports := make(chan workerReport)
+	for _, w := range workers {
+		go readReports(w, reports)
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	stopping := false
+	stopAll := func(reason string) {
+		if stopping {
+			return
+		}
+		stopping = true
+		fmt.Printf("Stopping every worker: %s\n", reason)
+		for _, w := range workers {
+			if !w.done {
+				w.enc.Encode(coordMessage{Type: "stop"})
+			}
+		}
+	}
+
+	remaining := len(workers)
+	for remaining > 0 {
+		select {
+		case r := <-reports:
+			w := r.worker
+			if w.done {
+				continue
+			}
+			switch r.msg.Type {
+			case "stats":
+				w.latest = r.msg
+				if opts.Verbose {
+					fmt.Printf("[VERBOSE] Worker %s: %d %s, %d exports (%d failed)\n", w.name, r.msg.Events, opts.Items, r.msg.Exports, r.msg.Failed)
+				}
+			case "done":
+				w.latest, w.done, w.err = r.msg, true, r.msg.Error
+				remaining--
+				if w.err != "" {
+					fmt.Printf("Worker %s failed: %s\n", w.name, w.err)
+					stopAll(fmt.Sprintf("worker %s failed", w.name))
+				} else if opts.Verbose {
+					fmt.Printf("[VERBOSE] Worker %s is done\n", w.name)
+				}
+			}
+		case <-ticker.C:
+			if time.Since(at) >= time.Second {
+				printCoordinatedProgress(workers, time.Since(at), opts.Items)
+			}
+		case <-opts.Stop:
+			opts.Stop = nil
+			stopAll("interrupted")
+		}
+	}
+
+	printCoordinatedSummary(workers, time.Since(at), opts.Items)
+	for _, w := range workers {
+		if w.err != "" {
+			return fmt.Errorf("worker %s failed: %s", w.name, w.err)
+		}
+	}
+	return nil
+}
+
+// acceptWorkers accepts connections until the workers have all joined
+func acceptWorkers(ln net.Listener, opts CoordinatorOptions) ([]*coordWorker, error) {
+	var workers []*coordWorker
+	names := make(map[string]bool)
+	for len(workers) < opts.Workers {
+		conn, err := ln.Accept()
+		if err != nil {
+			for _, w := range workers {
+				w.conn.Close()
+			}
+			select {
+			case <-opts.Stop:
+				return nil, fmt.Errorf("interrupted while waiting for workers (%d/%d joined)", len(workers), opts.Workers)
+			default:
+				return nil, fmt.Errorf("failed to accept worker: %w", err)
+			}
+		}
+		w := &coordWorker{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}
+		var join coordMessage
+		if err := w.dec.Decode(&join); err != nil || join.Type != "join" {
+			fmt.Printf("Ignoring connection from %s: not a worker\n", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		w.name = join.Name
+		if w.name == "" {
+			w.name = conn.RemoteAddr().String()
+		}
+		// Workers on the same host share a hostname, so later ones are numbered
+		for n := 2; names[w.name]; n++ {
+			w.name = fmt.Sprintf("%s#%d", join.Name, n)
+		}
+		names[w.name] = true
+		workers = append(workers, w)
+		fmt.Printf("Worker %s joined from %s (%d/%d)\n", w.name, conn.RemoteAddr(), len(workers), opts.Workers)
+	}
+	return workers, nil
+}
+
+// readReports passes on the worker's messages until it is done, reporting a lost
+// connection as a failure
+func readReports(w *coordWorker, reports chan<- workerReport) {
+	for {
+		var msg coordMessage
+		if err := w.dec.Decode(&msg); err != nil {
+			reports <- workerReport{worker: w, msg: coordMessage{Type: "done", Error: fmt.Sprintf("lost connection: %v", err)}}
+			return
+		}
+		reports <- workerReport{worker: w, msg: msg}
+		if msg.Type == "done" {
+			return
+		}
+	}
+}
+
+// coordinatedTotals sums the workers' latest reports
+func coordinatedTotals(workers []*coordWorker) (events, exports, failed int64) {
+	for _, w := range workers {
+		events += w.latest.Events
+		exports += w.latest.Exports
+		failed += w.latest.Failed
+	}
+	return events, exports, failed
+}
+
+// printCoordinatedProgress prints the workers' combined progress so far
+func printCoordinatedProgress(workers []*coordWorker, elapsed time.Duration, items string) {
+	events, exports, failed := coordinatedTotals(workers)
+	running := 0
+	for _, w := range workers {
+		if !w.done {
+			running++
+		}
+	}
+	fmt.Printf("[%s] %d/%d workers running: %d %s (%.1f/s), %d exports (%d failed)\n",
+		elapsed.Truncate(time.Second), running, len(workers), events, items, float64(events)/elapsed.Seconds(), exports, failed)
+}
+
+// printCoordinatedSummary prints each worker's totals and the combined totals
+func printCoordinatedSummary(workers []*coordWorker, elapsed time.Duration, items string) {
+	sorted := append([]*coordWorker(nil), workers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	fmt.Println()
+	fmt.Println("Worker stats:")
+	for _, w := range sorted {
+		status := ""
+		if w.err != "" {
+			status = " - failed: " + w.err
+		}
+		fmt.Printf("  %s: %d %s, %d exports (%d failed)%s\n", w.name, w.latest.Events, items, w.latest.Exports, w.latest.Failed, status)
+	}
+	events, exports, failed := coordinatedTotals(workers)
+	fmt.Printf("Generated %d %s across %d workers in %s (%.1f/s)\n", events, items, len(workers), elapsed.Truncate(time.Second), float64(events)/elapsed.Seconds())
+	fmt.Printf("Sent %d exports (%d failed)\n", exports, failed)
+}
+
+// dialCoordinator connects to the coordinator, retrying every second until joinTimeout
+func dialCoordinator(addr string, verbose bool) (net.Conn, error) {
+	deadline := time.Now().Add(joinTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil || time.Now().After(deadline) {
+			return conn, err
+		}
+		if verbose {
+			fmt.Printf("[VERBOSE] Coordinator not reachable yet, retrying: %v\n", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Join connects to the coordinator at addr as name, waits for it to start the run,
+// then runs the assigned arguments with run at the scheduled moment, reporting the
+// progress as it goes. The run is stopped when the coordinator says so or goes away.
+func Join(addr, name string, verbose bool, run func(args []string, progress *Progress, stop <-chan struct{}) error) error {
+	conn, err := dialCoordinator(addr, verbose)
+	if err != nil {
+		return fmt.Errorf("failed to join coordinator %s: %w", addr, err)
+	}
+	defer conn.Close()
+	enc, dec := json.NewEncoder(conn), json.NewDecoder(conn)
+
+	if err := enc.Encode(coordMessage{Type: "join", Name: name}); err != nil {
+		return fmt.Errorf("failed to join coordinator %s: %w", addr, err)
+	}
+	fmt.Printf("Joined coordinator %s as %s, waiting for the start\n", addr, name)
+
+	var start coordMessage
+	if err := dec.Decode(&start); err != nil {
+		return fmt.Errorf("failed to receive start from coordinator: %w", err)
+	}
+	if start.Type != "start" {
+		return fmt.Errorf("unexpected %q message from coordinator", start.Type)
+	}
+	if verbose {
+		fmt.Printf("[VERBOSE] Running at %s: otelgen %v\n", start.At.Format(time.TimeOnly), start.Args)
+	}
+
+	// The coordinator stops the run by message, or by going away
+	stop := make(chan struct{})
+	var once sync.Once
+	go func() {
+		defer once.Do(func() { close(stop) })
+		for {
+			var msg coordMessage
+			if err := dec.Decode(&msg); err != nil {
+				if verbose {
+					fmt.Printf("[VERBOSE] Lost coordinator: %v\n", err)
+				}
+				return
+			}
+			if msg.Type == "stop" {
+				fmt.Println("Coordinator stopped the run")
+				return
+			}
+		}
+	}()
+
+	progress := &Progress{}
+	report := func(typ string, err error) error {
+		events, exports, failed := progress.Snapshot()
+		msg := coordMessage{Type: typ, Events: events, Exports: exports, Failed: failed}
+		if err != nil {
+			msg.Error = err.Error()
+		}
+		return enc.Encode(msg)
+	}
+
+	// A run stopped before its start is done without having sent anything
+	select {
+	case <-time.After(time.Until(start.At)):
+	case <-stop:
+		report("done", nil)
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(start.Args, progress, stop)
+	}()
+
+	ticker := time.NewTicker(workerReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if rerr := report("done", err); rerr != nil && verbose {
+				fmt.Printf("[VERBOSE] Failed to report to coordinator: %v\n", rerr)
+			}
+			return err
+		case <-ticker.C:
+			if err := report("stats", nil); err != nil && verbose {
+				fmt.Printf("[VERBOSE] Failed to report to coordinator: %v\n", err)
+			}
+		}
+	}
+}
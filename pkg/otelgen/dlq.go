@@ -0,0 +1,355 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// deadLetterQueue keeps the export requests that failed for good, one serialized
+// protobuf request per file named after its signal, so they can be re-sent later
+type deadLetterQueue struct {
+	dir    string
+	signal string
+	seq    atomic.Int64
+
+	batches atomic.Int64
+	items   atomic.Int64
+	// missed counts failed exports whose request was never captured, because they
+	// failed before reaching the transport
+	missed atomic.Int64
+	// errors counts requests that could not be written to the directory
+	errors atomic.Int64
+}
+
+// newDeadLetterQueue creates the dead-letter directory if needed; it returns nil
+// when no directory is set
+func (t TransportOptions) newDeadLetterQueue(signal string) (*deadLetterQueue, error) {
+	if t.DeadLetterDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(t.DeadLetterDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	return &deadLetterQueue{dir: t.DeadLetterDir, signal: signal}, nil
+}
+
+// write stores a serialized request of items; files are written under a temporary
+// name first so a retry never picks up a partial one
+func (q *deadLetterQueue) write(body []byte, items int) {
+	name := fmt.Sprintf("%s-%d-%d-%06d.pb", q.signal, time.Now().UnixNano(), os.Getpid(), q.seq.Add(1))
+	path := filepath.Join(q.dir, name)
+	if err := os.WriteFile(path+".tmp", body, 0o644); err != nil {
+		q.failed(err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		q.failed(err)
+		return
+	}
+	q.batches.Add(1)
+	q.items.Add(int64(items))
+}
+
+// add stores a request the raw client failed to send
+func (q *deadLetterQueue) add(req proto.Message, items int) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		q.failed(err)
+		return
+	}
+	q.write(body, items)
+}
+
+// failed reports the first request that could not be kept, and counts the rest
+func (q *deadLetterQueue) failed(err error) {
+	if q.errors.Add(1) == 1 {
+		fmt.Printf("Error writing to dead-letter directory: %v\n", err)
+	}
+}
+
+// keep stores the request captured for a failed SDK export
+func (q *deadLetterQueue) keep(dl *deadLetter, items int) {
+	body, err := dl.request()
+	if err != nil {
+		q.failed(err)
+		return
+	}
+	if body == nil {
+		q.missed.Add(1)
+		return
+	}
+	q.write(body, items)
+}
+
+// printSummary prints how many failed requests were kept, if any
+func (q *deadLetterQueue) printSummary() {
+	if n := q.batches.Load(); n > 0 {
+		fmt.Printf("Dead-lettered %d failed requests (%d %s) in %s\n", n, q.items.Load(), signalItems[q.signal], q.dir)
+	}
+	if n := q.missed.Load(); n > 0 {
+		fmt.Printf("WARNING: %d failed exports never reached the endpoint and could not be dead-lettered\n", n)
+	}
+	if n := q.errors.Load(); n > 0 {
+		fmt.Printf("WARNING: %d failed requests could not be written to %s\n", n, q.dir)
+	}
+}
+
+// deadLetterKey carries a deadLetter through an SDK export's context to the gRPC
+// interceptor or HTTP transport, which see the serialized request the SDK built
+type deadLetterKey struct{}
+
+// deadLetter holds the last failed attempt of an SDK export, kept if every attempt
+// fails
+type deadLetter struct {
+	mu       sync.Mutex
+	msg      proto.Message
+	body     []byte
+	encoding string
+}
+
+// deadLetterFrom returns the holder in an export's context, if any
+func deadLetterFrom(ctx context.Context) *deadLetter {
+	dl, _ := ctx.Value(deadLetterKey{}).(*deadLetter)
+	return dl
+}
+
+// failedCall records a gRPC request that failed
+func (dl *deadLetter) failedCall(req any) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.msg, dl.body, dl.encoding = msg, nil, ""
+}
+
+// failedPost records an HTTP request body that failed, as sent
+func (dl *deadLetter) failedPost(body []byte, encoding string) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.msg, dl.body, dl.encoding = nil, body, encoding
+}
+
+// request returns the serialized protobuf request last recorded, or nil if none was
+func (dl *deadLetter) request() ([]byte, error) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.msg != nil {
+		return proto.Marshal(dl.msg)
+	}
+	if dl.body == nil || dl.encoding != "gzip" {
+		return dl.body, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(dl.body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	return io.ReadAll(zr)
+}
+
+// bufferRequest reads a request body so it can be kept after sending, and returns
+// the request to send in its place
+func bufferRequest(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil {
+		return nil, req, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	return body, out, nil
+}
+
+// deadLetterSpanExporter keeps the spans of exports that failed for good
+type deadLetterSpanExporter struct {
+	sdktrace.SpanExporter
+	dlq *deadLetterQueue
+}
+
+func (e deadLetterSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	dl := &deadLetter{}
+	err := e.SpanExporter.ExportSpans(context.WithValue(ctx, deadLetterKey{}, dl), spans)
+	if err != nil {
+		e.dlq.keep(dl, len(spans))
+	}
+	return err
+}
+
+// deadLetterLogExporter keeps the log records of exports that failed for good
+type deadLetterLogExporter struct {
+	sdklog.Exporter
+	dlq *deadLetterQueue
+}
+
+func (e deadLetterLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	dl := &deadLetter{}
+	err := e.Exporter.Export(context.WithValue(ctx, deadLetterKey{}, dl), records)
+	if err != nil {
+		e.dlq.keep(dl, len(records))
+	}
+	return err
+}
+
+// deadLetterMetricExporter keeps the datapoints of exports that failed for good
+type deadLetterMetricExporter struct {
+	sdkmetric.Exporter
+	dlq *deadLetterQueue
+}
+
+func (e deadLetterMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	dl := &deadLetter{}
+	err := e.Exporter.Export(context.WithValue(ctx, deadLetterKey{}, dl), rm)
+	if err != nil {
+		e.dlq.keep(dl, countSDKDataPoints(rm))
+	}
+	return err
+}
+
+// RetryDeadLetters re-sends the requests kept in a dead-letter directory, removing
+// each file once the endpoint accepted it and keeping the rest for another retry
+func RetryDeadLetters(endpoint *Endpoint, dir string, headers map[string]string, transport TransportOptions, verbose bool) error {
+	if err := transport.validate(endpoint); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read dead-letter directory: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".pb") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		fmt.Printf("No dead-lettered requests in %s\n", dir)
+		return nil
+	}
+
+	targets, err := transport.targets(endpoint)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	clients := map[string]*rawClient{}
+	defer func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}()
+
+	fmt.Printf("Re-sending %d dead-lettered requests from %s\n", len(files), dir)
+	sent := map[string]int{}
+	failed := 0
+	for _, name := range files {
+		signal, _, _ := strings.Cut(name, "-")
+		if _, ok := signalItems[signal]; !ok {
+			fmt.Printf("Skipping %s: not a dead-lettered request\n", name)
+			continue
+		}
+		path := filepath.Join(dir, name)
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		c := clients[signal]
+		if c == nil {
+			if c, err = newRawClient(targets, headers, transport, newExportObserver(signal, false)); err != nil {
+				return err
+			}
+			clients[signal] = c
+		}
+		items, err := resendDeadLetter(ctx, c, signal, body)
+		if err != nil {
+			failed++
+			fmt.Printf("Failed to re-send %s: %v\n", name, err)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		sent[signal] += items
+		if verbose {
+			fmt.Printf("[VERBOSE] Re-sent %s (%d %s)\n", name, items, signalItems[signal])
+		}
+	}
+
+	for _, signal := range []string{"traces", "logs", "metrics"} {
+		if n, ok := sent[signal]; ok {
+			fmt.Printf("Re-sent %d %s\n", n, signalItems[signal])
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d requests failed again and remain in %s", failed, dir)
+	}
+	fmt.Printf("Every dead-lettered request was delivered\n")
+	return nil
+}
+
+// resendDeadLetter sends one kept request and returns the number of items in it
+func resendDeadLetter(ctx context.Context, c *rawClient, signal string, body []byte) (int, error) {
+	switch signal {
+	case "traces":
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return 0, fmt.Errorf("failed to parse request: %w", err)
+		}
+		spans := 0
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans += len(ss.Spans)
+			}
+		}
+		return spans, c.ExportTraces(ctx, req)
+	case "logs":
+		req := &collogspb.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return 0, fmt.Errorf("failed to parse request: %w", err)
+		}
+		records := 0
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				records += len(sl.LogRecords)
+			}
+		}
+		return records, c.ExportLogs(ctx, req)
+	default:
+		req := &colmetricspb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return 0, fmt.Errorf("failed to parse request: %w", err)
+		}
+		points := 0
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				points += countDataPoints(sm.Metrics)
+			}
+		}
+		return points, c.ExportMetrics(ctx, req)
+	}
+}
@@ -0,0 +1,560 @@
+package otelgen
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// doctorExpiryWarning is how close to expiring a certificate must be to be flagged
+const doctorExpiryWarning = 14 * 24 * time.Hour
+
+// doctorServices names what each signal is exported to over gRPC
+var doctorServices = map[string]string{
+	"traces":  "TraceService",
+	"metrics": "MetricsService",
+	"logs":    "LogsService",
+}
+
+// doctorReport prints the findings of a diagnosis, counting its problems and warnings
+type doctorReport struct {
+	problems, warnings int
+}
+
+func (r *doctorReport) section(name string) {
+	fmt.Printf("\n%s\n", name)
+}
+
+func (r *doctorReport) ok(msg string) {
+	fmt.Printf("  OK    %s\n", msg)
+}
+
+// warn prints a finding that may explain a failure, and how to act on it if hint is set
+func (r *doctorReport) warn(msg, hint string) {
+	r.warnings++
+	r.finding("WARN", msg, hint)
+}
+
+// fail prints a problem that fails exports, and how to fix it if hint is set
+func (r *doctorReport) fail(msg, hint string) {
+	r.problems++
+	r.finding("FAIL", msg, hint)
+}
+
+func (r *doctorReport) finding(level, msg, hint string) {
+	fmt.Printf("  %-5s %s\n", level, msg)
+	if hint != "" {
+		fmt.Printf("        -> %s\n", hint)
+	}
+}
+
+// Doctor diagnoses each endpoint in depth, from DNS and TCP through the TLS chain and
+// ALPN to the OTLP services or paths it serves, the credentials it accepts, and the
+// compressions it supports, printing what is wrong and how to fix it; it returns an
+// error when it found problems
+func Doctor(endpoint *Endpoint, headers map[string]string, transport TransportOptions) error {
+	if transport.Writer != nil {
+		return fmt.Errorf("a diagnosis needs OTLP endpoints")
+	}
+	endpoints := append([]*Endpoint{endpoint}, transport.Endpoints...)
+	transport.Endpoints = nil
+	if err := transport.validate(endpoint); err != nil {
+		return err
+	}
+
+	var r doctorReport
+	for i, ep := range endpoints {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Diagnosing %s\n", ep)
+		r.diagnose(ep, headers, transport)
+	}
+
+	fmt.Println()
+	if r.problems == 0 && r.warnings == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+	fmt.Printf("%d problem(s), %d warning(s)\n", r.problems, r.warnings)
+	if r.problems > 0 {
+		return fmt.Errorf("found %d problem(s)", r.problems)
+	}
+	return nil
+}
+
+// diagnose checks one endpoint, layer by layer, stopping at the first layer that
+// leaves nothing above it to check
+func (r *doctorReport) diagnose(ep *Endpoint, headers map[string]string, t TransportOptions) {
+	addrs := r.checkDNS(ep, t)
+	if len(addrs) == 0 {
+		return
+	}
+	addr := r.checkTCP(ep, t, addrs)
+	if addr == "" {
+		return
+	}
+	if !r.checkTLS(ep, t, addr) {
+		return
+	}
+	if !r.checkOTLP(ep, headers, t) {
+		return
+	}
+	r.checkAuth(ep, headers, t)
+	r.checkCompression(ep, headers, t)
+}
+
+// checkDNS returns the addresses the endpoint's host resolves to, or is pinned to by
+// Resolve
+func (r *doctorReport) checkDNS(ep *Endpoint, t TransportOptions) []string {
+	r.section("DNS")
+	if pinned := t.Resolve[resolveKey(ep.Address())]; len(pinned) > 0 {
+		r.ok(fmt.Sprintf("%s is pinned to %s by --resolve", ep.Address(), strings.Join(pinned, ", ")))
+		return pinned
+	}
+	if _, err := netip.ParseAddr(ep.Host); err == nil {
+		r.ok(fmt.Sprintf("%s is an IP address, so there is nothing to resolve", ep.Host))
+		return []string{ep.Address()}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.connectTimeout())
+	defer cancel()
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, ep.Host)
+	took := time.Since(start)
+	if err != nil {
+		r.fail(fmt.Sprintf("%s does not resolve: %v", ep.Host, err),
+			fmt.Sprintf("check the host name, or dial an address directly with --resolve %s:<ip>", ep.Address()))
+		return nil
+	}
+
+	addrs := make([]string, 0, len(ips))
+	names := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), ep.Port))
+		names = append(names, ip.String())
+	}
+	alias := ""
+	if cname, err := net.DefaultResolver.LookupCNAME(ctx, ep.Host); err == nil && !strings.EqualFold(strings.TrimSuffix(cname, "."), ep.Host) {
+		alias = fmt.Sprintf(" through %s", strings.TrimSuffix(cname, "."))
+	}
+	r.ok(fmt.Sprintf("%s resolves%s to %s (%s)", ep.Host, alias, strings.Join(names, ", "), took.Round(10*time.Microsecond)))
+	if took > time.Second {
+		r.warn(fmt.Sprintf("resolving %s took %s", ep.Host, took.Round(10*time.Microsecond)),
+			"slow DNS delays every new connection; check the resolvers in /etc/resolv.conf")
+	}
+	return addrs
+}
+
+// checkTCP connects to each address, returning the first that accepts connections
+func (r *doctorReport) checkTCP(ep *Endpoint, t TransportOptions, addrs []string) string {
+	r.section("TCP")
+	dialer := &net.Dialer{Timeout: t.connectTimeout()}
+	reachable := ""
+	for _, addr := range addrs {
+		start := time.Now()
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			hint := ""
+			switch classifyError(err) {
+			case "TCP connect":
+				hint = fmt.Sprintf("nothing listens on port %s at this address; OTLP receivers listen on 4317 for gRPC and 4318 for HTTP", ep.Port)
+			case "timeout":
+				hint = fmt.Sprintf("the connection timed out; a firewall or security group may be dropping traffic to port %s", ep.Port)
+			}
+			r.fail(fmt.Sprintf("%s: %v", addr, err), hint)
+			continue
+		}
+		conn.Close()
+		r.ok(fmt.Sprintf("%s accepts connections (%s)", addr, time.Since(start).Round(10*time.Microsecond)))
+		if reachable == "" {
+			reachable = addr
+		}
+	}
+	return reachable
+}
+
+// checkTLS checks the handshake with the address: that a plaintext endpoint doesn't
+// speak TLS, and that a secure one verifies, has no certificate near expiry, and
+// negotiates the HTTP version its exports need; it reports whether exports can get
+// through
+func (r *doctorReport) checkTLS(ep *Endpoint, t TransportOptions, addr string) bool {
+	r.section("TLS")
+	if !ep.Secure {
+		// A server that expects TLS closes plaintext connections without a word
+		if state, err := doctorHandshake(ep, t, addr, true); err == nil {
+			r.fail(fmt.Sprintf("the endpoint is plaintext, but the server speaks %s", tls.VersionName(state.Version)),
+				fmt.Sprintf("use %s://%s", secureScheme(ep), ep.Address()))
+			return false
+		}
+		r.ok("the endpoint is plaintext, and so is the server")
+		return true
+	}
+
+	start := time.Now()
+	state, err := doctorHandshake(ep, t, addr, t.InsecureSkipVerify)
+	took := time.Since(start)
+	verified := err == nil
+	if err != nil {
+		// Handshaking again without verification shows the chain that failed
+		unverified, uerr := doctorHandshake(ep, t, addr, true)
+		if uerr != nil {
+			hint := ""
+			if strings.Contains(uerr.Error(), "first record does not look like a TLS handshake") {
+				hint = fmt.Sprintf("the server speaks plaintext; use %s://%s", plaintextScheme(ep), ep.Address())
+			}
+			r.fail(fmt.Sprintf("handshake failed: %v", uerr), hint)
+			return false
+		}
+		r.fail(fmt.Sprintf("certificate verification failed: %v", err), verifyHint(ep, t, err))
+		state = unverified
+	} else {
+		r.ok(fmt.Sprintf("%s with %s (%s)", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), took.Round(10*time.Microsecond)))
+	}
+	if t.InsecureSkipVerify {
+		r.warn("certificate verification is skipped by --insecure-skip-verify", "")
+	}
+
+	for i, cert := range state.PeerCertificates {
+		what := "certificate"
+		if i > 0 {
+			what = "issuer"
+		}
+		desc := fmt.Sprintf("%s %s, issued by %s", what, certName(cert.Subject.CommonName, cert.Subject.String()),
+			certName(cert.Issuer.CommonName, cert.Issuer.String()))
+		if i == 0 && len(cert.DNSNames) > 0 {
+			desc += fmt.Sprintf(", for %s", strings.Join(cert.DNSNames, ", "))
+		}
+		left := time.Until(cert.NotAfter)
+		switch {
+		case left < 0:
+			r.fail(fmt.Sprintf("%s, expired %s", desc, cert.NotAfter.Format(time.DateOnly)), "renew the certificate")
+		case left < doctorExpiryWarning:
+			r.warn(fmt.Sprintf("%s, expires %s (in %d days)", desc, cert.NotAfter.Format(time.DateOnly), int(left.Hours()/24)), "renew the certificate soon")
+		default:
+			r.ok(fmt.Sprintf("%s, expires %s (in %d days)", desc, cert.NotAfter.Format(time.DateOnly), int(left.Hours()/24)))
+		}
+	}
+
+	alpn := state.NegotiatedProtocol
+	switch {
+	case ep.IsGRPC() && alpn != "h2":
+		r.fail(fmt.Sprintf("ALPN negotiated %s, but gRPC needs h2", alpnName(alpn)),
+			fmt.Sprintf("the server, or a load balancer in front of it, does not offer HTTP/2 over TLS; enable it, or export to the OTLP/HTTP receiver with https://%s:4318", ep.Host))
+		return false
+	case t.HTTPVersion == "2" && alpn != "h2":
+		r.fail(fmt.Sprintf("ALPN negotiated %s, but --http-version 2 needs h2", alpnName(alpn)), "drop --http-version, or use --http-version 1.1")
+		return false
+	}
+	r.ok(fmt.Sprintf("ALPN negotiated %s", alpnName(alpn)))
+	// Every export fails the same verification
+	return verified
+}
+
+// doctorHandshake completes a TLS handshake with the address, offering the protocols
+// the exporters offer
+func doctorHandshake(ep *Endpoint, t TransportOptions, addr string, skipVerify bool) (tls.ConnectionState, error) {
+	config := t.tlsConfig()
+	config.InsecureSkipVerify = skipVerify
+	if config.ServerName == "" {
+		config.ServerName = ep.Host
+	}
+	config.NextProtos = []string{"h2", "http/1.1"}
+	if ep.IsGRPC() || t.HTTPVersion == "2" {
+		config.NextProtos = []string{"h2"}
+	} else if t.HTTPVersion == "1.1" {
+		config.NextProtos = []string{"http/1.1"}
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: t.connectTimeout()}, "tcp", addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState(), nil
+}
+
+// verifyHint returns how to fix a certificate that failed verification
+func verifyHint(ep *Endpoint, t TransportOptions, err error) string {
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &authorityErr):
+		return "the chain is not signed by a CA in the system pool; add the CA to the system pool, or skip verification with --insecure-skip-verify when testing"
+	case errors.As(err, &hostnameErr):
+		name := ep.Host
+		if t.TLSServerName != "" {
+			name = t.TLSServerName
+		}
+		return fmt.Sprintf("the certificate is not for %s; when the endpoint is reached by another name, set --tls-server-name to a name the certificate is for", name)
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return "a certificate has expired or is not valid yet; renew it, or check this host's clock"
+	}
+	return ""
+}
+
+// checkOTLP sends an empty export of each signal, checking that the endpoint speaks
+// its protocol and serves the OTLP services or paths; when it serves none, the other
+// protocol is tried on the same port, and the standard OTLP ports are checked for
+// what they serve. It reports whether exports are accepted
+func (r *doctorReport) checkOTLP(ep *Endpoint, headers map[string]string, t TransportOptions) bool {
+	r.section("OTLP")
+	probe := t
+	probe.Compression = "none"
+	signals := []string{"traces", "metrics", "logs"}
+	if ep.IsHTTP() && t.HTTPPath != "" {
+		// An empty request is a valid request of any signal
+		signals = signals[:1]
+	}
+
+	served := 0
+	var authErr error
+	for _, signal := range signals {
+		took, err := doctorExport(ep, headers, probe, signal)
+		if err != nil {
+			if isAuthError(err) {
+				authErr = err
+			}
+			r.fail(fmt.Sprintf("%s: %v", exportedTo(ep, t, signal), err), exportHint(ep, t, headers, err))
+			continue
+		}
+		served++
+		r.ok(fmt.Sprintf("%s accepts %s (%s)", exportedTo(ep, t, signal), signalItems[signal], took.Round(10*time.Microsecond)))
+	}
+
+	// Credentials are checked by every service alike, so the protocol was spoken
+	if served == 0 && authErr == nil {
+		other := otherProtocol(ep, ep.Port)
+		if _, err := doctorExport(other, nil, neutralTransport(t), "traces"); err == nil || isAuthError(err) {
+			r.fail(fmt.Sprintf("port %s speaks %s, not %s", ep.Port, protocolName(other), protocolName(ep)), fmt.Sprintf("use %s", other))
+		}
+	}
+	var sibling *Endpoint
+	switch {
+	case ep.IsGRPC() && ep.Port == "4317":
+		sibling = otherProtocol(ep, "4318")
+	case ep.IsHTTP() && ep.Port == "4318":
+		sibling = otherProtocol(ep, "4317")
+	}
+	if sibling != nil {
+		if _, err := doctorExport(sibling, nil, neutralTransport(t), "traces"); err == nil || isAuthError(err) {
+			r.ok(fmt.Sprintf("%s speaks %s too", sibling, protocolName(sibling)))
+		} else {
+			r.ok(fmt.Sprintf("%s does not speak %s: %v", sibling, protocolName(sibling), err))
+		}
+	}
+	return served > 0
+}
+
+// checkAuth checks that the credentials are what made exports accepted, by exporting
+// without them and the headers
+func (r *doctorReport) checkAuth(ep *Endpoint, headers map[string]string, t TransportOptions) {
+	r.section("Authentication")
+	if !hasCredentials(headers, t) {
+		r.ok("no credentials or headers are set, and the endpoint needs none")
+		return
+	}
+	anonymous := t
+	anonymous.BearerToken, anonymous.BearerTokenFile, anonymous.BasicAuth = "", "", ""
+	anonymous.OAuth2TokenURL, anonymous.HMACSecret = "", ""
+	anonymous.Compression = "none"
+	_, err := doctorExport(ep, nil, anonymous, "traces")
+	switch {
+	case err == nil:
+		r.warn("the credentials and headers are accepted, but so are exports without them", "the endpoint does not check credentials, so they may not be applied where it forwards the data")
+	case isAuthError(err):
+		r.ok(fmt.Sprintf("the credentials and headers are accepted, and exports without them are rejected (%v)", err))
+	default:
+		r.ok(fmt.Sprintf("the credentials and headers are accepted, and exports without them fail: %v", err))
+	}
+}
+
+// checkCompression checks which export compressions the endpoint accepts, failing
+// only on the one the exports use
+func (r *doctorReport) checkCompression(ep *Endpoint, headers map[string]string, t TransportOptions) {
+	r.section("Compression")
+	compressions := []string{"none", "gzip"}
+	if ep.IsGRPC() {
+		compressions = append(compressions, "zstd")
+	}
+	configured := t.compressor()
+	if configured == "" {
+		configured = "none"
+	}
+	for _, compression := range compressions {
+		probe := t
+		probe.Compression = compression
+		_, err := doctorExport(ep, headers, probe, "traces")
+		switch {
+		case err == nil:
+			r.ok(fmt.Sprintf("%s is accepted", compression))
+		case compression == configured:
+			r.fail(fmt.Sprintf("%s, which --compression sets, is rejected: %v", compression, err), "export with another --compression")
+		default:
+			r.warn(fmt.Sprintf("%s is rejected: %v", compression, err), "")
+		}
+	}
+}
+
+// doctorExport sends an empty export request of the signal, returning how long it
+// took to be answered
+func doctorExport(ep *Endpoint, headers map[string]string, t TransportOptions, signal string) (time.Duration, error) {
+	raw, err := newRawClient([]*target{newTarget(ep.String(), ep, t)}, headers, t, newExportObserver(signal, false))
+	if err != nil {
+		return 0, err
+	}
+	defer raw.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), t.exportTimeout())
+	defer cancel()
+	start := time.Now()
+	switch signal {
+	case "traces":
+		err = raw.ExportTraces(ctx, &coltracepb.ExportTraceServiceRequest{})
+	case "metrics":
+		err = raw.ExportMetrics(ctx, &colmetricspb.ExportMetricsServiceRequest{})
+	default:
+		err = raw.ExportLogs(ctx, &collogspb.ExportLogsServiceRequest{})
+	}
+	return time.Since(start), err
+}
+
+// exportedTo names where exports of the signal go: a gRPC service or an HTTP path
+func exportedTo(ep *Endpoint, t TransportOptions, signal string) string {
+	if ep.IsGRPC() {
+		return doctorServices[signal]
+	}
+	if t.HTTPPath != "" {
+		return "POST " + t.HTTPPath
+	}
+	return "POST /v1/" + signal
+}
+
+// exportHint returns how to fix an export that failed, or "" when there is nothing
+// to suggest
+func exportHint(ep *Endpoint, t TransportOptions, headers map[string]string, err error) string {
+	if isAuthError(err) {
+		if hasCredentials(headers, t) {
+			return "the endpoint rejected the credentials; check the token or key, and that --auth-preset or --headers sends it where the endpoint expects it"
+		}
+		return "the endpoint needs credentials; set --bearer-token, --basic-auth, --auth-preset with --api-key, or --headers"
+	}
+	var httpErr *httpStatusError
+	if errors.As(err, &httpErr) {
+		switch httpErr.code {
+		case http.StatusNotFound, http.StatusMethodNotAllowed:
+			return "the server does not serve this path; OTLP/HTTP receivers serve /v1/traces, /v1/metrics, and /v1/logs, so set --http-path if yours uses another, or check that the signal is enabled"
+		case http.StatusUnsupportedMediaType:
+			return "the server does not accept application/x-protobuf; it may not be an OTLP/HTTP receiver"
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return "the endpoint is throttling or overloaded; retry later, or lower the rate"
+		}
+		if httpErr.code/100 == 5 {
+			return "the server failed the request; check its logs"
+		}
+		return ""
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unimplemented:
+			return "the server speaks gRPC but does not serve this OTLP service; check that its OTLP receiver enables the signal"
+		case codes.Unavailable:
+			if classifyMessage(s.Message(), "") == "" {
+				return "the port may not speak gRPC; check that it is the OTLP gRPC receiver's"
+			}
+		}
+	}
+	return ""
+}
+
+// isAuthError reports whether an export failed for its credentials
+func isAuthError(err error) bool {
+	var httpErr *httpStatusError
+	if errors.As(err, &httpErr) {
+		return httpErr.code == http.StatusUnauthorized || httpErr.code == http.StatusForbidden
+	}
+	code := status.Code(err)
+	return code == codes.Unauthenticated || code == codes.PermissionDenied
+}
+
+// hasCredentials reports whether exports carry credentials or extra headers, which
+// may hold an API key
+func hasCredentials(headers map[string]string, t TransportOptions) bool {
+	return len(headers) > 0 || t.BearerToken != "" || t.BearerTokenFile != "" || t.BasicAuth != "" ||
+		t.OAuth2TokenURL != "" || t.HMACSecret != ""
+}
+
+// otherProtocol returns the endpoint on port over the other OTLP protocol, as secure
+// as ep
+func otherProtocol(ep *Endpoint, port string) *Endpoint {
+	other := *ep
+	other.Port = port
+	switch ep.Protocol {
+	case ProtocolGRPC:
+		other.Protocol = ProtocolHTTP
+	case ProtocolGRPCS:
+		other.Protocol = ProtocolHTTPS
+	case ProtocolHTTP:
+		other.Protocol = ProtocolGRPC
+	case ProtocolHTTPS:
+		other.Protocol = ProtocolGRPCS
+	}
+	return &other
+}
+
+// neutralTransport returns the settings without the ones that only one protocol takes,
+// for exporting over the other
+func neutralTransport(t TransportOptions) TransportOptions {
+	t.Compression = "none"
+	t.Encoding, t.HTTPPath, t.HTTPVersion = "", "", ""
+	t.GRPCMaxMessageSize, t.GRPCTimeout = 0, 0
+	return t
+}
+
+func protocolName(ep *Endpoint) string {
+	if ep.IsGRPC() {
+		return "OTLP/gRPC"
+	}
+	return "OTLP/HTTP"
+}
+
+func secureScheme(ep *Endpoint) string {
+	if ep.IsGRPC() {
+		return "grpcs"
+	}
+	return "https"
+}
+
+func plaintextScheme(ep *Endpoint) string {
+	if ep.IsGRPC() {
+		return "grpc"
+	}
+	return "http"
+}
+
+func alpnName(proto string) string {
+	if proto == "" {
+		return "none"
+	}
+	return proto
+}
+
+// certName returns a certificate name's common name, or the whole name without one
+func certName(commonName, name string) string {
+	if commonName != "" {
+		return commonName
+	}
+	return name
+}
@@ -0,0 +1,143 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// hexIDFields are the OTLP/JSON fields written as hex strings rather than the
+// base64 protojson uses for bytes
+var hexIDFields = map[string]bool{
+	"traceId":      true,
+	"spanId":       true,
+	"parentSpanId": true,
+}
+
+// marshalOTLPJSON encodes an OTLP message as OTLP/JSON: enums as numbers and trace
+// and span IDs in hex
+func marshalOTLPJSON(msg proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// hexIDs rewrites the base64 trace and span IDs in a decoded JSON document as hex
+func hexIDs(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if s, ok := field.(string); ok && hexIDFields[k] {
+				id, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("failed to decode %s: %w", k, err)
+				}
+				v[k] = hex.EncodeToString(id)
+				continue
+			}
+			if err := hexIDs(field); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := hexIDs(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newRequest returns an empty export request for the signal
+func newRequest(signal string) proto.Message {
+	switch signal {
+	case "traces":
+		return &coltracepb.ExportTraceServiceRequest{}
+	case "metrics":
+		return &colmetricspb.ExportMetricsServiceRequest{}
+	default:
+		return &collogspb.ExportLogsServiceRequest{}
+	}
+}
+
+// jsonTransport re-encodes protobuf export requests as OTLP/JSON, since the OTLP HTTP
+// exporters only send protobuf; gzipped requests are re-compressed after encoding
+type jsonTransport struct {
+	base   http.RoundTripper
+	signal string
+}
+
+func (t *jsonTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Header.Get("Content-Type") != "application/x-protobuf" {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+
+	gzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if gzipped {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress request: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress request: %w", err)
+		}
+	}
+
+	msg := newRequest(t.signal)
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+	if body, err = marshalOTLPJSON(msg); err != nil {
+		return nil, fmt.Errorf("failed to encode request as JSON: %w", err)
+	}
+
+	if gzipped {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	out := req.Clone(req.Context())
+	out.Header.Set("Content-Type", "application/json")
+	out.ContentLength = int64(len(body))
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(out)
+}
@@ -2,6 +2,8 @@ package otelgen
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
 	"net/url"
 	"strings"
 )
@@ -41,12 +43,12 @@ type Endpoint struct {
 
 // String returns the full endpoint URL
 func (e *Endpoint) String() string {
-	return fmt.Sprintf("%s://%s:%s", e.Protocol, e.Host, e.Port)
+	return fmt.Sprintf("%s://%s", e.Protocol, e.Address())
 }
 
-// Address returns host:port
+// Address returns host:port, with IPv6 hosts in brackets ([::1]:4317)
 func (e *Endpoint) Address() string {
-	return fmt.Sprintf("%s:%s", e.Host, e.Port)
+	return net.JoinHostPort(e.Host, e.Port)
 }
 
 // IsGRPC returns true if the protocol is gRPC-based
@@ -61,6 +63,8 @@ func (e *Endpoint) IsHTTP() bool {
 
 // ParseEndpoint parses the endpoint string and returns an Endpoint
 // Supports: grpc://host:port, grpcs://host:port, http://host:port, https://host:port
+// IPv6 hosts are written in brackets (grpc://[::1]:4317); a bare IPv6 literal
+// (grpc://::1) is accepted too, but cannot carry a port
 // Default ports: grpc://->443, grpcs://->443, http://->80, https://->443
 func ParseEndpoint(endpoint string) (*Endpoint, error) {
 	if endpoint == "" {
@@ -68,7 +72,7 @@ func ParseEndpoint(endpoint string) (*Endpoint, error) {
 	}
 
 	// Parse the URL
-	u, err := url.Parse(endpoint)
+	u, err := url.Parse(bracketIPv6(endpoint))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse endpoint: %w", err)
 	}
@@ -118,3 +122,40 @@ func ParseEndpoint(endpoint string) (*Endpoint, error) {
 
 	return ep, nil
 }
+
+// bracketIPv6 wraps a bare IPv6 host in brackets, so the URL parser does not read
+// its last group as a port
+func bracketIPv6(endpoint string) string {
+	scheme, rest, ok := strings.Cut(endpoint, "://")
+	if !ok {
+		return endpoint
+	}
+	host, path := rest, ""
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if strings.Count(host, ":") < 2 || strings.HasPrefix(host, "[") {
+		return endpoint
+	}
+	if _, err := netip.ParseAddr(host); err != nil {
+		return endpoint
+	}
+	// A zone must be escaped inside a URL host
+	return scheme + "://[" + strings.Replace(host, "%", "%25", 1) + "]" + path
+}
+
+// withDefaultPort returns host:port for a socket address given as host or host:port,
+// adding the default port when there is none
+func withDefaultPort(address, port string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("endpoint cannot be empty")
+	}
+	if host, p, err := net.SplitHostPort(address); err == nil {
+		if host == "" {
+			return "", fmt.Errorf("host cannot be empty")
+		}
+		return net.JoinHostPort(host, p), nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	return net.JoinHostPort(host, port), nil
+}
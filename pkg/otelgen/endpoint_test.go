@@ -0,0 +1,62 @@
+package otelgen
+
+import "testing"
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Endpoint
+		address string
+		wantErr bool
+	}{
+		{in: "grpc://localhost:4317", want: Endpoint{Protocol: ProtocolGRPC, Host: "localhost", Port: "4317"}, address: "localhost:4317"},
+		{in: "grpcs://collector.example.com", want: Endpoint{Protocol: ProtocolGRPCS, Host: "collector.example.com", Port: "443", Secure: true}, address: "collector.example.com:443"},
+		{in: "http://localhost", want: Endpoint{Protocol: ProtocolHTTP, Host: "localhost", Port: "80"}, address: "localhost:80"},
+		{in: "HTTPS://example.com:8443/", want: Endpoint{Protocol: ProtocolHTTPS, Host: "example.com", Port: "8443", Secure: true}, address: "example.com:8443"},
+		{in: "grpc://[::1]:4317", want: Endpoint{Protocol: ProtocolGRPC, Host: "::1", Port: "4317"}, address: "[::1]:4317"},
+		{in: "grpc://::1", want: Endpoint{Protocol: ProtocolGRPC, Host: "::1", Port: "443"}, address: "[::1]:443"},
+		{in: "http://[2001:db8::1]", want: Endpoint{Protocol: ProtocolHTTP, Host: "2001:db8::1", Port: "80"}, address: "[2001:db8::1]:80"},
+		{in: "https://2001:db8::1/v1/traces", want: Endpoint{Protocol: ProtocolHTTPS, Host: "2001:db8::1", Port: "443", Secure: true}, address: "[2001:db8::1]:443"},
+		{in: "grpc://[fe80::1%25eth0]:4317", want: Endpoint{Protocol: ProtocolGRPC, Host: "fe80::1%eth0", Port: "4317"}, address: "[fe80::1%eth0]:4317"},
+		{in: "grpc://fe80::1%eth0", want: Endpoint{Protocol: ProtocolGRPC, Host: "fe80::1%eth0", Port: "443"}, address: "[fe80::1%eth0]:443"},
+		{in: "", wantErr: true},
+		{in: "tcp://localhost:4317", wantErr: true},
+		{in: "localhost:4317", wantErr: true},
+		{in: "grpc://:4317", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEndpoint(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseEndpoint(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParseEndpoint(%q) = %+v, want %+v", tt.in, *got, tt.want)
+		}
+		if address := got.Address(); address != tt.address {
+			t.Errorf("ParseEndpoint(%q).Address() = %q, want %q", tt.in, address, tt.address)
+		}
+	}
+}
+
+func TestBracketIPv6(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "grpc://::1", want: "grpc://[::1]"},
+		{in: "http://2001:db8::1/v1/logs?x=1", want: "http://[2001:db8::1]/v1/logs?x=1"},
+		{in: "grpc://fe80::1%eth0", want: "grpc://[fe80::1%25eth0]"},
+		{in: "grpc://[::1]:4317", want: "grpc://[::1]:4317"},
+		{in: "grpc://localhost:4317", want: "grpc://localhost:4317"},
+		{in: "grpc://a:b:c", want: "grpc://a:b:c"},
+		{in: "::1", want: "::1"},
+	}
+	for _, tt := range tests {
+		if got := bracketIPv6(tt.in); got != tt.want {
+			t.Errorf("bracketIPv6(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package otelgen
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventStream writes a line of JSON for every event of a run as it happens: its start
+// and end, each phase it enters, the end of its warmup, and every export attempt, for
+// test harnesses to follow the run without parsing its prose
+type EventStream struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewEventStream returns a stream writing events to w
+func NewEventStream(w io.Writer) *EventStream {
+	return &EventStream{w: w}
+}
+
+// runEvent is the start or end of a run, or the end of its warmup
+type runEvent struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Signal   string    `json:"signal"`
+	Duration float64   `json:"duration_seconds,omitempty"`
+	Rate     *float64  `json:"rate,omitempty"`
+	// Items is the spans, log records, or metric events generated, leaving out any
+	// warmup, and Warmup those generated during it
+	Items  *int `json:"items,omitempty"`
+	Warmup *int `json:"warmup_items,omitempty"`
+}
+
+// phaseEvent is the run entering a phase of its load profile
+type phaseEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Phase string    `json:"phase"`
+	Rate  float64   `json:"rate"`
+}
+
+// exportEvent is an export attempt, as the export log records it
+type exportEvent struct {
+	Event string `json:"event"`
+	*exportRecord
+}
+
+// write writes an event; it does nothing without a stream
+func (s *EventStream) write(event any) {
+	if s == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(line, '\n'))
+}
+
+// start records the start of a run of the signal lasting duration, at the limiter's
+// target rate
+func (s *EventStream) start(signal string, duration time.Duration, limiter *rateLimiter) {
+	if s == nil {
+		return
+	}
+	rate := limiter.target(0)
+	// A run without a duration reports none
+	if duration == endless {
+		duration = 0
+	}
+	s.write(runEvent{Event: "start", Time: time.Now().UTC(), Signal: signal, Duration: duration.Seconds(), Rate: &rate})
+}
+
+// warmupEnded records the end of the warmup, and the items generated during it
+func (s *EventStream) warmupEnded(signal string, items int) {
+	s.write(runEvent{Event: "warmup_end", Time: time.Now().UTC(), Signal: signal, Warmup: &items})
+}
+
+// end records the end of a run and the items it generated, before the final flush
+func (s *EventStream) end(signal string, items, warmup int) {
+	s.write(runEvent{Event: "end", Time: time.Now().UTC(), Signal: signal, Items: &items, Warmup: &warmup})
+}
+
+// phase records the run entering a phase, at its rate then
+func (s *EventStream) phase(name string, rate float64) {
+	s.write(phaseEvent{Event: "phase", Time: time.Now().UTC(), Phase: name, Rate: rate})
+}
+
+// export records an export attempt
+func (s *EventStream) export(rec *exportRecord) {
+	s.write(exportEvent{Event: "export", exportRecord: rec})
+}
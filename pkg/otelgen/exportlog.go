@@ -0,0 +1,129 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportLog writes a line of JSON for every export attempt, retries included, for
+// analysis after the run
+type exportLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File
+}
+
+// exportRecord is the outcome of one export attempt; the gRPC code or HTTP status is
+// missing when the request got no response
+type exportRecord struct {
+	Time       time.Time `json:"time"`
+	Signal     string    `json:"signal"`
+	Protocol   string    `json:"protocol"`
+	Items      int       `json:"items"`
+	Bytes      int64     `json:"bytes"`
+	Duration   float64   `json:"duration_seconds"`
+	GRPCCode   string    `json:"grpc_code,omitempty"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// Rejected and RejectedMessage are the partial success of an accepted export
+	Rejected        int64  `json:"rejected"`
+	RejectedMessage string `json:"rejected_message,omitempty"`
+}
+
+// newExportLog returns the log of export attempts, or nil when none is kept; "-" logs
+// to stdout, and any other path is appended to
+func (t TransportOptions) newExportLog() (*exportLog, error) {
+	switch t.ExportLog {
+	case "":
+		return nil, nil
+	case "-":
+		return &exportLog{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(t.ExportLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export log: %w", err)
+	}
+	return &exportLog{w: f, file: f}, nil
+}
+
+// write logs an export attempt
+func (l *exportLog) write(rec *exportRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+// close closes the log's file, if it has one
+func (l *exportLog) close() {
+	if l != nil && l.file != nil {
+		l.file.Close()
+	}
+}
+
+// requestItems returns the spans, data points, or log records in an export request
+func requestItems(msg any) int {
+	items := 0
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				items += len(ss.Spans)
+			}
+		}
+	case *colmetricspb.ExportMetricsServiceRequest:
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				items += countDataPoints(sm.Metrics)
+			}
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				items += len(sl.LogRecords)
+			}
+		}
+	}
+	return items
+}
+
+// bodyItems returns the items in an HTTP export request body of the signal, or zero
+// when it can't be parsed
+func bodyItems(signal string, body []byte, encoding string) int {
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return 0
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return 0
+		}
+	}
+	var req proto.Message
+	switch signal {
+	case "traces":
+		req = &coltracepb.ExportTraceServiceRequest{}
+	case "metrics":
+		req = &colmetricspb.ExportMetricsServiceRequest{}
+	default:
+		req = &collogspb.ExportLogsServiceRequest{}
+	}
+	if err := proto.Unmarshal(body, req); err != nil {
+		return 0
+	}
+	return requestItems(req)
+}
@@ -0,0 +1,174 @@
+package otelgen
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failureClasses orders the classes of failed exports in the summary; gRPC status
+// codes follow them
+var failureClasses = []string{"DNS", "TCP connect", "connection lost", "TLS", "timeout", "HTTP 4xx", "HTTP 5xx"}
+
+// exportFailures counts failed export attempts by the class of their error, keeping
+// the first error of each class as an example
+type exportFailures struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	examples map[string]string
+}
+
+// add records a failed attempt
+func (f *exportFailures) add(class, example string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts == nil {
+		f.counts, f.examples = map[string]int{}, map[string]string{}
+	}
+	if f.counts[class] == 0 {
+		f.examples[class] = example
+	}
+	f.counts[class]++
+}
+
+// reset discards the attempts recorded so far
+func (f *exportFailures) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts, f.examples = nil, nil
+}
+
+// total returns the number of failed attempts recorded
+func (f *exportFailures) total() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	total := 0
+	for _, n := range f.counts {
+		total += n
+	}
+	return total
+}
+
+// printSummary prints the failed attempts of each class, if there were any
+func (f *exportFailures) printSummary() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	total := 0
+	for _, n := range f.counts {
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	classes := make([]string, 0, len(f.counts))
+	for class := range f.counts {
+		classes = append(classes, class)
+	}
+	slices.SortFunc(classes, func(a, b string) int {
+		ia, ib := slices.Index(failureClasses, a), slices.Index(failureClasses, b)
+		if ia < 0 {
+			ia = len(failureClasses)
+		}
+		if ib < 0 {
+			ib = len(failureClasses)
+		}
+		if ia != ib {
+			return ia - ib
+		}
+		return strings.Compare(a, b)
+	})
+	fmt.Printf("Failed export attempts: %d\n", total)
+	for _, class := range classes {
+		fmt.Printf("  %s: %d (e.g., %s)\n", class, f.counts[class], f.examples[class])
+	}
+}
+
+// httpFailure returns the class of an HTTP response status that failed an export
+func httpFailure(code int) string {
+	switch code / 100 {
+	case 4:
+		return "HTTP 4xx"
+	case 5:
+		return "HTTP 5xx"
+	}
+	return fmt.Sprintf("HTTP %d", code)
+}
+
+// classifyError returns the class of an error that failed an export: DNS, TCP connect,
+// connection lost, TLS, timeout, or the gRPC status code, or other
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var opErr *net.OpError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return "DNS"
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return "TLS"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "TCP connect"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection lost"
+	}
+
+	// gRPC reports connection failures in the status message only
+	s, ok := status.FromError(err)
+	if !ok {
+		return classifyMessage(err.Error(), "other")
+	}
+	if s.Code() == codes.DeadlineExceeded {
+		return "timeout"
+	}
+	return classifyMessage(s.Message(), "gRPC "+s.Code().String())
+}
+
+// classifyMessage returns the class of a connection failure described by msg, or
+// fallback when it describes none
+func classifyMessage(msg, fallback string) string {
+	switch {
+	case strings.Contains(msg, "no such host"), strings.Contains(msg, "server misbehaving"), strings.Contains(msg, "name resolver error"):
+		return "DNS"
+	case strings.Contains(msg, "tls:"), strings.Contains(msg, "x509:"):
+		return "TLS"
+	case strings.Contains(msg, "i/o timeout"):
+		return "timeout"
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "dial tcp"), strings.Contains(msg, "no route to host"):
+		return "TCP connect"
+	case strings.Contains(msg, "connection reset"), strings.Contains(msg, "broken pipe"):
+		return "connection lost"
+	}
+	return fallback
+}
+
+// handleExportErrors leaves the SDK's export errors to the failed attempts in the
+// summary, logging them as they happen only when verbose
+func handleExportErrors(verbose bool) {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		if verbose {
+			log.Print(err)
+		}
+	}))
+}
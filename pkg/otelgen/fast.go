@@ -0,0 +1,389 @@
+package otelgen
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// fastBatchTimeout is how long a partial batch waits before it is sent, as for the
+	// SDK's batch processors
+	fastBatchTimeout = 2 * time.Second
+	// fastInFlight is the number of requests sent concurrently over each connection
+	fastInFlight = 4
+	// fastShutdownTimeout bounds sending the final batches
+	fastShutdownTimeout = 10 * time.Second
+)
+
+// fastBatcher collects items into batches and sends each batch as one request from a
+// pool of senders. Like the SDK's batch processors, it drops full batches while every
+// sender is busy and the queue is full, rather than slowing generation down.
+type fastBatcher[T any] struct {
+	size  int
+	items string
+	send  func(context.Context, []T) error
+	// release, when set, takes back each item once it is sent or dropped
+	release func(T)
+	// verbose prints every failed send; otherwise they are left to the observer's
+	// summary
+	verbose bool
+
+	mu    sync.Mutex
+	batch []T
+	since time.Time
+
+	queue   chan []T
+	free    chan []T
+	pending sync.WaitGroup
+	dropped atomic.Int64
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newFastBatcher starts senders that send batches of size items with send
+func newFastBatcher[T any](size, senders int, items string, verbose bool, send func(context.Context, []T) error) *fastBatcher[T] {
+	b := &fastBatcher[T]{
+		size:    size,
+		items:   items,
+		send:    send,
+		verbose: verbose,
+		queue:   make(chan []T, 2*senders),
+		free:    make(chan []T, 2*senders+1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	b.batch = b.newBatch()
+	for i := 0; i < senders; i++ {
+		go b.run()
+	}
+	go b.expire()
+	return b
+}
+
+// add appends items to the current batch, queueing the batch once it is full; it is
+// safe for concurrent use
+func (b *fastBatcher[T]) add(items ...T) {
+	b.mu.Lock()
+	if len(b.batch) == 0 {
+		b.since = time.Now()
+	}
+	b.batch = append(b.batch, items...)
+	var full []T
+	if len(b.batch) >= b.size {
+		full = b.take()
+	}
+	b.mu.Unlock()
+	if full == nil {
+		return
+	}
+	select {
+	case b.queue <- full:
+	default:
+		b.dropped.Add(int64(len(full)))
+		b.done(full)
+	}
+}
+
+// take returns the current batch as pending and starts a new one; mu must be held
+func (b *fastBatcher[T]) take() []T {
+	full := b.batch
+	b.batch = b.newBatch()
+	b.pending.Add(1)
+	return full
+}
+
+// newBatch returns an empty batch, reusing one already sent when there is one
+func (b *fastBatcher[T]) newBatch() []T {
+	select {
+	case batch := <-b.free:
+		return batch
+	default:
+		return make([]T, 0, b.size+4)
+	}
+}
+
+func (b *fastBatcher[T]) run() {
+	for batch := range b.queue {
+		if err := b.send(context.Background(), batch); err != nil && b.verbose {
+			fmt.Printf("Error exporting %s: %v\n", b.items, err)
+		}
+		b.done(batch)
+	}
+}
+
+// done releases a pending batch once it is sent or dropped, keeping it for reuse
+func (b *fastBatcher[T]) done(batch []T) {
+	if b.release != nil {
+		for _, item := range batch {
+			b.release(item)
+		}
+	}
+	clear(batch)
+	select {
+	case b.free <- batch[:0]:
+	default:
+	}
+	b.pending.Done()
+}
+
+// expire queues partial batches that have waited fastBatchTimeout
+func (b *fastBatcher[T]) expire() {
+	defer close(b.stopped)
+	ticker := time.NewTicker(fastBatchTimeout / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			var full []T
+			if len(b.batch) > 0 && time.Since(b.since) >= fastBatchTimeout {
+				full = b.take()
+			}
+			b.mu.Unlock()
+			if full != nil {
+				b.queue <- full
+			}
+		}
+	}
+}
+
+// flush sends the partial batch and waits for every pending batch to be sent
+func (b *fastBatcher[T]) flush(ctx context.Context) error {
+	b.mu.Lock()
+	var full []T
+	if len(b.batch) > 0 {
+		full = b.take()
+	}
+	b.mu.Unlock()
+	if full != nil {
+		select {
+		case b.queue <- full:
+		case <-ctx.Done():
+			b.pending.Done()
+			return ctx.Err()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown sends the final batches and stops the senders, printing the items dropped
+// along the way; nothing may be added after it
+func (b *fastBatcher[T]) shutdown() {
+	close(b.stop)
+	<-b.stopped
+	ctx, cancel := context.WithTimeout(context.Background(), fastShutdownTimeout)
+	defer cancel()
+	if err := b.flush(ctx); err != nil {
+		fmt.Printf("Error sending final batches: %v\n", err)
+	}
+	close(b.queue)
+	if n := b.dropped.Load(); n > 0 {
+		fmt.Printf("Dropped %d %s while the endpoint could not keep up\n", n, b.items)
+	}
+}
+
+// fastSenders is the number of senders for the client's connections
+func fastSenders(raw *rawClient) int {
+	return len(raw.shards) * fastInFlight
+}
+
+// fastResource converts the generator's resource to its OTLP form once, to be shared
+// by every request
+func fastResource(res *resource.Resource) *resourcepb.Resource {
+	return &resourcepb.Resource{Attributes: attributesToProto(res.Attributes())}
+}
+
+// newFastTraceBatcher returns a batcher that sends spans in trace export requests
+// over the raw client
+func newFastTraceBatcher(raw *rawClient, res *resource.Resource, batchSize int, verbose bool) *fastBatcher[*tracepb.Span] {
+	resource := fastResource(res)
+	scope := &commonpb.InstrumentationScope{Name: "otelgen"}
+	b := newFastBatcher(batchSize, fastSenders(raw), "spans", verbose, func(ctx context.Context, spans []*tracepb.Span) error {
+		return raw.ExportTraces(ctx, &coltracepb.ExportTraceServiceRequest{
+			ResourceSpans: []*tracepb.ResourceSpans{{
+				Resource:   resource,
+				ScopeSpans: []*tracepb.ScopeSpans{{Scope: scope, Spans: spans}},
+				SchemaUrl:  res.SchemaURL(),
+			}},
+		})
+	})
+	b.release = releaseSpan
+	return b
+}
+
+// newFastLogBatcher returns a batcher that sends log records in logs export requests
+// over the raw client
+func newFastLogBatcher(raw *rawClient, res *resource.Resource, batchSize int, verbose bool) *fastBatcher[*logspb.LogRecord] {
+	resource := fastResource(res)
+	scope := &commonpb.InstrumentationScope{Name: "otelgen"}
+	b := newFastBatcher(batchSize, fastSenders(raw), "log records", verbose, func(ctx context.Context, records []*logspb.LogRecord) error {
+		return raw.ExportLogs(ctx, &collogspb.ExportLogsServiceRequest{
+			ResourceLogs: []*logspb.ResourceLogs{{
+				Resource:  resource,
+				ScopeLogs: []*logspb.ScopeLogs{{Scope: scope, LogRecords: records}},
+				SchemaUrl: res.SchemaURL(),
+			}},
+		})
+	})
+	b.release = releaseLogRecord
+	return b
+}
+
+// spanPool and logRecordPool hold sent spans and log records for reuse; pooled spans
+// keep their ID buffers
+var (
+	spanPool = sync.Pool{New: func() any {
+		return &tracepb.Span{TraceId: make([]byte, 16), SpanId: make([]byte, 8), ParentSpanId: make([]byte, 0, 8)}
+	}}
+	logRecordPool = sync.Pool{New: func() any { return new(logspb.LogRecord) }}
+)
+
+func releaseSpan(span *tracepb.Span) {
+	traceID, spanID, parentID := span.TraceId, span.SpanId, span.ParentSpanId
+	span.Reset()
+	span.TraceId, span.SpanId, span.ParentSpanId = traceID, spanID, parentID[:0]
+	spanPool.Put(span)
+}
+
+func releaseLogRecord(record *logspb.LogRecord) {
+	record.Reset()
+	logRecordPool.Put(record)
+}
+
+// fastSpan is the name and OTLP attributes of one span of a trace
+type fastSpan struct {
+	name  string
+	attrs []*commonpb.KeyValue
+}
+
+// fastTrace is a trace shape with its attributes in OTLP form, so a pooled shape is
+// converted only once
+type fastTrace struct {
+	parent   fastSpan
+	children []fastSpan
+}
+
+// newFastTrace converts a trace shape
+func newFastTrace(shape traceShape) fastTrace {
+	t := fastTrace{parent: fastSpan{name: shape.parent.name, attrs: attributesToProto(shape.parent.attrs)}}
+	for _, child := range shape.children {
+		t.children = append(t.children, fastSpan{name: child.name, attrs: attributesToProto(child.attrs)})
+	}
+	return t
+}
+
+// appendSpans appends the trace's spans, ending at now, to dst; the work
+// generateTrace sleeps through is drawn as span durations instead
+func (t fastTrace) appendSpans(rnd *runRandom, dst []*tracepb.Span, now time.Time) []*tracepb.Span {
+	n := 1 + len(t.children)
+	var traceID [16]byte
+	binary.LittleEndian.PutUint64(traceID[0:], rnd.Uint64())
+	binary.LittleEndian.PutUint64(traceID[8:], rnd.Uint64())
+
+	// The parent works before its children start, and ends after the last of them;
+	// child i runs from offsets[i] to offsets[i+1]
+	var fixed [5]time.Duration
+	offsets := append(fixed[:0], 0, time.Millisecond*time.Duration(rnd.Intn(100)))
+	for i := 2; i <= n; i++ {
+		offsets = append(offsets, offsets[i-1]+time.Millisecond*time.Duration(rnd.Intn(50)))
+	}
+	start := now.Add(-offsets[n])
+
+	var parent *tracepb.Span
+	for i := 0; i < n; i++ {
+		span := spanPool.Get().(*tracepb.Span)
+		copy(span.TraceId, traceID[:])
+		binary.LittleEndian.PutUint64(span.SpanId, rnd.Uint64())
+		span.Kind = tracepb.Span_SPAN_KIND_INTERNAL
+		if i == 0 {
+			parent = span
+			span.Name = t.parent.name
+			span.Attributes = t.parent.attrs
+			span.StartTimeUnixNano = unixNano(start)
+			span.EndTimeUnixNano = unixNano(now)
+		} else {
+			child := t.children[i-1]
+			span.Name = child.name
+			span.Attributes = child.attrs
+			span.ParentSpanId = append(span.ParentSpanId, parent.SpanId...)
+			span.StartTimeUnixNano = unixNano(start.Add(offsets[i]))
+			span.EndTimeUnixNano = unixNano(start.Add(offsets[i+1]))
+		}
+		dst = append(dst, span)
+	}
+	return dst
+}
+
+// fastLog is a log record's content in OTLP form, so a pooled record is converted
+// only once
+type fastLog struct {
+	severity logspb.SeverityNumber
+	level    string
+	body     *commonpb.AnyValue
+	attrs    []*commonpb.KeyValue
+}
+
+// newFastLog converts a log record's content
+func newFastLog(content logContent) fastLog {
+	attrs := make([]*commonpb.KeyValue, 0, len(content.attrs))
+	for _, kv := range content.attrs {
+		attrs = append(attrs, &commonpb.KeyValue{Key: kv.Key, Value: logValueToProto(kv.Value)})
+	}
+	return fastLog{
+		severity: logspb.SeverityNumber(content.severity),
+		level:    content.level,
+		body:     &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: content.body}},
+		attrs:    attrs,
+	}
+}
+
+// record builds the log record, timestamped now
+func (l fastLog) record(now time.Time) *logspb.LogRecord {
+	record := logRecordPool.Get().(*logspb.LogRecord)
+	record.TimeUnixNano = unixNano(now)
+	record.ObservedTimeUnixNano = record.TimeUnixNano
+	record.SeverityNumber = l.severity
+	record.SeverityText = l.level
+	record.Body = l.body
+	record.Attributes = l.attrs
+	return record
+}
+
+func logValueToProto(v log.Value) *commonpb.AnyValue {
+	switch v.Kind() {
+	case log.KindBool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case log.KindInt64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case log.KindFloat64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.String()}}
+	}
+}
@@ -0,0 +1,236 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileActiveName is the file being written; rotated files are renamed next to it
+const fileActiveName = "otelgen.log"
+
+// FileOptions configures the file payload writer
+type FileOptions struct {
+	// Dir holds the active file, otelgen.log, and the rotated files
+	Dir string
+	// Format is json (one JSON object per line) or plain (the log body alone)
+	Format string
+	// RotateSize rotates the active file once it reaches this many bytes; 0 disables it
+	RotateSize int64
+	// RotateEvery rotates the active file once it has been open this long; 0 disables it
+	RotateEvery time.Duration
+	// Compress gzips each rotated file
+	Compress bool
+}
+
+// FileWriter appends log records as lines to an active file, renaming it aside and
+// starting a new one when it grows too large or too old, the way logrotate's default
+// create mode does, so file-tailing agents see real rotations
+type FileWriter struct {
+	opts FileOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	opened   time.Time
+	rotated  int
+	compress sync.WaitGroup
+	errs     []error // from compressing rotated files
+	buf      bytes.Buffer
+}
+
+// NewFileWriter creates the directory if needed and opens the active file
+func NewFileWriter(opts FileOptions) (*FileWriter, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("directory cannot be empty")
+	}
+	if opts.Format == "" {
+		opts.Format = "json"
+	}
+	if opts.Format != "json" && opts.Format != "plain" {
+		return nil, fmt.Errorf("unsupported file format: %s (supported: json, plain)", opts.Format)
+	}
+	if opts.RotateSize < 0 || opts.RotateEvery < 0 {
+		return nil, fmt.Errorf("rotation size and interval cannot be negative")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	w := &FileWriter{opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *FileWriter) open() error {
+	f, err := os.OpenFile(filepath.Join(w.opts.Dir, fileActiveName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// WritePayload appends the records of an OTLP logs export request, rotating first if
+// the active file is due
+func (w *FileWriter) WritePayload(ctx context.Context, signal string, payload []byte) error {
+	entries, err := decodeLogEntries(payload)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Reset()
+	for _, entry := range entries {
+		if err := w.formatLine(entry); err != nil {
+			return err
+		}
+	}
+
+	if w.due(int64(w.buf.Len())) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(w.buf.Bytes())
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+	return nil
+}
+
+// formatLine appends one newline-terminated line for the record
+func (w *FileWriter) formatLine(entry logEntry) error {
+	if w.opts.Format == "plain" {
+		w.buf.WriteString(entry.Body)
+		w.buf.WriteByte('\n')
+		return nil
+	}
+
+	line := map[string]any{
+		"timestamp": entry.Time.UTC().Format(time.RFC3339Nano),
+		"level":     entry.Severity,
+		"message":   entry.Body,
+	}
+	if entry.Service != "" {
+		line["service.name"] = entry.Service
+	}
+	for _, attr := range entry.Attributes {
+		if _, ok := line[attr.Key]; !ok {
+			line[attr.Key] = attr.Value
+		}
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to encode log line: %w", err)
+	}
+	w.buf.Write(b)
+	w.buf.WriteByte('\n')
+	return nil
+}
+
+// due reports whether the active file must rotate before n more bytes are written; an
+// empty file is never rotated, so a single oversized batch still gets written
+func (w *FileWriter) due(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.RotateSize > 0 && w.size+n > w.opts.RotateSize {
+		return true
+	}
+	return w.opts.RotateEvery > 0 && time.Since(w.opened) >= w.opts.RotateEvery
+}
+
+// rotate renames the active file aside with a timestamp, compressing it in the
+// background if requested, and opens a new active file
+func (w *FileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	rotated := filepath.Join(w.opts.Dir, fmt.Sprintf("otelgen-%s.log", time.Now().UTC().Format("20060102T150405.000000000")))
+	if err := os.Rename(filepath.Join(w.opts.Dir, fileActiveName), rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	w.rotated++
+
+	if w.opts.Compress {
+		w.compress.Add(1)
+		go func() {
+			defer w.compress.Done()
+			if err := gzipFile(rotated); err != nil {
+				w.mu.Lock()
+				w.errs = append(w.errs, err)
+				w.mu.Unlock()
+			}
+		}()
+	}
+	return w.open()
+}
+
+// gzipFile replaces path with path.gz
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to compress rotated file: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return fmt.Errorf("failed to compress rotated file: %w", err)
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return fmt.Errorf("failed to compress rotated file: %w", err)
+	}
+	return os.Remove(path)
+}
+
+// Rotations returns how many times the active file has been rotated
+func (w *FileWriter) Rotations() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotated
+}
+
+// Close closes the active file and waits for rotated files to be compressed
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	err := w.file.Close()
+	w.mu.Unlock()
+
+	w.compress.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return errors.Join(append([]error{err}, w.errs...)...)
+}
+
+// Destination describes where the logs go, e.g. /var/log/otelgen/otelgen.log
+func (w *FileWriter) Destination() string {
+	return filepath.Join(w.opts.Dir, fileActiveName)
+}
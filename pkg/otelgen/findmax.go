@@ -0,0 +1,343 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// searchPrecision ends the search once the highest sustained rate and the lowest
+	// failed rate are within this fraction of each other
+	searchPrecision = 0.1
+	// maxSearchRate caps the search, in events per second
+	maxSearchRate = 1_000_000
+	// maxSearchInFlight bounds the events generated concurrently while searching
+	maxSearchInFlight = 10_000
+	// minKeptUp is the fraction of a probe's rate otelgen must generate for the
+	// probe to say anything about the endpoint
+	minKeptUp = 0.9
+)
+
+// FindMaxOptions configures the search for the highest rate the endpoint sustains
+type FindMaxOptions struct {
+	// Probe is how long each rate is held; the first fifth of it is not measured,
+	// while exports of the previous rate are still draining
+	Probe time.Duration
+	// MaxErrorRate is the highest fraction of failed exports a sustained rate may cause
+	MaxErrorRate float64
+	// MaxLatency is the highest p99 export latency a sustained rate may cause
+	MaxLatency time.Duration
+}
+
+func (o FindMaxOptions) validate() error {
+	if o.Probe <= 0 {
+		return fmt.Errorf("probe duration must be positive")
+	}
+	if o.MaxErrorRate < 0 || o.MaxErrorRate > 1 {
+		return fmt.Errorf("max error rate must be between 0%% and 100%%")
+	}
+	if o.MaxLatency <= 0 {
+		return fmt.Errorf("max latency must be positive")
+	}
+	return nil
+}
+
+// probeStats collects the exports completed during a probe
+type probeStats struct {
+	mu        sync.Mutex
+	exports   int
+	failed    int
+	latencies []time.Duration
+
+	// ended and delivered count the items ended and successfully exported over the
+	// whole probe, including the exports drained after it, to catch items the batch
+	// processors drop when exports can't keep up
+	ended     atomic.Int64
+	delivered atomic.Int64
+}
+
+func (s *probeStats) record(items int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exports++
+	if err != nil {
+		s.failed++
+	} else {
+		s.delivered.Add(int64(items))
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+// reset discards the exports recorded so far, keeping the items delivered
+func (s *probeStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exports, s.failed, s.latencies = 0, 0, s.latencies[:0]
+}
+
+// take returns the exports, failed exports, and latencies recorded since the last
+// take, and starts recording afresh
+func (s *probeStats) take() (int, int, []time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exports, failed, latencies := s.exports, s.failed, s.latencies
+	s.exports, s.failed, s.latencies = 0, 0, nil
+	return exports, failed, latencies
+}
+
+// snapshot returns the exports, failed exports, and p99 export latency recorded
+func (s *probeStats) snapshot() (int, int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.latencies) == 0 {
+		return s.exports, s.failed, 0
+	}
+	sorted := slices.Clone(s.latencies)
+	slices.Sort(sorted)
+	return s.exports, s.failed, percentile(sorted, 0.99)
+}
+
+// percentile returns the q quantile of sorted latencies
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	return sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+}
+
+// timedSpanExporter records the latency and outcome of each export in the probe stats
+type timedSpanExporter struct {
+	sdktrace.SpanExporter
+	stats *probeStats
+}
+
+func (e timedSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.stats.record(len(spans), time.Since(start), err)
+	return err
+}
+
+// endedSpans counts the spans ended in the probe stats
+type endedSpans struct {
+	stats *probeStats
+}
+
+func (p endedSpans) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p endedSpans) OnEnd(sdktrace.ReadOnlySpan) {
+	p.stats.ended.Add(1)
+}
+
+func (p endedSpans) ForceFlush(context.Context) error { return nil }
+
+func (p endedSpans) Shutdown(context.Context) error { return nil }
+
+// probeResult is the outcome of holding one rate
+type probeResult struct {
+	rate float64
+	// events is every event generated during the probe; generated is the rate of
+	// those generated after it settled
+	events    int64
+	generated float64
+	exports   int
+	failed    int
+	p99       time.Duration
+	// ended and delivered are the items ended and delivered over the probe
+	ended, delivered int64
+	// failure says why the endpoint did not sustain the rate, if it didn't
+	failure string
+}
+
+// keptUp reports whether otelgen generated close enough to the rate for the probe
+// to measure the endpoint rather than the generator
+func (r probeResult) keptUp() bool {
+	return r.generated >= minKeptUp*r.rate
+}
+
+// rateSearch finds the highest rate the endpoint sustains: it doubles the rate until
+// a probe fails, then bisects between the highest sustained and lowest failed rates
+type rateSearch struct {
+	opts  FindMaxOptions
+	start float64
+	// events names what the rate counts, and items what is exported
+	events, items string
+	arrival       string
+	gap           func() float64
+	stats         probeStats
+}
+
+// newRateSearch returns a search starting at rate, in events per second, for the load;
+// the search replaces the load shaping, so none may be set
+func (l LoadOptions) newRateSearch(rate float64, events, items string) (*rateSearch, error) {
+	if l.RampUp > 0 || l.RampDown > 0 || len(l.Steps) > 0 || l.Burst.Count > 0 || l.Pattern != nil || l.Throughput > 0 || l.Soak != nil || l.Warmup > 0 || l.Adaptive || l.ControlSocket != "" {
+		return nil, fmt.Errorf("finding the maximum rate cannot be combined with ramps, steps, bursts, a rate pattern, a throughput target, a soak, a warmup, adaptive pacing, or a control socket")
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	if err := l.FindMax.validate(); err != nil {
+		return nil, err
+	}
+	gap, err := arrivalGap(l.Arrival, l.newArrivalRandom())
+	if err != nil {
+		return nil, err
+	}
+	return &rateSearch{opts: *l.FindMax, start: rate, events: events, items: items, arrival: l.Arrival, gap: gap}, nil
+}
+
+// describe prints the search settings in verbose mode
+func (s *rateSearch) describe() {
+	fmt.Printf("[VERBOSE] Searching from %g %s/s, holding each rate for %s\n", s.start, s.events, s.opts.Probe)
+	fmt.Printf("[VERBOSE] A rate is sustained with at most %g%% failed exports and a p99 export latency of at most %s\n",
+		s.opts.MaxErrorRate*100, s.opts.MaxLatency)
+}
+
+// run searches, generating events with emit, which may be called concurrently, and
+// draining the exports with flush between probes; it returns the events generated
+func (s *rateSearch) run(emit func(), flush func(context.Context) error, flushTimeout time.Duration) int64 {
+	var events int64
+	var sustained, failed *probeResult
+	rate := s.start
+	for i := 1; ; i++ {
+		r := s.probe(rate, emit)
+		events += r.events
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		flush(ctx)
+		cancel()
+		s.evaluate(&r)
+
+		outcome := "sustained"
+		switch {
+		case !r.keptUp():
+			outcome = fmt.Sprintf("otelgen only generated %.1f/s", r.generated)
+		case r.failure != "":
+			outcome = "failed: " + r.failure
+		}
+		fmt.Printf("Probe %d: %g %s/s, %d exports (%d failed), %d of %d %s delivered, p99 export latency %s, %s\n",
+			i, r.rate, s.events, r.exports, r.failed, r.delivered, r.ended, s.items, r.p99.Round(time.Millisecond), outcome)
+
+		if !r.keptUp() {
+			s.printResult(sustained, failed, &r)
+			return events
+		}
+		if r.failure == "" {
+			sustained = &r
+		} else {
+			failed = &r
+		}
+
+		if failed == nil {
+			if rate >= maxSearchRate {
+				break
+			}
+			rate = min(rate*2, maxSearchRate)
+			continue
+		}
+		low := 0.0
+		if sustained != nil {
+			low = sustained.rate
+		}
+		if failed.rate-low <= max(1, low*searchPrecision) {
+			break
+		}
+		rate = math.Round((low + failed.rate) / 2)
+	}
+	s.printResult(sustained, failed, nil)
+	return events
+}
+
+// probe holds the rate for the probe duration, measuring the exports completed after
+// the first fifth of it
+func (s *rateSearch) probe(rate float64, emit func()) probeResult {
+	settle := s.opts.Probe / 5
+	profile := &loadProfile{phases: []*loadPhase{{name: "Probe", end: s.opts.Probe, rate: func(time.Duration) float64 { return rate }}}}
+	limiter := newRateLimiter(profile, rate, max(1, int(rate/20)), LoadBurst{}, s.arrival, s.gap, nil)
+	defer limiter.stop()
+
+	s.stats.reset()
+	s.stats.ended.Store(0)
+	s.stats.delivered.Store(0)
+
+	settleTimer := time.NewTimer(settle)
+	defer settleTimer.Stop()
+	timer := time.NewTimer(s.opts.Probe)
+	defer timer.Stop()
+
+	// Events are generated concurrently, so slow events don't hold back the rate
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, maxSearchInFlight)
+	var events, measured int64
+	settled := false
+	for {
+		select {
+		case <-settleTimer.C:
+			s.stats.reset()
+			settled = true
+		case <-timer.C:
+			exports, failed, p99 := s.stats.snapshot()
+			wg.Wait()
+			return probeResult{
+				rate:      rate,
+				events:    events,
+				generated: float64(measured) / (s.opts.Probe - settle).Seconds(),
+				exports:   exports,
+				failed:    failed,
+				p99:       p99,
+			}
+		case n := <-limiter.C:
+			for i := 0; i < n; i++ {
+				inFlight <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					emit()
+					<-inFlight
+				}()
+			}
+			events += int64(n)
+			if settled {
+				measured += int64(n)
+			}
+		}
+	}
+}
+
+// evaluate records the items delivered over the drained probe, and why the endpoint
+// did not sustain the rate, if it didn't
+func (s *rateSearch) evaluate(r *probeResult) {
+	r.ended, r.delivered = s.stats.ended.Load(), s.stats.delivered.Load()
+	switch {
+	case r.exports > 0 && float64(r.failed)/float64(r.exports) > s.opts.MaxErrorRate:
+		r.failure = fmt.Sprintf("%.1f%% of exports failed", 100*float64(r.failed)/float64(r.exports))
+	case r.ended > 0 && float64(r.ended-r.delivered)/float64(r.ended) > s.opts.MaxErrorRate:
+		r.failure = fmt.Sprintf("%.1f%% of %s not delivered", 100*float64(r.ended-r.delivered)/float64(r.ended), s.items)
+	case r.p99 > s.opts.MaxLatency:
+		r.failure = fmt.Sprintf("p99 export latency over %s", s.opts.MaxLatency)
+	}
+}
+
+// printResult prints the highest rate sustained and what limited it
+func (s *rateSearch) printResult(sustained, failed, limited *probeResult) {
+	if sustained == nil {
+		if limited != nil {
+			fmt.Printf("No maximum found: otelgen could not generate %g %s/s\n", limited.rate, s.events)
+			return
+		}
+		fmt.Printf("No rate sustained: %g %s/s failed (%s)\n", failed.rate, s.events, failed.failure)
+		return
+	}
+	fmt.Printf("Maximum sustained rate: %g %s/s\n", sustained.rate, s.events)
+	switch {
+	case limited != nil:
+		fmt.Printf("otelgen could not generate %g %s/s, so the endpoint may sustain more\n", limited.rate, s.events)
+	case failed != nil:
+		fmt.Printf("Lowest failed rate: %g %s/s (%s)\n", failed.rate, s.events, failed.failure)
+	default:
+		fmt.Printf("The endpoint sustained the search's ceiling of %d %s/s\n", maxSearchRate, s.events)
+	}
+}
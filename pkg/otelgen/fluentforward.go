@@ -0,0 +1,107 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FluentForwardOptions configures the Fluent Forward payload writer
+type FluentForwardOptions struct {
+	// Address is the fluentd or fluent-bit forward input, as host:port (default port 24224)
+	Address string
+	// Tag is attached to every event
+	Tag string
+	// DialTimeout bounds each connection attempt
+	DialTimeout time.Duration
+}
+
+// FluentForwardWriter sends each export's log records as one Fluent Forward message in
+// Forward mode, over a TCP connection that is re-established after a failed write
+type FluentForwardWriter struct {
+	tag string
+
+	mu   sync.Mutex
+	conn socketConn
+	enc  msgpackEncoder
+}
+
+// NewFluentForwardWriter connects to the forward input
+func NewFluentForwardWriter(opts FluentForwardOptions) (*FluentForwardWriter, error) {
+	address, err := withDefaultPort(opts.Address, "24224")
+	if err != nil {
+		return nil, err
+	}
+	w := &FluentForwardWriter{
+		tag:  opts.Tag,
+		conn: socketConn{network: "tcp", address: address, dialTimeout: opts.DialTimeout},
+	}
+	if w.tag == "" {
+		w.tag = "otelgen"
+	}
+	if err := w.conn.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WritePayload sends the records of an OTLP logs export request as
+// [tag, [[time, record], ...], {"size": n}]
+func (w *FluentForwardWriter) WritePayload(ctx context.Context, signal string, payload []byte) error {
+	entries, err := decodeLogEntries(payload)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.enc.Reset()
+	w.enc.writeArrayHeader(3)
+	w.enc.writeString(w.tag)
+	w.enc.writeArrayHeader(len(entries))
+	for _, entry := range entries {
+		w.enc.writeArrayHeader(2)
+		w.enc.writeEventTime(entry.Time)
+		writeFluentRecord(&w.enc, entry)
+	}
+	w.enc.writeMapHeader(1)
+	w.enc.writeString("size")
+	w.enc.writeInt(int64(len(entries)))
+	return w.conn.write(ctx, w.enc.Bytes())
+}
+
+// writeFluentRecord writes the event record: the body as message, the severity as
+// level, the service name, then the record's attributes
+func writeFluentRecord(enc *msgpackEncoder, entry logEntry) {
+	fields := 2 + len(entry.Attributes)
+	if entry.Service != "" {
+		fields++
+	}
+	enc.writeMapHeader(fields)
+	enc.writeString("message")
+	enc.writeString(entry.Body)
+	enc.writeString("level")
+	enc.writeString(entry.Severity)
+	if entry.Service != "" {
+		enc.writeString("service.name")
+		enc.writeString(entry.Service)
+	}
+	for _, attr := range entry.Attributes {
+		enc.writeString(attr.Key)
+		enc.writeValue(attr.Value)
+	}
+}
+
+// Close disconnects
+func (w *FluentForwardWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.close()
+}
+
+// Destination describes where the logs go, e.g. fluentforward://localhost:24224
+func (w *FluentForwardWriter) Destination() string {
+	return fmt.Sprintf("fluentforward://%s", w.conn.address)
+}
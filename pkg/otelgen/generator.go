@@ -0,0 +1,199 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// generatorRun is the setup the trace, metric, and log generators share
+type generatorRun struct {
+	signal    string
+	transport TransportOptions
+	load      LoadOptions
+	// ctx is what exports and the final flush run under. It outlives the caller's
+	// context, whose cancellation ends the run like an interrupt does; only connecting
+	// is cut short by it
+	ctx context.Context
+	// endpoint is the one the exporters are built for. A payload writer replaces it,
+	// and as its connection details do not apply, exporterVerbose leaves them out
+	endpoint        *Endpoint
+	exporterVerbose bool
+	// exportLog logs every export attempt, if asked
+	exportLog *exportLog
+	// usage measures otelgen's own resource usage
+	usage *usageMonitor
+}
+
+// newGeneratorRun sets up a run of signal; close it once the run's summaries are
+// deferred, so it ends after them
+func newGeneratorRun(parent context.Context, signal string, endpoint *Endpoint, verbose bool, transport TransportOptions, load LoadOptions) (*generatorRun, error) {
+	exportLog, err := transport.newExportLog()
+	if err != nil {
+		return nil, err
+	}
+	if transport.Writer == nil {
+		handleExportErrors(verbose)
+	}
+	return &generatorRun{
+		signal:          signal,
+		transport:       transport,
+		load:            load,
+		ctx:             context.WithoutCancel(parent),
+		endpoint:        transport.exportEndpoint(endpoint),
+		exporterVerbose: verbose && transport.Writer == nil,
+		exportLog:       exportLog,
+		usage:           load.newUsageMonitor(),
+	}, nil
+}
+
+// close reports the resource usage and closes the export log
+func (g *generatorRun) close() {
+	g.usage.stop()
+	g.exportLog.close()
+}
+
+// newResource returns the resource of the generated telemetry; numbered items carry
+// their run's ID on it
+func (g *generatorRun) newResource(serviceName string, stamps *itemStamps) (*resource.Resource, error) {
+	res, err := resource.New(g.ctx,
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion("1.0.0"),
+		),
+		resource.WithAttributes(stamps.resourceAttributes()...),
+		resource.WithAttributes(g.load.resourceAttributes()...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return res, nil
+}
+
+// newObserver returns an observer of the run's exports, which logs them to the
+// export log and the run's events
+func (g *generatorRun) newObserver(retries bool) *exportObserver {
+	obs := newExportObserver(g.signal, retries)
+	obs.name = g.load.Name
+	obs.log = g.exportLog
+	obs.events = g.load.Events
+	obs.latencyAlert = g.load.LatencyAlert
+	return obs
+}
+
+// runMonitors watch a run's exports as it goes; each is nil when the run has none
+type runMonitors struct {
+	// soak counts the exports for its checkpoints
+	soak *soakMonitor
+	// stats and report time every export
+	stats  *statsMonitor
+	report *runReport
+	// delivery counts the items the endpoint accepted
+	delivery *deliveryCheck
+	// self records every export as otelgen's own telemetry
+	self *selfTelemetry
+	// progress reports the exports of a coordinated run to its coordinator
+	progress *targetStats
+}
+
+// newMonitors returns the monitors of the run, whose items are what its rate counts;
+// obs sees the exports whose bytes and partial successes they count
+func (g *generatorRun) newMonitors(items string, duration time.Duration, serviceName string, obs *exportObserver, verbose bool) (*runMonitors, error) {
+	m := &runMonitors{}
+	var err error
+	m.soak, err = g.load.newSoakMonitor(g.signal, items, duration)
+	if err == nil {
+		m.stats, err = g.load.newStatsMonitor(g.signal, items, duration, g.transport.observed(obs)...)
+	}
+	if err == nil {
+		m.report, err = g.load.newRunReport(g.signal, items)
+	}
+	if err == nil {
+		m.delivery, err = g.load.newDeliveryCheck(g.signal, obs)
+	}
+	if err == nil {
+		m.self, err = g.load.newSelfTelemetry(g.signal, serviceName, g.transport, verbose)
+	}
+	// What started before the error is stopped
+	if err != nil {
+		m.stop()
+		return nil, err
+	}
+	if g.load.Progress != nil {
+		m.progress = &g.load.Progress.stats
+	}
+	return m, nil
+}
+
+// stop stops the monitors, flushing the last of the self-telemetry
+func (m *runMonitors) stop() {
+	m.self.shutdown()
+	m.stats.stop()
+	m.soak.stop()
+}
+
+// exporterWrappers wrap one signal's exporters to count, time, record, or dead-letter
+// their exports
+type exporterWrappers[E any] struct {
+	counting      func(E, *targetStats) E
+	timing        func(E, *probeStats) E
+	recording     func(E, *selfTelemetry) E
+	deadLettering func(E, *deadLetterQueue) E
+}
+
+// count wraps each exporter to count its exports in stats
+func (w exporterWrappers[E]) count(exporters []E, stats *targetStats) {
+	for i, e := range exporters {
+		exporters[i] = w.counting(e, stats)
+	}
+}
+
+// time wraps each exporter to time its exports in stats
+func (w exporterWrappers[E]) time(exporters []E, stats *probeStats) {
+	for i, e := range exporters {
+		exporters[i] = w.timing(e, stats)
+	}
+}
+
+// monitor wraps each exporter so the monitors see its exports, and has the raw client
+// of fast mode, if any, report its exports to them too
+func (w exporterWrappers[E]) monitor(exporters []E, raw *rawClient, m *runMonitors) {
+	if m.soak != nil {
+		w.count(exporters, &m.soak.stats)
+		raw.count(&m.soak.stats)
+	}
+	if m.stats != nil {
+		w.time(exporters, &m.stats.stats)
+		raw.time(&m.stats.stats)
+	}
+	if m.report != nil {
+		w.time(exporters, &m.report.stats)
+		m.report.watch(raw)
+	}
+	if m.delivery != nil {
+		w.count(exporters, &m.delivery.stats)
+		m.delivery.watch(raw)
+	}
+	if m.self != nil {
+		for i, e := range exporters {
+			exporters[i] = w.recording(e, m.self)
+		}
+		m.self.watch(raw)
+	}
+	if m.progress != nil {
+		w.count(exporters, m.progress)
+		raw.count(m.progress)
+	}
+}
+
+// deadLetter wraps each exporter, and has the raw client, if any, keep the exports
+// that fail for good in q
+func (w exporterWrappers[E]) deadLetter(exporters []E, raw *rawClient, q *deadLetterQueue) {
+	for i, e := range exporters {
+		exporters[i] = w.deadLettering(e, q)
+	}
+	raw.deadLetters(q)
+}
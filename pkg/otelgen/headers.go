@@ -0,0 +1,191 @@
+package otelgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultHMACHeader carries the request signature when no header is named
+const defaultHMACHeader = "X-Signature"
+
+// headerValues are the values a header template can use, e.g. {{.UUID}}; they are
+// drawn once per export, so every templated header of an export sees the same ones
+type headerValues struct {
+	// Timestamp is the time of the export in Unix seconds
+	Timestamp int64
+	// TimestampMillis is the time of the export in Unix milliseconds
+	TimestampMillis int64
+	// RFC3339 is the time of the export in RFC 3339 form, in UTC
+	RFC3339 string
+	// Nonce is 16 random bytes in hex
+	Nonce string
+	// UUID is a random (version 4) UUID
+	UUID string
+}
+
+func newHeaderValues() headerValues {
+	now := time.Now().UTC()
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	return headerValues{
+		Timestamp:       now.Unix(),
+		TimestampMillis: now.UnixMilli(),
+		RFC3339:         now.Format(time.RFC3339),
+		Nonce:           hex.EncodeToString(nonce),
+		UUID:            uuid.NewString(),
+	}
+}
+
+// requestHeaders computes the headers that change with every export: templated
+// header values and the HMAC signature of the request body
+type requestHeaders struct {
+	templates map[string]*template.Template
+	secret    []byte
+	signature string
+}
+
+// newRequestHeaders splits the templated headers from the static ones, returning the
+// static headers and the per-export headers, which are nil when every header is
+// static and exports are not signed
+func (t TransportOptions) newRequestHeaders(headers map[string]string) (map[string]string, *requestHeaders, error) {
+	h := &requestHeaders{templates: make(map[string]*template.Template)}
+	static := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if !strings.Contains(value, "{{") {
+			static[name] = value
+			continue
+		}
+		tmpl, err := template.New(name).Parse(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid template for header %s: %w", name, err)
+		}
+		if err := tmpl.Execute(io.Discard, newHeaderValues()); err != nil {
+			return nil, nil, fmt.Errorf("invalid template for header %s: %w", name, err)
+		}
+		h.templates[name] = tmpl
+	}
+
+	if t.HMACSecret != "" {
+		h.secret = []byte(t.HMACSecret)
+		h.signature = t.HMACHeader
+		if h.signature == "" {
+			h.signature = defaultHMACHeader
+		}
+	}
+	if len(h.templates) == 0 && h.secret == nil {
+		return static, nil, nil
+	}
+	return static, h, nil
+}
+
+// values evaluates the templated headers and signs the body, if exports are signed
+func (h *requestHeaders) values(body []byte) (map[string]string, error) {
+	values := make(map[string]string, len(h.templates)+1)
+	data := newHeaderValues()
+	for name, tmpl := range h.templates {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to evaluate header %s: %w", name, err)
+		}
+		values[name] = buf.String()
+	}
+	if h.secret != nil {
+		mac := hmac.New(sha256.New, h.secret)
+		mac.Write(body)
+		values[h.signature] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return values, nil
+}
+
+// describe prints the per-export headers in verbose mode
+func (h *requestHeaders) describe() {
+	if len(h.templates) > 0 {
+		var names []string
+		for name := range h.templates {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Printf("[VERBOSE] Evaluating templated headers for every export: %s\n", strings.Join(names, ", "))
+	}
+	if h.secret != nil {
+		fmt.Printf("[VERBOSE] Signing every export with HMAC-SHA256 in the %s header\n", h.signature)
+	}
+}
+
+// interceptor adds the per-export headers to each gRPC call as metadata, signing the
+// serialized request message
+func (h *requestHeaders) interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var body []byte
+		if h.secret != nil {
+			msg, ok := req.(proto.Message)
+			if !ok {
+				return fmt.Errorf("cannot sign %T request", req)
+			}
+			var err error
+			if body, err = proto.Marshal(msg); err != nil {
+				return fmt.Errorf("failed to marshal request for signing: %w", err)
+			}
+		}
+		values, err := h.values(body)
+		if err != nil {
+			return err
+		}
+		kv := make([]string, 0, 2*len(values))
+		for name, value := range values {
+			kv = append(kv, name, value)
+		}
+		return invoker(metadata.AppendToOutgoingContext(ctx, kv...), method, req, reply, cc, opts...)
+	}
+}
+
+// requestHeadersTransport adds the per-export headers to every HTTP request, signing
+// the body as sent, after encoding and compression
+type requestHeadersTransport struct {
+	base    http.RoundTripper
+	headers *requestHeaders
+}
+
+func (t *requestHeadersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if t.headers.secret != nil && req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request: %w", err)
+		}
+	}
+	values, err := t.headers.values(body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	for name, value := range values {
+		out.Header.Set(name, value)
+	}
+	if t.headers.secret != nil && req.Body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return t.base.RoundTrip(out)
+}
@@ -0,0 +1,75 @@
+package otelgen
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// healthWindow is the time over which readiness looks for a successful export
+const healthWindow = 30 * time.Second
+
+// health answers the liveness and readiness probes of a generator running in a
+// cluster: it is live as long as it answers, and ready once it generates, while its
+// exports aren't all failing, until it starts to stop
+type health struct {
+	progress *Progress
+	stopping atomic.Bool
+	// failing is set when the last window had exports and every one of them failed
+	failing atomic.Bool
+}
+
+// ServeHealth serves /healthz and /readyz on addr for the run that progress counts,
+// which turns unready once stop is closed
+func ServeHealth(addr string, progress *Progress, stop <-chan struct{}) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for health probes: %w", err)
+	}
+	h := &health{progress: progress}
+	go h.watch(stop)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", h.serveLive)
+	mux.HandleFunc("/readyz", h.serveReady)
+	go http.Serve(ln, mux)
+	fmt.Printf("Serving health probes on http://%s/healthz and /readyz\n", ln.Addr())
+	return nil
+}
+
+// watch checks the exports of every window, until the run starts to stop
+func (h *health) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(healthWindow)
+	defer ticker.Stop()
+	_, exports, failed := h.progress.Snapshot()
+	for {
+		select {
+		case <-ticker.C:
+			_, e, f := h.progress.Snapshot()
+			h.failing.Store(e > exports && f-failed == e-exports)
+			exports, failed = e, f
+		case <-stop:
+			h.stopping.Store(true)
+			return
+		}
+	}
+}
+
+func (h *health) serveLive(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+func (h *health) serveReady(w http.ResponseWriter, r *http.Request) {
+	events, _, _ := h.progress.Snapshot()
+	switch {
+	case h.stopping.Load():
+		http.Error(w, "stopping", http.StatusServiceUnavailable)
+	case events == 0:
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	case h.failing.Load():
+		http.Error(w, fmt.Sprintf("every export failed in the last %s", healthWindow), http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintln(w, "ready")
+	}
+}
@@ -0,0 +1,69 @@
+package otelgen
+
+import (
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// inconsistentHistograms occasionally emits histograms whose count, sum, min, max,
+// and buckets contradict each other, to exercise validation and repair logic
+type inconsistentHistograms struct {
+	start time.Time
+	rnd   *runRandom
+}
+
+func newInconsistentHistograms(rnd *runRandom) *inconsistentHistograms {
+	return &inconsistentHistograms{start: time.Now(), rnd: rnd}
+}
+
+var inconsistencyKinds = []string{"count_mismatch", "sum_outside_range", "min_greater_than_max", "bucket_length_mismatch", "unsorted_bounds"}
+
+// Metrics returns one inconsistent histogram on roughly one tick in ten
+func (h *inconsistentHistograms) Metrics(now time.Time) []*metricspb.Metric {
+	if h.rnd.Float64() >= injectionProbability {
+		return nil
+	}
+
+	// Start from a consistent datapoint: 10 samples of 5, 50, and 500ms
+	count := uint64(10)
+	sum, lo, hi := 4*5.0+4*50.0+2*500.0, 5.0, 500.0
+	dp := &metricspb.HistogramDataPoint{
+		StartTimeUnixNano: unixNano(h.start),
+		TimeUnixNano:      unixNano(now),
+		ExplicitBounds:    []float64{10, 100, 1000},
+		BucketCounts:      []uint64{4, 4, 2, 0},
+	}
+
+	kind := h.rnd.Intn(len(inconsistencyKinds))
+	switch kind {
+	case 0:
+		// Count disagrees with the bucket counts
+		count += uint64(h.rnd.Intn(10) + 1)
+	case 1:
+		// Sum cannot be produced by count samples within [min, max]
+		sum = hi * float64(count) * 2
+	case 2:
+		lo, hi = hi, lo
+	case 3:
+		dp.BucketCounts = []uint64{4, 4, 2}
+	default:
+		dp.ExplicitBounds = []float64{100, 10, 1000}
+	}
+
+	dp.Count = count
+	dp.Sum = &sum
+	dp.Min = &lo
+	dp.Max = &hi
+	dp.Attributes = []*commonpb.KeyValue{stringAttr("inconsistency.kind", inconsistencyKinds[kind])}
+
+	return []*metricspb.Metric{{
+		Name: "otelgen.inconsistent.histogram",
+		Unit: "ms",
+		Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			DataPoints:             []*metricspb.HistogramDataPoint{dp},
+		}},
+	}}
+}
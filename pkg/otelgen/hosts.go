@@ -0,0 +1,153 @@
+package otelgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+)
+
+// fleetHost is one simulated host with its own meter provider and metric set
+type fleetHost struct {
+	mp       *sdkmetric.MeterProvider
+	reader   *sdkmetric.ManualReader
+	recorder metricRecorder
+}
+
+// hostFleet simulates many hosts and batches all of their metrics into a single
+// export request per interval, the way a gateway collector forwards agent traffic
+type hostFleet struct {
+	hosts   []*fleetHost
+	src     *metricsSource
+	verbose bool
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newHostFleet creates one meter pipeline per host and starts exporting them periodically
+func newHostFleet(res *resource.Resource, src *metricsSource, verbose bool) (*hostFleet, error) {
+	f := &hostFleet{
+		src:     src,
+		verbose: verbose,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	for i := 0; i < src.opts.Hosts; i++ {
+		hostRes, err := resource.Merge(res, resource.NewSchemaless(
+			semconv.HostName(fmt.Sprintf("otelgen-host-%03d", i+1)),
+			semconv.HostID(fmt.Sprintf("host-%03d", i+1)),
+			semconv.ServiceInstanceID(fmt.Sprintf("%016x", src.rnd.Uint64())),
+		))
+		if err != nil {
+			f.shutdownHosts(context.Background())
+			return nil, fmt.Errorf("failed to create host resource: %w", err)
+		}
+
+		reader := sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(reader),
+			sdkmetric.WithResource(hostRes),
+			sdkmetric.WithView(src.views...),
+		)
+		recorder, err := newMetricRecorder(mp.Meter("otelgen"), src)
+		if err != nil {
+			mp.Shutdown(context.Background())
+			f.shutdownHosts(context.Background())
+			return nil, err
+		}
+		f.hosts = append(f.hosts, &fleetHost{mp: mp, reader: reader, recorder: recorder})
+	}
+
+	go f.run()
+	return f, nil
+}
+
+func (f *hostFleet) run() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(metricsExportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), f.src.timeout)
+			if err := f.export(ctx); err != nil {
+				fmt.Printf("Error exporting host metrics: %v\n", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// export collects every host and sends them as one request
+func (f *hostFleet) export(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	points := 0
+	for _, h := range f.hosts {
+		rm := &metricdata.ResourceMetrics{}
+		if err := h.reader.Collect(ctx, rm); err != nil {
+			return fmt.Errorf("failed to collect host metrics: %w", err)
+		}
+		if f.src.sparse != nil {
+			rm = f.src.sparse.Filter(rm)
+		}
+		prm := resourceMetricsToProto(rm)
+		for _, sm := range prm.ScopeMetrics {
+			points += countDataPoints(sm.Metrics)
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, prm)
+	}
+
+	if err := f.src.raw.ExportMetrics(ctx, req); err != nil {
+		return err
+	}
+	if f.verbose {
+		fmt.Printf("[VERBOSE] Exported %d datapoints from %d hosts in one request\n", points, len(f.hosts))
+	}
+	return nil
+}
+
+// Record records one event on every host
+func (f *hostFleet) Record(ctx context.Context) {
+	for _, h := range f.hosts {
+		h.recorder.Record(ctx)
+	}
+}
+
+// ForceFlush exports the current state of every host immediately
+func (f *hostFleet) ForceFlush(ctx context.Context) error {
+	return f.export(ctx)
+}
+
+// Shutdown stops the periodic export, sends the final values, and releases the hosts
+func (f *hostFleet) Shutdown(ctx context.Context) error {
+	close(f.stop)
+	<-f.done
+
+	err := f.export(ctx)
+	return errors.Join(err, f.shutdownHosts(ctx))
+}
+
+func (f *hostFleet) shutdownHosts(ctx context.Context) error {
+	var errs []error
+	for _, h := range f.hosts {
+		errs = append(errs, h.mp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
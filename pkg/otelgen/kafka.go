@@ -0,0 +1,92 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// kafkaDefaultTopics match the collector's kafka exporter and receiver defaults
+var kafkaDefaultTopics = map[string]string{
+	"traces":  "otlp_spans",
+	"metrics": "otlp_metrics",
+	"logs":    "otlp_logs",
+}
+
+// KafkaOptions configures the Kafka payload writer
+type KafkaOptions struct {
+	// Brokers are the seed brokers, as host:port
+	Brokers []string
+	// Topic receives every export; by default each signal goes to the collector's
+	// default topic for it (otlp_spans, otlp_metrics, otlp_logs)
+	Topic string
+	// DialTimeout bounds each broker connection attempt
+	DialTimeout time.Duration
+}
+
+// KafkaWriter produces each export request as one Kafka message, the way the
+// collector's kafka exporter does, so its kafka receiver can consume them
+type KafkaWriter struct {
+	client  *kgo.Client
+	brokers []string
+	topic   string
+}
+
+// NewKafkaWriter connects to the brokers, failing if none of them can be reached
+func NewKafkaWriter(opts KafkaOptions) (*KafkaWriter, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("at least one Kafka broker is required")
+	}
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultConnectTimeout
+	}
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(opts.Brokers...),
+		kgo.DialTimeout(dialTimeout),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Kafka brokers: %w", err)
+	}
+
+	return &KafkaWriter{client: client, brokers: opts.Brokers, topic: opts.Topic}, nil
+}
+
+// WritePayload produces the payload to the signal's topic and waits for the brokers
+// to acknowledge it
+func (w *KafkaWriter) WritePayload(ctx context.Context, signal string, payload []byte) error {
+	record := &kgo.Record{Topic: w.topicFor(signal), Value: payload}
+	if err := w.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce to Kafka topic %s: %w", record.Topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending messages and disconnects
+func (w *KafkaWriter) Close() error {
+	w.client.Close()
+	return nil
+}
+
+// Destination describes where the signal goes, e.g. kafka://b1:9092/otlp_logs
+func (w *KafkaWriter) Destination(signal string) string {
+	return fmt.Sprintf("kafka://%s/%s", strings.Join(w.brokers, ","), w.topicFor(signal))
+}
+
+func (w *KafkaWriter) topicFor(signal string) string {
+	if w.topic != "" {
+		return w.topic
+	}
+	return kafkaDefaultTopics[signal]
+}
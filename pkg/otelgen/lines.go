@@ -0,0 +1,85 @@
+package otelgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LineOptions configures the raw socket payload writer
+type LineOptions struct {
+	// Address is the agent's socket input, as host:port (default port 5140)
+	Address string
+	// Network is tcp or udp
+	Network string
+	// DialTimeout bounds each connection attempt
+	DialTimeout time.Duration
+}
+
+// LineWriter writes each log record's body as a newline-terminated line to a raw
+// socket, with no envelope
+type LineWriter struct {
+	mu   sync.Mutex
+	conn socketConn
+	buf  bytes.Buffer
+}
+
+// NewLineWriter connects to the socket input
+func NewLineWriter(opts LineOptions) (*LineWriter, error) {
+	if opts.Network != "tcp" && opts.Network != "udp" {
+		return nil, fmt.Errorf("unsupported network: %s (supported: tcp, udp)", opts.Network)
+	}
+	address, err := withDefaultPort(opts.Address, "5140")
+	if err != nil {
+		return nil, err
+	}
+	w := &LineWriter{conn: socketConn{network: opts.Network, address: address, dialTimeout: opts.DialTimeout}}
+	if err := w.conn.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WritePayload writes the records of an OTLP logs export request, together in one
+// write over tcp, or one datagram each over udp
+func (w *LineWriter) WritePayload(ctx context.Context, signal string, payload []byte) error {
+	entries, err := decodeLogEntries(payload)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Reset()
+	for _, entry := range entries {
+		if w.conn.network == "udp" {
+			w.buf.Reset()
+		}
+		w.buf.WriteString(entry.Body)
+		w.buf.WriteByte('\n')
+		if w.conn.network == "udp" {
+			if err := w.conn.write(ctx, w.buf.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+	if w.conn.network == "udp" || w.buf.Len() == 0 {
+		return nil
+	}
+	return w.conn.write(ctx, w.buf.Bytes())
+}
+
+// Close disconnects
+func (w *LineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.close()
+}
+
+// Destination describes where the logs go, e.g. tcp://localhost:5140
+func (w *LineWriter) Destination() string {
+	return w.conn.String()
+}
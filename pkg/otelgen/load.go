@@ -0,0 +1,772 @@
+package otelgen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// endless is the duration of a run without one, which goes on until its count, Stop,
+// or an interrupt ends it
+const endless = time.Duration(math.MaxInt64)
+
+// maxLimiterWait bounds each limiter sleep, so rate changes are picked up promptly
+// even while the rate is near zero
+const maxLimiterWait = 100 * time.Millisecond
+
+// LoadOptions shapes how fast events are generated
+type LoadOptions struct {
+	// RateBurst is the most events emitted at once when generation falls behind the
+	// rate, e.g. after a slow export; by default 50ms worth of events
+	RateBurst int
+	// RampUp scales the rate linearly from zero to the target over the start of the run
+	RampUp time.Duration
+	// RampDown scales the rate linearly from the target to zero over the end of the run
+	RampDown time.Duration
+	// Steps replace the rate and duration with a sequence of rate plateaus
+	Steps []LoadStep
+	// Burst superimposes periodic bursts of events on the rate
+	Burst LoadBurst
+	// Pattern, when set, replaces the rate with a cyclical curve
+	Pattern *RatePattern
+	// Throughput, when set, replaces the rate with a target in uncompressed OTLP bytes
+	// per second; the rate follows the average size of the events exported so far
+	Throughput float64
+	// FindMax, when set, replaces the rate and duration with a search for the highest
+	// rate the endpoint sustains
+	FindMax *FindMaxOptions
+	// PayloadPool, when positive, is the number of payloads generated up front and
+	// cycled through, for rates where generating each one would be the bottleneck
+	PayloadPool int
+	// Workers, when more than one, is the number of goroutines emitting traces or log
+	// records, for rates one cannot reach, such as traces, whose spans sleep to
+	// simulate work
+	Workers int
+	// Fast builds OTLP export requests directly and sends them over raw gRPC or HTTP
+	// clients, bypassing the SDK's providers, processors, and exporters, for rates the
+	// SDK cannot sustain; failed exports are not retried
+	Fast bool
+	// Adaptive slows the rate whenever the endpoint throttles an export, holding it for
+	// any delay the endpoint asks for, and regains the rate once it stops
+	Adaptive bool
+	// Warmup is the start of the run, within the duration, during which events are
+	// sent but not counted in the summary
+	Warmup time.Duration
+	// Arrival is how events are spaced around the rate: fixed (the default), evenly
+	// spaced; poisson, a Poisson process; or uniform, gaps drawn uniformly between zero
+	// and twice the mean
+	Arrival string
+	// Soak, when set, checkpoints the run's stats and otelgen's own memory and GC
+	// activity at an interval
+	Soak *SoakOptions
+	// Stats, when set, prints the achieved rate, exports, bytes sent, and export
+	// latency of every interval of the run
+	Stats *StatsOptions
+	// Progress, when set, counts the run's events and exports as it goes
+	Progress *Progress
+	// Stop, when set, ends the run early, with its usual summary, once it is closed
+	Stop <-chan struct{}
+	// Control, when set, changes the rate while the run goes on
+	Control *RateControl
+	// ControlSocket, when set, is the path of a Unix socket accepting rate changes
+	// while the run goes on, one command per line
+	ControlSocket string
+	// StampEmitTime adds the time each span or log record was emitted as an attribute,
+	// for a sink to measure end-to-end latency
+	StampEmitTime bool
+	// Sequence numbers each span or log record, and tags the resource with the run's
+	// ID, for a sink to find lost, duplicated, and reordered items
+	Sequence bool
+	// SelfTelemetry, when set, is an OTLP endpoint otelgen exports its own metrics to
+	SelfTelemetry *Endpoint
+	// TraceSelf also exports a span for every export to the self-telemetry endpoint,
+	// with its batch size, compression, and result
+	TraceSelf bool
+	// Report, when set, writes a report of the run as it ends and fails the run when
+	// it misses a threshold
+	Report *ReportOptions
+	// ProgressBar draws the run's progress, time left, and achieved rate against the
+	// target on stderr
+	ProgressBar bool
+	// Delivery, when set, ends the run once enough items are confirmed delivered, and
+	// fails it unless they are in time
+	Delivery *DeliveryOptions
+	// LatencyAlert, when positive, warns about every export attempt slower than this
+	// as it happens, and counts them in the summary
+	LatencyAlert time.Duration
+	// Name, when set, labels the run's export summary, for a command running several
+	// generators at once
+	Name string
+	// InstanceID, when set, is the service.instance.id of the run's resource, for the
+	// signals of one simulated service to share it
+	InstanceID string
+	// ResourceAttributes are added to the run's resource
+	ResourceAttributes map[string]string
+	// Seed, when set, seeds everything generated at random, so runs with the same seed
+	// generate the same payloads apart from their timestamps
+	Seed *int64
+	// MemLimit, when positive, aborts the run once otelgen's resident memory passes
+	// this many bytes
+	MemLimit int64
+	// Events, when set, receives the run's start, end, and phases, and every export
+	// attempt, as they happen
+	Events *EventStream
+	// Count, when positive, ends the run once this many traces, metric events, or log
+	// records have been generated, warmup included, unless the duration ends it first;
+	// with a zero duration, which otherwise runs until Stop, the run lasts as long as
+	// the count takes
+	Count int64
+}
+
+// RatePattern is a rate that follows a smooth cycle between a minimum and a maximum
+type RatePattern struct {
+	// Shape is sine, which starts at the minimum and peaks half a period in, or
+	// diurnal, a 24h sine that follows the local time of day
+	Shape string
+	// Period is the length of one sine cycle
+	Period time.Duration
+	// Min and Max bound the rate, in events per second
+	Min, Max float64
+	// Peak is the local time of day, since midnight, at which a diurnal rate peaks
+	Peak time.Duration
+}
+
+// ParseRatePattern parses a rate pattern like "sine:period=24h:min=10:max=500" or
+// "diurnal:min=10:max=500:peak=14h"
+func ParseRatePattern(s string) (*RatePattern, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ":")
+	p := &RatePattern{Shape: fields[0], Period: 24 * time.Hour, Peak: 14 * time.Hour}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pattern field %q (expected key=value)", field)
+		}
+		var err error
+		switch key {
+		case "period":
+			p.Period, err = time.ParseDuration(value)
+		case "peak":
+			p.Peak, err = time.ParseDuration(value)
+		case "min":
+			p.Min, err = ParsePerSecond(value)
+		case "max":
+			p.Max, err = ParsePerSecond(value)
+		default:
+			return nil, fmt.Errorf("unknown pattern field %q (supported: period, min, max, peak)", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", key, err)
+		}
+	}
+	return p, p.validate()
+}
+
+func (p *RatePattern) validate() error {
+	switch p.Shape {
+	case "sine":
+		if p.Period <= 0 {
+			return fmt.Errorf("pattern period must be positive")
+		}
+	case "diurnal":
+		if p.Peak < 0 || p.Peak >= 24*time.Hour {
+			return fmt.Errorf("diurnal peak must be a time of day between 0h and 24h")
+		}
+	default:
+		return fmt.Errorf("unknown rate pattern %q (supported: sine, diurnal)", p.Shape)
+	}
+	if p.Min < 0 || p.Max <= 0 || p.Max < p.Min {
+		return fmt.Errorf("pattern needs a positive max rate, at least the min rate")
+	}
+	return nil
+}
+
+// rate returns the rate function of the pattern for a run starting at start
+func (p *RatePattern) rate(start time.Time) func(time.Duration) float64 {
+	// level maps a position in the cycle, in [0, 1), to the rate; 0 is the minimum
+	level := func(cycle float64) float64 {
+		return p.Min + (p.Max-p.Min)*(1-math.Cos(2*math.Pi*cycle))/2
+	}
+	if p.Shape == "diurnal" {
+		return func(elapsed time.Duration) float64 {
+			t := start.Add(elapsed)
+			midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			sinceTrough := t.Sub(midnight) - p.Peak + 12*time.Hour
+			return level(float64(sinceTrough) / float64(24*time.Hour))
+		}
+	}
+	return func(elapsed time.Duration) float64 {
+		return level(float64(elapsed%p.Period) / float64(p.Period))
+	}
+}
+
+// LoadBurst is a burst of events sent at once, on top of the rate, at an interval
+type LoadBurst struct {
+	// Count is the number of events in each burst
+	Count int
+	// Every is the time between bursts; the first comes one interval into the run
+	Every time.Duration
+}
+
+// ParseBurst parses a burst like "count=5000,every=60s"
+func ParseBurst(s string) (LoadBurst, error) {
+	var b LoadBurst
+	if s == "" {
+		return b, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return LoadBurst{}, fmt.Errorf("invalid burst field %q (expected key=value)", field)
+		}
+		var err error
+		switch key {
+		case "count":
+			b.Count, err = strconv.Atoi(value)
+		case "every":
+			b.Every, err = time.ParseDuration(value)
+		default:
+			return LoadBurst{}, fmt.Errorf("unknown burst field %q (supported: count, every)", key)
+		}
+		if err != nil {
+			return LoadBurst{}, fmt.Errorf("invalid burst %s: %w", key, err)
+		}
+	}
+	if b.Count <= 0 || b.Every <= 0 {
+		return LoadBurst{}, fmt.Errorf("burst needs a positive count and every")
+	}
+	return b, nil
+}
+
+// SignalMix is the share of a combined run's total rate each signal gets, in percent
+type SignalMix struct {
+	Traces  int
+	Metrics int
+	Logs    int
+}
+
+// ParseMix parses a mix like "logs=70,metrics=20,traces=10", whose weights add up to
+// 100; a signal left out gets none of the rate
+func ParseMix(s string) (SignalMix, error) {
+	var m SignalMix
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return SignalMix{}, fmt.Errorf("invalid mix field %q (expected signal=weight)", field)
+		}
+		if seen[key] {
+			return SignalMix{}, fmt.Errorf("signal %q is given twice", key)
+		}
+		seen[key] = true
+		weight, err := strconv.Atoi(value)
+		if err != nil {
+			return SignalMix{}, fmt.Errorf("invalid mix weight for %s: %w", key, err)
+		}
+		if weight < 0 {
+			return SignalMix{}, fmt.Errorf("mix weight for %s cannot be negative", key)
+		}
+		switch key {
+		case "traces":
+			m.Traces = weight
+		case "metrics":
+			m.Metrics = weight
+		case "logs":
+			m.Logs = weight
+		default:
+			return SignalMix{}, fmt.Errorf("unknown mix signal %q (supported: traces, metrics, logs)", key)
+		}
+	}
+	if sum := m.Traces + m.Metrics + m.Logs; sum != 100 {
+		return SignalMix{}, fmt.Errorf("mix weights must add up to 100, not %d", sum)
+	}
+	return m, nil
+}
+
+// LoadStep is one plateau of a step load profile
+type LoadStep struct {
+	// Rate is the events per second sent during the step
+	Rate float64
+	// Duration is how long the step lasts
+	Duration time.Duration
+}
+
+// ParseSteps parses a step load profile like "10/s:5m,100/s:5m,1000/s:5m"
+func ParseSteps(s string) ([]LoadStep, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var steps []LoadStep
+	for _, entry := range strings.Split(s, ",") {
+		rateStr, durationStr, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid step %q (expected rate:duration, e.g. 100/s:5m)", entry)
+		}
+		rate, err := ParsePerSecond(rateStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid step %q: %w", entry, err)
+		}
+		d, err := time.ParseDuration(durationStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid step %q: %w", entry, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid step %q: duration must be positive", entry)
+		}
+		steps = append(steps, LoadStep{Rate: rate, Duration: d})
+	}
+	return steps, nil
+}
+
+// runDuration returns how long the run lasts: the total of the steps, if any, the
+// delivery window, or the duration
+func (l LoadOptions) runDuration(duration time.Duration) time.Duration {
+	if l.Delivery != nil {
+		return l.Delivery.window()
+	}
+	if duration == 0 && len(l.Steps) == 0 {
+		return endless
+	}
+	if len(l.Steps) == 0 {
+		return duration
+	}
+	var total time.Duration
+	for _, step := range l.Steps {
+		total += step.Duration
+	}
+	return total
+}
+
+// capCount limits the n events due to those left before the count, given the events
+// generated so far, and reports whether they reach it
+func (l LoadOptions) capCount(n, generated int) (int, bool) {
+	if l.Count <= 0 {
+		return n, false
+	}
+	left := max(int(l.Count)-generated, 0)
+	n = min(n, left)
+	return n, n == left
+}
+
+// loadPhase is a stretch of the run with its own rate, reported separately in the
+// summary
+type loadPhase struct {
+	name       string
+	start, end time.Duration
+	// rate is the target rate at a point in the run, in events per second
+	rate func(elapsed time.Duration) float64
+	// events counts the events handed out during the phase
+	events atomic.Int64
+}
+
+// loadProfile is the target rate over the run, as consecutive phases
+type loadProfile struct {
+	phases []*loadPhase
+	// peakRate is the highest rate, when the phases' start and end rates don't show it
+	peakRate float64
+}
+
+// profile returns the load profile for the target rate, in events per second, or for
+// the throughput target when there is one
+func (l LoadOptions) profile(rate float64, duration time.Duration, tput *throughputTarget) (*loadProfile, error) {
+	if tput != nil && (len(l.Steps) > 0 || l.Pattern != nil) {
+		return nil, fmt.Errorf("a throughput target cannot be combined with steps or a rate pattern")
+	}
+	if len(l.Steps) > 0 {
+		return l.stepProfile()
+	}
+	if l.Pattern != nil {
+		return l.patternProfile(duration)
+	}
+	target := func() float64 { return rate }
+	if tput != nil {
+		target = tput.rate
+	} else if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	if l.RampUp < 0 || l.RampDown < 0 {
+		return nil, fmt.Errorf("ramp-up and ramp-down cannot be negative")
+	}
+	if l.RampUp+l.RampDown > duration {
+		return nil, fmt.Errorf("ramp-up and ramp-down (%s) cannot exceed the duration (%s)", l.RampUp+l.RampDown, duration)
+	}
+
+	p := &loadProfile{}
+	if l.RampUp > 0 {
+		p.phases = append(p.phases, &loadPhase{name: "Ramp-up", start: 0, end: l.RampUp, rate: func(elapsed time.Duration) float64 {
+			return target() * float64(elapsed) / float64(l.RampUp)
+		}})
+	}
+	if steady := duration - l.RampUp - l.RampDown; steady > 0 {
+		p.phases = append(p.phases, &loadPhase{name: "Steady", start: l.RampUp, end: l.RampUp + steady, rate: func(time.Duration) float64 {
+			return target()
+		}})
+	}
+	if l.RampDown > 0 {
+		p.phases = append(p.phases, &loadPhase{name: "Ramp-down", start: duration - l.RampDown, end: duration, rate: func(elapsed time.Duration) float64 {
+			return max(0, target()*float64(duration-elapsed)/float64(l.RampDown))
+		}})
+	}
+	return p, nil
+}
+
+// stepProfile returns a profile with a phase for each step
+func (l LoadOptions) stepProfile() (*loadProfile, error) {
+	if l.RampUp > 0 || l.RampDown > 0 || l.Pattern != nil {
+		return nil, fmt.Errorf("steps cannot be combined with ramp-up, ramp-down, or a rate pattern")
+	}
+	p := &loadProfile{}
+	var start time.Duration
+	for i, step := range l.Steps {
+		if step.Rate < 0 || step.Duration <= 0 {
+			return nil, fmt.Errorf("step %d must have a non-negative rate and a positive duration", i+1)
+		}
+		rate := step.Rate
+		p.phases = append(p.phases, &loadPhase{
+			name:  fmt.Sprintf("Step %d (%s/s)", i+1, strconv.FormatFloat(rate, 'f', -1, 64)),
+			start: start,
+			end:   start + step.Duration,
+			rate:  func(time.Duration) float64 { return rate },
+		})
+		start += step.Duration
+	}
+	return p, nil
+}
+
+// patternProfile returns a profile following the rate pattern for the whole run
+func (l LoadOptions) patternProfile(duration time.Duration) (*loadProfile, error) {
+	if l.RampUp > 0 || l.RampDown > 0 {
+		return nil, fmt.Errorf("a rate pattern cannot be combined with ramp-up or ramp-down")
+	}
+	if err := l.Pattern.validate(); err != nil {
+		return nil, err
+	}
+	p := &loadProfile{peakRate: l.Pattern.Max}
+	p.phases = append(p.phases, &loadPhase{name: "Pattern", end: duration, rate: l.Pattern.rate(time.Now())})
+	return p, nil
+}
+
+// peak returns the highest rate of the profile, which sizes the default burst
+func (p *loadProfile) peak() float64 {
+	peak := p.peakRate
+	for _, ph := range p.phases {
+		peak = max(peak, ph.rate(ph.start), ph.rate(ph.end))
+	}
+	return peak
+}
+
+// phase returns the phase running at a point in the run; past the end, the last one
+func (p *loadProfile) phase(elapsed time.Duration) *loadPhase {
+	for _, ph := range p.phases {
+		if elapsed < ph.end {
+			return ph
+		}
+	}
+	return p.phases[len(p.phases)-1]
+}
+
+// arrivalGap returns the function drawing the gap before each event from rnd, in
+// multiples of the mean gap at the current rate
+func arrivalGap(arrival string, rnd *rand.Rand) (func() float64, error) {
+	switch arrival {
+	case "", "fixed":
+		return func() float64 { return 1 }, nil
+	case "poisson":
+		return rnd.ExpFloat64, nil
+	case "uniform":
+		return func() float64 { return 2 * rnd.Float64() }, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival process %q (supported: fixed, poisson, uniform)", arrival)
+	}
+}
+
+// limiter returns a rate limiter following the load profile for the target rate, or
+// for the throughput target when there is one, scaled by the pacer if there is one
+func (l LoadOptions) limiter(rate float64, duration time.Duration, tput *throughputTarget, pacer *adaptivePacer) (*rateLimiter, error) {
+	if l.Count < 0 {
+		return nil, fmt.Errorf("count cannot be negative")
+	}
+	if duration == endless && l.RampDown > 0 {
+		return nil, fmt.Errorf("a run without a duration has no end to ramp down to")
+	}
+	profile, err := l.profile(rate, duration, tput)
+	if err != nil {
+		return nil, err
+	}
+	control := l.Control
+	if control == nil && l.ControlSocket != "" {
+		control = NewRateControl()
+	}
+	if control != nil {
+		control.control(profile)
+	}
+	if pacer != nil {
+		pacer.pace(profile)
+	}
+	if l.RateBurst < 0 {
+		return nil, fmt.Errorf("rate burst cannot be negative")
+	}
+	if l.PayloadPool < 0 {
+		return nil, fmt.Errorf("payload pool size cannot be negative")
+	}
+	if l.Warmup < 0 || l.Warmup >= duration {
+		return nil, fmt.Errorf("warmup (%s) must be shorter than the duration (%s)", l.Warmup, duration)
+	}
+	if l.Burst.Count < 0 || l.Burst.Every < 0 || (l.Burst.Count > 0) != (l.Burst.Every > 0) {
+		return nil, fmt.Errorf("burst needs a positive count and every")
+	}
+	gap, err := arrivalGap(l.Arrival, l.newArrivalRandom())
+	if err != nil {
+		return nil, err
+	}
+	peak := profile.peak()
+	burst := l.RateBurst
+	if burst == 0 {
+		burst = max(1, int(peak/20))
+	}
+	var closeControl func()
+	if l.ControlSocket != "" {
+		if closeControl, err = control.serve(l.ControlSocket); err != nil {
+			return nil, err
+		}
+	}
+	limiter := newRateLimiter(profile, peak, burst, l.Burst, l.Arrival, gap, l.Events)
+	limiter.closeControl = closeControl
+	return limiter, nil
+}
+
+// rateLimiter is a token bucket handing out the events to emit: each value received
+// from C is the number of events due, from 1 up to the burst size. Unlike a ticker,
+// the average rate stays exact at any rate, since events that come due between
+// wakeups are handed out together instead of being lost to timer resolution. Each
+// event comes due once the tokens reach a gap drawn from the arrival process, which
+// averages one token, so the events are spaced randomly around the rate.
+type rateLimiter struct {
+	C       <-chan int
+	profile *loadProfile
+	peak    float64
+	burst   int
+	spikes  LoadBurst
+	arrival string
+	gap     func() float64
+	start   time.Time
+	done    chan struct{}
+	// spiked counts the bursts handed out
+	spiked atomic.Int64
+	// missed counts the events that came due while generation was too busy to take
+	// them, and overflowed the bucket; warmupMissed is its count as the warmup ended
+	missed       atomic.Int64
+	warmupMissed int64
+	// counted is how far into the run the summary starts, after any warmup
+	counted time.Duration
+	// closeControl closes the control socket, if any
+	closeControl func()
+	// events, when set, records each phase the run enters
+	events *EventStream
+}
+
+func newRateLimiter(profile *loadProfile, peak float64, burst int, spikes LoadBurst, arrival string, gap func() float64, events *EventStream) *rateLimiter {
+	c := make(chan int)
+	l := &rateLimiter{
+		C:       c,
+		profile: profile,
+		peak:    peak,
+		burst:   burst,
+		spikes:  spikes,
+		arrival: arrival,
+		gap:     gap,
+		start:   time.Now(),
+		done:    make(chan struct{}),
+		events:  events,
+	}
+	go l.run(c)
+	return l
+}
+
+func (l *rateLimiter) run(c chan<- int) {
+	tokens, missed := 0.0, 0.0
+	last := l.start
+	gap := l.gap()
+
+	// Bursts bypass the bucket, so they go out whole on top of the rate
+	pending, spikes := 0, 0
+	var nextSpike time.Time
+	if l.spikes.Count > 0 {
+		nextSpike = l.start.Add(l.spikes.Every)
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	var phase *loadPhase
+	for {
+		// Tokens accrue at the rate midway through the interval, which tracks ramps closely
+		now := time.Now()
+		mid := last.Add(now.Sub(last) / 2).Sub(l.start)
+		ph := l.profile.phase(mid)
+		rate := ph.rate(mid)
+		if ph != phase && len(l.profile.phases) > 1 {
+			l.events.phase(ph.name, rate)
+			phase = ph
+		}
+		// The bucket always holds enough for the next event, however long its gap; what
+		// overflows it came due while nothing was taking the events
+		tokens += now.Sub(last).Seconds() * rate
+		if limit := max(float64(l.burst), gap); tokens > limit {
+			missed += tokens - limit
+			l.missed.Store(int64(missed))
+			tokens = limit
+		}
+		last = now
+		if !nextSpike.IsZero() && !now.Before(nextSpike) {
+			pending += l.spikes.Count
+			spikes++
+			nextSpike = nextSpike.Add(l.spikes.Every)
+		}
+
+		n := 0
+		for tokens >= gap && n < l.burst {
+			tokens -= gap
+			gap = l.gap()
+			n++
+		}
+
+		if n == 0 && pending == 0 {
+			wait := maxLimiterWait
+			if rate > 0 {
+				wait = min(wait, time.Duration((gap-tokens)/rate*float64(time.Second)))
+			}
+			if !nextSpike.IsZero() {
+				wait = min(wait, nextSpike.Sub(now))
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-l.done:
+				return
+			}
+			continue
+		}
+
+		select {
+		case c <- n + pending:
+			l.profile.phase(time.Since(l.start)).events.Add(int64(n + pending))
+			l.spiked.Add(int64(spikes))
+			pending, spikes = 0, 0
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// target returns the rate the limiter aims for at elapsed into the run, in events per
+// second, leaving out bursts
+func (l *rateLimiter) target(elapsed time.Duration) float64 {
+	return l.profile.phase(elapsed).rate(elapsed)
+}
+
+// endWarmup restarts the phase and burst counts, so the summary leaves out the warmup
+func (l *rateLimiter) endWarmup() {
+	l.counted = time.Since(l.start)
+	for _, ph := range l.profile.phases {
+		ph.events.Store(0)
+	}
+	l.spiked.Store(0)
+	l.warmupMissed = l.missed.Load()
+}
+
+// warmupTimer returns a channel that fires once the warmup is over, or never without
+// one, and the function stopping it
+func (l LoadOptions) warmupTimer() (<-chan time.Time, func() bool) {
+	if l.Warmup <= 0 {
+		return nil, func() bool { return false }
+	}
+	timer := time.NewTimer(l.Warmup)
+	return timer.C, timer.Stop
+}
+
+// endWarmup restarts the summary's counts once the warmup is over; the observer and
+// throughput target may be nil
+func endWarmup(limiter *rateLimiter, obs *exportObserver, targets []*target, tput *throughputTarget) {
+	limiter.endWarmup()
+	if obs != nil {
+		obs.endWarmup()
+	}
+	for _, tg := range targets {
+		tg.reset()
+	}
+	if tput != nil {
+		tput.endWarmup()
+	}
+}
+
+// printWarmup prints the events the warmup left out of the summary
+func (l LoadOptions) printWarmup(events int, items string) {
+	if l.Warmup > 0 {
+		fmt.Printf("Excluded %d %s sent during the %s warmup\n", events, items, l.Warmup)
+	}
+}
+
+// stop stops handing out events
+func (l *rateLimiter) stop() {
+	close(l.done)
+	if l.closeControl != nil {
+		l.closeControl()
+	}
+}
+
+// describe prints the limiter settings in verbose mode
+func (l *rateLimiter) describe() {
+	fmt.Printf("[VERBOSE] Limiting to %g events/s in bursts of up to %d\n", l.peak, l.burst)
+	if l.arrival != "" && l.arrival != "fixed" {
+		fmt.Printf("[VERBOSE] Spacing events as %s arrivals\n", l.arrival)
+	}
+	if l.spikes.Count > 0 {
+		fmt.Printf("[VERBOSE] Adding a burst of %d events every %s\n", l.spikes.Count, l.spikes.Every)
+	}
+	if len(l.profile.phases) > 1 {
+		for _, ph := range l.profile.phases {
+			fmt.Printf("[VERBOSE] %s phase from %s to %s\n", ph.name, ph.start, ph.end)
+		}
+	}
+}
+
+// printSummary prints the bursts sent, a warning when generation fell short of the
+// rate, and the events and achieved rate of each phase that has started when the run
+// has more than one
+func (l *rateLimiter) printSummary(items string) {
+	if l.spikes.Count > 0 {
+		spiked := l.spiked.Load()
+		fmt.Printf("Sent %d bursts (%d %s)\n", spiked, spiked*int64(l.spikes.Count), items)
+	}
+	elapsed := time.Since(l.start)
+	var sent int64
+	for _, ph := range l.profile.phases {
+		sent += ph.events.Load()
+	}
+	// A shortfall within the rounding of a short run is not worth a warning
+	if missed := l.missed.Load() - l.warmupMissed; missed > 0 && float64(missed) > 0.05*float64(sent+missed) {
+		fmt.Printf("WARNING: generation fell behind the rate: %d of the %d %s due (%.1f%%) were never sent\n",
+			missed, sent+missed, items, float64(missed)/float64(sent+missed)*100)
+	}
+	if len(l.profile.phases) < 2 {
+		return
+	}
+	for _, ph := range l.profile.phases {
+		if ph.start >= elapsed {
+			break
+		}
+		if ph.end <= l.counted {
+			continue
+		}
+		length := min(ph.end, elapsed) - max(ph.start, l.counted)
+		events := ph.events.Load()
+		fmt.Printf("%s: %d %s in %s (%.1f/s)\n", ph.name, events, items, length.Round(time.Millisecond), float64(events)/length.Seconds())
+	}
+}
@@ -0,0 +1,102 @@
+package otelgen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SignalMix
+		wantErr bool
+	}{
+		{in: "logs=70,metrics=20,traces=10", want: SignalMix{Traces: 10, Metrics: 20, Logs: 70}},
+		{in: " traces=50 , logs=50 ", want: SignalMix{Traces: 50, Logs: 50}},
+		{in: "metrics=100", want: SignalMix{Metrics: 100}},
+		{in: "traces=100,logs=0", want: SignalMix{Traces: 100}},
+		{in: "", wantErr: true},
+		{in: "traces", wantErr: true},
+		{in: "traces=50,traces=50", wantErr: true},
+		{in: "traces=x,logs=100", wantErr: true},
+		{in: "traces=-10,logs=110", wantErr: true},
+		{in: "spans=100", wantErr: true},
+		{in: "traces=50,logs=40", wantErr: true},
+		{in: "traces=60,logs=60", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMix(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMix(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []LoadStep
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "10/s:5m", want: []LoadStep{{Rate: 10, Duration: 5 * time.Minute}}},
+		{in: "10/s:5m,100/s:5m,1000/s:5m", want: []LoadStep{
+			{Rate: 10, Duration: 5 * time.Minute},
+			{Rate: 100, Duration: 5 * time.Minute},
+			{Rate: 1000, Duration: 5 * time.Minute},
+		}},
+		{in: "600/min:30s, 0:1m", want: []LoadStep{
+			{Rate: 10, Duration: 30 * time.Second},
+			{Rate: 0, Duration: time.Minute},
+		}},
+		{in: "10/s", wantErr: true},
+		{in: "fast:5m", wantErr: true},
+		{in: "10/s:soon", wantErr: true},
+		{in: "10/s:0s", wantErr: true},
+		{in: "10/s:-1m", wantErr: true},
+		{in: "10/s:5m,", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSteps(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSteps(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSteps(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCapCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int64
+		n         int
+		generated int
+		want      int
+		wantLast  bool
+	}{
+		{name: "no count", count: 0, n: 5, generated: 100, want: 5},
+		{name: "negative count", count: -1, n: 5, generated: 100, want: 5},
+		{name: "well before the count", count: 100, n: 5, generated: 10, want: 5},
+		{name: "just reaching the count", count: 100, n: 5, generated: 95, want: 5, wantLast: true},
+		{name: "past the count", count: 100, n: 5, generated: 98, want: 2, wantLast: true},
+		{name: "count already reached", count: 100, n: 5, generated: 100, want: 0, wantLast: true},
+		{name: "generated beyond the count", count: 100, n: 5, generated: 120, want: 0, wantLast: true},
+		{name: "nothing due", count: 100, n: 0, generated: 10, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, last := LoadOptions{Count: tt.count}.capCount(tt.n, tt.generated)
+			if got != tt.want || last != tt.wantLast {
+				t.Errorf("capCount(%d, %d) with count %d = %d, %v, want %d, %v", tt.n, tt.generated, tt.count, got, last, tt.want, tt.wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package otelgen
+
+import (
+	"fmt"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// logEntry is one log record flattened out of an OTLP logs export request, for
+// exporters that write plain log events rather than OTLP
+type logEntry struct {
+	Time       time.Time
+	Severity   string
+	Body       string
+	Service    string
+	Attributes []logAttribute // in record order
+}
+
+type logAttribute struct {
+	Key   string
+	Value any
+}
+
+// decodeLogEntries flattens a protobuf OTLP logs export request into its records
+func decodeLogEntries(payload []byte) ([]logEntry, error) {
+	var req collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode logs request: %w", err)
+	}
+
+	var entries []logEntry
+	for _, rl := range req.ResourceLogs {
+		var service string
+		for _, kv := range rl.GetResource().GetAttributes() {
+			if kv.Key == "service.name" {
+				service = kv.GetValue().GetStringValue()
+			}
+		}
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				ts := lr.TimeUnixNano
+				if ts == 0 {
+					ts = lr.ObservedTimeUnixNano
+				}
+				entry := logEntry{
+					Time:     time.Unix(0, int64(ts)),
+					Severity: lr.SeverityText,
+					Body:     anyValueString(lr.Body),
+					Service:  service,
+				}
+				for _, kv := range lr.Attributes {
+					entry.Attributes = append(entry.Attributes, logAttribute{Key: kv.Key, Value: anyValue(kv.Value)})
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// anyValue converts an OTLP attribute value to the matching Go value
+func anyValue(v *commonpb.AnyValue) any {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]any, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, anyValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		values := make(map[string]any, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.Key] = anyValue(kv.Value)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// anyValueString returns a log body as text; the generator only emits string bodies
+func anyValueString(v *commonpb.AnyValue) string {
+	if s, ok := v.GetValue().(*commonpb.AnyValue_StringValue); ok {
+		return s.StringValue
+	}
+	if value := anyValue(v); value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
@@ -2,20 +2,17 @@ package otelgen
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/log"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"google.golang.org/grpc/credentials"
 )
 
@@ -72,57 +69,66 @@ var errorMessages = []string{
 }
 
 // generateRealisticLogPayload creates a realistic JSON log payload
-func generateRealisticLogPayload(baseMessage string, level string, targetSize int64) string {
+func generateRealisticLogPayload(rnd *runRandom, baseMessage string, level string, targetSize int64) string {
+	// A seeded run leaves the clock out, so it repeats the body byte for byte
+	now := rnd.now()
+	requestID := "req-" + randomString(rnd, 16)
+	if !now.IsZero() {
+		requestID += fmt.Sprintf("-%d", now.Unix())
+	}
 	logData := map[string]interface{}{
-		"timestamp":    time.Now().Format(time.RFC3339Nano),
 		"level":        level,
 		"message":      baseMessage,
 		"service":      "api-gateway",
 		"environment":  "production",
 		"version":      "v1.2.3",
-		"host":         fmt.Sprintf("server-%d", rand.Intn(10)),
-		"pod_id":       fmt.Sprintf("pod-%d-%s", rand.Intn(100), randomString(8)),
-		"request_id":   fmt.Sprintf("req-%s-%d", randomString(16), time.Now().Unix()),
-		"trace_id":     randomString(32),
-		"span_id":      randomString(16),
+		"host":         fmt.Sprintf("server-%d", rnd.Intn(10)),
+		"pod_id":       fmt.Sprintf("pod-%d-%s", rnd.Intn(100), randomString(rnd, 8)),
+		"request_id":   requestID,
+		"trace_id":     randomString(rnd, 32),
+		"span_id":      randomString(rnd, 16),
 		"http": map[string]interface{}{
-			"method":      httpMethods[rand.Intn(len(httpMethods))],
-			"endpoint":    endpoints[rand.Intn(len(endpoints))],
-			"status_code": []int{200, 201, 400, 401, 403, 404, 500, 502, 503}[rand.Intn(9)],
-			"duration_ms": rand.Intn(5000),
-			"user_agent":  userAgents[rand.Intn(len(userAgents))],
-			"client_ip":   fmt.Sprintf("10.%d.%d.%d", rand.Intn(256), rand.Intn(256), rand.Intn(256)),
+			"method":      httpMethods[rnd.Intn(len(httpMethods))],
+			"endpoint":    endpoints[rnd.Intn(len(endpoints))],
+			"status_code": []int{200, 201, 400, 401, 403, 404, 500, 502, 503}[rnd.Intn(9)],
+			"duration_ms": rnd.Intn(5000),
+			"user_agent":  userAgents[rnd.Intn(len(userAgents))],
+			"client_ip":   fmt.Sprintf("10.%d.%d.%d", rnd.Intn(256), rnd.Intn(256), rnd.Intn(256)),
 		},
 		"user": map[string]interface{}{
-			"id":       fmt.Sprintf("user_%d", rand.Intn(10000)),
-			"email":    fmt.Sprintf("user%d@example.com", rand.Intn(10000)),
-			"role":     []string{"admin", "user", "guest", "developer"}[rand.Intn(4)],
-			"org_id":   fmt.Sprintf("org_%d", rand.Intn(100)),
+			"id":       fmt.Sprintf("user_%d", rnd.Intn(10000)),
+			"email":    fmt.Sprintf("user%d@example.com", rnd.Intn(10000)),
+			"role":     []string{"admin", "user", "guest", "developer"}[rnd.Intn(4)],
+			"org_id":   fmt.Sprintf("org_%d", rnd.Intn(100)),
 		},
 	}
 
+	if !now.IsZero() {
+		logData["timestamp"] = now.Format(time.RFC3339Nano)
+	}
+
 	// Add error details for ERROR level
 	if level == "ERROR" {
 		logData["error"] = map[string]interface{}{
 			"type":    "ServiceError",
-			"message": errorMessages[rand.Intn(len(errorMessages))],
+			"message": errorMessages[rnd.Intn(len(errorMessages))],
 			"stack_trace": fmt.Sprintf("at com.example.service.Handler.handle(%s.java:%d)\n"+
 				"at com.example.service.Processor.process(%s.java:%d)\n"+
 				"at com.example.service.Worker.run(%s.java:%d)",
-				randomString(10), rand.Intn(500),
-				randomString(10), rand.Intn(500),
-				randomString(10), rand.Intn(500)),
-			"code": fmt.Sprintf("ERR_%d", rand.Intn(9999)),
+				randomString(rnd, 10), rnd.Intn(500),
+				randomString(rnd, 10), rnd.Intn(500),
+				randomString(rnd, 10), rnd.Intn(500)),
+			"code": fmt.Sprintf("ERR_%d", rnd.Intn(9999)),
 		}
 	}
 
 	// Add database info occasionally
-	if rand.Float32() < 0.3 {
+	if rnd.Float32() < 0.3 {
 		logData["database"] = map[string]interface{}{
-			"query_time_ms": rand.Intn(1000),
-			"rows_affected": rand.Intn(100),
-			"connection_id": rand.Intn(50),
-			"database":      []string{"users_db", "orders_db", "products_db", "analytics_db"}[rand.Intn(4)],
+			"query_time_ms": rnd.Intn(1000),
+			"rows_affected": rnd.Intn(100),
+			"connection_id": rnd.Intn(50),
+			"database":      []string{"users_db", "orders_db", "products_db", "analytics_db"}[rnd.Intn(4)],
 		}
 	}
 
@@ -134,7 +140,7 @@ func generateRealisticLogPayload(baseMessage string, level string, targetSize in
 	if targetSize > 0 && currentSize < targetSize {
 		remainingSize := targetSize - currentSize - 100 // Reserve space for field name and JSON structure
 		if remainingSize > 0 {
-			logData["payload_data"] = randomString(int(remainingSize))
+			logData["payload_data"] = randomString(rnd, int(remainingSize))
 			jsonBytes, _ = json.Marshal(logData)
 		}
 	}
@@ -143,176 +149,298 @@ func generateRealisticLogPayload(baseMessage string, level string, targetSize in
 }
 
 // randomString generates a random alphanumeric string of specified length
-func randomString(length int) string {
+func randomString(rnd *runRandom, length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	var sb strings.Builder
 	sb.Grow(length)
 	for i := 0; i < length; i++ {
-		sb.WriteByte(charset[rand.Intn(len(charset))])
+		sb.WriteByte(charset[rnd.Intn(len(charset))])
 	}
 	return sb.String()
 }
 
-// GenerateLogs generates log data and sends it to the specified OTLP endpoint
-func GenerateLogs(endpoint *Endpoint, serviceName string, rate int, durationStr string, payloadSize int64, batchSize int, headers map[string]string, verbose bool, insecureSkip bool) error {
-	duration, err := time.ParseDuration(durationStr)
+// logWrappers wrap log exporters for the monitors of a log run
+var logWrappers = exporterWrappers[sdklog.Exporter]{
+	counting: func(e sdklog.Exporter, stats *targetStats) sdklog.Exporter {
+		return countingLogExporter{Exporter: e, stats: stats}
+	},
+	timing: func(e sdklog.Exporter, stats *probeStats) sdklog.Exporter {
+		return timedLogExporter{Exporter: e, stats: stats}
+	},
+	recording: func(e sdklog.Exporter, self *selfTelemetry) sdklog.Exporter {
+		return selfLogExporter{Exporter: e, self: self}
+	},
+	deadLettering: func(e sdklog.Exporter, dlq *deadLetterQueue) sdklog.Exporter {
+		return deadLetterLogExporter{Exporter: e, dlq: dlq}
+	},
+}
+
+// generateLogs generates log data and sends it to the specified OTLP endpoint
+func generateLogs(parent context.Context, endpoint *Endpoint, serviceName string, rate float64, duration time.Duration, payloadSize int64, batchSize int, headers map[string]string, verbose bool, transport TransportOptions, load LoadOptions, result *Result) error {
+	duration = load.runDuration(duration)
+	rnd := load.newRandom()
+
+	run, err := newGeneratorRun(parent, "logs", endpoint, verbose, transport, load)
 	if err != nil {
-		return fmt.Errorf("invalid duration: %w", err)
+		return err
 	}
+	defer run.close()
+	ctx, endpoint := run.ctx, run.endpoint
 
-	ctx := context.Background()
-
-	// Create resource
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-		),
-	)
+	stamps := load.itemStamps()
+	res, err := run.newResource(serviceName, stamps)
 	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
+		return err
 	}
 
 	// Create log exporter based on protocol
-	var exporter sdklog.Exporter
-
-	// Use context with timeout for exporter creation
-	exporterCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	if endpoint.IsGRPC() {
-		opts := []otlploggrpc.Option{
-			otlploggrpc.WithEndpoint(endpoint.Address()),
-		}
-
-		if endpoint.Secure {
-			tlsConfig := &tls.Config{
-				InsecureSkipVerify: insecureSkip,
-				MinVersion:         tls.VersionTLS12,
-			}
-			if verbose {
-				fmt.Printf("[VERBOSE] Using TLS with system certs, InsecureSkipVerify=%v\n", insecureSkip)
-			}
-			opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
-		} else {
-			if verbose {
-				fmt.Println("[VERBOSE] Using insecure gRPC connection")
-			}
-			opts = append(opts, otlploggrpc.WithInsecure())
+	// Partial success totals are printed once the final records have been flushed
+	obs := run.newObserver(transport.retryEnabled() && !load.Fast)
+	defer obs.printSummary()
+	defer result.observe(obs)
+
+	// Adaptive pacing slows the rate whenever the observer sees the endpoint push back
+	var pacer *adaptivePacer
+	if load.Adaptive {
+		if transport.Writer != nil {
+			return fmt.Errorf("adaptive pacing needs an OTLP endpoint that can push back")
 		}
+		pacer = newAdaptivePacer("log records")
+		obs.pacer = pacer
+	}
 
-		if len(headers) > 0 {
-			if verbose {
-				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
-			}
-			opts = append(opts, otlploggrpc.WithHeaders(headers))
+	targets, err := transport.targets(endpoint)
+	if err != nil {
+		return err
+	}
+	// Fast mode builds requests directly and sends them over a raw client instead of
+	// the exporters; the wrappers below apply to whichever is in use
+	var exporters []sdklog.Exporter
+	var owners []*target
+	var raw *rawClient
+	var provided bool
+	if load.Fast {
+		if raw, err = newRawClient(targets, headers, transport, obs); err != nil {
+			return err
 		}
-
+		defer raw.Close()
 		if verbose {
-			fmt.Printf("[VERBOSE] Creating gRPC log exporter for %s\n", endpoint.Address())
+			fmt.Println("[VERBOSE] Fast mode: building export requests directly, without retries")
+			transport.describeTargets(targets, len(raw.shards), "log records")
 		}
-		exporter, err = otlploggrpc.New(exporterCtx, opts...)
 	} else {
-		opts := []otlploghttp.Option{
-			otlploghttp.WithEndpoint(endpoint.Address()),
-		}
-
-		if !endpoint.Secure {
-			if verbose {
-				fmt.Println("[VERBOSE] Using insecure HTTP connection")
-			}
-			opts = append(opts, otlploghttp.WithInsecure())
-		} else {
-			if verbose {
-				fmt.Printf("[VERBOSE] Using HTTPS with system certs, InsecureSkipVerify=%v\n", insecureSkip)
-			}
-			if insecureSkip {
-				opts = append(opts, otlploghttp.WithTLSClientConfig(&tls.Config{
-					InsecureSkipVerify: true,
-					MinVersion:         tls.VersionTLS12,
-				}))
-			}
+		exporters, owners, err = openTargets(targets, transport.connections(), run.exporterVerbose, func(tg *target, verbose bool) (sdklog.Exporter, error) {
+			return newLogExporter(parent, tg.endpoint, headers, tg.transport, obs, verbose)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create log exporter: %w", err)
 		}
 
-		if len(headers) > 0 {
-			if verbose {
-				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
-			}
-			opts = append(opts, otlploghttp.WithHeaders(headers))
+		if verbose {
+			fmt.Println("[VERBOSE] Log exporter created successfully")
+			transport.describeTargets(targets, len(exporters), "log records")
 		}
+		defer closeTargets(exporters, &provided)()
+	}
 
-		if verbose {
-			fmt.Printf("[VERBOSE] Creating HTTP log exporter for %s\n", endpoint.Address())
+	// Per-target totals are printed once the final records have been flushed
+	if len(targets) > 1 {
+		for i, e := range exporters {
+			exporters[i] = logWrappers.counting(e, &owners[i].stats)
 		}
-		exporter, err = otlploghttp.New(exporterCtx, opts...)
+		defer printTargetStats(targets, "logs")
 	}
 
+	// The monitors watch every export
+	mon, err := run.newMonitors("log records", duration, serviceName, obs, verbose)
 	if err != nil {
-		return fmt.Errorf("failed to create log exporter: %w", err)
+		return err
+	}
+	defer mon.stop()
+	logWrappers.monitor(exporters, raw, mon)
+
+	// A throughput target sizes the rate from the records exported so far
+	var tput *throughputTarget
+	if load.Throughput > 0 {
+		tput = newThroughputTarget(load.Throughput, 1, payloadSize, obs)
+		logWrappers.count(exporters, &tput.stats)
+		raw.count(&tput.stats)
+		defer tput.printSummary(duration - load.Warmup)
 	}
 
-	if verbose {
-		fmt.Println("[VERBOSE] Log exporter created successfully")
+	// Adaptive pacing compares the records offered to the endpoint with those it accepted
+	if pacer != nil {
+		logWrappers.count(exporters, &pacer.stats)
+		raw.count(&pacer.stats)
+		defer pacer.printSummary(duration - load.Warmup)
 	}
 
-	defer exporter.Shutdown(ctx)
+	// Exports that fail for good are kept in the dead-letter directory, if any
+	dlq, err := transport.newDeadLetterQueue("logs")
+	if err != nil {
+		return err
+	}
+	if dlq != nil {
+		logWrappers.deadLetter(exporters, raw, dlq)
+		defer dlq.printSummary()
+	}
 
-	// Create batch processor with configurable batch size
-	batchProcessor := sdklog.NewBatchProcessor(exporter,
-		sdklog.WithMaxQueueSize(batchSize*2), // Queue size should be larger than batch size
-		sdklog.WithExportMaxBatchSize(batchSize),
-	)
+	// emit generates a log record, and flush sends every record generated so far
+	var emit func()
+	var flush func(context.Context) error
+	if raw != nil {
+		fast := newFastLogBatcher(raw, res, batchSize, verbose)
+		defer fast.shutdown()
+		if verbose {
+			fmt.Printf("[VERBOSE] Sending up to %d log records per request\n", batchSize)
+		}
+		nextRecord := pooled(load.PayloadPool, func() fastLog { return newFastLog(newLogContent(rnd, payloadSize)) }, "log record", verbose)
+		emit = func() {
+			now := time.Now()
+			record := nextRecord().record(now)
+			if stamps != nil {
+				stamps.stampLogRecord(record, now)
+			}
+			fast.add(record)
+		}
+		flush = fast.flush
+	} else {
+		// Create batch processor with configurable batch size
+		// The queue also holds a whole burst, so bursts are not dropped before they are sent
+		batchProcessor := newLogProcessor(exporters, transport.newBalancer(owners),
+			sdklog.WithMaxQueueSize(max(batchSize*2, batchSize+load.Burst.Count)), // Queue size should be larger than batch size
+			sdklog.WithExportMaxBatchSize(batchSize),
+			sdklog.WithExportTimeout(transport.exportTimeout()),
+		)
 
-	if verbose {
-		fmt.Printf("[VERBOSE] Configured batch processor with max batch size: %d\n", batchSize)
-	}
-
-	// Create log provider
-	lp := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(batchProcessor),
-		sdklog.WithResource(res),
-	)
-	defer func() {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := lp.Shutdown(shutdownCtx); err != nil {
-			fmt.Printf("Error shutting down log provider: %v\n", err)
+		if verbose {
+			fmt.Printf("[VERBOSE] Configured batch processor with max batch size: %d\n", batchSize)
 		}
-	}()
 
-	logger := lp.Logger("otelgen")
+		// Create log provider
+		lp := sdklog.NewLoggerProvider(
+			sdklog.WithProcessor(batchProcessor),
+			sdklog.WithResource(res),
+		)
+		provided = true
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := lp.Shutdown(shutdownCtx); err != nil {
+				fmt.Printf("Error shutting down log provider: %v\n", err)
+			}
+		}()
+
+		logger := lp.Logger("otelgen")
+
+		// Record contents are drawn for every record, or cycled from a pool drawn up front
+		nextContent := pooled(load.PayloadPool, func() logContent { return newLogContent(rnd, payloadSize) }, "log record", verbose)
+		emit = func() {
+			generateLogRecord(ctx, logger, nextContent(), stamps)
+		}
+		flush = lp.ForceFlush
+	}
+
+	// Events are emitted on a pool of workers, if asked
+	workers, err := load.newEmitPool(emit)
+	if err != nil {
+		return err
+	}
+	defer workers.stop()
 
 	// Generate logs
-	ticker := time.NewTicker(time.Second / time.Duration(rate))
-	defer ticker.Stop()
+	limiter, err := load.limiter(rate, duration, tput, pacer)
+	if err != nil {
+		return err
+	}
+	defer limiter.stop()
+	if verbose {
+		limiter.describe()
+	}
 
 	timer := time.NewTimer(duration)
 	defer timer.Stop()
-
-	count := 0
+	defer load.endOnStop(timer)()
+	mon.soak.begin(verbose)
+	mon.stats.begin(verbose)
+	mon.report.begin()
+	result.begin()
+	mon.delivery.begin()
+	load.Events.start("logs", duration, limiter)
+	bar := load.newProgressBar("log records", duration)
+	bar.begin(limiter)
+	defer bar.stop()
+
+	// Nothing sent during the warmup is counted in the summary
+	warmupC, stopWarmup := load.warmupTimer()
+	defer stopWarmup()
+
+	count, warmupCount := 0, 0
 	for {
 		select {
 		case <-timer.C:
+			bar.stop()
+			workers.stop()
+			load.Events.end("logs", count, warmupCount)
+			result.generated(count)
 			fmt.Printf("Generated %d log records\n", count)
-			return nil
-		case <-ticker.C:
-			generateLogRecord(ctx, logger, payloadSize)
-			count++
+			load.printWarmup(warmupCount, "log records")
+			limiter.printSummary("log records")
+			// Delivery is judged as the window ends, before the final flush
+			return errors.Join(mon.delivery.finish(), mon.report.finish(count, flush, transport.exportTimeout()))
+		case <-mon.soak.tick():
+			mon.soak.checkpoint(warmupCount + count)
+		case <-run.usage.exceeded():
+			bar.stop()
+			return run.usage.err()
+		case <-mon.stats.tick():
+			mon.stats.report(warmupCount + count)
+		case <-bar.tick():
+			bar.update(warmupCount + count)
+		case <-mon.delivery.tick():
+			if mon.delivery.check() {
+				timer.Reset(0)
+			}
+		case <-warmupC:
+			warmupCount, count = count, 0
+			load.Events.warmupEnded("logs", warmupCount)
+			endWarmup(limiter, obs, targets, tput)
+			pacer.endWarmup()
+			mon.report.endWarmup()
+			result.begin()
+		case n := <-limiter.C:
+			n, last := load.capCount(n, warmupCount+count)
+			workers.emit(n, emit)
+			count += n
+			load.Progress.add(n)
+			if last {
+				timer.Reset(0)
+			}
 		}
 	}
 }
 
-func generateLogRecord(ctx context.Context, logger log.Logger, payloadSize int64) {
-	baseMessage := logMessages[rand.Intn(len(logMessages))]
-	level := logLevels[rand.Intn(len(logLevels))]
+// logContent is the content of a log record, without its timestamps
+type logContent struct {
+	level       string
+	baseMessage string
+	severity    log.Severity
+	body        string
+	attrs       []log.KeyValue
+}
+
+// newLogContent draws the level, message, JSON body, and attributes of a log record
+func newLogContent(rnd *runRandom, payloadSize int64) logContent {
+	baseMessage := logMessages[rnd.Intn(len(logMessages))]
+	level := logLevels[rnd.Intn(len(logLevels))]
 
 	// Generate realistic JSON log body
 	var logBody string
 	if payloadSize > 0 {
-		logBody = generateRealisticLogPayload(baseMessage, level, payloadSize)
+		logBody = generateRealisticLogPayload(rnd, baseMessage, level, payloadSize)
 	} else {
 		// For no size specified, still create a smaller realistic JSON log
-		logBody = generateRealisticLogPayload(baseMessage, level, 0)
+		logBody = generateRealisticLogPayload(rnd, baseMessage, level, 0)
 	}
 
 	// Map log level to severity
@@ -333,21 +461,147 @@ func generateLogRecord(ctx context.Context, logger log.Logger, payloadSize int64
 	// Create attributes
 	attrs := []log.KeyValue{
 		log.String("component", "otelgen"),
-		log.String("request_id", fmt.Sprintf("req-%s", randomString(16))),
-		log.String("user_id", fmt.Sprintf("user_%d", rand.Intn(10000))),
+		log.String("request_id", fmt.Sprintf("req-%s", randomString(rnd, 16))),
+		log.String("user_id", fmt.Sprintf("user_%d", rnd.Intn(10000))),
 	}
 
+	return logContent{level: level, baseMessage: baseMessage, severity: severity, body: logBody, attrs: attrs}
+}
+
+// generateLogRecord emits a log record of the content, stamping it if stamps are set
+func generateLogRecord(ctx context.Context, logger log.Logger, content logContent, stamps *itemStamps) {
 	// Emit log record with body as the message
+	now := time.Now()
 	logRecord := log.Record{}
-	logRecord.SetTimestamp(time.Now())
-	logRecord.SetObservedTimestamp(time.Now())
-	logRecord.SetSeverity(severity)
-	logRecord.SetSeverityText(level)
-	logRecord.SetBody(log.StringValue(logBody))
-	logRecord.AddAttributes(attrs...)
+	logRecord.SetTimestamp(now)
+	logRecord.SetObservedTimestamp(now)
+	logRecord.SetSeverity(content.severity)
+	logRecord.SetSeverityText(content.level)
+	logRecord.SetBody(log.StringValue(content.body))
+	logRecord.AddAttributes(content.attrs...)
+	if stamps != nil {
+		logRecord.AddAttributes(stamps.logAttributes(now)...)
+	}
 
 	logger.Emit(ctx, logRecord)
 
 	// Also print to stdout
-	slog.Info("Generated log", "level", level, "message", baseMessage)
+	slog.Info("Generated log", "level", content.level, "message", content.baseMessage)
+}
+
+// newLogExporter creates an OTLP log exporter for the endpoint's protocol
+func newLogExporter(ctx context.Context, endpoint *Endpoint, headers map[string]string, transport TransportOptions, obs *exportObserver, verbose bool) (sdklog.Exporter, error) {
+	// Use context with timeout for exporter creation
+	exporterCtx, cancel := context.WithTimeout(ctx, transport.connectTimeout())
+	defer cancel()
+
+	if err := transport.validate(endpoint); err != nil {
+		return nil, err
+	}
+	if c := transport.compressor(); c != "" && verbose {
+		fmt.Printf("[VERBOSE] Using %s compression\n", c)
+	}
+
+	auth, err := transport.newAuthorization()
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil && verbose {
+		fmt.Println("[VERBOSE] Adding Authorization header to every export")
+	}
+	headers, dynamic, err := transport.newRequestHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	if dynamic != nil && verbose {
+		dynamic.describe()
+	}
+	if verbose {
+		transport.describeRetry()
+	}
+
+	if endpoint.IsGRPC() {
+		opts := []otlploggrpc.Option{
+			otlploggrpc.WithEndpoint(endpoint.Address()),
+		}
+
+		if endpoint.Secure {
+			if verbose {
+				transport.describeTLS("TLS")
+			}
+			opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(transport.tlsConfig())))
+		} else {
+			if verbose {
+				fmt.Println("[VERBOSE] Using insecure gRPC connection")
+			}
+			opts = append(opts, otlploggrpc.WithInsecure())
+		}
+
+		if len(headers) > 0 {
+			if verbose {
+				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
+			}
+			opts = append(opts, otlploggrpc.WithHeaders(headers))
+		}
+
+		opts = append(opts, otlploggrpc.WithDialOption(transport.dialOptions(endpoint, auth, dynamic, obs)...))
+
+		if transport.Retry != nil {
+			opts = append(opts, otlploggrpc.WithRetry(otlploggrpc.RetryConfig(*transport.Retry)))
+		}
+
+		opts = append(opts, otlploggrpc.WithTimeout(transport.grpcTimeout()))
+
+		if verbose {
+			fmt.Printf("[VERBOSE] Creating gRPC log exporter for %s\n", endpoint.Address())
+		}
+		return otlploggrpc.New(exporterCtx, opts...)
+	}
+
+	opts := []otlploghttp.Option{
+		otlploghttp.WithEndpoint(endpoint.Address()),
+	}
+
+	if !endpoint.Secure {
+		if verbose {
+			fmt.Println("[VERBOSE] Using insecure HTTP connection")
+		}
+		opts = append(opts, otlploghttp.WithInsecure())
+	} else {
+		if verbose {
+			transport.describeTLS("HTTPS")
+		}
+	}
+
+	if len(headers) > 0 {
+		if verbose {
+			fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
+		}
+		opts = append(opts, otlploghttp.WithHeaders(headers))
+	}
+
+	if transport.compressor() == "gzip" {
+		opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+	}
+
+	if transport.HTTPPath != "" {
+		if verbose {
+			fmt.Printf("[VERBOSE] Using URL path %s\n", transport.HTTPPath)
+		}
+		opts = append(opts, otlploghttp.WithURLPath(transport.HTTPPath))
+	}
+
+	if transport.Retry != nil {
+		opts = append(opts, otlploghttp.WithRetry(otlploghttp.RetryConfig(*transport.Retry)))
+	}
+
+	opts = append(opts, otlploghttp.WithTimeout(transport.exportTimeout()))
+
+	// The client carries the TLS settings and credentials, and observes the responses
+	opts = append(opts, otlploghttp.WithHTTPClient(transport.httpClient(endpoint, auth, dynamic, obs)))
+
+	if verbose {
+		fmt.Printf("[VERBOSE] Creating HTTP log exporter for %s\n", endpoint.Address())
+	}
+	return otlploghttp.New(exporterCtx, opts...)
 }
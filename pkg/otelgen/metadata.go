@@ -0,0 +1,101 @@
+package otelgen
+
+import (
+	"strings"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// maxInstrumentNameLength is the longest metric name the OTel API accepts
+const maxInstrumentNameLength = 255
+
+// metadataCase is one metric name/description/unit combination
+type metadataCase struct {
+	kind        string
+	name        string
+	description string
+	unit        string
+}
+
+// metadataCases covers name and description shapes that stress catalog storage and
+// OpenMetrics conversion. Names over the API limit are only reachable via raw OTLP.
+var metadataCases = []metadataCase{
+	{
+		kind:        "name_at_limit",
+		name:        paddedName("otelgen.metadata.at_limit.", maxInstrumentNameLength),
+		description: "Metric name exactly at the 255 character limit",
+	},
+	{
+		kind:        "name_over_limit",
+		name:        paddedName("otelgen.metadata.over_limit.", maxInstrumentNameLength+1),
+		description: "Metric name one character over the 255 character limit",
+	},
+	{
+		kind:        "name_very_long",
+		name:        paddedName("otelgen.metadata.very_long.", 2048),
+		description: "Metric name far over the 255 character limit",
+	},
+	{
+		kind:        "description_very_long",
+		name:        "otelgen.metadata.long_description",
+		description: strings.Repeat("This description is deliberately verbose. ", 200),
+	},
+	{
+		kind:        "description_unicode",
+		name:        "otelgen.metadata.unicode_description",
+		description: "Durée de traitement — 処理時間 — время обработки — 🚀📈",
+		unit:        "ms",
+	},
+	{
+		kind: "description_missing",
+		name: "otelgen.metadata.no_description",
+	},
+	{
+		kind:        "description_escapes",
+		name:        "otelgen.metadata.escaped_description",
+		description: "Line one\nLine two with \"quotes\", a back\\slash, and a # hash",
+	},
+	{
+		kind:        "name_special_characters",
+		name:        "otelgen.metadata.dots-dashes/slashes_and_underscores",
+		description: "Metric name using every separator the API allows",
+	},
+	{
+		kind:        "name_unicode",
+		name:        "otelgen.metadata.ünïcödé_名前",
+		description: "Metric name with non-ASCII characters",
+	},
+	{
+		kind:        "name_leading_digit",
+		name:        "1otelgen.metadata.leading_digit",
+		description: "Metric name starting with a digit",
+	},
+}
+
+// paddedName extends prefix with filler characters to exactly length characters
+func paddedName(prefix string, length int) string {
+	return prefix + strings.Repeat("x", length-len(prefix))
+}
+
+// metadataEdgeCases emits one gauge per tick, rotating through the metadata cases
+type metadataEdgeCases struct {
+	next int
+	rnd  *runRandom
+}
+
+func newMetadataEdgeCases(rnd *runRandom) *metadataEdgeCases {
+	return &metadataEdgeCases{rnd: rnd}
+}
+
+// Metrics returns the next metadata edge case
+func (m *metadataEdgeCases) Metrics(now time.Time) []*metricspb.Metric {
+	mc := metadataCases[m.next]
+	m.next = (m.next + 1) % len(metadataCases)
+
+	metric := gaugeMetric(mc.name, []*commonpb.KeyValue{stringAttr("metadata.case", mc.kind)}, unixNano(now), m.rnd.Float64()*100)
+	metric.Description = mc.description
+	metric.Unit = mc.unit
+	return []*metricspb.Metric{metric}
+}
@@ -2,9 +2,11 @@ package otelgen
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -14,127 +16,399 @@ import (
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
 	"google.golang.org/grpc/credentials"
 )
 
-// GenerateMetrics generates metric data and sends it to the specified OTLP endpoint
-func GenerateMetrics(endpoint *Endpoint, serviceName string, rate int, durationStr string, payloadSize int64, headers map[string]string, verbose bool, insecureSkip bool) error {
-	duration, err := time.ParseDuration(durationStr)
-	if err != nil {
-		return fmt.Errorf("invalid duration: %w", err)
-	}
+// metricsExportInterval is how often the periodic reader exports collected metrics
+const metricsExportInterval = 2 * time.Second
+
+// defaultObservedSeries is the number of datapoints the default metric set's
+// observable instruments contribute to every export
+const defaultObservedSeries = 3
+
+// MetricsOptions holds the metrics-specific generation settings
+type MetricsOptions struct {
+	// Preset selects a predefined metric catalog (jvm, goruntime) instead of the default metrics
+	Preset string
+	// Real reports the actual host's CPU, memory, disk, and network values instead of random numbers
+	Real bool
+	// ResetEvery simulates a process restart at this interval: counters reset and the start time changes
+	ResetEvery time.Duration
+	// Pattern shapes the gauge values (random, sine, sawtooth, step, random-walk, constant)
+	Pattern string
+	// PatternPeriod is the length of one waveform cycle
+	PatternPeriod time.Duration
+	// PatternAmplitude is how far the gauge swings around its center of 50
+	PatternAmplitude float64
+	// Sparse is the fraction (0-1) of series that are silent at any time, for random intervals
+	Sparse float64
+	// AdversarialValues occasionally emits NaN/±Inf, negative counters, and other out-of-spec datapoints
+	AdversarialValues bool
+	// HistogramBuckets sets explicit bucket boundaries for the duration histogram
+	HistogramBuckets []float64
+	// InconsistentHistograms occasionally emits histograms whose count, sum, and buckets disagree
+	InconsistentHistograms bool
+	// Churn introduces this many brand-new series per second
+	Churn float64
+	// Definitions replaces the default metrics with user-defined ones (see LoadMetricsFile)
+	Definitions []MetricDefinition
+	// Views customize how the SDK aggregates and exports matching instruments
+	Views []ViewDefinition
+	// UnitVariety cycles through gauges using a broad set of UCUM units and invalid unit strings
+	UnitVariety bool
+	// MetadataEdgeCases rotates through metrics with extreme names and descriptions (over-long, unicode, missing)
+	MetadataEdgeCases bool
+	// StalenessMarkers reports short-lived series that end with a NO_RECORDED_VALUE datapoint
+	StalenessMarkers bool
+	// ConflictRate is the probability (0-1) per tick of re-emitting a default metric name
+	// with a different type or unit
+	ConflictRate float64
+	// ValueType selects Int64 ("int") or Float64 ("double") datapoints for counters and
+	// gauges; "mixed" (the default) keeps each instrument's natural type
+	ValueType string
+	// RateUnit is what the rate counts: "events" (Add/Record calls, the default) or
+	// "datapoints" (datapoints per second on the wire, default metric set only)
+	RateUnit string
+	// Backfill sends the default metrics for this much past time, as fast as the endpoint
+	// accepts them, instead of generating live data
+	Backfill time.Duration
+	// BackfillStep is the spacing between backfilled timestamps (default 15s)
+	BackfillStep time.Duration
+	// BackfillCheckpoint, when set, is the file a backfill's progress is saved to after
+	// every request
+	BackfillCheckpoint string
+	// BackfillResume continues the backfill saved in BackfillCheckpoint instead of
+	// starting a new one; Backfill and BackfillStep, when zero, are taken from it
+	BackfillResume bool
+	// ActiveSeries replaces the default metrics with exactly this many series, reported at
+	// every export and spread over several metrics and the simulated hosts
+	ActiveSeries int
+	// Hosts simulates this many hosts, each with its own resource and series, batched into one request per export
+	Hosts int
+}
 
-	ctx := context.Background()
+// defaultMetricSet reports whether the built-in otelgen.* metrics are generated
+func (o *MetricsOptions) defaultMetricSet() bool {
+	return o.Preset == "" && !o.Real && len(o.Definitions) == 0 && o.ActiveSeries == 0
+}
 
-	// Create resource
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-		),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
+// metricsSource holds generation state that outlives a single meter provider
+type metricsSource struct {
+	opts        MetricsOptions
+	payloadSize int64
+	timeout     time.Duration // per-export timeout
+	gauge       *waveform
+	churn       *dueCounter
+	custom      []*customMetric
+	series      int
+	views       []sdkmetric.View
+	sparse      *sparseFilter
+	raw         *rawClient
+	active      *seriesAllocator
+	rnd         *runRandom
+	observed    *rand.Rand // drawn from on the SDK's goroutines
+}
+
+// metricRecorder records one metric event per generation tick
+type metricRecorder interface {
+	Record(ctx context.Context)
+}
+
+// meterPipeline is the part of a meter provider the generation loop drives
+type meterPipeline interface {
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// metricWrappers wrap metric exporters for the monitors of a metric run
+var metricWrappers = exporterWrappers[sdkmetric.Exporter]{
+	counting: func(e sdkmetric.Exporter, stats *targetStats) sdkmetric.Exporter {
+		return countingMetricExporter{Exporter: e, stats: stats}
+	},
+	timing: func(e sdkmetric.Exporter, stats *probeStats) sdkmetric.Exporter {
+		return timedMetricExporter{Exporter: e, stats: stats}
+	},
+	recording: func(e sdkmetric.Exporter, self *selfTelemetry) sdkmetric.Exporter {
+		return selfMetricExporter{Exporter: e, self: self}
+	},
+	deadLettering: func(e sdkmetric.Exporter, dlq *deadLetterQueue) sdkmetric.Exporter {
+		return deadLetterMetricExporter{Exporter: e, dlq: dlq}
+	},
+}
+
+// generateMetrics generates metric data and sends it to the specified OTLP endpoint
+func generateMetrics(parent context.Context, endpoint *Endpoint, serviceName string, rate float64, duration time.Duration, payloadSize int64, headers map[string]string, verbose bool, transport TransportOptions, load LoadOptions, opts MetricsOptions, result *Result) error {
+	duration = load.runDuration(duration)
+	rnd, observed := load.newRandom(), load.newObservedRandom()
+	if load.Throughput > 0 {
+		return fmt.Errorf("throughput targets are not supported for metrics, whose export size depends on the series rather than the rate")
 	}
 
-	// Create exporter based on protocol
-	var exporter sdkmetric.Exporter
+	if err := validValueType(opts.ValueType); err != nil {
+		return err
+	}
 
-	// Use context with timeout for exporter creation
-	exporterCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	switch opts.RateUnit {
+	case "", "events":
+	case "datapoints":
+		if !opts.defaultMetricSet() {
+			return fmt.Errorf("rate unit datapoints is only supported with the default metric set")
+		}
+	default:
+		return fmt.Errorf("unknown rate unit %q (supported: events, datapoints)", opts.RateUnit)
+	}
 
-	if endpoint.IsGRPC() {
-		opts := []otlpmetricgrpc.Option{
-			otlpmetricgrpc.WithEndpoint(endpoint.Address()),
+	// A resumed backfill keeps the window and step it was started with
+	var resume *backfillCheckpoint
+	var err error
+	if opts.BackfillResume {
+		if opts.BackfillCheckpoint == "" {
+			return fmt.Errorf("resuming a backfill needs its checkpoint file")
+		}
+		if resume, err = loadBackfillCheckpoint(opts.BackfillCheckpoint); err != nil {
+			return err
+		}
+		if opts.Backfill == 0 {
+			opts.Backfill = resume.To.Sub(resume.From)
 		}
+		if opts.BackfillStep == 0 {
+			opts.BackfillStep = resume.Step
+		}
+	}
+	if opts.BackfillCheckpoint != "" && opts.Backfill == 0 {
+		return fmt.Errorf("a checkpoint file is only supported with backfill")
+	}
 
-		if endpoint.Secure {
-			tlsConfig := &tls.Config{
-				InsecureSkipVerify: insecureSkip,
-				MinVersion:         tls.VersionTLS12,
-			}
-			if verbose {
-				fmt.Printf("[VERBOSE] Using TLS with system certs, InsecureSkipVerify=%v\n", insecureSkip)
-			}
-			opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
-		} else {
-			if verbose {
-				fmt.Println("[VERBOSE] Using insecure gRPC connection")
-			}
-			opts = append(opts, otlpmetricgrpc.WithInsecure())
+	if opts.Backfill > 0 {
+		if !opts.defaultMetricSet() {
+			return fmt.Errorf("backfill is only supported with the default metric set")
+		}
+		if opts.BackfillStep == 0 {
+			opts.BackfillStep = 15 * time.Second
+		}
+		if opts.BackfillStep < 0 {
+			return fmt.Errorf("backfill step must be positive")
+		}
+		if load.Soak != nil {
+			return fmt.Errorf("backfill cannot be combined with a soak")
 		}
+		if load.Stats != nil {
+			return fmt.Errorf("backfill cannot be combined with rolling stats")
+		}
+		if load.Report != nil {
+			return fmt.Errorf("backfill is sent as fast as the endpoint accepts it, so it has no run to report on")
+		}
+		if load.Delivery != nil {
+			return fmt.Errorf("backfill cannot be combined with a delivery check")
+		}
+		if load.ControlSocket != "" {
+			return fmt.Errorf("backfill is sent as fast as the endpoint accepts it, so it has no rate to control")
+		}
+	}
 
-		if len(headers) > 0 {
-			if verbose {
-				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
-			}
-			opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
+	modes := 0
+	for _, set := range []bool{opts.Preset != "", opts.Real, len(opts.Definitions) > 0, opts.ActiveSeries > 0} {
+		if set {
+			modes++
 		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("only one of preset, real host metrics, metric definitions, and active series can be used")
+	}
 
+	if opts.PatternPeriod == 0 {
+		opts.PatternPeriod = time.Minute
+	}
+	if opts.PatternAmplitude == 0 {
+		opts.PatternAmplitude = 50
+	}
+	gauge, err := newWaveform(opts.Pattern, opts.PatternPeriod, 50, opts.PatternAmplitude, observed)
+	if err != nil {
+		return err
+	}
+	src := &metricsSource{
+		opts:        opts,
+		payloadSize: payloadSize,
+		timeout:     transport.exportTimeout(),
+		gauge:       gauge,
+		rnd:         rnd,
+		observed:    observed,
+	}
+	if opts.Churn > 0 {
+		src.churn = newDueCounter(opts.Churn)
+	}
+	if opts.ActiveSeries > 0 {
+		src.active = newSeriesAllocator(opts.ActiveSeries, opts.Hosts)
+	}
+	src.views, err = newViews(opts.Views)
+	if err != nil {
+		return err
+	}
+	if opts.RateUnit == "datapoints" {
+		src.series = datapointSeries(rate, opts.Hosts)
 		if verbose {
-			fmt.Printf("[VERBOSE] Creating gRPC metrics exporter for %s\n", endpoint.Address())
+			fmt.Printf("[VERBOSE] Spreading recordings over %d series per instrument for ~%g datapoints/s\n", src.series, rate)
 		}
-		exporter, err = otlpmetricgrpc.New(exporterCtx, opts...)
-	} else {
-		opts := []otlpmetrichttp.Option{
-			otlpmetrichttp.WithEndpoint(endpoint.Address()),
+	}
+	if len(opts.Definitions) > 0 {
+		src.custom, err = newCustomMetrics(opts.Definitions, rnd, observed)
+		if err != nil {
+			return err
 		}
-
-		if !endpoint.Secure {
-			if verbose {
-				fmt.Println("[VERBOSE] Using insecure HTTP connection")
-			}
-			opts = append(opts, otlpmetrichttp.WithInsecure())
-		} else {
-			if verbose {
-				fmt.Printf("[VERBOSE] Using HTTPS with system certs, InsecureSkipVerify=%v\n", insecureSkip)
-			}
-			if insecureSkip {
-				opts = append(opts, otlpmetrichttp.WithTLSClientConfig(&tls.Config{
-					InsecureSkipVerify: true,
-					MinVersion:         tls.VersionTLS12,
-				}))
-			}
+		if _, schedules, _ := scheduleGroups(src.custom); len(schedules) > 0 && opts.Hosts > 1 {
+			return fmt.Errorf("per-metric interval and offset cannot be combined with multiple hosts")
 		}
+	}
 
-		if len(headers) > 0 {
-			if verbose {
-				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
-			}
-			opts = append(opts, otlpmetrichttp.WithHeaders(headers))
-		}
+	run, err := newGeneratorRun(parent, "metrics", endpoint, verbose, transport, load)
+	if err != nil {
+		return err
+	}
+	defer run.close()
+	ctx, endpoint := run.ctx, run.endpoint
 
-		if verbose {
-			fmt.Printf("[VERBOSE] Creating HTTP metrics exporter for %s\n", endpoint.Address())
+	res, err := run.newResource(serviceName, nil)
+	if err != nil {
+		return err
+	}
+
+	targets, err := transport.targets(endpoint)
+	if err != nil {
+		return err
+	}
+	defer printTargetStats(targets, "metrics")
+
+	// Exports that fail for good are kept in the dead-letter directory, if any
+	dlq, err := transport.newDeadLetterQueue("metrics")
+	if err != nil {
+		return err
+	}
+	if dlq != nil {
+		defer dlq.printSummary()
+	}
+
+	// Historical data is sent directly, without a meter provider
+	if opts.Backfill > 0 {
+		raw, err := newRawClient(targets, headers, transport, run.newObserver(false))
+		if err != nil {
+			return err
 		}
-		exporter, err = otlpmetrichttp.New(exporterCtx, opts...)
+		defer raw.Close()
+		defer raw.obs.printSummary()
+		defer result.observe(raw.obs)
+		raw.deadLetters(dlq)
+		self, err := load.newSelfTelemetry("metrics", serviceName, transport, verbose)
+		if err != nil {
+			return err
+		}
+		defer self.shutdown()
+		self.watch(raw)
+		return runBackfill(ctx, raw, res, src, resume, load.Stop, verbose)
 	}
 
+	// Create exporter based on protocol
+	// Partial success totals are printed once the final metrics have been flushed
+	obs := run.newObserver(transport.retryEnabled())
+	defer obs.printSummary()
+	defer result.observe(obs)
+
+	exporters, owners, err := openTargets(targets, transport.connections(), run.exporterVerbose, func(tg *target, verbose bool) (sdkmetric.Exporter, error) {
+		return newMetricExporter(parent, tg.endpoint, headers, tg.transport, obs, verbose)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create metrics exporter: %w", err)
 	}
+	if len(targets) > 1 {
+		for i, e := range exporters {
+			exporters[i] = metricWrappers.counting(e, &owners[i].stats)
+		}
+	}
+	exporter := newShardedMetricExporter(exporters, transport.newBalancer(owners))
+	// Keep the exporter open across simulated restarts; it is shut down once at the end,
+	// which also covers the errors returned before the provider is created
+	defer exporter.Shutdown(ctx)
+
+	// The monitors watch every export; the raw client, if any, is watched below
+	mon, err := run.newMonitors("metric events", duration, serviceName, obs, verbose)
+	if err != nil {
+		return err
+	}
+	defer mon.stop()
+	wrapped := []sdkmetric.Exporter{exporter}
+	metricWrappers.monitor(wrapped, nil, mon)
+
+	// Exports that fail for good are dead-lettered once every attempt has failed
+	if dlq != nil {
+		metricWrappers.deadLetter(wrapped, nil, dlq)
+	}
+	exporter = wrapped[0]
 
 	if verbose {
 		fmt.Println("[VERBOSE] Metrics exporter created successfully")
 		fmt.Println("[VERBOSE] Note: Metrics will be exported periodically every 2 seconds")
+		transport.describeTargets(targets, len(exporters), "exports")
 		fmt.Println()
 	}
 
-	// Create meter provider
-	mp := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter,
-			sdkmetric.WithInterval(2*time.Second),
-			sdkmetric.WithTimeout(30*time.Second), // Increased timeout
-		)),
-		sdkmetric.WithResource(res),
-	)
+	exporter = reusableExporter{exporter}
+
+	if opts.Sparse > 0 {
+		src.sparse = newSparseFilter(opts.Sparse, metricsExportInterval, verbose, observed)
+		exporter = sparseExporter{Exporter: exporter, filter: src.sparse}
+	}
+
+	// Data the SDK refuses to produce, or cannot batch, is sent through a raw OTLP client
+	var rawSources []rawMetricSource
+	if opts.AdversarialValues {
+		rawSources = append(rawSources, newAdversarialValues(rnd))
+	}
+	if opts.InconsistentHistograms {
+		rawSources = append(rawSources, newInconsistentHistograms(rnd))
+	}
+	if opts.MetadataEdgeCases {
+		rawSources = append(rawSources, newMetadataEdgeCases(rnd))
+	}
+	var staleness *stalenessMarkers
+	if opts.StalenessMarkers {
+		staleness = newStalenessMarkers(rnd)
+		rawSources = append(rawSources, staleness)
+	}
+	var conflicts *conflictInjector
+	if opts.ConflictRate > 0 {
+		conflicts = newConflictInjector(opts.ConflictRate, rnd)
+		rawSources = append(rawSources, conflicts)
+	}
+	if len(rawSources) > 0 || opts.Hosts > 1 {
+		src.raw, err = newRawClient(targets, headers, transport, run.newObserver(false))
+		if err != nil {
+			return err
+		}
+		defer src.raw.Close()
+		defer src.raw.obs.printSummary()
+		defer result.observe(src.raw.obs)
+		src.raw.deadLetters(dlq)
+		mon.stats.watch(src.raw, transport)
+		mon.report.watch(src.raw)
+		mon.delivery.watch(src.raw)
+		mon.self.watch(src.raw)
+	}
+	rawCount := 0
+
+	if opts.Hosts > 1 && verbose {
+		fmt.Printf("[VERBOSE] Simulating %d hosts, batched into one request per export\n", opts.Hosts)
+	}
+
+	// Create meter provider and metrics
+	mp, recorder, err := newMeterPipeline(exporter, res, src, verbose)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if verbose {
 			fmt.Println("[VERBOSE] Shutting down meter provider and flushing metrics...")
 		}
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), src.timeout)
 		defer cancel()
 		if err := mp.Shutdown(shutdownCtx); err != nil {
 			fmt.Printf("Error shutting down meter provider: %v\n", err)
@@ -143,60 +417,73 @@ func GenerateMetrics(endpoint *Endpoint, serviceName string, rate int, durationS
 		}
 	}()
 
-	otel.SetMeterProvider(mp)
-	meter := mp.Meter("otelgen")
-
-	// Create metrics
-	counter, err := meter.Int64Counter(
-		"otelgen.requests",
-		metric.WithDescription("Number of requests"),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create counter: %w", err)
+	// Simulate process restarts if requested
+	var resetC <-chan time.Time
+	if opts.ResetEvery > 0 {
+		resetTicker := time.NewTicker(opts.ResetEvery)
+		defer resetTicker.Stop()
+		resetC = resetTicker.C
 	}
+	restarts := 0
 
-	histogram, err := meter.Float64Histogram(
-		"otelgen.duration",
-		metric.WithDescription("Request duration"),
-		metric.WithUnit("ms"),
-	)
+	// Generate metrics
+	limiter, err := load.limiter(rate, duration, nil, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create histogram: %w", err)
+		return err
 	}
-
-	gauge, err := meter.Float64ObservableGauge(
-		"otelgen.cpu_usage",
-		metric.WithDescription("CPU usage percentage"),
-		metric.WithFloat64Callback(func(ctx context.Context, observer metric.Float64Observer) error {
-			observer.Observe(rand.Float64()*100, metric.WithAttributes(
-				attribute.String("host", "localhost"),
-			))
-			return nil
-		}),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create gauge: %w", err)
+	defer limiter.stop()
+	if verbose {
+		limiter.describe()
 	}
-	_ = gauge // gauge is automatically recorded
-
-	// Generate metrics
-	ticker := time.NewTicker(time.Second / time.Duration(rate))
-	defer ticker.Stop()
 
 	timer := time.NewTimer(duration)
 	defer timer.Stop()
-
-	count := 0
+	defer load.endOnStop(timer)()
+	mon.soak.begin(verbose)
+	mon.stats.begin(verbose)
+	mon.report.begin()
+	result.begin()
+	mon.delivery.begin()
+	load.Events.start("metrics", duration, limiter)
+	bar := load.newProgressBar("metric events", duration)
+	bar.begin(limiter)
+	defer bar.stop()
+
+	// Nothing sent during the warmup is counted in the summary
+	warmupC, stopWarmup := load.warmupTimer()
+	defer stopWarmup()
+
+	count, warmupCount := 0, 0
 	for {
 		select {
 		case <-timer.C:
+			bar.stop()
+			load.Events.end("metrics", count, warmupCount)
+			result.generated(count)
 			fmt.Printf("Generated %d metric events\n", count)
+			load.printWarmup(warmupCount, "metric events")
+			limiter.printSummary("metric events")
+			if restarts > 0 {
+				fmt.Printf("Simulated %d restarts\n", restarts)
+			}
+			if len(rawSources) > 0 {
+				fmt.Printf("Sent %d raw datapoints\n", rawCount)
+			}
+			if staleness != nil {
+				fmt.Printf("Ended %d series with staleness markers\n", staleness.Ended())
+			}
+			if conflicts != nil {
+				fmt.Printf("Injected %d schema conflicts\n", conflicts.Injected())
+			}
+			if src.churn != nil {
+				fmt.Printf("Introduced %d churned series\n", src.churn.Count())
+			}
 
 			// Force flush before returning to ensure all metrics are sent
 			if verbose {
 				fmt.Println("[VERBOSE] Forcing final metrics flush...")
 			}
-			flushCtx, flushCancel := context.WithTimeout(context.Background(), 30*time.Second)
+			flushCtx, flushCancel := context.WithTimeout(context.Background(), src.timeout)
 			defer flushCancel()
 
 			if err := mp.ForceFlush(flushCtx); err != nil {
@@ -205,30 +492,459 @@ func GenerateMetrics(endpoint *Endpoint, serviceName string, rate int, durationS
 				fmt.Println("[VERBOSE] Final metrics flushed successfully")
 			}
 
-			return nil
-		case <-ticker.C:
-			// Create attributes list
-			attrs := []attribute.KeyValue{
-				attribute.String("method", "GET"),
-				attribute.String("endpoint", "/api/test"),
+			return errors.Join(mon.delivery.finish(), mon.report.finish(count, nil, 0))
+		case <-mon.soak.tick():
+			mon.soak.checkpoint(warmupCount + count)
+		case <-run.usage.exceeded():
+			bar.stop()
+			return run.usage.err()
+		case <-mon.stats.tick():
+			mon.stats.report(warmupCount + count)
+		case <-bar.tick():
+			bar.update(warmupCount + count)
+		case <-mon.delivery.tick():
+			if mon.delivery.check() {
+				timer.Reset(0)
+			}
+		case <-warmupC:
+			warmupCount, count = count, 0
+			load.Events.warmupEnded("metrics", warmupCount)
+			rawCount, restarts = 0, 0
+			endWarmup(limiter, obs, targets, nil)
+			if src.raw != nil {
+				src.raw.obs.endWarmup()
+			}
+			mon.report.endWarmup()
+			result.begin()
+		case <-resetC:
+			// Shut down the old "process" so its final values are exported, then start over
+			if verbose {
+				fmt.Println("[VERBOSE] Simulating process restart: counters reset and start time changes")
+			}
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), src.timeout)
+			if err := mp.Shutdown(shutdownCtx); err != nil {
+				fmt.Printf("Error shutting down meter provider: %v\n", err)
 			}
+			cancel()
 
-			// Add padding attribute if size is specified
-			if payloadSize > 0 {
-				attrs = append(attrs, attribute.String("payload.data", GeneratePadding(payloadSize)))
+			mp, recorder, err = newMeterPipeline(exporter, res, src, verbose)
+			if err != nil {
+				return err
+			}
+			restarts++
+		case n := <-limiter.C:
+			n, last := load.capCount(n, warmupCount+count)
+			for i := 0; i < n; i++ {
+				recorder.Record(ctx)
+				if len(rawSources) > 0 {
+					rawCount += emitRawMetrics(ctx, src.raw, res, rawSources, verbose)
+				}
+				count++
+
+				if verbose && count%5 == 0 {
+					fmt.Printf("[VERBOSE] Generated %d metric events (next export in ~%ds)\n", count, 2-(count%2))
+				}
 			}
+			load.Progress.add(n)
+			if last {
+				timer.Reset(0)
+			}
+		}
+	}
+}
 
-			// Record counter
-			counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+// newMeterPipeline creates a meter provider and the metric set recorded through it.
+// With more than one host, the pipeline is a fleet of providers exported together.
+func newMeterPipeline(exporter sdkmetric.Exporter, res *resource.Resource, src *metricsSource, verbose bool) (meterPipeline, metricRecorder, error) {
+	if src.opts.Hosts > 1 {
+		fleet, err := newHostFleet(res, src, verbose)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fleet, fleet, nil
+	}
 
-			// Record histogram
-			histogram.Record(ctx, rand.Float64()*1000, metric.WithAttributes(attrs...))
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter,
+			sdkmetric.WithInterval(metricsExportInterval),
+			sdkmetric.WithTimeout(src.timeout),
+		)),
+		sdkmetric.WithResource(res),
+		sdkmetric.WithView(src.views...),
+	)
 
-			count++
+	otel.SetMeterProvider(mp)
+
+	recorder, err := newMetricRecorder(mp.Meter("otelgen"), src)
+	if err != nil {
+		mp.Shutdown(context.Background())
+		return nil, nil, err
+	}
+
+	_, schedules, groups := scheduleGroups(src.custom)
+	if len(schedules) == 0 {
+		return mp, recorder, nil
+	}
+
+	// Metrics with their own interval or offset get a provider each per schedule
+	pipelines := pipelineGroup{mp}
+	recorders := recorderGroup{recorder}
+	for _, s := range schedules {
+		p := newScheduledProvider(exporter, res, src.views, src.timeout)
+		set, err := newCustomMetricSet(p.mp.Meter("otelgen"), groups[s], src.opts.ValueType)
+		if err != nil {
+			p.mp.Shutdown(context.Background())
+			pipelines.Shutdown(context.Background())
+			return nil, nil, err
+		}
+		if verbose {
+			fmt.Printf("[VERBOSE] Exporting %d metrics every %s, offset %s\n", len(groups[s]), s.interval, s.offset)
+		}
+		p.start(s)
+		pipelines = append(pipelines, p)
+		recorders = append(recorders, set)
+	}
+
+	return pipelines, recorders, nil
+}
+
+// newMetricRecorder creates the configured metric set on meter
+func newMetricRecorder(meter metric.Meter, src *metricsSource) (metricRecorder, error) {
+	var recorder metricRecorder
+	var err error
+	if src.opts.Preset != "" {
+		recorder, err = newPresetMetrics(meter, src.opts.Preset, src.rnd)
+	} else if src.opts.Real {
+		recorder, err = newSystemMetrics(meter)
+	} else if len(src.custom) > 0 {
+		// Metrics with their own schedule are recorded through separate providers
+		shared, _, _ := scheduleGroups(src.custom)
+		recorder, err = newCustomMetricSet(meter, shared, src.opts.ValueType)
+	} else if src.active != nil {
+		recorder, err = newActiveSeriesMetrics(meter, src.active, src.observed)
+	} else {
+		recorder, err = newDefaultMetrics(meter, src)
+	}
+	if err == nil && src.churn != nil {
+		recorder, err = newChurnRecorder(meter, recorder, src.churn, src.rnd)
+	}
+	if err == nil && src.opts.UnitVariety {
+		recorder, err = newUnitVarietyRecorder(meter, recorder, src.rnd)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+// datapointSeries returns how many series each synchronous default instrument needs so
+// that every export carries rate datapoints per second of export interval. Each series
+// yields one counter and one histogram datapoint per export, on top of the observable ones.
+func datapointSeries(rate float64, hosts int) int {
+	if hosts < 1 {
+		hosts = 1
+	}
+	perExport := rate * metricsExportInterval.Seconds() / float64(hosts)
+	series := int(math.Round((perExport - defaultObservedSeries) / 2))
+	if series < 1 {
+		return 1
+	}
+	return series
+}
+
+// emitRawMetrics sends whatever the raw sources produce this tick and returns the number of datapoints sent
+func emitRawMetrics(ctx context.Context, raw *rawClient, res *resource.Resource, sources []rawMetricSource, verbose bool) int {
+	now := time.Now()
+	var metrics []*metricspb.Metric
+	for _, src := range sources {
+		metrics = append(metrics, src.Metrics(now)...)
+	}
+	if len(metrics) == 0 {
+		return 0
+	}
+
+	if err := raw.ExportMetrics(ctx, rawMetricsRequest(res, "otelgen/raw", metrics)); err != nil {
+		fmt.Printf("Error sending raw metrics: %v\n", err)
+		return 0
+	}
+
+	points := countDataPoints(metrics)
+	if verbose {
+		fmt.Printf("[VERBOSE] Sent %d raw datapoints\n", points)
+	}
+	return points
+}
+
+// countDataPoints returns the number of datapoints across OTLP metrics
+func countDataPoints(metrics []*metricspb.Metric) int {
+	n := 0
+	for _, m := range metrics {
+		switch d := m.Data.(type) {
+		case *metricspb.Metric_Gauge:
+			n += len(d.Gauge.DataPoints)
+		case *metricspb.Metric_Sum:
+			n += len(d.Sum.DataPoints)
+		case *metricspb.Metric_Histogram:
+			n += len(d.Histogram.DataPoints)
+		case *metricspb.Metric_ExponentialHistogram:
+			n += len(d.ExponentialHistogram.DataPoints)
+		case *metricspb.Metric_Summary:
+			n += len(d.Summary.DataPoints)
+		}
+	}
+	return n
+}
+
+// reusableExporter keeps the underlying exporter open when a meter provider shuts down,
+// so the same connection can be handed to the provider created after a simulated restart
+type reusableExporter struct {
+	sdkmetric.Exporter
+}
+
+func (e reusableExporter) Shutdown(ctx context.Context) error {
+	return e.Exporter.ForceFlush(ctx)
+}
+
+// defaultMetrics is the built-in otelgen.* metric set
+type defaultMetrics struct {
+	counter     numberAdder
+	histogram   metric.Float64Histogram
+	payloadSize int64
+	rnd         *runRandom
+
+	// series spreads recordings over this many series.id values when above one
+	series int
+	next   int
+	// sets holds each series' attributes, built once rather than on every recording
+	sets []metric.MeasurementOption
+
+	// Totals reported by the observable counter and up-down counter callbacks
+	bytesSent      atomic.Int64
+	activeRequests atomic.Int64
+}
+
+func newDefaultMetrics(meter metric.Meter, src *metricsSource) (*defaultMetrics, error) {
+	valueType := src.opts.ValueType
+	counter, err := newNumberCounter(meter, "otelgen.requests", useInt(valueType, true),
+		metric.WithDescription("Number of requests"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create counter: %w", err)
+	}
+
+	histogramOpts := []metric.Float64HistogramOption{
+		metric.WithDescription("Request duration"),
+		metric.WithUnit("ms"),
+	}
+	if len(src.opts.HistogramBuckets) > 0 {
+		histogramOpts = append(histogramOpts, metric.WithExplicitBucketBoundaries(src.opts.HistogramBuckets...))
+	}
+	histogram, err := meter.Float64Histogram("otelgen.duration", histogramOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create histogram: %w", err)
+	}
+
+	err = newObservableNumber(meter, "gauge", "otelgen.cpu_usage", useInt(valueType, false),
+		func(observe func(float64, ...metric.ObserveOption)) {
+			observe(src.gauge.Value(time.Now()), metric.WithAttributes(
+				attribute.String("host", "localhost"),
+			))
+		},
+		metric.WithDescription("CPU usage percentage"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gauge: %w", err)
+	}
+
+	m := &defaultMetrics{
+		counter:     counter,
+		histogram:   histogram,
+		payloadSize: src.payloadSize,
+		rnd:         src.rnd,
+		series:      src.series,
+		sets:        make([]metric.MeasurementOption, max(1, src.series)),
+	}
+	for i := range m.sets {
+		// Create attributes list
+		attrs := make([]attribute.KeyValue, 0, 4)
+		attrs = append(attrs,
+			attribute.String("method", "GET"),
+			attribute.String("endpoint", "/api/test"),
+		)
+
+		// Cycle through series when a datapoint rate is targeted
+		if m.series > 1 {
+			attrs = append(attrs, attribute.Int("series.id", i))
+		}
+
+		// Add padding attribute if size is specified
+		if m.payloadSize > 0 {
+			attrs = append(attrs, attribute.String("payload.data", GeneratePadding(m.payloadSize)))
+		}
+		m.sets[i] = metric.WithAttributeSet(attribute.NewSet(attrs...))
+	}
+
+	err = newObservableNumber(meter, "counter", "otelgen.bytes_sent", useInt(valueType, true),
+		func(observe func(float64, ...metric.ObserveOption)) {
+			observe(float64(m.bytesSent.Load()), metric.WithAttributes(
+				attribute.String("host", "localhost"),
+			))
+		},
+		metric.WithDescription("Total response bytes sent"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create observable counter: %w", err)
+	}
+
+	err = newObservableNumber(meter, "updowncounter", "otelgen.active_requests", useInt(valueType, true),
+		func(observe func(float64, ...metric.ObserveOption)) {
+			observe(float64(m.activeRequests.Load()), metric.WithAttributes(
+				attribute.String("host", "localhost"),
+			))
+		},
+		metric.WithDescription("Requests currently in flight"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create observable up-down counter: %w", err)
+	}
+
+	return m, nil
+}
+
+// Record adds one request to the counter and one sample to the histogram, and moves the observed totals
+func (m *defaultMetrics) Record(ctx context.Context) {
+	attrs := m.sets[m.next]
+	m.next = (m.next + 1) % len(m.sets)
+
+	// Record counter
+	m.counter(ctx, 1, attrs)
+
+	// Record histogram
+	m.histogram.Record(ctx, m.rnd.Float64()*1000, attrs)
+
+	// Update the totals read by the observable instruments
+	m.bytesSent.Add(m.rnd.Int63n(4096) + 512 + m.payloadSize)
+	if active := m.activeRequests.Add(m.rnd.Int63n(5) - 2); active < 0 {
+		m.activeRequests.Store(0)
+	}
+}
+
+// newMetricExporter creates an OTLP metrics exporter for the endpoint's protocol
+func newMetricExporter(ctx context.Context, endpoint *Endpoint, headers map[string]string, transport TransportOptions, obs *exportObserver, verbose bool) (sdkmetric.Exporter, error) {
+	// Use context with timeout for exporter creation
+	exporterCtx, cancel := context.WithTimeout(ctx, transport.connectTimeout())
+	defer cancel()
+
+	if err := transport.validate(endpoint); err != nil {
+		return nil, err
+	}
+	if c := transport.compressor(); c != "" && verbose {
+		fmt.Printf("[VERBOSE] Using %s compression\n", c)
+	}
+
+	auth, err := transport.newAuthorization()
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil && verbose {
+		fmt.Println("[VERBOSE] Adding Authorization header to every export")
+	}
+	headers, dynamic, err := transport.newRequestHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	if dynamic != nil && verbose {
+		dynamic.describe()
+	}
+	if verbose {
+		transport.describeRetry()
+	}
+
+	if endpoint.IsGRPC() {
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpoint(endpoint.Address()),
+		}
 
-			if verbose && count%5 == 0 {
-				fmt.Printf("[VERBOSE] Generated %d metric events (next export in ~%ds)\n", count, 2-(count%2))
+		if endpoint.Secure {
+			if verbose {
+				transport.describeTLS("TLS")
+			}
+			opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(transport.tlsConfig())))
+		} else {
+			if verbose {
+				fmt.Println("[VERBOSE] Using insecure gRPC connection")
 			}
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
 		}
+
+		if len(headers) > 0 {
+			if verbose {
+				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
+			}
+			opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
+		}
+
+		opts = append(opts, otlpmetricgrpc.WithDialOption(transport.dialOptions(endpoint, auth, dynamic, obs)...))
+
+		if transport.Retry != nil {
+			opts = append(opts, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(*transport.Retry)))
+		}
+
+		opts = append(opts, otlpmetricgrpc.WithTimeout(transport.grpcTimeout()))
+
+		if verbose {
+			fmt.Printf("[VERBOSE] Creating gRPC metrics exporter for %s\n", endpoint.Address())
+		}
+		return otlpmetricgrpc.New(exporterCtx, opts...)
+	}
+
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(endpoint.Address()),
+	}
+
+	if !endpoint.Secure {
+		if verbose {
+			fmt.Println("[VERBOSE] Using insecure HTTP connection")
+		}
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else {
+		if verbose {
+			transport.describeTLS("HTTPS")
+		}
+	}
+
+	if len(headers) > 0 {
+		if verbose {
+			fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
+		}
+		opts = append(opts, otlpmetrichttp.WithHeaders(headers))
+	}
+
+	if transport.compressor() == "gzip" {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+
+	if transport.HTTPPath != "" {
+		if verbose {
+			fmt.Printf("[VERBOSE] Using URL path %s\n", transport.HTTPPath)
+		}
+		opts = append(opts, otlpmetrichttp.WithURLPath(transport.HTTPPath))
+	}
+
+	if transport.Retry != nil {
+		opts = append(opts, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(*transport.Retry)))
+	}
+
+	opts = append(opts, otlpmetrichttp.WithTimeout(transport.exportTimeout()))
+
+	// The client carries the TLS settings and credentials, and observes the responses
+	opts = append(opts, otlpmetrichttp.WithHTTPClient(transport.httpClient(endpoint, auth, dynamic, obs)))
+
+	if verbose {
+		fmt.Printf("[VERBOSE] Creating HTTP metrics exporter for %s\n", endpoint.Address())
 	}
+	return otlpmetrichttp.New(exporterCtx, opts...)
 }
@@ -0,0 +1,253 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gopkg.in/yaml.v3"
+)
+
+// MetricsFile is the layout of a --metrics-file document
+type MetricsFile struct {
+	Metrics []MetricDefinition `yaml:"metrics"`
+	Views   []ViewDefinition   `yaml:"views"`
+}
+
+// MetricDefinition describes one custom metric
+type MetricDefinition struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"` // counter, updowncounter, histogram, gauge, observable_counter, observable_updowncounter, observable_gauge
+	Unit        string `yaml:"unit"`
+	Description string `yaml:"description"`
+	// Attributes maps each key to one fixed value or a list picked from at random
+	Attributes map[string]AttributeValues `yaml:"attributes"`
+	Value      ValueGenerator             `yaml:"value"`
+	// Rate is recordings per second; zero records once per generation tick
+	Rate float64 `yaml:"rate"`
+	// Interval exports this metric on its own schedule instead of the shared 2s one
+	Interval time.Duration `yaml:"interval"`
+	// Offset delays this metric's first export, so its exports are out of phase with the rest
+	Offset time.Duration `yaml:"offset"`
+}
+
+// AttributeValues is a single attribute value or a list of candidate values
+type AttributeValues []string
+
+// UnmarshalYAML accepts either a scalar or a sequence
+func (a *AttributeValues) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*a = AttributeValues{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*a = values
+	return nil
+}
+
+// ValueGenerator describes how a metric's values are produced
+type ValueGenerator struct {
+	Pattern string        `yaml:"pattern"` // random, sine, sawtooth, step, random-walk, constant
+	Min     float64       `yaml:"min"`
+	Max     float64       `yaml:"max"`
+	Period  time.Duration `yaml:"period"`
+}
+
+// LoadMetricsFile reads and validates a metrics definition file
+func LoadMetricsFile(path string) (*MetricsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics file: %w", err)
+	}
+
+	var file MetricsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics file: %w", err)
+	}
+
+	for i, def := range file.Metrics {
+		if def.Name == "" {
+			return nil, fmt.Errorf("metric %d: name is required", i+1)
+		}
+		switch def.Type {
+		case "counter", "updowncounter", "histogram", "gauge",
+			"observable_counter", "observable_updowncounter", "observable_gauge":
+		default:
+			return nil, fmt.Errorf("metric %s: unknown type %q (supported: counter, updowncounter, histogram, gauge, observable_counter, observable_updowncounter, observable_gauge)", def.Name, def.Type)
+		}
+		if def.Interval < 0 || def.Offset < 0 {
+			return nil, fmt.Errorf("metric %s: interval and offset cannot be negative", def.Name)
+		}
+	}
+
+	if _, err := newViews(file.Views); err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// customMetric is a definition plus the state that outlives a meter provider
+type customMetric struct {
+	def   MetricDefinition
+	value *waveform
+	due   *dueCounter
+	rnd   *runRandom
+}
+
+// newCustomMetrics prepares the long-lived state for each definition. Attributes are
+// drawn from rnd and the waveforms from observed.
+func newCustomMetrics(defs []MetricDefinition, rnd *runRandom, observed *rand.Rand) ([]*customMetric, error) {
+	metrics := make([]*customMetric, 0, len(defs))
+	for _, def := range defs {
+		v := def.Value
+		if v.Min == 0 && v.Max == 0 {
+			v.Max = 100
+		}
+		if v.Period == 0 {
+			v.Period = time.Minute
+		}
+		w, err := newWaveform(v.Pattern, v.Period, (v.Min+v.Max)/2, (v.Max-v.Min)/2, observed)
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %w", def.Name, err)
+		}
+
+		cm := &customMetric{def: def, value: w, rnd: rnd}
+		if def.Rate > 0 {
+			cm.due = newDueCounter(def.Rate)
+		}
+		metrics = append(metrics, cm)
+	}
+	return metrics, nil
+}
+
+// recordings returns how many values the metric should record this tick
+func (cm *customMetric) recordings(now time.Time) int {
+	if cm.due == nil {
+		return 1
+	}
+	from, to := cm.due.due(now)
+	return to - from
+}
+
+// randomAttributes picks one value per attribute key
+func (cm *customMetric) randomAttributes() []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(cm.def.Attributes))
+	for k, values := range cm.def.Attributes {
+		if len(values) > 0 {
+			attrs = append(attrs, attribute.String(k, values[cm.rnd.Intn(len(values))]))
+		}
+	}
+	return attrs
+}
+
+// attributeCombinations returns every combination of attribute values
+func (cm *customMetric) attributeCombinations() [][]attribute.KeyValue {
+	keys := make([]string, 0, len(cm.def.Attributes))
+	for k := range cm.def.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combos := [][]attribute.KeyValue{{}}
+	for _, k := range keys {
+		var next [][]attribute.KeyValue
+		for _, combo := range combos {
+			for _, v := range cm.def.Attributes[k] {
+				next = append(next, append(append([]attribute.KeyValue{}, combo...), attribute.String(k, v)))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// customMetricSet records user-defined metrics
+type customMetricSet struct {
+	recorders []func(ctx context.Context, now time.Time)
+}
+
+func newCustomMetricSet(meter metric.Meter, metrics []*customMetric, valueType string) (*customMetricSet, error) {
+	set := &customMetricSet{}
+	isInt := useInt(valueType, false)
+
+	for _, cm := range metrics {
+		def := cm.def
+		opts := []metric.InstrumentOption{metric.WithDescription(def.Description), metric.WithUnit(def.Unit)}
+
+		var err error
+		switch def.Type {
+		case "counter":
+			var counter numberAdder
+			counter, err = newNumberCounter(meter, def.Name, isInt, opts...)
+			set.recorders = append(set.recorders, func(ctx context.Context, now time.Time) {
+				for i := cm.recordings(now); i > 0; i-- {
+					counter(ctx, math.Max(0, cm.value.Value(now)), metric.WithAttributes(cm.randomAttributes()...))
+				}
+			})
+		case "updowncounter":
+			var counter numberAdder
+			counter, err = newNumberUpDownCounter(meter, def.Name, isInt, opts...)
+			set.recorders = append(set.recorders, func(ctx context.Context, now time.Time) {
+				for i := cm.recordings(now); i > 0; i-- {
+					counter(ctx, cm.value.Value(now), metric.WithAttributes(cm.randomAttributes()...))
+				}
+			})
+		case "histogram":
+			var histogram metric.Float64Histogram
+			histogram, err = meter.Float64Histogram(def.Name, metric.WithDescription(def.Description), metric.WithUnit(def.Unit))
+			set.recorders = append(set.recorders, func(ctx context.Context, now time.Time) {
+				for i := cm.recordings(now); i > 0; i-- {
+					histogram.Record(ctx, cm.value.Value(now), metric.WithAttributes(cm.randomAttributes()...))
+				}
+			})
+		case "gauge", "observable_gauge":
+			err = newObservableNumber(meter, "gauge", def.Name, isInt, func(observe func(float64, ...metric.ObserveOption)) {
+				now := time.Now()
+				for _, attrs := range cm.attributeCombinations() {
+					observe(cm.value.Value(now), metric.WithAttributes(attrs...))
+				}
+			}, opts...)
+		case "observable_counter":
+			// Each collection adds the next value to a running total per attribute combination
+			combos := cm.attributeCombinations()
+			totals := make([]float64, len(combos))
+			err = newObservableNumber(meter, "counter", def.Name, isInt, func(observe func(float64, ...metric.ObserveOption)) {
+				now := time.Now()
+				for i, attrs := range combos {
+					totals[i] += math.Max(0, cm.value.Value(now))
+					observe(totals[i], metric.WithAttributes(attrs...))
+				}
+			}, opts...)
+		case "observable_updowncounter":
+			err = newObservableNumber(meter, "updowncounter", def.Name, isInt, func(observe func(float64, ...metric.ObserveOption)) {
+				now := time.Now()
+				for _, attrs := range cm.attributeCombinations() {
+					observe(cm.value.Value(now), metric.WithAttributes(attrs...))
+				}
+			}, opts...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", def.Name, err)
+		}
+	}
+
+	return set, nil
+}
+
+// Record records each synchronous metric as often as its rate calls for
+func (s *customMetricSet) Record(ctx context.Context) {
+	now := time.Now()
+	for _, record := range s.recorders {
+		record(ctx, now)
+	}
+}
@@ -0,0 +1,144 @@
+package otelgen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// msgpackEncoder writes the subset of MessagePack the Fluent Forward protocol needs
+type msgpackEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *msgpackEncoder) Bytes() []byte { return e.buf.Bytes() }
+
+func (e *msgpackEncoder) Reset() { e.buf.Reset() }
+
+func (e *msgpackEncoder) writeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xdc)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		e.buf.WriteByte(0xdd)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func (e *msgpackEncoder) writeMapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xde)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		e.buf.WriteByte(0xdf)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(0xd9)
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xda)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		e.buf.WriteByte(0xdb)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+	e.buf.WriteString(s)
+}
+
+func (e *msgpackEncoder) writeBinary(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(0xc4)
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xc5)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		e.buf.WriteByte(0xc6)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+	e.buf.Write(b)
+}
+
+func (e *msgpackEncoder) writeInt(v int64) {
+	switch {
+	case v >= 0 && v < 128:
+		e.buf.WriteByte(byte(v))
+	case v < 0 && v >= -32:
+		e.buf.WriteByte(byte(v))
+	default:
+		e.buf.WriteByte(0xd3)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(v)))
+	}
+}
+
+// writeEventTime writes the Fluent Forward EventTime extension, which keeps
+// nanosecond precision that an integer timestamp would lose
+func (e *msgpackEncoder) writeEventTime(t time.Time) {
+	e.buf.WriteByte(0xd7) // fixext 8
+	e.buf.WriteByte(0x00) // EventTime
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Unix())))
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Nanosecond())))
+}
+
+// writeValue writes a string, number, bool, byte slice, slice, or map; maps are
+// written in key order so the same record always encodes the same way
+func (e *msgpackEncoder) writeValue(v any) {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			e.buf.WriteByte(0xc3)
+		} else {
+			e.buf.WriteByte(0xc2)
+		}
+	case int:
+		e.writeInt(int64(v))
+	case int64:
+		e.writeInt(v)
+	case float64:
+		e.buf.WriteByte(0xcb)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case string:
+		e.writeString(v)
+	case []byte:
+		e.writeBinary(v)
+	case []any:
+		e.writeArrayHeader(len(v))
+		for _, item := range v {
+			e.writeValue(item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.writeMapHeader(len(keys))
+		for _, k := range keys {
+			e.writeString(k)
+			e.writeValue(v[k])
+		}
+	default:
+		e.writeString(fmt.Sprint(v))
+	}
+}
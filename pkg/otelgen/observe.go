@@ -0,0 +1,459 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// signalItems names what each signal's partial success responses count
+var signalItems = map[string]string{
+	"traces":  "spans",
+	"metrics": "data points",
+	"logs":    "log records",
+}
+
+// exportObserver watches export responses, reporting throttling and partially rejected
+// exports as they happen and totalling the rejections for the run summary
+type exportObserver struct {
+	signal   string
+	retrying bool
+	// name, when set, labels the summary, telling it apart from those of the runs
+	// alongside
+	name string
+	// requests counts every export request written, retries included; bytes totals
+	// their uncompressed size, and wireBytes their size as written, after compression
+	// and with gRPC's message framing
+	requests  atomic.Int64
+	bytes     atomic.Int64
+	wireBytes atomic.Int64
+
+	// pacer, when set, slows the rate whenever the endpoint throttles an export
+	pacer *adaptivePacer
+	// failures counts the failed export attempts by class
+	failures exportFailures
+	// log and events, when set, record every export attempt
+	log    *exportLog
+	events *EventStream
+
+	// runRejected totals the rejected items of the whole run, warmup included
+	runRejected atomic.Int64
+	// latencyAlert, when positive, is the latency over which an export attempt is
+	// warned about as it happens and counted in the summary
+	latencyAlert time.Duration
+
+	mu       sync.Mutex
+	partial  int
+	rejected int64
+	// overBudget counts the export attempts slower than latencyAlert, slowest is the
+	// slowest of them, and unwarned those not warned about since the last warning
+	overBudget int
+	slowest    time.Duration
+	lastAlert  time.Time
+	unwarned   int
+	// warmup is the requests and bytes written before the summary starts
+	warmup struct{ requests, bytes, wireBytes int64 }
+}
+
+func newExportObserver(signal string, retrying bool) *exportObserver {
+	return &exportObserver{signal: signal, retrying: retrying}
+}
+
+// response records the partial success carried by an export response, if any, and
+// returns its rejected items and error message
+func (o *exportObserver) response(msg any) (int64, string) {
+	var rejected int64
+	var message string
+	switch r := msg.(type) {
+	case *coltracepb.ExportTraceServiceResponse:
+		rejected, message = r.GetPartialSuccess().GetRejectedSpans(), r.GetPartialSuccess().GetErrorMessage()
+	case *colmetricspb.ExportMetricsServiceResponse:
+		rejected, message = r.GetPartialSuccess().GetRejectedDataPoints(), r.GetPartialSuccess().GetErrorMessage()
+	case *collogspb.ExportLogsServiceResponse:
+		rejected, message = r.GetPartialSuccess().GetRejectedLogRecords(), r.GetPartialSuccess().GetErrorMessage()
+	default:
+		return 0, ""
+	}
+	if rejected == 0 && message == "" {
+		return 0, ""
+	}
+
+	o.runRejected.Add(rejected)
+	o.mu.Lock()
+	o.partial++
+	o.rejected += rejected
+	o.mu.Unlock()
+
+	text := message
+	if text == "" {
+		text = "no error message"
+	}
+	fmt.Printf("Export partially rejected: %d %s rejected (%s)\n", rejected, signalItems[o.signal], text)
+	return rejected, message
+}
+
+// newResponse returns an empty export response for the observed signal
+func (o *exportObserver) newResponse() proto.Message {
+	switch o.signal {
+	case "traces":
+		return &coltracepb.ExportTraceServiceResponse{}
+	case "metrics":
+		return &colmetricspb.ExportMetricsServiceResponse{}
+	default:
+		return &collogspb.ExportLogsServiceResponse{}
+	}
+}
+
+// endWarmup zeroes the partial success totals, so the summary leaves out the warmup
+func (o *exportObserver) endWarmup() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.partial, o.rejected = 0, 0
+	o.overBudget, o.slowest = 0, 0
+	o.warmup.requests, o.warmup.bytes, o.warmup.wireBytes = o.requests.Load(), o.bytes.Load(), o.wireBytes.Load()
+	o.failures.reset()
+}
+
+// printSummary prints the bytes of the export requests written, and the partial
+// success totals and failed export attempts, if there were any
+func (o *exportObserver) printSummary() {
+	label := ""
+	if o.name != "" {
+		label = o.name + ": "
+	}
+	o.mu.Lock()
+	if requests := o.requests.Load() - o.warmup.requests; requests > 0 {
+		size, wire := float64(o.bytes.Load()-o.warmup.bytes), float64(o.wireBytes.Load()-o.warmup.wireBytes)
+		ratio := 0.0
+		if size > 0 {
+			ratio = wire / size * 100
+		}
+		fmt.Printf("%sWrote %d export requests: %s uncompressed (%s each), %s on the wire (%s each, %.0f%%)\n",
+			label, requests, formatBytes(size), formatBytes(size/float64(requests)),
+			formatBytes(wire), formatBytes(wire/float64(requests)), ratio)
+	}
+	if o.partial > 0 {
+		fmt.Printf("%sPartially rejected exports: %d (%d %s rejected)\n", label, o.partial, o.rejected, signalItems[o.signal])
+	}
+	if o.latencyAlert > 0 {
+		fmt.Printf("%sExport attempts over the %s latency budget: %d", label, o.latencyAlert, o.overBudget)
+		if o.overBudget > 0 {
+			fmt.Printf(" (slowest %s)", o.slowest.Round(10*time.Microsecond))
+		}
+		fmt.Println()
+	}
+	o.mu.Unlock()
+	o.failures.printSummary()
+}
+
+// timed warns about an export attempt that started at start if it took longer than
+// the latency budget, at most once a second, so a degrading endpoint shows at once
+func (o *exportObserver) timed(start time.Time) {
+	if o.latencyAlert <= 0 {
+		return
+	}
+	latency := time.Since(start)
+	if latency <= o.latencyAlert {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.overBudget++
+	o.slowest = max(o.slowest, latency)
+	if time.Since(o.lastAlert) < time.Second {
+		o.unwarned++
+		return
+	}
+	msg := fmt.Sprintf("WARNING: %s export took %s, over the %s latency budget", o.signal, latency.Round(10*time.Microsecond), o.latencyAlert)
+	if o.unwarned > 0 {
+		msg += fmt.Sprintf(" (and %d more since the last warning)", o.unwarned)
+	}
+	fmt.Println(msg)
+	o.lastAlert, o.unwarned = time.Now(), 0
+}
+
+// failed records an export attempt that failed with err
+func (o *exportObserver) failed(err error) {
+	msg := err.Error()
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	o.failures.add(classifyError(err), msg)
+}
+
+// throttled prints a throttling response and whether the exporter honors it
+func (o *exportObserver) throttled(reason string, delay time.Duration) {
+	if o.retrying {
+		fmt.Printf("Endpoint throttled export (%s), retrying after %s\n", reason, delay)
+		return
+	}
+	fmt.Printf("Endpoint throttled export (%s), asked to retry after %s but retries are disabled\n", reason, delay)
+}
+
+// pushedBack tells the pacer, if any, that the endpoint throttled an export, with the
+// delay it asked for or zero
+func (o *exportObserver) pushedBack(reason string, delay time.Duration) {
+	if o.pacer != nil {
+		o.pacer.throttled(reason, delay)
+	}
+}
+
+// interceptor observes each gRPC export's response or RetryInfo error
+func (o *exportObserver) interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		o.timed(start)
+		if err == nil {
+			rejected, message := o.response(reply)
+			o.logCall(start, req, nil, rejected, message)
+			return nil
+		}
+		o.logCall(start, req, err, 0, "")
+		if dl := deadLetterFrom(ctx); dl != nil {
+			dl.failedCall(req)
+		}
+		o.failed(err)
+		s, ok := status.FromError(err)
+		if !ok {
+			return err
+		}
+		throttled := s.Code() == codes.ResourceExhausted
+		var delay time.Duration
+		for _, detail := range s.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+				throttled, delay = true, info.RetryDelay.AsDuration()
+				o.throttled(fmt.Sprintf("gRPC %s RetryInfo", s.Code()), delay)
+			}
+		}
+		if throttled {
+			o.pushedBack(fmt.Sprintf("gRPC %s", s.Code()), delay)
+		}
+		return err
+	}
+}
+
+// logging reports whether export attempts are logged
+func (o *exportObserver) logging() bool {
+	return o.log != nil || o.events != nil
+}
+
+// record writes an export attempt to the export log and event stream, if set
+func (o *exportObserver) record(rec *exportRecord) {
+	if o.log != nil {
+		o.log.write(rec)
+	}
+	o.events.export(rec)
+}
+
+// logCall logs a gRPC export attempt that started at start, if attempts are logged
+func (o *exportObserver) logCall(start time.Time, req any, err error, rejected int64, message string) {
+	if !o.logging() {
+		return
+	}
+	rec := &exportRecord{
+		Time:     start.UTC(),
+		Signal:   o.signal,
+		Protocol: "grpc",
+		Items:    requestItems(req),
+		Duration: time.Since(start).Seconds(),
+		GRPCCode: status.Code(err).String(),
+	}
+	if msg, ok := req.(proto.Message); ok {
+		rec.Bytes = int64(proto.Size(msg))
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	rec.Rejected, rec.RejectedMessage = rejected, message
+	o.record(rec)
+}
+
+// logPost logs an HTTP export attempt of items that started at start
+func (o *exportObserver) logPost(start time.Time, items int, size int64, resp *http.Response, err error, rejected int64, message string) {
+	rec := &exportRecord{
+		Time:            start.UTC(),
+		Signal:          o.signal,
+		Protocol:        "http",
+		Items:           items,
+		Bytes:           size,
+		Duration:        time.Since(start).Seconds(),
+		Rejected:        rejected,
+		RejectedMessage: message,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.HTTPStatus = resp.StatusCode
+	}
+	o.record(rec)
+}
+
+// observedStats counts the bytes of each gRPC export request as it is written
+type observedStats struct {
+	obs *exportObserver
+}
+
+func (h observedStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context { return ctx }
+
+func (h observedStats) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if p, ok := s.(*stats.OutPayload); ok && p.IsClient() {
+		h.obs.requests.Add(1)
+		h.obs.bytes.Add(int64(p.Length))
+		h.obs.wireBytes.Add(int64(p.WireLength))
+	}
+}
+
+func (h observedStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context { return ctx }
+
+func (h observedStats) HandleConn(context.Context, stats.ConnStats) {}
+
+// observedTransport observes each HTTP export's response body or Retry-After delay
+type observedTransport struct {
+	base http.RoundTripper
+	obs  *exportObserver
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body of an export that may be dead-lettered is kept until it succeeds, and
+	// that of a logged export is read for its items
+	start := time.Now()
+	dl := deadLetterFrom(req.Context())
+	var body []byte
+	if dl != nil || t.obs.logging() {
+		var err error
+		if body, req, err = bufferRequest(req); err != nil {
+			return nil, err
+		}
+	}
+	size, req, err := measureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	t.obs.timed(start)
+	t.obs.requests.Add(1)
+	t.obs.bytes.Add(size)
+	var rejected int64
+	var message string
+	if t.obs.logging() {
+		items := bodyItems(t.obs.signal, body, req.Header.Get("Content-Encoding"))
+		defer func() { t.obs.logPost(start, items, size, resp, err, rejected, message) }()
+	}
+	if dl != nil && (err != nil || resp.StatusCode/100 != 2) {
+		dl.failedPost(body, req.Header.Get("Content-Encoding"))
+	}
+	if err != nil {
+		t.obs.failed(err)
+		return resp, err
+	}
+	if resp.StatusCode/100 != 2 {
+		t.obs.failures.add(httpFailure(resp.StatusCode), resp.Status)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// The exporters only understand Retry-After in seconds
+		throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		var delay time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			throttled, delay = true, time.Duration(seconds)*time.Second
+			t.obs.throttled(fmt.Sprintf("HTTP %d Retry-After", resp.StatusCode), delay)
+		}
+		if throttled {
+			t.obs.pushedBack(fmt.Sprintf("HTTP %d", resp.StatusCode), delay)
+		}
+	case http.StatusOK:
+		// Responses are small; the body is read here and handed back to the exporter
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil || len(body) == 0 || resp.Header.Get("Content-Encoding") != "" {
+			return resp, nil
+		}
+		msg := t.obs.newResponse()
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, msg)
+		} else {
+			err = proto.Unmarshal(body, msg)
+		}
+		if err == nil {
+			rejected, message = t.obs.response(msg)
+		}
+	}
+	return resp, nil
+}
+
+// measureRequest returns the size of a request body before compression, and the
+// request to send in its place, since measuring a gzipped body consumes it
+func measureRequest(req *http.Request) (int64, *http.Request, error) {
+	if req.Body == nil || (req.Header.Get("Content-Encoding") != "gzip" && req.ContentLength >= 0) {
+		return max(req.ContentLength, 0), req, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	if req.Header.Get("Content-Encoding") != "gzip" {
+		return int64(len(body)), out, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	n, err := io.Copy(io.Discard, zr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	return n, out, nil
+}
+
+// wireTransport counts the bytes of each request body as the transport beneath it
+// writes them, after compression and any re-encoding
+type wireTransport struct {
+	base http.RoundTripper
+	obs  *exportObserver
+}
+
+func (t *wireTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		out := *req
+		out.Body = &countedBody{ReadCloser: req.Body, n: &t.obs.wireBytes}
+		req = &out
+	}
+	return t.base.RoundTrip(req)
+}
+
+// countedBody adds the bytes read from a request body to n
+type countedBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,339 @@
+// Package otelgentest provides an in-memory OTLP collector and golden-file helpers,
+// for Go tests that assert on what otelgen generates and how its exporters behave
+// without network access.
+package otelgentest
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/edgedelta/otelgen/pkg/otelgen"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // receives gzip-compressed exports
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// host is the name the collector's endpoints are reached at; it is never resolved
+	host = "otelgentest"
+	// grpcAddress and httpAddress are the collector's gRPC and HTTP receivers
+	grpcAddress = host + ":4317"
+	httpAddress = host + ":4318"
+	// bufferSize is the buffer of each in-memory connection
+	bufferSize = 1 << 20
+)
+
+// Collector is an OTLP receiver over gRPC and HTTP whose connections live in memory.
+// It keeps every request it receives, and can be told to fail the next ones to see
+// how the exporters retry
+type Collector struct {
+	grpcListener *bufconn.Listener
+	httpListener *bufconn.Listener
+	grpcServer   *grpc.Server
+	httpServer   *http.Server
+
+	mu      sync.Mutex
+	traces  []*coltracepb.ExportTraceServiceRequest
+	logs    []*collogspb.ExportLogsServiceRequest
+	metrics []*colmetricspb.ExportMetricsServiceRequest
+	// failures is the number of requests still to fail, and failed those failed
+	failures int
+	failed   int
+}
+
+// NewCollector starts a collector that is stopped when the test ends
+func NewCollector(tb testing.TB) *Collector {
+	tb.Helper()
+	c := &Collector{
+		grpcListener: bufconn.Listen(bufferSize),
+		httpListener: bufconn.Listen(bufferSize),
+		grpcServer:   grpc.NewServer(),
+	}
+	coltracepb.RegisterTraceServiceServer(c.grpcServer, collectorTraces{Collector: c})
+	collogspb.RegisterLogsServiceServer(c.grpcServer, collectorLogs{Collector: c})
+	colmetricspb.RegisterMetricsServiceServer(c.grpcServer, collectorMetrics{Collector: c})
+	go c.grpcServer.Serve(c.grpcListener)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/traces", c.handle("traces"))
+	mux.HandleFunc("/v1/logs", c.handle("logs"))
+	mux.HandleFunc("/v1/metrics", c.handle("metrics"))
+	c.httpServer = &http.Server{Handler: h2c.NewHandler(mux, &http2.Server{})}
+	go c.httpServer.Serve(c.httpListener)
+
+	tb.Cleanup(c.Close)
+	return c
+}
+
+// Close stops the collector, keeping what it received
+func (c *Collector) Close() {
+	c.grpcServer.Stop()
+	c.httpServer.Close()
+}
+
+// GRPCEndpoint returns the endpoint of the gRPC receiver
+func (c *Collector) GRPCEndpoint() *otelgen.Endpoint {
+	return &otelgen.Endpoint{Protocol: otelgen.ProtocolGRPC, Host: host, Port: "4317"}
+}
+
+// HTTPEndpoint returns the endpoint of the HTTP receiver, which takes OTLP/protobuf
+// and OTLP/JSON, gzip-compressed or not, on the default /v1/<signal> paths, over
+// HTTP/1.1 or cleartext HTTP/2
+func (c *Collector) HTTPEndpoint() *otelgen.Endpoint {
+	return &otelgen.Endpoint{Protocol: otelgen.ProtocolHTTP, Host: host, Port: "4318"}
+}
+
+// Transport returns transport settings that reach the collector in memory; other
+// settings can be added to them, except TLS
+func (c *Collector) Transport() otelgen.TransportOptions {
+	return otelgen.TransportOptions{Dialer: c.Dial}
+}
+
+// Dial opens an in-memory connection to the receiver at address
+func (c *Collector) Dial(ctx context.Context, address string) (net.Conn, error) {
+	switch address {
+	case grpcAddress:
+		return c.grpcListener.DialContext(ctx)
+	case httpAddress:
+		return c.httpListener.DialContext(ctx)
+	}
+	return nil, fmt.Errorf("otelgentest: no receiver at %s (use %s or %s)", address, grpcAddress, httpAddress)
+}
+
+// FailNext fails the next n requests, of any signal, with a retryable error:
+// UNAVAILABLE over gRPC and 503 Service Unavailable over HTTP
+func (c *Collector) FailNext(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = n
+}
+
+// Failed returns the number of requests failed on purpose so far
+func (c *Collector) Failed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failed
+}
+
+// Reset forgets everything received so far
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.traces, c.logs, c.metrics = nil, nil, nil
+	c.failed = 0
+}
+
+// Traces returns the trace requests received, in order
+func (c *Collector) Traces() []*coltracepb.ExportTraceServiceRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*coltracepb.ExportTraceServiceRequest(nil), c.traces...)
+}
+
+// Logs returns the log requests received, in order
+func (c *Collector) Logs() []*collogspb.ExportLogsServiceRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*collogspb.ExportLogsServiceRequest(nil), c.logs...)
+}
+
+// Metrics returns the metric requests received, in order
+func (c *Collector) Metrics() []*colmetricspb.ExportMetricsServiceRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*colmetricspb.ExportMetricsServiceRequest(nil), c.metrics...)
+}
+
+// Spans returns every span received, in order
+func (c *Collector) Spans() []*tracepb.Span {
+	var spans []*tracepb.Span
+	for _, req := range c.Traces() {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+// LogRecords returns every log record received, in order
+func (c *Collector) LogRecords() []*logspb.LogRecord {
+	var records []*logspb.LogRecord
+	for _, req := range c.Logs() {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}
+	return records
+}
+
+// MetricsNamed returns every metric received with the name, one per request that
+// carried it, in order
+func (c *Collector) MetricsNamed(name string) []*metricspb.Metric {
+	var metrics []*metricspb.Metric
+	for _, req := range c.Metrics() {
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name == name {
+						metrics = append(metrics, m)
+					}
+				}
+			}
+		}
+	}
+	return metrics
+}
+
+// fail reports whether the request should be failed on purpose, counting it
+func (c *Collector) fail() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures == 0 {
+		return false
+	}
+	c.failures--
+	c.failed++
+	return true
+}
+
+// receive keeps a request of any signal
+func (c *Collector) receive(req proto.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch r := req.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		c.traces = append(c.traces, r)
+	case *collogspb.ExportLogsServiceRequest:
+		c.logs = append(c.logs, r)
+	case *colmetricspb.ExportMetricsServiceRequest:
+		c.metrics = append(c.metrics, r)
+	}
+}
+
+// handle returns the HTTP handler for a signal's OTLP path
+func (c *Collector) handle(signal string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if c.fail() {
+			http.Error(w, "otelgentest: failing on request", http.StatusServiceUnavailable)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req, resp proto.Message
+		switch signal {
+		case "traces":
+			req, resp = &coltracepb.ExportTraceServiceRequest{}, &coltracepb.ExportTraceServiceResponse{}
+		case "logs":
+			req, resp = &collogspb.ExportLogsServiceRequest{}, &collogspb.ExportLogsServiceResponse{}
+		default:
+			req, resp = &colmetricspb.ExportMetricsServiceRequest{}, &colmetricspb.ExportMetricsServiceResponse{}
+		}
+		isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+		if isJSON {
+			err = protojson.Unmarshal(data, req)
+		} else {
+			err = proto.Unmarshal(data, req)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse request: %v", err), http.StatusBadRequest)
+			return
+		}
+		c.receive(req)
+
+		var out []byte
+		if isJSON {
+			out, err = protojson.Marshal(resp)
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			out, err = proto.Marshal(resp)
+			w.Header().Set("Content-Type", "application/x-protobuf")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(out)
+	}
+}
+
+// errFailing is the gRPC error of requests failed on purpose
+var errFailing = status.Error(codes.Unavailable, "otelgentest: failing on request")
+
+type collectorTraces struct {
+	*Collector
+	coltracepb.UnimplementedTraceServiceServer
+}
+
+func (c collectorTraces) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	if c.fail() {
+		return nil, errFailing
+	}
+	c.receive(req)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type collectorLogs struct {
+	*Collector
+	collogspb.UnimplementedLogsServiceServer
+}
+
+func (c collectorLogs) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if c.fail() {
+		return nil, errFailing
+	}
+	c.receive(req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+type collectorMetrics struct {
+	*Collector
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+func (c collectorMetrics) Export(_ context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if c.fail() {
+		return nil, errFailing
+	}
+	c.receive(req)
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
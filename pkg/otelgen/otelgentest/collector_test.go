@@ -0,0 +1,69 @@
+package otelgentest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edgedelta/otelgen/pkg/otelgen"
+)
+
+func TestCollectorRoundTrip(t *testing.T) {
+	const count = 50
+	for _, tt := range []struct {
+		name        string
+		http        bool
+		httpVersion string
+	}{
+		{name: "grpc"},
+		{name: "http", http: true},
+		{name: "h2c", http: true, httpVersion: "2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(t)
+			endpoint := c.GRPCEndpoint()
+			if tt.http {
+				endpoint = c.HTTPEndpoint()
+			}
+			transport := c.Transport()
+			transport.HTTPVersion = tt.httpVersion
+			cfg := otelgen.NewConfig(endpoint,
+				otelgen.WithRate(1000),
+				otelgen.WithDuration(10*time.Second),
+				otelgen.WithTransport(transport),
+				otelgen.WithLoad(otelgen.LoadOptions{Count: count}),
+			)
+
+			result, err := otelgen.NewTraceGenerator(cfg).Run(context.Background())
+			if err != nil {
+				t.Fatalf("traces: %v", err)
+			}
+			if result.Events != count {
+				t.Errorf("traces: generated %d events, want %d", result.Events, count)
+			}
+			roots := 0
+			for _, span := range c.Spans() {
+				if len(span.ParentSpanId) == 0 {
+					roots++
+				}
+			}
+			if roots != count {
+				t.Errorf("traces: received %d root spans, want %d", roots, count)
+			}
+
+			result, err = otelgen.NewLogGenerator(cfg).Run(context.Background())
+			if err != nil {
+				t.Fatalf("logs: %v", err)
+			}
+			if result.Events != count {
+				t.Errorf("logs: generated %d events, want %d", result.Events, count)
+			}
+			if got := len(c.LogRecords()); got != count {
+				t.Errorf("logs: received %d log records, want %d", got, count)
+			}
+			if c.Failed() != 0 {
+				t.Errorf("collector failed %d requests, want none", c.Failed())
+			}
+		})
+	}
+}
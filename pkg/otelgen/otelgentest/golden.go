@@ -0,0 +1,212 @@
+package otelgentest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// UpdateEnv is the environment variable that, when set, makes AssertGolden write the
+// golden files instead of comparing against them
+const UpdateEnv = "OTELGENTEST_UPDATE"
+
+// Shape describes what the collector received of a signal, without the values that
+// change from run to run: one sorted line for every resource attribute, scope, and
+// item attribute seen, with its type, and for metrics every metric's name, type,
+// and unit. It stays the same across runs as long as the generated telemetry does,
+// which makes it suited to golden files
+func (c *Collector) Shape(signal string) []byte {
+	lines := make(map[string]bool)
+	add := func(format string, args ...any) {
+		lines[fmt.Sprintf(format, args...)] = true
+	}
+	addResource := func(res *resourcepb.Resource) {
+		for _, kv := range res.GetAttributes() {
+			add("resource %s %s", kv.Key, valueType(kv.Value))
+		}
+	}
+	addAttributes := func(kind string, attrs []*commonpb.KeyValue) {
+		for _, kv := range attrs {
+			add("%s attribute %s %s", kind, kv.Key, valueType(kv.Value))
+		}
+	}
+
+	switch signal {
+	case "traces":
+		for _, req := range c.Traces() {
+			for _, rs := range req.ResourceSpans {
+				addResource(rs.Resource)
+				for _, ss := range rs.ScopeSpans {
+					add("scope %s", ss.Scope.GetName())
+					for _, span := range ss.Spans {
+						addAttributes("span", span.Attributes)
+					}
+				}
+			}
+		}
+	case "logs":
+		for _, req := range c.Logs() {
+			for _, rl := range req.ResourceLogs {
+				addResource(rl.Resource)
+				for _, sl := range rl.ScopeLogs {
+					add("scope %s", sl.Scope.GetName())
+					for _, record := range sl.LogRecords {
+						addAttributes("log", record.Attributes)
+					}
+				}
+			}
+		}
+	case "metrics":
+		for _, req := range c.Metrics() {
+			for _, rm := range req.ResourceMetrics {
+				addResource(rm.Resource)
+				for _, sm := range rm.ScopeMetrics {
+					add("scope %s", sm.Scope.GetName())
+					for _, m := range sm.Metrics {
+						add("metric %s %s %q", m.Name, metricType(m), m.Unit)
+						for _, attrs := range dataPointAttributes(m) {
+							addAttributes("metric "+m.Name, attrs)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(lines))
+	for line := range lines {
+		sorted = append(sorted, line)
+	}
+	slices.Sort(sorted)
+	if len(sorted) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(sorted, "\n") + "\n")
+}
+
+// AssertGolden fails the test unless got matches the golden file at path; with
+// OTELGENTEST_UPDATE set, it writes got to the file instead
+func AssertGolden(tb testing.TB, path string, got []byte) {
+	tb.Helper()
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			tb.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("failed to read golden file (set %s=1 to create it): %v", UpdateEnv, err)
+	}
+	if string(got) != string(want) {
+		tb.Errorf("%s differs from what was received (set %s=1 to update it):\n%s", path, UpdateEnv, lineDiff(string(want), string(got)))
+	}
+}
+
+// lineDiff lists the lines only in want, marked -, and only in got, marked +
+func lineDiff(want, got string) string {
+	wantLines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	gotLines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	var b strings.Builder
+	for _, line := range wantLines {
+		if !slices.Contains(gotLines, line) {
+			fmt.Fprintf(&b, "- %s\n", line)
+		}
+	}
+	for _, line := range gotLines {
+		if !slices.Contains(wantLines, line) {
+			fmt.Fprintf(&b, "+ %s\n", line)
+		}
+	}
+	if b.Len() == 0 {
+		return "(the same lines in a different order)\n"
+	}
+	return b.String()
+}
+
+// valueType names the type of an attribute value
+func valueType(v *commonpb.AnyValue) string {
+	switch v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return "string"
+	case *commonpb.AnyValue_BoolValue:
+		return "bool"
+	case *commonpb.AnyValue_IntValue:
+		return "int"
+	case *commonpb.AnyValue_DoubleValue:
+		return "double"
+	case *commonpb.AnyValue_ArrayValue:
+		return "array"
+	case *commonpb.AnyValue_KvlistValue:
+		return "map"
+	case *commonpb.AnyValue_BytesValue:
+		return "bytes"
+	}
+	return "empty"
+}
+
+// metricType names the type of a metric, with its temporality and monotonicity
+func metricType(m *metricspb.Metric) string {
+	switch d := m.Data.(type) {
+	case *metricspb.Metric_Gauge:
+		return "gauge"
+	case *metricspb.Metric_Sum:
+		kind := "updowncounter"
+		if d.Sum.IsMonotonic {
+			kind = "counter"
+		}
+		return kind + " " + temporality(d.Sum.AggregationTemporality)
+	case *metricspb.Metric_Histogram:
+		return "histogram " + temporality(d.Histogram.AggregationTemporality)
+	case *metricspb.Metric_ExponentialHistogram:
+		return "exponential_histogram " + temporality(d.ExponentialHistogram.AggregationTemporality)
+	case *metricspb.Metric_Summary:
+		return "summary"
+	}
+	return "empty"
+}
+
+func temporality(t metricspb.AggregationTemporality) string {
+	if t == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+		return "delta"
+	}
+	return "cumulative"
+}
+
+// dataPointAttributes returns the attributes of each of a metric's data points
+func dataPointAttributes(m *metricspb.Metric) [][]*commonpb.KeyValue {
+	var attrs [][]*commonpb.KeyValue
+	switch d := m.Data.(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range d.Gauge.DataPoints {
+			attrs = append(attrs, dp.Attributes)
+		}
+	case *metricspb.Metric_Sum:
+		for _, dp := range d.Sum.DataPoints {
+			attrs = append(attrs, dp.Attributes)
+		}
+	case *metricspb.Metric_Histogram:
+		for _, dp := range d.Histogram.DataPoints {
+			attrs = append(attrs, dp.Attributes)
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		for _, dp := range d.ExponentialHistogram.DataPoints {
+			attrs = append(attrs, dp.Attributes)
+		}
+	case *metricspb.Metric_Summary:
+		for _, dp := range d.Summary.DataPoints {
+			attrs = append(attrs, dp.Attributes)
+		}
+	}
+	return attrs
+}
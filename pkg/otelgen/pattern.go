@@ -0,0 +1,74 @@
+package otelgen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// waveform produces gauge values that follow a predictable shape over time
+type waveform struct {
+	mu        sync.Mutex
+	kind      string
+	period    time.Duration
+	center    float64
+	amplitude float64
+	start     time.Time
+	walk      float64
+	rnd       *rand.Rand
+}
+
+// newWaveform creates a waveform of the given kind oscillating around center.
+// Supported kinds: random, sine, sawtooth, step, random-walk, constant. The random kinds
+// draw from rnd.
+func newWaveform(kind string, period time.Duration, center, amplitude float64, rnd *rand.Rand) (*waveform, error) {
+	switch kind {
+	case "", "random", "sine", "sawtooth", "step", "random-walk", "constant":
+	default:
+		return nil, fmt.Errorf("unknown pattern: %s (supported: random, sine, sawtooth, step, random-walk, constant)", kind)
+	}
+	if period <= 0 {
+		return nil, fmt.Errorf("pattern period must be positive")
+	}
+
+	return &waveform{
+		kind:      kind,
+		period:    period,
+		center:    center,
+		amplitude: amplitude,
+		start:     time.Now(),
+		walk:      center,
+		rnd:       rnd,
+	}, nil
+}
+
+// Value returns the waveform value at the given time
+func (w *waveform) Value(now time.Time) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Position within the current period, in [0, 1)
+	phase := float64(now.Sub(w.start)%w.period) / float64(w.period)
+
+	switch w.kind {
+	case "sine":
+		return w.center + w.amplitude*math.Sin(2*math.Pi*phase)
+	case "sawtooth":
+		return w.center - w.amplitude + 2*w.amplitude*phase
+	case "step":
+		if phase < 0.5 {
+			return w.center + w.amplitude
+		}
+		return w.center - w.amplitude
+	case "random-walk":
+		w.walk += w.rnd.NormFloat64() * w.amplitude / 10
+		w.walk = math.Max(w.center-w.amplitude, math.Min(w.center+w.amplitude, w.walk))
+		return w.walk
+	case "constant":
+		return w.center
+	default:
+		return w.center - w.amplitude + w.rnd.Float64()*2*w.amplitude
+	}
+}
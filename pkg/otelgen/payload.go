@@ -0,0 +1,42 @@
+package otelgen
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// payloadPool cycles through payloads generated up front, so high rates don't pay for
+// JSON marshaling and random strings on every item
+type payloadPool[T any] struct {
+	payloads []T
+	next     atomic.Uint64
+}
+
+// newPayloadPool generates size payloads, printing how long it took in verbose mode
+func newPayloadPool[T any](size int, generate func() T, items string, verbose bool) *payloadPool[T] {
+	start := time.Now()
+	p := &payloadPool[T]{payloads: make([]T, size)}
+	for i := range p.payloads {
+		p.payloads[i] = generate()
+	}
+	if verbose {
+		fmt.Printf("[VERBOSE] Pre-generated %d %s payloads in %s\n", size, items, time.Since(start).Round(time.Millisecond))
+	}
+	return p
+}
+
+// get returns the next payload, starting over after the last; it is safe for
+// concurrent use
+func (p *payloadPool[T]) get() T {
+	return p.payloads[(p.next.Add(1)-1)%uint64(len(p.payloads))]
+}
+
+// pooled returns generate, or, when size is positive, the get of a pool of size
+// payloads it generated
+func pooled[T any](size int, generate func() T, items string, verbose bool) func() T {
+	if size <= 0 {
+		return generate
+	}
+	return newPayloadPool(size, generate, items, verbose).get
+}
@@ -0,0 +1,366 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// newPresetMetrics creates the metric set for the named preset
+func newPresetMetrics(meter metric.Meter, preset string, rnd *runRandom) (metricRecorder, error) {
+	switch preset {
+	case "jvm":
+		return newJVMMetrics(meter, rnd)
+	case "goruntime":
+		return newGoRuntimeMetrics(meter, rnd)
+	default:
+		return nil, fmt.Errorf("unknown preset: %s (supported: jvm, goruntime)", preset)
+	}
+}
+
+// loadWalk is a bounded random walk used to drive correlated runtime behavior
+type loadWalk struct {
+	value float64
+	rnd   *runRandom
+}
+
+func (l *loadWalk) step() float64 {
+	l.value += (l.rnd.Float64() - 0.5) * 0.1
+	l.value = math.Max(0.1, math.Min(1.0, l.value))
+	return l.value
+}
+
+// jvmPool is a simulated JVM memory pool
+type jvmPool struct {
+	name      string
+	memType   string
+	used      int64
+	committed int64
+	limit     int64
+	afterGC   int64
+}
+
+// jvmMetrics simulates a JVM whose heap, GC, threads, and CPU follow the same load curve
+type jvmMetrics struct {
+	mu         sync.Mutex
+	load       loadWalk
+	eden       *jvmPool
+	survivor   *jvmPool
+	old        *jvmPool
+	metaspace  *jvmPool
+	codeCache  *jvmPool
+	threads    int64
+	daemons    int64
+	classes    int64
+	unloaded   int64
+	cpuTime    float64
+	cpuRecent  float64
+	gcDuration metric.Float64Histogram
+}
+
+func newJVMMetrics(meter metric.Meter, rnd *runRandom) (*jvmMetrics, error) {
+	const mb = 1024 * 1024
+	m := &jvmMetrics{
+		load:      loadWalk{value: 0.3, rnd: rnd},
+		eden:      &jvmPool{name: "G1 Eden Space", memType: "heap", committed: 256 * mb, limit: 256 * mb},
+		survivor:  &jvmPool{name: "G1 Survivor Space", memType: "heap", committed: 32 * mb, limit: 32 * mb},
+		old:       &jvmPool{name: "G1 Old Gen", memType: "heap", used: 64 * mb, committed: 512 * mb, limit: 768 * mb},
+		metaspace: &jvmPool{name: "Metaspace", memType: "non_heap", used: 48 * mb, committed: 52 * mb, limit: -1},
+		codeCache: &jvmPool{name: "CodeCache", memType: "non_heap", used: 12 * mb, committed: 16 * mb, limit: 240 * mb},
+		threads:   30,
+		daemons:   20,
+		classes:   8000,
+	}
+
+	var err error
+	m.gcDuration, err = meter.Float64Histogram(
+		"jvm.gc.duration",
+		metric.WithDescription("Duration of JVM garbage collection actions."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.1, 1, 10),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.gc.duration: %w", err)
+	}
+
+	used, err := meter.Int64ObservableUpDownCounter("jvm.memory.used",
+		metric.WithDescription("Measure of memory used."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.memory.used: %w", err)
+	}
+	committed, err := meter.Int64ObservableUpDownCounter("jvm.memory.committed",
+		metric.WithDescription("Measure of memory committed."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.memory.committed: %w", err)
+	}
+	limit, err := meter.Int64ObservableUpDownCounter("jvm.memory.limit",
+		metric.WithDescription("Measure of max obtainable memory."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.memory.limit: %w", err)
+	}
+	afterGC, err := meter.Int64ObservableUpDownCounter("jvm.memory.used_after_last_gc",
+		metric.WithDescription("Measure of memory used, as measured after the most recent garbage collection event on this pool."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.memory.used_after_last_gc: %w", err)
+	}
+	threads, err := meter.Int64ObservableUpDownCounter("jvm.thread.count",
+		metric.WithDescription("Number of executing platform threads."), metric.WithUnit("{thread}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.thread.count: %w", err)
+	}
+	classLoaded, err := meter.Int64ObservableCounter("jvm.class.loaded",
+		metric.WithDescription("Number of classes loaded since JVM start."), metric.WithUnit("{class}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.class.loaded: %w", err)
+	}
+	classCount, err := meter.Int64ObservableUpDownCounter("jvm.class.count",
+		metric.WithDescription("Number of classes currently loaded."), metric.WithUnit("{class}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.class.count: %w", err)
+	}
+	cpuTime, err := meter.Float64ObservableCounter("jvm.cpu.time",
+		metric.WithDescription("CPU time used by the process as reported by the JVM."), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.cpu.time: %w", err)
+	}
+	cpuRecent, err := meter.Float64ObservableGauge("jvm.cpu.recent_utilization",
+		metric.WithDescription("Recent CPU utilization for the process as reported by the JVM."), metric.WithUnit("1"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jvm.cpu.recent_utilization: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		for _, p := range []*jvmPool{m.eden, m.survivor, m.old, m.metaspace, m.codeCache} {
+			attrs := metric.WithAttributes(
+				attribute.String("jvm.memory.type", p.memType),
+				attribute.String("jvm.memory.pool.name", p.name),
+			)
+			o.ObserveInt64(used, p.used, attrs)
+			o.ObserveInt64(committed, p.committed, attrs)
+			if p.limit > 0 {
+				o.ObserveInt64(limit, p.limit, attrs)
+			}
+			if p.memType == "heap" {
+				o.ObserveInt64(afterGC, p.afterGC, attrs)
+			}
+		}
+
+		o.ObserveInt64(threads, m.daemons, metric.WithAttributes(
+			attribute.Bool("jvm.thread.daemon", true),
+			attribute.String("jvm.thread.state", "runnable"),
+		))
+		o.ObserveInt64(threads, m.threads-m.daemons, metric.WithAttributes(
+			attribute.Bool("jvm.thread.daemon", false),
+			attribute.String("jvm.thread.state", "runnable"),
+		))
+		o.ObserveInt64(classLoaded, m.classes+m.unloaded)
+		o.ObserveInt64(classCount, m.classes)
+		o.ObserveFloat64(cpuTime, m.cpuTime)
+		o.ObserveFloat64(cpuRecent, m.cpuRecent)
+		return nil
+	}, used, committed, limit, afterGC, threads, classLoaded, classCount, cpuTime, cpuRecent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register jvm callback: %w", err)
+	}
+
+	return m, nil
+}
+
+// Record advances the simulated JVM by one step, running GCs as pools fill up
+func (m *jvmMetrics) Record(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	load := m.load.step()
+
+	// Allocation rate follows load
+	m.eden.used += int64(load * float64(m.load.rnd.Intn(24*1024*1024)+4*1024*1024))
+
+	if m.eden.used >= m.eden.limit {
+		// Minor GC: most of eden dies, some survives, survivors age into old gen
+		survived := m.eden.used / 20
+		promoted := m.survivor.used / 2
+		m.eden.used = 0
+		m.survivor.used = min(m.survivor.used-promoted+survived, m.survivor.limit)
+		m.old.used += promoted
+		m.eden.afterGC = 0
+		m.survivor.afterGC = m.survivor.used
+
+		pause := 0.005 + float64(survived)/float64(m.survivor.limit)*0.05 + m.load.rnd.Float64()*0.01
+		m.gcDuration.Record(ctx, pause, metric.WithAttributes(
+			attribute.String("jvm.gc.name", "G1 Young Generation"),
+			attribute.String("jvm.gc.action", "end of minor GC"),
+		))
+		m.cpuTime += pause
+	}
+
+	if m.old.used >= m.old.limit*85/100 {
+		// Major GC: old gen collapses back to the live set
+		live := int64(float64(m.old.limit) * (0.15 + load*0.2))
+		pause := 0.1 + float64(m.old.used-live)/float64(m.old.limit)*0.4 + m.load.rnd.Float64()*0.05
+		m.old.used = live
+		m.old.afterGC = live
+		m.gcDuration.Record(ctx, pause, metric.WithAttributes(
+			attribute.String("jvm.gc.name", "G1 Old Generation"),
+			attribute.String("jvm.gc.action", "end of major GC"),
+		))
+		m.cpuTime += pause
+	}
+	if m.old.used > m.old.committed {
+		m.old.committed = min(m.old.used+m.old.used/10, m.old.limit)
+	}
+
+	// Threads and class loading track load
+	m.threads = 30 + int64(load*170) + int64(m.load.rnd.Intn(5))
+	m.daemons = 20 + int64(load*20)
+	if m.classes < 15000 && m.load.rnd.Float64() < load {
+		m.classes += int64(m.load.rnd.Intn(20))
+		m.metaspace.used += int64(m.load.rnd.Intn(64 * 1024))
+		m.metaspace.committed = max(m.metaspace.committed, m.metaspace.used)
+	}
+	m.codeCache.used = min(m.codeCache.used+int64(load*float64(m.load.rnd.Intn(16*1024))), m.codeCache.limit)
+
+	m.cpuRecent = math.Min(1, load*0.8+m.load.rnd.Float64()*0.05)
+	m.cpuTime += m.cpuRecent * 0.1
+}
+
+// goRuntimeMetrics simulates a Go process whose heap, GC, and goroutines follow the same load curve
+type goRuntimeMetrics struct {
+	mu          sync.Mutex
+	load        loadWalk
+	heapLive    int64
+	heapUsed    int64
+	stack       int64
+	gcGoal      int64
+	memLimit    int64
+	allocated   int64
+	allocations int64
+	goroutines  int64
+	gogc        int64
+	procs       int64
+	schedDelay  metric.Float64Histogram
+}
+
+func newGoRuntimeMetrics(meter metric.Meter, rnd *runRandom) (*goRuntimeMetrics, error) {
+	const mb = 1024 * 1024
+	m := &goRuntimeMetrics{
+		load:       loadWalk{value: 0.3, rnd: rnd},
+		heapLive:   16 * mb,
+		heapUsed:   16 * mb,
+		stack:      2 * mb,
+		gcGoal:     32 * mb,
+		memLimit:   460 * mb, // GOMEMLIMIT for a container with 512MiB of memory
+		goroutines: 50,
+		gogc:       100,
+		procs:      8,
+	}
+
+	var err error
+	m.schedDelay, err = meter.Float64Histogram(
+		"go.schedule.duration",
+		metric.WithDescription("The time goroutines have spent in the scheduler in a runnable state before actually running."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.schedule.duration: %w", err)
+	}
+
+	memUsed, err := meter.Int64ObservableUpDownCounter("go.memory.used",
+		metric.WithDescription("Memory used by the Go runtime."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.memory.used: %w", err)
+	}
+	memLimit, err := meter.Int64ObservableUpDownCounter("go.memory.limit",
+		metric.WithDescription("Go runtime memory limit configured by the user, if a limit exists."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.memory.limit: %w", err)
+	}
+	allocated, err := meter.Int64ObservableCounter("go.memory.allocated",
+		metric.WithDescription("Memory allocated to the heap by the application."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.memory.allocated: %w", err)
+	}
+	allocations, err := meter.Int64ObservableCounter("go.memory.allocations",
+		metric.WithDescription("Count of allocations to the heap by the application."), metric.WithUnit("{allocation}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.memory.allocations: %w", err)
+	}
+	gcGoal, err := meter.Int64ObservableUpDownCounter("go.memory.gc.goal",
+		metric.WithDescription("Heap size target for the end of the GC cycle."), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.memory.gc.goal: %w", err)
+	}
+	goroutines, err := meter.Int64ObservableUpDownCounter("go.goroutine.count",
+		metric.WithDescription("Count of live goroutines."), metric.WithUnit("{goroutine}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.goroutine.count: %w", err)
+	}
+	procs, err := meter.Int64ObservableUpDownCounter("go.processor.limit",
+		metric.WithDescription("The number of OS threads that can execute user-level Go code simultaneously."), metric.WithUnit("{thread}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.processor.limit: %w", err)
+	}
+	gogc, err := meter.Int64ObservableUpDownCounter("go.config.gogc",
+		metric.WithDescription("Heap size target percentage configured by the user, otherwise 100."), metric.WithUnit("%"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go.config.gogc: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		o.ObserveInt64(memUsed, m.stack, metric.WithAttributes(attribute.String("go.memory.type", "stack")))
+		o.ObserveInt64(memUsed, m.heapUsed, metric.WithAttributes(attribute.String("go.memory.type", "other")))
+		o.ObserveInt64(memLimit, m.memLimit)
+		o.ObserveInt64(allocated, m.allocated)
+		o.ObserveInt64(allocations, m.allocations)
+		o.ObserveInt64(gcGoal, m.gcGoal)
+		o.ObserveInt64(goroutines, m.goroutines)
+		o.ObserveInt64(procs, m.procs)
+		o.ObserveInt64(gogc, m.gogc)
+		return nil
+	}, memUsed, memLimit, allocated, allocations, gcGoal, goroutines, procs, gogc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register go runtime callback: %w", err)
+	}
+
+	return m, nil
+}
+
+// Record advances the simulated Go runtime by one step, running a GC when the heap reaches its goal
+func (m *goRuntimeMetrics) Record(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	load := m.load.step()
+
+	// Goroutines and allocations follow load
+	m.goroutines = 20 + int64(load*480) + int64(m.load.rnd.Intn(10))
+	m.stack = m.goroutines * 8 * 1024
+
+	alloc := int64(load * float64(m.load.rnd.Intn(8*1024*1024)+512*1024))
+	m.heapUsed += alloc
+	m.allocated += alloc
+	m.allocations += alloc / int64(m.load.rnd.Intn(200)+48)
+
+	// The live set drifts with load as well
+	m.heapLive = int64(float64(8*1024*1024) + load*float64(48*1024*1024))
+
+	if m.heapUsed >= m.gcGoal {
+		// GC: heap falls back to the live set and the goal is recomputed from GOGC, capped
+		// by the memory limit
+		m.heapUsed = m.heapLive
+		m.gcGoal = min(m.heapLive+m.heapLive*m.gogc/100, m.memLimit)
+	}
+
+	// Scheduler latency rises with goroutine count
+	m.schedDelay.Record(ctx, (float64(m.goroutines)/float64(m.procs))*(1e-6+m.load.rnd.Float64()*5e-6))
+}
@@ -0,0 +1,134 @@
+package otelgen
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// progressInterval is the time between redraws of the progress bar on a terminal
+	progressInterval = 500 * time.Millisecond
+	// progressLogInterval is the time between progress lines when stderr is not a
+	// terminal, such as a CI log, where redrawing would leave a line per redraw
+	progressLogInterval = 10 * time.Second
+	// progressWidth is the number of cells in the bar
+	progressWidth = 24
+	// progressBehind is the fraction of the target rate below which the achieved rate
+	// is flagged
+	progressBehind = 0.9
+)
+
+// progressBar draws the progress of a run on stderr: the share of the duration done,
+// the time left, and the achieved rate against the target, flagging a rate that falls
+// behind, e.g. because the endpoint is slow
+type progressBar struct {
+	events   string
+	duration time.Duration
+	// total, when positive, is the count of events that ends the run
+	total    int64
+	terminal bool
+	limiter  *rateLimiter
+	ticker   *time.Ticker
+	// drawn is set once a line has been redrawn in place, and must be ended
+	drawn bool
+
+	start, last time.Time
+	count       int
+}
+
+// newProgressBar returns the progress bar of the run, or nil when it shows none;
+// events names what the rate counts
+func (l LoadOptions) newProgressBar(events string, duration time.Duration) *progressBar {
+	if !l.ProgressBar {
+		return nil
+	}
+	terminal := false
+	if info, err := os.Stderr.Stat(); err == nil {
+		terminal = info.Mode()&os.ModeCharDevice != 0
+	}
+	return &progressBar{events: events, duration: duration, total: l.Count, terminal: terminal}
+}
+
+// begin starts drawing as generation starts, against the limiter's target rate
+func (b *progressBar) begin(limiter *rateLimiter) {
+	if b == nil {
+		return
+	}
+	b.limiter = limiter
+	b.start = time.Now()
+	b.last = b.start
+	interval := progressInterval
+	if !b.terminal {
+		interval = progressLogInterval
+	}
+	b.ticker = time.NewTicker(interval)
+}
+
+// tick delivers the redraw times; it never fires without a progress bar
+func (b *progressBar) tick() <-chan time.Time {
+	if b == nil {
+		return nil
+	}
+	return b.ticker.C
+}
+
+// update redraws the bar, given the events generated since the run started
+func (b *progressBar) update(events int) {
+	now := time.Now()
+	elapsed := min(now.Sub(b.start), b.duration)
+	interval := now.Sub(b.last)
+	achieved := float64(events-b.count) / interval.Seconds()
+	target := b.limiter.target(now.Sub(b.start) - interval/2)
+	b.last, b.count = now, events
+
+	done := elapsed.Seconds() / b.duration.Seconds()
+	left := b.duration - elapsed
+	length := fmt.Sprintf("%s/%s", elapsed.Round(time.Second), b.duration)
+	if b.duration == endless {
+		length = elapsed.Round(time.Second).String()
+	}
+	// A count ends the run once it is further along than the duration, which a run
+	// without one never is; the time left follows the average rate so far
+	if b.total > 0 {
+		if share := min(float64(events)/float64(b.total), 1); share >= done {
+			done, left = share, 0
+			if share > 0 {
+				left = time.Duration(float64(now.Sub(b.start)) * (1/share - 1))
+			}
+		}
+	}
+	var line string
+	if b.duration == endless && b.total <= 0 {
+		// A run without an end has no share done or time left to show
+		line = fmt.Sprintf("%s | %d %s, %.1f/s (target %.1f/s)", length, events, b.events, achieved, target)
+	} else {
+		filled := int(done * progressWidth)
+		line = fmt.Sprintf("[%s%s] %3.0f%% %s ETA %s | %d %s, %.1f/s (target %.1f/s)",
+			strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), done*100,
+			length, left.Round(time.Second), events, b.events, achieved, target)
+	}
+	if target > 0 && achieved < progressBehind*target {
+		line += " WARNING: behind the target rate"
+	}
+	if b.terminal {
+		// Clearing to the end of the line erases what a longer line left behind
+		fmt.Fprintf(os.Stderr, "\r%s\033[K", line)
+		b.drawn = true
+		return
+	}
+	fmt.Fprintln(os.Stderr, line)
+}
+
+// stop stops drawing, ending the bar's line so the summary starts on a fresh one
+func (b *progressBar) stop() {
+	if b == nil || b.ticker == nil {
+		return
+	}
+	b.ticker.Stop()
+	if b.drawn {
+		fmt.Fprintln(os.Stderr)
+		b.drawn = false
+	}
+}
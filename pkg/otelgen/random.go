@@ -0,0 +1,106 @@
+package otelgen
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runRandom is where everything a run generates draws its randomness from. Each run
+// has its own, so runs going on together in one process don't draw from or reseed
+// each other's; a run with a seed also leaves the clock out of the payloads it draws,
+// so it repeats them byte for byte
+type runRandom struct {
+	*rand.Rand
+	seeded bool
+}
+
+// newRandom returns the run's source, seeded with its seed or from the clock; a
+// seeded run draws the same values as every other run with its seed
+func (l LoadOptions) newRandom() *runRandom {
+	if l.Seed != nil {
+		return &runRandom{Rand: rand.New(newLockedSource(*l.Seed)), seeded: true}
+	}
+	return &runRandom{Rand: rand.New(newLockedSource(time.Now().UnixNano()))}
+}
+
+// newObservedRandom returns the source drawn from as metrics are collected and
+// exported, on the SDK's goroutines, so that what the generators draw from the run's
+// source doesn't depend on when that happens
+func (l LoadOptions) newObservedRandom() *rand.Rand {
+	if l.Seed != nil {
+		return rand.New(newLockedSource(*l.Seed + 1))
+	}
+	return rand.New(newLockedSource(time.Now().UnixNano()))
+}
+
+// newArrivalRandom returns the source the rate limiter draws the gaps between events
+// from, on its own goroutine, so a seeded run repeats its timing without the gaps
+// depending on what the generators have drawn
+func (l LoadOptions) newArrivalRandom() *rand.Rand {
+	if l.Seed != nil {
+		return rand.New(newLockedSource(*l.Seed + 2))
+	}
+	return rand.New(newLockedSource(time.Now().UnixNano()))
+}
+
+// now returns the time to draw into a payload, or zero for a seeded run, which leaves
+// it out
+func (r *runRandom) now() time.Time {
+	if r.seeded {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// lockedSource serializes a rand source, which is not safe for concurrent use; a
+// run's workers and the SDK's goroutines share it
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func newLockedSource(seed int64) *lockedSource {
+	return &lockedSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// randomIDs draws trace and span IDs from the run's source, so a seeded run repeats
+// them too
+type randomIDs struct {
+	rnd *runRandom
+}
+
+func (g randomIDs) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	binary.BigEndian.PutUint64(tid[:8], g.rnd.Uint64())
+	binary.BigEndian.PutUint64(tid[8:], g.rnd.Uint64())
+	return tid, g.NewSpanID(ctx, tid)
+}
+
+func (g randomIDs) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], g.rnd.Uint64())
+	return sid
+}
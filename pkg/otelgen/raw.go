@@ -0,0 +1,353 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// rawClient sends hand-built OTLP requests, for data the SDK refuses to produce or
+// rates it cannot sustain
+type rawClient struct {
+	headers map[string]string
+	gzip    bool
+	path    string
+	timeout time.Duration
+	obs     *exportObserver
+	shards  []rawShard
+	bal     balancer
+	// watchers are told of every export, besides its target's stats
+	watchers []func(items int, latency time.Duration, err error)
+	// dlq keeps the requests that failed, when set
+	dlq *deadLetterQueue
+}
+
+// rawShard is one connection to one target, over a gRPC channel or an HTTP client
+type rawShard struct {
+	target *target
+	conn   *grpc.ClientConn
+	client *http.Client
+}
+
+// newRawClient creates a raw OTLP client using the same transport settings and
+// targets as the SDK exporters; obs observes its responses, and must not expect
+// retries, since raw requests are never retried
+func newRawClient(targets []*target, headers map[string]string, transport TransportOptions, obs *exportObserver) (*rawClient, error) {
+	headers, dynamic, err := transport.newRequestHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	c := &rawClient{
+		headers: headers,
+		gzip:    transport.compressor() == "gzip",
+		path:    transport.HTTPPath,
+		timeout: transport.grpcTimeout(),
+		obs:     obs,
+	}
+
+	// Each connection gets its own channel or client, so requests spread across them
+	var owners []*target
+	for _, tg := range targets {
+		if err := tg.transport.validate(tg.endpoint); err != nil {
+			c.Close()
+			return nil, err
+		}
+		auth, err := tg.transport.newAuthorization()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		for i := 0; i < transport.connections(); i++ {
+			shard := rawShard{target: tg}
+			if tg.endpoint.IsGRPC() {
+				creds := insecure.NewCredentials()
+				if tg.endpoint.Secure {
+					creds = credentials.NewTLS(tg.transport.tlsConfig())
+				}
+				dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, tg.transport.dialOptions(tg.endpoint, auth, dynamic, c.obs)...)
+				shard.conn, err = grpc.NewClient(tg.endpoint.Address(), dialOpts...)
+				if err != nil {
+					c.Close()
+					return nil, fmt.Errorf("failed to create raw gRPC client: %w", err)
+				}
+			} else {
+				shard.client = tg.transport.httpClient(tg.endpoint, auth, dynamic, c.obs)
+			}
+			c.shards = append(c.shards, shard)
+			owners = append(owners, tg)
+		}
+	}
+	c.bal = transport.newBalancer(owners)
+
+	return c, nil
+}
+
+// ExportMetrics sends a metrics export request
+func (c *rawClient) ExportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	points := 0
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			points += countDataPoints(sm.Metrics)
+		}
+	}
+	return c.export(ctx, "/v1/metrics", req, points, func(ctx context.Context, conn *grpc.ClientConn) error {
+		_, err := colmetricspb.NewMetricsServiceClient(conn).Export(ctx, req)
+		return err
+	})
+}
+
+// ExportTraces sends a trace export request
+func (c *rawClient) ExportTraces(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	spans := 0
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			spans += len(ss.Spans)
+		}
+	}
+	return c.export(ctx, "/v1/traces", req, spans, func(ctx context.Context, conn *grpc.ClientConn) error {
+		_, err := coltracepb.NewTraceServiceClient(conn).Export(ctx, req)
+		return err
+	})
+}
+
+// ExportLogs sends a logs export request
+func (c *rawClient) ExportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	records := 0
+	for _, rl := range req.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			records += len(sl.LogRecords)
+		}
+	}
+	return c.export(ctx, "/v1/logs", req, records, func(ctx context.Context, conn *grpc.ClientConn) error {
+		_, err := collogspb.NewLogsServiceClient(conn).Export(ctx, req)
+		return err
+	})
+}
+
+// count records every export in stats too, like the counting exporters; it does
+// nothing without a client, so the SDK and raw paths can share the call
+func (c *rawClient) count(stats *targetStats) {
+	if c == nil {
+		return
+	}
+	c.watchers = append(c.watchers, stats.record)
+}
+
+// deadLetters keeps every request that fails in q; it does nothing without a client
+func (c *rawClient) deadLetters(q *deadLetterQueue) {
+	if c != nil {
+		c.dlq = q
+	}
+}
+
+// time records the latency of every export in stats, like the timed exporters; it
+// does nothing without a client
+func (c *rawClient) time(stats *probeStats) {
+	if c == nil {
+		return
+	}
+	c.watchers = append(c.watchers, stats.record)
+}
+
+// export sends the request of items to the next shard, over gRPC with call or over
+// HTTP to path, and records the outcome
+func (c *rawClient) export(ctx context.Context, path string, req proto.Message, items int, call func(context.Context, *grpc.ClientConn) error) error {
+	shard := c.shards[c.bal.pick(len(c.shards))]
+	start := time.Now()
+	var err error
+	if shard.conn != nil {
+		if len(c.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.headers))
+		}
+		callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		err = call(callCtx, shard.conn)
+		cancel()
+	} else {
+		if c.path != "" {
+			path = c.path
+		}
+		err = c.post(ctx, shard, path, req)
+	}
+	shard.target.stats.record(items, time.Since(start), err)
+	if err != nil && c.dlq != nil {
+		c.dlq.add(req, items)
+	}
+	for _, watch := range c.watchers {
+		watch(items, time.Since(start), err)
+	}
+	return err
+}
+
+// requestBuffers holds marshaled request bodies for reuse once they have been sent
+var requestBuffers = sync.Pool{New: func() any { return new([]byte) }}
+
+// pooledBody returns its buffer to requestBuffers once the transport closes it
+type pooledBody struct {
+	*bytes.Reader
+	buf  *[]byte
+	once sync.Once
+}
+
+func (b *pooledBody) Close() error {
+	b.once.Do(func() { requestBuffers.Put(b.buf) })
+	return nil
+}
+
+func (c *rawClient) post(ctx context.Context, shard rawShard, path string, msg proto.Message) error {
+	buf := requestBuffers.Get().(*[]byte)
+	body, err := proto.MarshalOptions{}.MarshalAppend((*buf)[:0], msg)
+	if err != nil {
+		requestBuffers.Put(buf)
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	*buf = body
+	if c.gzip {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		_, err := zw.Write(body)
+		if err == nil {
+			err = zw.Close()
+		}
+		requestBuffers.Put(buf)
+		if err != nil {
+			return fmt.Errorf("failed to compress request: %w", err)
+		}
+		body = zbuf.Bytes()
+	}
+	// An uncompressed body is the pooled buffer itself, so it is only reused once the
+	// transport is done with it
+	var reader io.ReadCloser = io.NopCloser(bytes.NewReader(body))
+	if !c.gzip {
+		reader = &pooledBody{Reader: bytes.NewReader(body), buf: buf}
+	}
+
+	scheme := "http"
+	if shard.target.endpoint.Secure {
+		scheme = "https"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s://%s%s", scheme, shard.target.endpoint.Address(), path), reader)
+	if err != nil {
+		reader.Close()
+		return err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if c.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := shard.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &httpStatusError{code: resp.StatusCode, status: resp.Status, body: string(bytes.TrimSpace(msg))}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// httpStatusError is an HTTP export that the endpoint answered with a failure status
+type httpStatusError struct {
+	code   int
+	status string
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("export failed with status %s", e.status)
+	}
+	return fmt.Sprintf("export failed with status %s: %s", e.status, e.body)
+}
+
+// Close releases the client's connections
+func (c *rawClient) Close() error {
+	var errs []error
+	for _, shard := range c.shards {
+		if shard.conn != nil {
+			errs = append(errs, shard.conn.Close())
+		} else {
+			shard.client.CloseIdleConnections()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rawMetricSource produces metrics that bypass the SDK. Metrics is called once per
+// generation tick and may return nothing.
+type rawMetricSource interface {
+	Metrics(now time.Time) []*metricspb.Metric
+}
+
+// rawMetricsRequest wraps metrics in a request carrying the generator's resource
+func rawMetricsRequest(res *resource.Resource, scope string, metrics []*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: attributesToProto(res.Attributes())},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: scope},
+				Metrics: metrics,
+			}},
+			SchemaUrl: res.SchemaURL(),
+		}},
+	}
+}
+
+// attributesToProto converts SDK attributes to their OTLP representation
+func attributesToProto(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: attributeValueToProto(kv.Value)})
+	}
+	return out
+}
+
+func attributeValueToProto(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+	}
+}
+
+// stringAttr builds a string-valued OTLP attribute
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// unixNano converts a time to an OTLP timestamp
+func unixNano(t time.Time) uint64 {
+	return uint64(t.UnixNano())
+}
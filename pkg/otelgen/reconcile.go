@@ -0,0 +1,161 @@
+package otelgen
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const (
+	// maxSequence bounds the sequence numbers a sink tracks, so a bogus one can't
+	// exhaust memory; it takes a bitmap of 256 MB
+	maxSequence = 1 << 31
+	// reportedGaps is how many gaps per stream the summary lists
+	reportedGaps = 5
+)
+
+// sinkStream is the numbered items a sink received of one signal from one resource
+type sinkStream struct {
+	resource   string
+	received   int64
+	duplicates int64
+	// outOfRange counts the sequence numbers that were negative or beyond maxSequence
+	outOfRange int64
+	// seen has a bit set for every sequence number received, and highest is the
+	// highest of them, or -1
+	seen    []uint64
+	highest int64
+	// reordering is the furthest an item arrived behind the highest one before it
+	reordering int64
+}
+
+func newSinkStream(resource string) *sinkStream {
+	return &sinkStream{resource: resource, highest: -1}
+}
+
+// add records the arrival of an item's sequence number
+func (s *sinkStream) add(seq int64) {
+	s.received++
+	if seq < 0 || seq >= maxSequence {
+		s.outOfRange++
+		return
+	}
+	word, bit := int(seq/64), uint64(1)<<(seq%64)
+	if word >= len(s.seen) {
+		s.seen = append(s.seen, make([]uint64, word+1-len(s.seen))...)
+	}
+	if s.seen[word]&bit != 0 {
+		s.duplicates++
+		return
+	}
+	s.seen[word] |= bit
+	if seq < s.highest {
+		s.reordering = max(s.reordering, s.highest-seq)
+	} else {
+		s.highest = seq
+	}
+}
+
+// sequenceGap is a run of sequence numbers never received
+type sequenceGap struct {
+	from, to int64
+}
+
+// gaps returns the items missing below the highest sequence number, and the first
+// of the gaps they fall in along with how many there are
+func (s *sinkStream) gaps() (int64, []sequenceGap, int) {
+	var missing int64
+	var gaps []sequenceGap
+	count := 0
+	for seq := int64(0); seq <= s.highest; seq++ {
+		if s.seen[seq/64]&(uint64(1)<<(seq%64)) != 0 {
+			continue
+		}
+		missing++
+		if seq > 0 && s.seen[(seq-1)/64]&(uint64(1)<<((seq-1)%64)) == 0 {
+			if count <= reportedGaps {
+				gaps[len(gaps)-1].to = seq
+			}
+			continue
+		}
+		count++
+		if count <= reportedGaps {
+			gaps = append(gaps, sequenceGap{from: seq, to: seq})
+		}
+	}
+	return missing, gaps, count
+}
+
+// describe summarizes what was sent and received of the stream
+func (s *sinkStream) describe() string {
+	sent := s.highest + 1
+	missing, gaps, count := s.gaps()
+	text := fmt.Sprintf("%d sent, %d received, %d missing", sent, s.received, missing)
+	if count > 0 {
+		ranges := make([]string, 0, len(gaps))
+		for _, g := range gaps {
+			if g.from == g.to {
+				ranges = append(ranges, fmt.Sprint(g.from))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", g.from, g.to))
+			}
+		}
+		if count > len(gaps) {
+			ranges = append(ranges, "...")
+		}
+		text += fmt.Sprintf(" in %d gaps (%s)", count, strings.Join(ranges, ", "))
+	}
+	text += fmt.Sprintf(", %d duplicates, max reordering distance %d", s.duplicates, s.reordering)
+	if s.outOfRange > 0 {
+		text += fmt.Sprintf(", %d sequence numbers out of range", s.outOfRange)
+	}
+	return text
+}
+
+// resourceKey renders a resource's attributes, sorted by key, to tell resources apart
+func resourceKey(res *resourcepb.Resource) string {
+	attrs := make([]string, 0, len(res.GetAttributes()))
+	for _, kv := range res.GetAttributes() {
+		attrs = append(attrs, kv.Key+"="+anyValueString(kv.Value))
+	}
+	slices.Sort(attrs)
+	return strings.Join(attrs, ", ")
+}
+
+// sequenceOf returns the sequence number among attrs, if there is one
+func sequenceOf(attrs []*commonpb.KeyValue) (int64, bool) {
+	for _, kv := range attrs {
+		if kv.Key == SequenceAttribute {
+			if v, ok := kv.Value.GetValue().(*commonpb.AnyValue_IntValue); ok {
+				return v.IntValue, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// printReconciliation prints the items of a signal sent and received, with their gaps,
+// duplicates, and reordering, in total and per resource
+func printReconciliation(signal string, streams []*sinkStream) {
+	if len(streams) == 0 {
+		return
+	}
+	slices.SortFunc(streams, func(a, b *sinkStream) int { return strings.Compare(a.resource, b.resource) })
+	var sent, received, missing, duplicates, reordering int64
+	for _, s := range streams {
+		m, _, _ := s.gaps()
+		sent += s.highest + 1
+		received += s.received
+		missing += m
+		duplicates += s.duplicates
+		reordering = max(reordering, s.reordering)
+	}
+	fmt.Printf("Delivery of %s from %d resources: %d sent, %d received, %d missing, %d duplicates, max reordering distance %d\n",
+		signalItems[signal], len(streams), sent, received, missing, duplicates, reordering)
+	for _, s := range streams {
+		fmt.Printf("  %s: %s\n", s.resource, s.describe())
+	}
+}
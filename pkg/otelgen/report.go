@@ -0,0 +1,319 @@
+package otelgen
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReportOptions configures the report written when a run ends and the thresholds the
+// run must meet, for failing a CI build when the endpoint underperforms
+type ReportOptions struct {
+	// File, when set, receives the report: JUnit XML for a .xml file, or JSON for a
+	// .json file
+	File string
+	// MinRate, when positive, is the lowest rate the run may achieve, in events per
+	// second
+	MinRate float64
+	// MaxErrorRate, when set, is the highest fraction of exports that may fail
+	MaxErrorRate *float64
+	// MaxP99Latency, when positive, is the highest p99 export latency the run may see
+	MaxP99Latency time.Duration
+}
+
+// reportFormat returns the format of the report file, from its extension
+func reportFormat(file string) (string, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xml":
+		return "junit", nil
+	case ".json":
+		return "json", nil
+	}
+	return "", fmt.Errorf("unknown report format for %s (expected a .xml or .json file)", file)
+}
+
+// runReport measures the run for its report and checks it against the thresholds
+type runReport struct {
+	opts   ReportOptions
+	signal string
+	events string
+	// stats times the exports; exports, when set, counts them instead, for signals
+	// that count their own and can't time them
+	stats   probeStats
+	exports func() (int64, int64)
+
+	start        time.Time
+	sent, failed int64
+}
+
+// newRunReport returns the report of the run, or nil when it has no report or
+// thresholds; events names what the rate counts
+func (l LoadOptions) newRunReport(signal, events string) (*runReport, error) {
+	if l.Report == nil {
+		return nil, nil
+	}
+	if l.Report.File != "" {
+		if _, err := reportFormat(l.Report.File); err != nil {
+			return nil, err
+		}
+	}
+	if l.Report.MinRate < 0 {
+		return nil, fmt.Errorf("minimum rate must not be negative")
+	}
+	if l.Report.MaxErrorRate != nil && (*l.Report.MaxErrorRate < 0 || *l.Report.MaxErrorRate > 1) {
+		return nil, fmt.Errorf("max error rate must be between 0%% and 100%%")
+	}
+	if l.Report.MaxP99Latency < 0 {
+		return nil, fmt.Errorf("max p99 export latency must not be negative")
+	}
+	return &runReport{opts: *l.Report, signal: signal, events: events}, nil
+}
+
+// begin starts measuring as generation starts
+func (r *runReport) begin() {
+	if r == nil {
+		return
+	}
+	r.start = time.Now()
+}
+
+// endWarmup discards what was measured during the warmup
+func (r *runReport) endWarmup() {
+	if r == nil {
+		return
+	}
+	r.start = time.Now()
+	r.stats.reset()
+	if r.exports != nil {
+		r.sent, r.failed = r.exports()
+	}
+}
+
+// watch also times the exports of a raw client; it does nothing without a report
+func (r *runReport) watch(raw *rawClient) {
+	if r == nil {
+		return
+	}
+	raw.time(&r.stats)
+}
+
+// reportAssertion is the outcome of checking the run against one threshold
+type reportAssertion struct {
+	Name      string  `json:"name"`
+	Threshold float64 `json:"threshold"`
+	Actual    float64 `json:"actual"`
+	Passed    bool    `json:"passed"`
+	Message   string  `json:"message"`
+}
+
+// reportResult is the report of a run; rates are per second, error rates fractions,
+// and latencies seconds
+type reportResult struct {
+	Signal        string            `json:"signal"`
+	Start         time.Time         `json:"start"`
+	Duration      float64           `json:"duration_seconds"`
+	Events        int64             `json:"events"`
+	Rate          float64           `json:"rate"`
+	Exports       int64             `json:"exports"`
+	FailedExports int64             `json:"failed_exports"`
+	ErrorRate     float64           `json:"error_rate"`
+	LatencyP99    *float64          `json:"export_latency_p99_seconds,omitempty"`
+	Passed        bool              `json:"passed"`
+	Assertions    []reportAssertion `json:"assertions"`
+}
+
+// finish checks the run that generated events against the thresholds, once flush
+// has sent the exports still in flight, and writes the report; it returns an error
+// when a threshold was not met
+func (r *runReport) finish(events int, flush func(context.Context) error, flushTimeout time.Duration) error {
+	if r == nil {
+		return nil
+	}
+	elapsed := time.Since(r.start)
+	if flush != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		flush(ctx)
+		cancel()
+	}
+
+	result := &reportResult{
+		Signal:     r.signal,
+		Start:      r.start.UTC(),
+		Duration:   elapsed.Seconds(),
+		Events:     int64(events),
+		Rate:       float64(events) / elapsed.Seconds(),
+		Passed:     true,
+		Assertions: []reportAssertion{},
+	}
+	var latencies []time.Duration
+	if r.exports != nil {
+		sent, failed := r.exports()
+		result.Exports, result.FailedExports = sent-r.sent, failed-r.failed
+	} else {
+		var exports, failed int
+		exports, failed, latencies = r.stats.take()
+		result.Exports, result.FailedExports = int64(exports), int64(failed)
+	}
+	if result.Exports > 0 {
+		result.ErrorRate = float64(result.FailedExports) / float64(result.Exports)
+	}
+	var p99 time.Duration
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		p99 = percentile(latencies, 0.99)
+		seconds := p99.Seconds()
+		result.LatencyP99 = &seconds
+	}
+
+	if r.opts.MinRate > 0 {
+		result.check(reportAssertion{
+			Name:      "min rate",
+			Threshold: r.opts.MinRate,
+			Actual:    result.Rate,
+			Passed:    result.Rate >= r.opts.MinRate,
+			Message:   fmt.Sprintf("achieved %.1f %s/s, expected at least %g", result.Rate, r.events, r.opts.MinRate),
+		})
+	}
+	if r.opts.MaxErrorRate != nil {
+		result.check(reportAssertion{
+			Name:      "max error rate",
+			Threshold: *r.opts.MaxErrorRate,
+			Actual:    result.ErrorRate,
+			Passed:    result.ErrorRate <= *r.opts.MaxErrorRate,
+			Message: fmt.Sprintf("%d of %d exports failed (%.2f%%), expected at most %g%%",
+				result.FailedExports, result.Exports, 100*result.ErrorRate, 100**r.opts.MaxErrorRate),
+		})
+	}
+	if r.opts.MaxP99Latency > 0 {
+		a := reportAssertion{
+			Name:      "max p99 export latency",
+			Threshold: r.opts.MaxP99Latency.Seconds(),
+			Actual:    p99.Seconds(),
+			Passed:    len(latencies) > 0 && p99 <= r.opts.MaxP99Latency,
+			Message:   fmt.Sprintf("p99 export latency %s, expected at most %s", p99.Round(10*time.Microsecond), r.opts.MaxP99Latency),
+		}
+		if len(latencies) == 0 {
+			a.Message = "no exports completed"
+		}
+		result.check(a)
+	}
+
+	failed := 0
+	for _, a := range result.Assertions {
+		outcome := "PASS"
+		if !a.Passed {
+			outcome = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s %s: %s\n", outcome, a.Name, a.Message)
+	}
+	if r.opts.File != "" {
+		if err := result.write(r.opts.File); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote report to %s\n", r.opts.File)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d thresholds not met", failed, len(result.Assertions))
+	}
+	return nil
+}
+
+// check adds the outcome of an assertion to the report
+func (res *reportResult) check(a reportAssertion) {
+	res.Assertions = append(res.Assertions, a)
+	res.Passed = res.Passed && a.Passed
+}
+
+// write writes the report to file, in the format its extension names
+func (res *reportResult) write(file string) error {
+	format, err := reportFormat(file)
+	if err != nil {
+		return err
+	}
+	var out []byte
+	if format == "junit" {
+		out, err = xml.MarshalIndent(res.junit(), "", "  ")
+		out = append([]byte(xml.Header), out...)
+	} else {
+		out, err = json.MarshalIndent(res, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(file, append(out, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// junitSuites is a JUnit XML report of one suite, with a test case per assertion
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Output    string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// junit returns the report as a JUnit XML suite, with the run's measurements as
+// properties
+func (res *reportResult) junit() junitSuites {
+	suite := junitSuite{
+		Name:      "otelgen " + res.Signal,
+		Tests:     len(res.Assertions),
+		Time:      fmt.Sprintf("%.3f", res.Duration),
+		Timestamp: res.Start.Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "events", Value: fmt.Sprint(res.Events)},
+			{Name: "rate", Value: fmt.Sprintf("%.3f", res.Rate)},
+			{Name: "exports", Value: fmt.Sprint(res.Exports)},
+			{Name: "failed_exports", Value: fmt.Sprint(res.FailedExports)},
+			{Name: "error_rate", Value: fmt.Sprintf("%.6f", res.ErrorRate)},
+		},
+	}
+	if res.LatencyP99 != nil {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "export_latency_p99_seconds", Value: fmt.Sprintf("%.6f", *res.LatencyP99)})
+	}
+	for _, a := range res.Assertions {
+		c := junitCase{Name: a.Name, Classname: "otelgen." + res.Signal}
+		if a.Passed {
+			c.Output = a.Message
+		} else {
+			c.Failure = &junitFailure{Message: a.Message, Type: "threshold"}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	return junitSuites{Suites: []junitSuite{suite}}
+}
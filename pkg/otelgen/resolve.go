@@ -0,0 +1,79 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// ParseResolve parses curl-style host:port:address[,address...] entries into the
+// addresses to dial for each host:port; IPv6 addresses may be written in brackets
+func ParseResolve(entries []string) (map[string][]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	resolve := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		host, rest, ok := strings.Cut(entry, ":")
+		port, addrs, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 || host == "" || port == "" {
+			return nil, fmt.Errorf("invalid resolve entry %q (expected host:port:address)", entry)
+		}
+		key := net.JoinHostPort(strings.ToLower(host), port)
+		for _, addr := range strings.Split(addrs, ",") {
+			ip, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid resolve entry %q: address must be an IP: %w", entry, err)
+			}
+			resolve[key] = append(resolve[key], net.JoinHostPort(ip.String(), port))
+		}
+	}
+	return resolve, nil
+}
+
+// resolvedAddress returns the address to dial for host:port, which Resolve may pin
+// to a specific IP; a target with several addresses has its Resolve narrowed to one
+func (t TransportOptions) resolvedAddress(address string) string {
+	if pinned := t.Resolve[resolveKey(address)]; len(pinned) > 0 {
+		return pinned[0]
+	}
+	return address
+}
+
+// dialContext dials pinned addresses in place of the hosts they override, through
+// the Dialer when there is one; TLS is layered on by the caller, so the server name
+// stays the original host
+func (t TransportOptions) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if t.Dialer != nil {
+		return func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return t.Dialer(ctx, t.resolvedAddress(addr))
+		}
+	}
+	if len(t.Resolve) == 0 {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, t.resolvedAddress(addr))
+	}
+}
+
+// resolverOption pins a gRPC channel to the endpoint's resolved address. Channels
+// resolve their target through DNS before dialing, so a dialer never sees the host;
+// replacing the dns resolver for the channel keeps the target, and with it the
+// authority and TLS server name, unchanged. With a Dialer, the host may not exist in
+// DNS at all, so the channel is pinned to the endpoint's own address for the Dialer
+func (t TransportOptions) resolverOption(endpoint *Endpoint) (grpc.DialOption, bool) {
+	pinned := t.resolvedAddress(endpoint.Address())
+	if pinned == endpoint.Address() && t.Dialer == nil {
+		return nil, false
+	}
+	r := manual.NewBuilderWithScheme("dns")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: pinned}}})
+	return grpc.WithResolvers(r), true
+}
@@ -0,0 +1,75 @@
+package otelgen
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Result is the outcome of a generator's run, for library users and the CLI to render
+// or assert on; like the printed summary, it leaves out the warmup
+type Result struct {
+	// Signal is traces, metrics, or logs
+	Signal string `json:"signal"`
+	// Events is the number of traces, metric events, or log records generated
+	Events int64 `json:"events"`
+	// Exports is the number of export requests written, retries included
+	Exports int64 `json:"exports"`
+	// FailedExports is the number of export attempts that failed
+	FailedExports int64 `json:"failed_exports"`
+	// RejectedItems is the number of items the endpoint rejected in partial successes
+	RejectedItems int64 `json:"rejected_items"`
+	// Bytes is the uncompressed size of the export requests, and WireBytes their size
+	// as written, after compression and framing
+	Bytes     int64 `json:"bytes"`
+	WireBytes int64 `json:"wire_bytes"`
+	// Elapsed is how long the run generated for
+	Elapsed time.Duration `json:"-"`
+	// Rate is the achieved rate, in events per second
+	Rate float64 `json:"rate"`
+
+	start time.Time
+}
+
+// MarshalJSON encodes the result with its elapsed time in seconds
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Elapsed float64 `json:"elapsed_seconds"`
+	}{result(r), r.Elapsed.Seconds()})
+}
+
+// begin starts timing the run as generation starts, or again as the warmup ends
+func (r *Result) begin() {
+	if r == nil {
+		return
+	}
+	r.start = time.Now()
+}
+
+// generated records the events generated as the run ends
+func (r *Result) generated(events int) {
+	if r == nil || r.start.IsZero() {
+		return
+	}
+	r.Events = int64(events)
+	r.Elapsed = time.Since(r.start)
+	if r.Elapsed > 0 {
+		r.Rate = float64(events) / r.Elapsed.Seconds()
+	}
+}
+
+// observe adds the exports an observer saw; it is deferred as the observer is created,
+// so it runs once the final exports have been flushed
+func (r *Result) observe(obs *exportObserver) {
+	if r == nil {
+		return
+	}
+	obs.mu.Lock()
+	r.Exports += obs.requests.Load() - obs.warmup.requests
+	r.Bytes += obs.bytes.Load() - obs.warmup.bytes
+	r.WireBytes += obs.wireBytes.Load() - obs.warmup.wireBytes
+	r.RejectedItems += obs.rejected
+	obs.mu.Unlock()
+	r.FailedExports += int64(obs.failures.total())
+}
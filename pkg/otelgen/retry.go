@@ -0,0 +1,37 @@
+package otelgen
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryOptions is the exporters' retry policy; its fields match the OTLP exporters'
+// RetryConfig so it converts directly
+type RetryOptions struct {
+	// Enabled retries failed exports with exponential backoff
+	Enabled bool
+	// InitialInterval is the wait after the first failure
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between retries
+	MaxInterval time.Duration
+	// MaxElapsedTime is the total time spent on one export, including retries, before it is dropped
+	MaxElapsedTime time.Duration
+}
+
+// retryEnabled reports whether the exporters retry failed exports
+func (t TransportOptions) retryEnabled() bool {
+	return t.Retry == nil || t.Retry.Enabled
+}
+
+// describeRetry prints the retry policy in verbose mode
+func (t TransportOptions) describeRetry() {
+	switch {
+	case t.Retry == nil:
+		fmt.Println("[VERBOSE] Using the exporter's default retry policy")
+	case !t.Retry.Enabled:
+		fmt.Println("[VERBOSE] Retries disabled")
+	default:
+		fmt.Printf("[VERBOSE] Retrying failed exports: initial interval %s, max interval %s, max elapsed %s\n",
+			t.Retry.InitialInterval, t.Retry.MaxInterval, t.Retry.MaxElapsedTime)
+	}
+}
@@ -0,0 +1,87 @@
+package otelgen
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is the layout of a --config document: generation jobs that run together,
+// so a multi-signal, multi-endpoint load test can be kept in version control
+type Scenario struct {
+	Jobs []ScenarioJob `yaml:"jobs"`
+}
+
+// ScenarioJob is one generator of a scenario; the fields left out take the values of
+// the command line
+type ScenarioJob struct {
+	// Name identifies the job in its output (default <signal>-<n>)
+	Name string `yaml:"name"`
+	// Signal is traces, metrics, or logs
+	Signal string `yaml:"signal"`
+	// Endpoint is the OTLP endpoint; several comma-separated endpoints are load-balanced
+	Endpoint string            `yaml:"endpoint"`
+	Service  string            `yaml:"service"`
+	Rate     float64           `yaml:"rate"`
+	Duration time.Duration     `yaml:"duration"`
+	Size     string            `yaml:"size"`
+	Headers  map[string]string `yaml:"headers"`
+	// Attributes are added to the job's resource
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// LoadScenario reads and validates a scenario file, in YAML or JSON
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	// JSON is YAML too, so one decoder reads both
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	if len(scenario.Jobs) == 0 {
+		return nil, fmt.Errorf("scenario has no jobs")
+	}
+
+	names := make(map[string]bool)
+	for i := range scenario.Jobs {
+		job := &scenario.Jobs[i]
+		switch job.Signal {
+		case "traces", "metrics", "logs":
+		case "":
+			return nil, fmt.Errorf("job %d: signal is required", i+1)
+		default:
+			return nil, fmt.Errorf("job %d: unknown signal %q (supported: traces, metrics, logs)", i+1, job.Signal)
+		}
+		if job.Name == "" {
+			job.Name = fmt.Sprintf("%s-%d", job.Signal, i+1)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("job %d: name %q is used by another job", i+1, job.Name)
+		}
+		names[job.Name] = true
+		if job.Endpoint != "" {
+			for _, s := range strings.Split(job.Endpoint, ",") {
+				if _, err := ParseEndpoint(strings.TrimSpace(s)); err != nil {
+					return nil, fmt.Errorf("job %s: invalid endpoint: %w", job.Name, err)
+				}
+			}
+		}
+		if job.Rate < 0 {
+			return nil, fmt.Errorf("job %s: rate cannot be negative", job.Name)
+		}
+		if job.Duration < 0 {
+			return nil, fmt.Errorf("job %s: duration cannot be negative", job.Name)
+		}
+		if _, err := ParseSize(job.Size); err != nil {
+			return nil, fmt.Errorf("job %s: invalid size: %w", job.Name, err)
+		}
+	}
+	return &scenario, nil
+}
@@ -0,0 +1,163 @@
+package otelgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// metricSchedule is a custom export interval and phase offset
+type metricSchedule struct {
+	interval time.Duration
+	offset   time.Duration
+}
+
+// schedule returns the metric's export schedule, or false if it uses the shared one
+func (cm *customMetric) schedule() (metricSchedule, bool) {
+	if cm.def.Interval == 0 && cm.def.Offset == 0 {
+		return metricSchedule{}, false
+	}
+	s := metricSchedule{interval: cm.def.Interval, offset: cm.def.Offset}
+	if s.interval == 0 {
+		s.interval = metricsExportInterval
+	}
+	return s, true
+}
+
+// scheduleGroups splits custom metrics into those on the shared schedule and groups
+// sharing each custom schedule, in definition order
+func scheduleGroups(metrics []*customMetric) (shared []*customMetric, schedules []metricSchedule, groups map[metricSchedule][]*customMetric) {
+	groups = make(map[metricSchedule][]*customMetric)
+	for _, cm := range metrics {
+		s, ok := cm.schedule()
+		if !ok {
+			shared = append(shared, cm)
+			continue
+		}
+		if _, seen := groups[s]; !seen {
+			schedules = append(schedules, s)
+		}
+		groups[s] = append(groups[s], cm)
+	}
+	return shared, schedules, groups
+}
+
+// scheduledProvider exports its metrics on its own interval, starting after a phase
+// offset, so its exports do not line up with the other metrics
+type scheduledProvider struct {
+	mp       *sdkmetric.MeterProvider
+	reader   *sdkmetric.ManualReader
+	exporter sdkmetric.Exporter
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newScheduledProvider(exporter sdkmetric.Exporter, res *resource.Resource, views []sdkmetric.View, timeout time.Duration) *scheduledProvider {
+	reader := sdkmetric.NewManualReader(
+		sdkmetric.WithTemporalitySelector(exporter.Temporality),
+		sdkmetric.WithAggregationSelector(exporter.Aggregation),
+	)
+	return &scheduledProvider{
+		mp: sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(reader),
+			sdkmetric.WithResource(res),
+			sdkmetric.WithView(views...),
+		),
+		reader:   reader,
+		exporter: exporter,
+		timeout:  timeout,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start begins exporting at offset and every interval after that
+func (p *scheduledProvider) start(s metricSchedule) {
+	go func() {
+		defer close(p.done)
+
+		offset := time.NewTimer(s.offset)
+		defer offset.Stop()
+		select {
+		case <-p.stop:
+			return
+		case <-offset.C:
+		}
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			if err := p.export(ctx); err != nil {
+				fmt.Printf("Error exporting metrics: %v\n", err)
+			}
+			cancel()
+
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *scheduledProvider) export(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rm := &metricdata.ResourceMetrics{}
+	if err := p.reader.Collect(ctx, rm); err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	return p.exporter.Export(ctx, rm)
+}
+
+// ForceFlush exports the current values immediately
+func (p *scheduledProvider) ForceFlush(ctx context.Context) error {
+	return p.export(ctx)
+}
+
+// Shutdown stops the schedule, exports the final values, and shuts down the provider
+func (p *scheduledProvider) Shutdown(ctx context.Context) error {
+	close(p.stop)
+	<-p.done
+	return errors.Join(p.export(ctx), p.mp.Shutdown(ctx))
+}
+
+// pipelineGroup drives several meter pipelines as one
+type pipelineGroup []meterPipeline
+
+func (g pipelineGroup) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, p := range g {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (g pipelineGroup) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, p := range g {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// recorderGroup records on several metric sets
+type recorderGroup []metricRecorder
+
+func (g recorderGroup) Record(ctx context.Context) {
+	for _, r := range g {
+		r.Record(ctx)
+	}
+}
@@ -0,0 +1,206 @@
+package otelgen
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// selfTelemetryInterval is the time between exports of otelgen's own metrics
+const selfTelemetryInterval = 10 * time.Second
+
+// selfTelemetry exports otelgen's own metrics, and optionally a span for every export
+// it makes, to a separate OTLP endpoint, to watch the generator alongside the system
+// under test
+type selfTelemetry struct {
+	signal string
+	// compression is how the run's exports are compressed, for their spans
+	compression string
+	mp          *sdkmetric.MeterProvider
+	// tp and tracer are set when exports are traced
+	tp     *sdktrace.TracerProvider
+	tracer trace.Tracer
+
+	exports   metric.Int64Counter
+	items     metric.Int64Counter
+	duration  metric.Float64Histogram
+	batchSize metric.Int64Histogram
+}
+
+// newSelfTelemetry returns the self-telemetry of the run, or nil when it has none;
+// serviceName is the service the run generates telemetry for, and transport how the
+// run exports it
+func (l LoadOptions) newSelfTelemetry(signal, serviceName string, transport TransportOptions, verbose bool) (*selfTelemetry, error) {
+	if l.SelfTelemetry == nil {
+		return nil, nil
+	}
+	ctx := context.Background()
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName("otelgen"),
+			attribute.String("otelgen.signal", signal),
+			attribute.String("otelgen.generated_service", serviceName),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create self-telemetry resource: %w", err)
+	}
+
+	// The self-telemetry endpoint is reached without the run's transport settings,
+	// which belong to the endpoint under test
+	var own TransportOptions
+	metrics, err := newMetricExporter(ctx, l.SelfTelemetry, nil, own, newExportObserver("metrics", false), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create self-telemetry metrics exporter: %w", err)
+	}
+
+	t := &selfTelemetry{
+		signal:      signal,
+		compression: cmp.Or(transport.compressor(), "none"),
+		mp: sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metrics, sdkmetric.WithInterval(selfTelemetryInterval))),
+			sdkmetric.WithResource(res),
+		),
+	}
+	if l.TraceSelf {
+		spans, err := newTraceExporter(ctx, l.SelfTelemetry, nil, own, newExportObserver("traces", false), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create self-telemetry trace exporter: %w", err)
+		}
+		t.tp = sdktrace.NewTracerProvider(sdktrace.WithBatcher(spans), sdktrace.WithResource(res))
+		t.tracer = t.tp.Tracer("otelgen")
+	}
+	meter := t.mp.Meter("otelgen")
+	if t.exports, err = meter.Int64Counter("otelgen.exports",
+		metric.WithDescription("Export requests made, by outcome"), metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+	if t.items, err = meter.Int64Counter("otelgen.exported_items",
+		metric.WithDescription("Items in the export requests made, by outcome"), metric.WithUnit("{item}")); err != nil {
+		return nil, err
+	}
+	if t.duration, err = meter.Float64Histogram("otelgen.export.duration",
+		metric.WithDescription("Time taken by each export request, including its retries"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if t.batchSize, err = meter.Int64Histogram("otelgen.export.batch_size",
+		metric.WithDescription("Items in each export request"), metric.WithUnit("{item}")); err != nil {
+		return nil, err
+	}
+	if verbose && t.tracer != nil {
+		fmt.Printf("[VERBOSE] Exporting otelgen's own metrics and export spans to %s\n", l.SelfTelemetry)
+	} else if verbose {
+		fmt.Printf("[VERBOSE] Exporting otelgen's own metrics to %s\n", l.SelfTelemetry)
+	}
+	return t, nil
+}
+
+// record records an export of items that started at start and ended now
+func (t *selfTelemetry) record(items int, start time.Time, err error) {
+	end := time.Now()
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	ctx := context.Background()
+	attrs := metric.WithAttributes(attribute.String("otelgen.signal", t.signal), attribute.String("outcome", outcome))
+	t.exports.Add(ctx, 1, attrs)
+	t.items.Add(ctx, int64(items), attrs)
+	t.duration.Record(ctx, end.Sub(start).Seconds(), attrs)
+	t.batchSize.Record(ctx, int64(items), attrs)
+
+	if t.tracer == nil {
+		return
+	}
+	_, span := t.tracer.Start(ctx, "export "+t.signal, trace.WithTimestamp(start), trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("otelgen.signal", t.signal),
+			attribute.Int("otelgen.batch_size", items),
+			attribute.String("otelgen.compression", t.compression),
+			attribute.String("otelgen.result", outcome),
+		))
+	if err != nil {
+		span.SetAttributes(attribute.String("otelgen.failure", classifyError(err)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// watch records every export of a raw client; it does nothing without self-telemetry
+func (t *selfTelemetry) watch(raw *rawClient) {
+	if t == nil || raw == nil {
+		return
+	}
+	raw.watchers = append(raw.watchers, func(items int, latency time.Duration, err error) {
+		t.record(items, time.Now().Add(-latency), err)
+	})
+}
+
+// shutdown flushes the last of the self-telemetry
+func (t *selfTelemetry) shutdown() {
+	if t == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if t.tp != nil {
+		if err := t.tp.Shutdown(ctx); err != nil {
+			fmt.Printf("Warning: failed to flush self-telemetry spans: %v\n", err)
+		}
+	}
+	if err := t.mp.Shutdown(ctx); err != nil {
+		fmt.Printf("Warning: failed to flush self-telemetry metrics: %v\n", err)
+	}
+}
+
+// selfSpanExporter records each export in the self-telemetry
+type selfSpanExporter struct {
+	sdktrace.SpanExporter
+	self *selfTelemetry
+}
+
+func (e selfSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.self.record(len(spans), start, err)
+	return err
+}
+
+// selfLogExporter records each export in the self-telemetry
+type selfLogExporter struct {
+	sdklog.Exporter
+	self *selfTelemetry
+}
+
+func (e selfLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.self.record(len(records), start, err)
+	return err
+}
+
+// selfMetricExporter records each export in the self-telemetry
+type selfMetricExporter struct {
+	sdkmetric.Exporter
+	self *selfTelemetry
+}
+
+func (e selfMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.self.record(countSDKDataPoints(rm), start, err)
+	return err
+}
@@ -0,0 +1,98 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// activeSeriesMetricCount is how many metrics the active series are spread over
+const activeSeriesMetricCount = 10
+
+// seriesAllocator splits a fixed series count into consecutive ranges, one per host,
+// handing them out in rotation so a rebuilt fleet receives the same ranges again
+type seriesAllocator struct {
+	mu    sync.Mutex
+	total int
+	parts int
+	next  int
+}
+
+func newSeriesAllocator(total, parts int) *seriesAllocator {
+	if parts < 1 {
+		parts = 1
+	}
+	return &seriesAllocator{total: total, parts: parts}
+}
+
+// take returns the next range of series IDs
+func (a *seriesAllocator) take() (from, to int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := a.next
+	a.next = (a.next + 1) % a.parts
+	return a.total * i / a.parts, a.total * (i + 1) / a.parts
+}
+
+// activeSeriesMetrics continuously reports a fixed set of series through observable
+// instruments, so every export carries exactly the same series
+type activeSeriesMetrics struct{}
+
+func newActiveSeriesMetrics(meter metric.Meter, alloc *seriesAllocator, rnd *rand.Rand) (*activeSeriesMetrics, error) {
+	from, to := alloc.take()
+
+	// Pre-build the attribute options so callbacks do not allocate per series
+	perMetric := make([][]metric.ObserveOption, activeSeriesMetricCount)
+	for id := from; id < to; id++ {
+		k := id % activeSeriesMetricCount
+		perMetric[k] = append(perMetric[k], metric.WithAttributeSet(attribute.NewSet(
+			attribute.Int("series.id", id),
+		)))
+	}
+
+	for k, options := range perMetric {
+		if len(options) == 0 {
+			continue
+		}
+		name := fmt.Sprintf("otelgen.series.%02d", k)
+
+		var err error
+		if k%2 == 0 {
+			_, err = meter.Float64ObservableGauge(name,
+				metric.WithDescription("Gauge series held at a fixed active count"),
+				metric.WithFloat64Callback(func(ctx context.Context, observer metric.Float64Observer) error {
+					for _, opt := range options {
+						observer.Observe(rnd.Float64()*100, opt)
+					}
+					return nil
+				}),
+			)
+		} else {
+			// Counters report a running total that grows every collection
+			var collections int64
+			_, err = meter.Int64ObservableCounter(name,
+				metric.WithDescription("Counter series held at a fixed active count"),
+				metric.WithInt64Callback(func(ctx context.Context, observer metric.Int64Observer) error {
+					collections++
+					for _, opt := range options {
+						observer.Observe(collections, opt)
+					}
+					return nil
+				}),
+			)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+	}
+
+	return &activeSeriesMetrics{}, nil
+}
+
+// Record does nothing; the series are observed at each export
+func (m *activeSeriesMetrics) Record(ctx context.Context) {}
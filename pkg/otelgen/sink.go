@@ -0,0 +1,425 @@
+package otelgen
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // receives gzip-compressed exports
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// sinkLatencySamples bounds the latencies kept per signal for percentiles; beyond it,
+// a uniform sample of every latency seen is kept
+const sinkLatencySamples = 100000
+
+// sinkReceivedPath is where the HTTP receiver serves what the sink received so far,
+// for runs that confirm their delivery against it
+const sinkReceivedPath = "/received"
+
+// sinkCount is what the sink received of one signal, as served on sinkReceivedPath
+type sinkCount struct {
+	Requests int64 `json:"requests"`
+	Items    int64 `json:"items"`
+}
+
+// SinkOptions configures an OTLP receiver that counts what it receives
+type SinkOptions struct {
+	// GRPCListen and HTTPListen are the addresses of the gRPC and HTTP receivers; at
+	// least one is required
+	GRPCListen string
+	HTTPListen string
+	// Duration, when positive, ends the sink after this long; otherwise it runs until
+	// Stop is closed
+	Duration time.Duration
+	// Interval is the time between progress reports (default 10s)
+	Interval time.Duration
+	// Stop, when set, ends the sink once it is closed
+	Stop <-chan struct{}
+	// Capture, when set, is a file to write every request received to, as a line of
+	// OTLP/JSON along with the time it was received
+	Capture string
+}
+
+// sinkSignal is what the sink received of one signal
+type sinkSignal struct {
+	requests int64
+	items    int64
+	// stamped counts the items carrying an emit time, and early those whose emit time
+	// was after they were received, which means the clocks disagree
+	stamped   int64
+	early     int64
+	max       time.Duration
+	latencies []time.Duration
+	// streams tracks the numbered items of each resource
+	streams map[string]*sinkStream
+}
+
+// latency records one item's end-to-end latency
+func (s *sinkSignal) latency(d time.Duration) {
+	s.stamped++
+	if d < 0 {
+		s.early++
+		d = 0
+	}
+	s.max = max(s.max, d)
+	if len(s.latencies) < sinkLatencySamples {
+		s.latencies = append(s.latencies, d)
+	} else if i := rand.Int63n(s.stamped); i < sinkLatencySamples {
+		s.latencies[i] = d
+	}
+}
+
+// sink counts the items received per signal
+type sink struct {
+	mu      sync.Mutex
+	signals map[string]*sinkSignal
+	capture *sinkCapture
+}
+
+// sinkSequences is the sequence numbers received from one resource in a request
+type sinkSequences struct {
+	resource *resourcepb.Resource
+	seqs     []int64
+}
+
+// receive records a request of items received at now, along with the emit times and
+// sequence numbers found on them
+func (s *sink) receive(signal string, items int, stamps []int64, sequences []sinkSequences, now int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sig := s.signals[signal]
+	sig.requests++
+	sig.items += int64(items)
+	for _, stamp := range stamps {
+		sig.latency(time.Duration(now - stamp))
+	}
+	for _, rs := range sequences {
+		key := resourceKey(rs.resource)
+		stream := sig.streams[key]
+		if stream == nil {
+			stream = newSinkStream(key)
+			sig.streams[key] = stream
+		}
+		for _, seq := range rs.seqs {
+			stream.add(seq)
+		}
+	}
+}
+
+// RunSink receives OTLP exports over gRPC and HTTP, printing what it received at every
+// interval and, once it ends, the totals and the end-to-end latency of items stamped
+// with their emit time
+func RunSink(opts SinkOptions) error {
+	if opts.GRPCListen == "" && opts.HTTPListen == "" {
+		return fmt.Errorf("a gRPC or HTTP listen address is required")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	capture, err := newSinkCapture(opts.Capture)
+	if err != nil {
+		return err
+	}
+	s := &sink{signals: map[string]*sinkSignal{}, capture: capture}
+	for _, signal := range []string{"traces", "logs", "metrics"} {
+		s.signals[signal] = &sinkSignal{streams: map[string]*sinkStream{}}
+	}
+
+	errs := make(chan error, 2)
+	if opts.GRPCListen != "" {
+		ln, err := net.Listen("tcp", opts.GRPCListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+		server := grpc.NewServer(grpc.MaxRecvMsgSize(64 << 20))
+		coltracepb.RegisterTraceServiceServer(server, sinkTraces{sink: s})
+		collogspb.RegisterLogsServiceServer(server, sinkLogs{sink: s})
+		colmetricspb.RegisterMetricsServiceServer(server, sinkMetrics{sink: s})
+		go func() { errs <- server.Serve(ln) }()
+		defer server.Stop()
+		fmt.Printf("Receiving OTLP over gRPC on %s\n", ln.Addr())
+	}
+	if opts.HTTPListen != "" {
+		ln, err := net.Listen("tcp", opts.HTTPListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for HTTP: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/traces", s.handle("traces"))
+		mux.HandleFunc("/v1/logs", s.handle("logs"))
+		mux.HandleFunc("/v1/metrics", s.handle("metrics"))
+		mux.HandleFunc(sinkReceivedPath, s.serveReceived)
+		// Cleartext HTTP/2 (h2c) is served too, for --http-version 2 over http://
+		server := &http.Server{Handler: h2c.NewHandler(mux, &http2.Server{})}
+		go func() { errs <- server.Serve(ln) }()
+		defer server.Close()
+		fmt.Printf("Receiving OTLP over HTTP on %s\n", ln.Addr())
+	}
+
+	var timeout <-chan time.Time
+	if opts.Duration > 0 {
+		timer := time.NewTimer(opts.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	start := time.Now()
+	var reported int64
+	for {
+		select {
+		case err := <-errs:
+			s.capture.close()
+			return fmt.Errorf("receiver failed: %w", err)
+		case <-ticker.C:
+			reported = s.printProgress(reported, time.Since(start))
+		case <-timeout:
+			s.printSummary(time.Since(start))
+			return s.capture.close()
+		case <-opts.Stop:
+			s.printSummary(time.Since(start))
+			return s.capture.close()
+		}
+	}
+}
+
+// printProgress prints the totals so far when anything arrived since the last report,
+// and returns the items received
+func (s *sink) printProgress(reported int64, elapsed time.Duration) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := int64(0)
+	for _, sig := range s.signals {
+		total += sig.items
+	}
+	if total != reported {
+		fmt.Printf("[%s] Received %s\n", elapsed.Round(time.Second), s.describe())
+	}
+	return total
+}
+
+// describe lists the items and requests received of each signal
+func (s *sink) describe() string {
+	var parts []string
+	for _, signal := range []string{"traces", "logs", "metrics"} {
+		sig := s.signals[signal]
+		parts = append(parts, fmt.Sprintf("%d %s (%d requests)", sig.items, signalItems[signal], sig.requests))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printSummary prints the totals and each signal's end-to-end latency percentiles
+func (s *sink) printSummary(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Printf("Received %s over %s\n", s.describe(), elapsed.Round(time.Millisecond))
+	stamped := false
+	for _, signal := range []string{"traces", "logs"} {
+		sig := s.signals[signal]
+		if sig.stamped == 0 {
+			continue
+		}
+		stamped = true
+		sorted := slices.Clone(sig.latencies)
+		slices.Sort(sorted)
+		fmt.Printf("End-to-end latency of %d %s: p50 %s, p95 %s, p99 %s, max %s\n", sig.stamped, signalItems[signal],
+			percentile(sorted, 0.5).Round(10*time.Microsecond), percentile(sorted, 0.95).Round(10*time.Microsecond),
+			percentile(sorted, 0.99).Round(10*time.Microsecond), sig.max.Round(10*time.Microsecond))
+		if sig.early > 0 {
+			fmt.Printf("WARNING: %d %s arrived before their emit time; the generator's and sink's clocks disagree\n", sig.early, signalItems[signal])
+		}
+	}
+	if !stamped && (s.signals["traces"].items > 0 || s.signals["logs"].items > 0) {
+		fmt.Println("No items carried an emit time; generate with --stamp-emit-time to measure end-to-end latency")
+	}
+	for _, signal := range []string{"traces", "logs"} {
+		printReconciliation(signal, slices.Collect(maps.Values(s.signals[signal].streams)))
+	}
+}
+
+// serveReceived serves the requests and items received of each signal, as JSON
+func (s *sink) serveReceived(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	counts := make(map[string]sinkCount, len(s.signals))
+	for signal, sig := range s.signals {
+		counts[signal] = sinkCount{Requests: sig.requests, Items: sig.items}
+	}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+// handle returns the HTTP handler for a signal's OTLP path
+func (s *sink) handle(signal string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req, resp proto.Message
+		switch signal {
+		case "traces":
+			req, resp = &coltracepb.ExportTraceServiceRequest{}, &coltracepb.ExportTraceServiceResponse{}
+		case "logs":
+			req, resp = &collogspb.ExportLogsServiceRequest{}, &collogspb.ExportLogsServiceResponse{}
+		default:
+			req, resp = &colmetricspb.ExportMetricsServiceRequest{}, &colmetricspb.ExportMetricsServiceResponse{}
+		}
+		isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+		if isJSON {
+			err = protojson.Unmarshal(data, req)
+		} else {
+			err = proto.Unmarshal(data, req)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse request: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.export(signal, req)
+
+		var out []byte
+		if isJSON {
+			out, err = protojson.Marshal(resp)
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			out, err = proto.Marshal(resp)
+			w.Header().Set("Content-Type", "application/x-protobuf")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(out)
+	}
+}
+
+// export records a received export request of any signal
+func (s *sink) export(signal string, req proto.Message) {
+	now := time.Now()
+	s.capture.write(signal, req, now)
+	var stamps []int64
+	var sequences []sinkSequences
+	items := 0
+	switch r := req.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range r.ResourceSpans {
+			numbered := sinkSequences{resource: rs.Resource}
+			for _, ss := range rs.ScopeSpans {
+				items += len(ss.Spans)
+				for _, span := range ss.Spans {
+					stamps = appendEmitTime(stamps, span.Attributes)
+					if seq, ok := sequenceOf(span.Attributes); ok {
+						numbered.seqs = append(numbered.seqs, seq)
+					}
+				}
+			}
+			if len(numbered.seqs) > 0 {
+				sequences = append(sequences, numbered)
+			}
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for _, rl := range r.ResourceLogs {
+			numbered := sinkSequences{resource: rl.Resource}
+			for _, sl := range rl.ScopeLogs {
+				items += len(sl.LogRecords)
+				for _, record := range sl.LogRecords {
+					stamps = appendEmitTime(stamps, record.Attributes)
+					if seq, ok := sequenceOf(record.Attributes); ok {
+						numbered.seqs = append(numbered.seqs, seq)
+					}
+				}
+			}
+			if len(numbered.seqs) > 0 {
+				sequences = append(sequences, numbered)
+			}
+		}
+	case *colmetricspb.ExportMetricsServiceRequest:
+		for _, rm := range r.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				items += countDataPoints(sm.Metrics)
+			}
+		}
+	}
+	s.receive(signal, items, stamps, sequences, now.UnixNano())
+}
+
+// appendEmitTime appends the emit time among attrs to stamps, if there is one
+func appendEmitTime(stamps []int64, attrs []*commonpb.KeyValue) []int64 {
+	for _, kv := range attrs {
+		if kv.Key == EmitTimeAttribute {
+			if v, ok := kv.Value.GetValue().(*commonpb.AnyValue_IntValue); ok {
+				return append(stamps, v.IntValue)
+			}
+		}
+	}
+	return stamps
+}
+
+type sinkTraces struct {
+	*sink
+	coltracepb.UnimplementedTraceServiceServer
+}
+
+func (s sinkTraces) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.export("traces", req)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type sinkLogs struct {
+	*sink
+	collogspb.UnimplementedLogsServiceServer
+}
+
+func (s sinkLogs) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.export("logs", req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+type sinkMetrics struct {
+	*sink
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+func (s sinkMetrics) Export(_ context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	s.export("metrics", req)
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
@@ -2,8 +2,10 @@ package otelgen
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // ParseSize parses a size string like "1kb", "1mb", "500b" into bytes
@@ -53,15 +55,98 @@ func ParseSize(sizeStr string) (int64, error) {
 	return int64(num * float64(multiplier)), nil
 }
 
+// ParseThroughput parses a throughput like "50mb/s" or "500kb" into bytes per second
+func ParseThroughput(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	size, err := ParseSize(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "/s"))
+	if err != nil {
+		return 0, err
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("throughput must be positive: %s", s)
+	}
+	return float64(size), nil
+}
+
+// formatBytes formats a byte count with the units ParseSize accepts, e.g. "1.5 MB"
+func formatBytes(n float64) string {
+	switch {
+	case n >= 1024*1024*1024:
+		return fmt.Sprintf("%.2f GB", n/(1024*1024*1024))
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.2f MB", n/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.2f KB", n/1024)
+	default:
+		return fmt.Sprintf("%.0f B", n)
+	}
+}
+
+// paddings holds the padding generated for each size, since every item of a run is
+// padded to the same size
+var paddings sync.Map
+
 // GeneratePadding creates a padding string of the specified size
 func GeneratePadding(size int64) string {
 	if size <= 0 {
 		return ""
 	}
-	// Create a buffer of the specified size filled with 'x' characters
-	padding := make([]byte, size)
-	for i := range padding {
-		padding[i] = 'x'
+	if padding, ok := paddings.Load(size); ok {
+		return padding.(string)
+	}
+	// Create a string of the specified size filled with 'x' characters
+	padding, _ := paddings.LoadOrStore(size, strings.Repeat("x", int(size)))
+	return padding.(string)
+}
+
+// ParsePercentage parses a percentage like "20%" or a fraction like "0.2" into a fraction between 0 and 1
+func ParsePercentage(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	s = strings.TrimSpace(s)
+	percent := strings.HasSuffix(s, "%")
+	num, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return 0, fmt.Errorf("invalid percentage: %s", s)
+	}
+	if percent {
+		num /= 100
+	}
+	if num < 0 || num > 1 {
+		return 0, fmt.Errorf("percentage out of range: %s (must be between 0%% and 100%%)", s)
+	}
+
+	return num, nil
+}
+
+// ParsePerSecond parses a rate like "1000/min", "50/s", or "2" into events per second
+func ParsePerSecond(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	numStr, unit, _ := strings.Cut(s, "/")
+	num, err := strconv.ParseFloat(strings.TrimSpace(numStr), 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return 0, fmt.Errorf("invalid rate number: %s", numStr)
+	}
+	if num < 0 {
+		return 0, fmt.Errorf("rate cannot be negative: %s", s)
+	}
+
+	switch strings.TrimSpace(unit) {
+	case "", "s", "sec", "second":
+		return num, nil
+	case "m", "min", "minute":
+		return num / 60, nil
+	case "h", "hour":
+		return num / 3600, nil
+	default:
+		return 0, fmt.Errorf("unknown rate unit: %s (supported: s, min, h)", unit)
 	}
-	return string(padding)
 }
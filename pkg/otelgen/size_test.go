@@ -0,0 +1,96 @@
+package otelgen
+
+import "testing"
+
+func TestParsePerSecond(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "2", want: 2},
+		{in: "50/s", want: 50},
+		{in: " 50 / sec ", want: 50},
+		{in: "0.2/second", want: 0.2},
+		{in: "60000/min", want: 1000},
+		{in: "120/m", want: 2},
+		{in: "7200/h", want: 2},
+		{in: "3600/HOUR", want: 1},
+		{in: "-1/s", wantErr: true},
+		{in: "abc/s", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "Inf/s", wantErr: true},
+		{in: "10/day", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePerSecond(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePerSecond(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePerSecond(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePercentage(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "20%", want: 0.2},
+		{in: " 1% ", want: 0.01},
+		{in: "0.2", want: 0.2},
+		{in: "0", want: 0},
+		{in: "100%", want: 1},
+		{in: "1", want: 1},
+		{in: "101%", wantErr: true},
+		{in: "1.5", wantErr: true},
+		{in: "-1%", wantErr: true},
+		{in: "%", wantErr: true},
+		{in: "NaN%", wantErr: true},
+		{in: "Inf", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePercentage(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePercentage(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePercentage(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseThroughput(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "500b", want: 500},
+		{in: "500kb", want: 500 * 1024},
+		{in: "50mb/s", want: 50 * 1024 * 1024},
+		{in: " 1GB/S ", want: 1024 * 1024 * 1024},
+		{in: "1.5k/s", want: 1536},
+		{in: "0mb/s", wantErr: true},
+		{in: "mb/s", wantErr: true},
+		{in: "10tb/s", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseThroughput(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseThroughput(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseThroughput(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package otelgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	// soakHeapGrowth and soakHeapGrowthBytes are how much otelgen's live heap may grow
+	// over a soak, as a fraction and in bytes, before the summary warns about it
+	soakHeapGrowth      = 0.5
+	soakHeapGrowthBytes = 10 * 1024 * 1024
+	// soakRateDrop is how far the achieved rate may fall over a soak before the
+	// summary warns about it
+	soakRateDrop = 0.1
+)
+
+// SoakOptions configures the checkpoints of a long run
+type SoakOptions struct {
+	// Interval is the time between checkpoints
+	Interval time.Duration
+	// File, when set, receives every checkpoint as a line of JSON
+	File string
+}
+
+// soakCheckpoint is the rolling stats of one interval, with otelgen's own memory and
+// GC activity
+type soakCheckpoint struct {
+	Time          time.Time `json:"time"`
+	Signal        string    `json:"signal"`
+	Elapsed       float64   `json:"elapsed_seconds"`
+	Events        int64     `json:"events"`
+	Rate          float64   `json:"rate"`
+	Exports       int64     `json:"exports"`
+	FailedExports int64     `json:"failed_exports"`
+	LiveHeapBytes uint64    `json:"live_heap_bytes"`
+	SysBytes      uint64    `json:"sys_bytes"`
+	Goroutines    int       `json:"goroutines"`
+	GCCycles      uint32    `json:"gc_cycles"`
+	GCPause       float64   `json:"gc_pause_seconds"`
+}
+
+// soakMonitor prints a checkpoint at every interval of a long run, to show whether the
+// endpoint or otelgen itself degrades over time
+type soakMonitor struct {
+	opts   SoakOptions
+	signal string
+	items  string
+	// steady is whether the target rate holds still, so a falling rate is a problem
+	steady bool
+	// stats counts the exports; exports reads them, unless the signal counts its own
+	stats   targetStats
+	exports func() (int64, int64)
+	file    *os.File
+	ticker  *time.Ticker
+
+	start, last   time.Time
+	events        int
+	sent, failed  int64
+	numGC         uint32
+	pauseNs       uint64
+	first, latest *soakCheckpoint
+	checkpoints   int
+}
+
+// newSoakMonitor returns the monitor for the run, or nil when it is not a soak
+func (l LoadOptions) newSoakMonitor(signal, items string, duration time.Duration) (*soakMonitor, error) {
+	if l.Soak == nil {
+		return nil, nil
+	}
+	if l.Soak.Interval <= 0 {
+		return nil, fmt.Errorf("soak interval must be positive")
+	}
+	if l.Soak.Interval >= duration {
+		return nil, fmt.Errorf("soak interval (%s) must be shorter than the duration (%s)", l.Soak.Interval, duration)
+	}
+	m := &soakMonitor{
+		opts:   *l.Soak,
+		signal: signal,
+		items:  items,
+		steady: l.RampUp == 0 && l.RampDown == 0 && len(l.Steps) == 0 && l.Pattern == nil,
+	}
+	m.exports = func() (int64, int64) {
+		return m.stats.exports.Load(), m.stats.failed.Load()
+	}
+	if l.Soak.File != "" {
+		f, err := os.OpenFile(l.Soak.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open soak file: %w", err)
+		}
+		m.file = f
+	}
+	return m, nil
+}
+
+// begin starts the checkpoint interval as generation starts
+func (m *soakMonitor) begin(verbose bool) {
+	if m == nil {
+		return
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	m.numGC, m.pauseNs = ms.NumGC, ms.PauseTotalNs
+	m.start = time.Now()
+	m.last = m.start
+	m.ticker = time.NewTicker(m.opts.Interval)
+	if verbose {
+		fmt.Printf("[VERBOSE] Checkpointing every %s\n", m.opts.Interval)
+		if m.file != nil {
+			fmt.Printf("[VERBOSE] Appending checkpoints to %s\n", m.opts.File)
+		}
+	}
+}
+
+// tick delivers the checkpoint times; it never fires outside a soak
+func (m *soakMonitor) tick() <-chan time.Time {
+	if m == nil {
+		return nil
+	}
+	return m.ticker.C
+}
+
+// checkpoint prints and records the stats of the interval since the last one, given
+// the events generated since the run started
+func (m *soakMonitor) checkpoint(events int) {
+	now := time.Now()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	sent, failed := m.exports()
+	interval := now.Sub(m.last)
+
+	c := &soakCheckpoint{
+		Time:          now.UTC(),
+		Signal:        m.signal,
+		Elapsed:       now.Sub(m.start).Seconds(),
+		Events:        int64(events - m.events),
+		Rate:          float64(events-m.events) / interval.Seconds(),
+		Exports:       sent - m.sent,
+		FailedExports: failed - m.failed,
+		LiveHeapBytes: liveHeapBytes(&ms),
+		SysBytes:      ms.Sys,
+		Goroutines:    runtime.NumGoroutine(),
+		GCCycles:      ms.NumGC - m.numGC,
+		GCPause:       time.Duration(ms.PauseTotalNs - m.pauseNs).Seconds(),
+	}
+	m.last, m.events, m.sent, m.failed = now, events, sent, failed
+	m.numGC, m.pauseNs = ms.NumGC, ms.PauseTotalNs
+	if m.first == nil {
+		m.first = c
+	}
+	m.latest = c
+	m.checkpoints++
+
+	fmt.Printf("[%s] %d %s (%.1f/s), %d exports (%d failed), live heap %s, sys %s, %d goroutines, %d GCs (%s paused)\n",
+		now.Sub(m.start).Round(time.Second), c.Events, m.items, c.Rate, c.Exports, c.FailedExports,
+		formatBytes(float64(c.LiveHeapBytes)), formatBytes(float64(c.SysBytes)), c.Goroutines, c.GCCycles,
+		time.Duration(c.GCPause*float64(time.Second)).Round(time.Microsecond))
+	if m.file != nil {
+		line, err := json.Marshal(c)
+		if err == nil {
+			_, err = m.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to record checkpoint: %v\n", err)
+		}
+	}
+}
+
+// stop stops checkpointing, printing how the first and last checkpoints compare
+func (m *soakMonitor) stop() {
+	if m == nil {
+		return
+	}
+	if m.ticker != nil {
+		m.ticker.Stop()
+	}
+	if m.file != nil {
+		m.file.Close()
+	}
+	if m.checkpoints < 2 {
+		return
+	}
+	first, last := m.first, m.latest
+	fmt.Printf("Soak: %d checkpoints; rate %.1f/s to %.1f/s, live heap %s to %s, %d to %d goroutines\n",
+		m.checkpoints, first.Rate, last.Rate, formatBytes(float64(first.LiveHeapBytes)), formatBytes(float64(last.LiveHeapBytes)),
+		first.Goroutines, last.Goroutines)
+	if m.steady && last.Rate < (1-soakRateDrop)*first.Rate {
+		fmt.Printf("Warning: the achieved rate fell from %.1f/s to %.1f/s over the soak\n", first.Rate, last.Rate)
+	}
+	growth := float64(last.LiveHeapBytes) - float64(first.LiveHeapBytes)
+	if growth > soakHeapGrowthBytes && growth > soakHeapGrowth*float64(first.LiveHeapBytes) {
+		fmt.Printf("Warning: otelgen's live heap grew from %s to %s over the soak\n",
+			formatBytes(float64(first.LiveHeapBytes)), formatBytes(float64(last.LiveHeapBytes)))
+	}
+}
+
+// liveHeapBytes returns the heap still in use after the last GC, which, unlike the
+// allocated heap, doesn't swing with the GC cycle; before the first GC, it is the
+// allocated heap
+func liveHeapBytes(ms *runtime.MemStats) uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 || ms.NumGC == 0 {
+		return ms.HeapAlloc
+	}
+	return sample[0].Value.Uint64()
+}
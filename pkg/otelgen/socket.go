@@ -0,0 +1,71 @@
+package otelgen
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// socketConn is a tcp, udp, or tls connection for the log event exporters; it is
+// re-established on the next write after a write fails. It is not safe for
+// concurrent use.
+type socketConn struct {
+	network     string // tcp, udp, or tls
+	address     string
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	conn        net.Conn
+}
+
+func (s *socketConn) dial() error {
+	dialer := &net.Dialer{Timeout: s.dialTimeout}
+	if s.dialTimeout == 0 {
+		dialer.Timeout = defaultConnectTimeout
+	}
+	var conn net.Conn
+	var err error
+	if s.network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(s.network, s.address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", s, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// write sends b, dialing first if the previous write failed
+func (s *socketConn) write(ctx context.Context, b []byte) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	if _, err := s.conn.Write(b); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("failed to send to %s: %w", s, err)
+	}
+	return nil
+}
+
+func (s *socketConn) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// String returns the destination URL, e.g. tcp://localhost:514
+func (s *socketConn) String() string {
+	return fmt.Sprintf("%s://%s", s.network, s.address)
+}
@@ -0,0 +1,164 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// maxSilentExports is the longest gap, in export intervals, a sparse series stays silent
+const maxSilentExports = 10
+
+// seriesKey identifies one metric series
+type seriesKey struct {
+	resource attribute.Distinct
+	name     string
+	attrs    attribute.Distinct
+}
+
+// sparseFilter tracks which series are currently silent, so a fraction of series
+// stop reporting for random intervals and later reappear
+type sparseFilter struct {
+	mu       sync.Mutex
+	fraction float64
+	interval time.Duration
+	silent   map[seriesKey]time.Time
+	verbose  bool
+	rnd      *rand.Rand
+}
+
+func newSparseFilter(fraction float64, interval time.Duration, verbose bool, rnd *rand.Rand) *sparseFilter {
+	return &sparseFilter{
+		fraction: fraction,
+		interval: interval,
+		silent:   make(map[seriesKey]time.Time),
+		verbose:  verbose,
+		rnd:      rnd,
+	}
+}
+
+// Filter returns a copy of rm without the datapoints of currently silent series
+func (f *sparseFilter) Filter(rm *metricdata.ResourceMetrics) *metricdata.ResourceMetrics {
+	f.mu.Lock()
+	now := time.Now()
+	resource := rm.Resource.Equivalent()
+	total, dropped := 0, 0
+	keep := func(name string, attrs attribute.Set) bool {
+		total++
+		if f.isSilent(seriesKey{resource: resource, name: name, attrs: attrs.Equivalent()}, now) {
+			dropped++
+			return false
+		}
+		return true
+	}
+
+	filtered := &metricdata.ResourceMetrics{
+		Resource:     rm.Resource,
+		ScopeMetrics: make([]metricdata.ScopeMetrics, 0, len(rm.ScopeMetrics)),
+	}
+	for _, sm := range rm.ScopeMetrics {
+		scope := metricdata.ScopeMetrics{Scope: sm.Scope}
+		for _, m := range sm.Metrics {
+			if m.Data = filterAggregation(m.Name, m.Data, keep); m.Data != nil {
+				scope.Metrics = append(scope.Metrics, m)
+			}
+		}
+		filtered.ScopeMetrics = append(filtered.ScopeMetrics, scope)
+	}
+	f.mu.Unlock()
+
+	if f.verbose && dropped > 0 {
+		fmt.Printf("[VERBOSE] Sparse: %d of %d series silent this export\n", dropped, total)
+	}
+
+	return filtered
+}
+
+// isSilent reports whether a series should be skipped, starting and ending silent
+// periods so that on average the configured fraction of series is silent
+func (f *sparseFilter) isSilent(key seriesKey, now time.Time) bool {
+	if until, ok := f.silent[key]; ok {
+		if now.Before(until) {
+			return true
+		}
+		delete(f.silent, key)
+		return false
+	}
+
+	// With a mean gap of D exports, starting gaps with probability p/((1-p)*D)
+	// keeps the steady-state silent fraction at p
+	meanGap := float64(maxSilentExports+1) / 2
+	if f.fraction >= 1 || f.rnd.Float64() < f.fraction/((1-f.fraction)*meanGap) {
+		gap := time.Duration(f.rnd.Intn(maxSilentExports)+1) * f.interval
+		f.silent[key] = now.Add(gap)
+		return true
+	}
+	return false
+}
+
+// sparseExporter applies a sparseFilter before handing data to the wrapped exporter
+type sparseExporter struct {
+	sdkmetric.Exporter
+	filter *sparseFilter
+}
+
+// Export filters out silent series before exporting
+func (e sparseExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.Exporter.Export(ctx, e.filter.Filter(rm))
+}
+
+// filterAggregation returns a copy of data holding only the datapoints keep accepts,
+// or nil if none remain
+func filterAggregation(name string, data metricdata.Aggregation, keep func(string, attribute.Set) bool) metricdata.Aggregation {
+	switch d := data.(type) {
+	case metricdata.Gauge[int64]:
+		d.DataPoints = filterPoints(d.DataPoints, func(p metricdata.DataPoint[int64]) bool { return keep(name, p.Attributes) })
+		if len(d.DataPoints) > 0 {
+			return d
+		}
+	case metricdata.Gauge[float64]:
+		d.DataPoints = filterPoints(d.DataPoints, func(p metricdata.DataPoint[float64]) bool { return keep(name, p.Attributes) })
+		if len(d.DataPoints) > 0 {
+			return d
+		}
+	case metricdata.Sum[int64]:
+		d.DataPoints = filterPoints(d.DataPoints, func(p metricdata.DataPoint[int64]) bool { return keep(name, p.Attributes) })
+		if len(d.DataPoints) > 0 {
+			return d
+		}
+	case metricdata.Sum[float64]:
+		d.DataPoints = filterPoints(d.DataPoints, func(p metricdata.DataPoint[float64]) bool { return keep(name, p.Attributes) })
+		if len(d.DataPoints) > 0 {
+			return d
+		}
+	case metricdata.Histogram[int64]:
+		d.DataPoints = filterPoints(d.DataPoints, func(p metricdata.HistogramDataPoint[int64]) bool { return keep(name, p.Attributes) })
+		if len(d.DataPoints) > 0 {
+			return d
+		}
+	case metricdata.Histogram[float64]:
+		d.DataPoints = filterPoints(d.DataPoints, func(p metricdata.HistogramDataPoint[float64]) bool { return keep(name, p.Attributes) })
+		if len(d.DataPoints) > 0 {
+			return d
+		}
+	default:
+		return data
+	}
+	return nil
+}
+
+func filterPoints[T any](points []T, keep func(T) bool) []T {
+	out := make([]T, 0, len(points))
+	for _, p := range points {
+		if keep(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
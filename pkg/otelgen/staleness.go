@@ -0,0 +1,97 @@
+package otelgen
+
+import (
+	"fmt"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+const (
+	// stalenessSeries is how many short-lived series are alive at once
+	stalenessSeries = 5
+	// stalenessMinLifetime and stalenessMaxLifetime bound how long each series reports
+	stalenessMinLifetime = 10 * time.Second
+	stalenessMaxLifetime = 60 * time.Second
+)
+
+// staleSeries is one short-lived series and its running total
+type staleSeries struct {
+	id    int
+	start time.Time
+	end   time.Time
+	total float64
+}
+
+// stalenessMarkers reports a rotating set of short-lived series and, when each one
+// ends, sends a final datapoint flagged NO_RECORDED_VALUE (a Prometheus staleness marker)
+type stalenessMarkers struct {
+	series   []*staleSeries
+	nextID   int
+	lastEmit time.Time
+	ended    int
+	rnd      *runRandom
+}
+
+func newStalenessMarkers(rnd *runRandom) *stalenessMarkers {
+	s := &stalenessMarkers{rnd: rnd}
+	now := time.Now()
+	for i := 0; i < stalenessSeries; i++ {
+		s.series = append(s.series, s.newSeries(now))
+	}
+	return s
+}
+
+func (s *stalenessMarkers) newSeries(now time.Time) *staleSeries {
+	s.nextID++
+	lifetime := stalenessMinLifetime + time.Duration(s.rnd.Int63n(int64(stalenessMaxLifetime-stalenessMinLifetime)))
+	return &staleSeries{id: s.nextID, start: now, end: now.Add(lifetime)}
+}
+
+// Metrics reports every live series once per export interval, ending and replacing
+// the series whose lifetime is over
+func (s *stalenessMarkers) Metrics(now time.Time) []*metricspb.Metric {
+	if now.Sub(s.lastEmit) < metricsExportInterval {
+		return nil
+	}
+	s.lastEmit = now
+
+	ts := unixNano(now)
+	gauge := &metricspb.Gauge{}
+	sum := &metricspb.Sum{
+		AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		IsMonotonic:            true,
+	}
+
+	for i, series := range s.series {
+		attrs := []*commonpb.KeyValue{stringAttr("series.id", fmt.Sprintf("stale-%d", series.id))}
+		gaugePoint := &metricspb.NumberDataPoint{Attributes: attrs, TimeUnixNano: ts}
+		sumPoint := &metricspb.NumberDataPoint{Attributes: attrs, StartTimeUnixNano: unixNano(series.start), TimeUnixNano: ts}
+
+		if now.Before(series.end) {
+			series.total += float64(s.rnd.Intn(10) + 1)
+			gaugePoint.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: s.rnd.Float64() * 100}
+			sumPoint.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: series.total}
+		} else {
+			// End of life: no value, only the flag
+			gaugePoint.Flags = uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+			sumPoint.Flags = uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+			s.series[i] = s.newSeries(now)
+			s.ended++
+		}
+
+		gauge.DataPoints = append(gauge.DataPoints, gaugePoint)
+		sum.DataPoints = append(sum.DataPoints, sumPoint)
+	}
+
+	return []*metricspb.Metric{
+		{Name: "otelgen.staleness.gauge", Description: "Short-lived series that end with a staleness marker", Data: &metricspb.Metric_Gauge{Gauge: gauge}},
+		{Name: "otelgen.staleness.counter", Description: "Short-lived series that end with a staleness marker", Data: &metricspb.Metric_Sum{Sum: sum}},
+	}
+}
+
+// Ended returns the number of series that have been ended with a staleness marker
+func (s *stalenessMarkers) Ended() int {
+	return s.ended
+}
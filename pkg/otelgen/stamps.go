@@ -0,0 +1,132 @@
+package otelgen
+
+import (
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// EmitTimeAttribute carries when otelgen emitted a span or log record, in
+	// nanoseconds since the Unix epoch, for a sink to measure end-to-end latency
+	EmitTimeAttribute = "otelgen.emit_time_unix_nano"
+	// SequenceAttribute numbers the spans or log records of a run from zero, in the
+	// order otelgen emitted them, for a sink to find lost, duplicated, and reordered
+	// items
+	SequenceAttribute = "otelgen.sequence"
+	// RunIDAttribute is the resource attribute that tells apart the runs whose items
+	// are numbered
+	RunIDAttribute = "otelgen.run.id"
+)
+
+// itemStamps adds the attributes a sink checks to every span or log record
+type itemStamps struct {
+	emitTime bool
+	// runID and sequence, when set, number the items of the run
+	runID    string
+	sequence *atomic.Int64
+}
+
+// itemStamps returns the stamps for the run's items, or nil when they aren't stamped
+func (l LoadOptions) itemStamps() *itemStamps {
+	if !l.StampEmitTime && !l.Sequence {
+		return nil
+	}
+	s := &itemStamps{emitTime: l.StampEmitTime}
+	if l.Sequence {
+		s.runID, s.sequence = uuid.NewString(), new(atomic.Int64)
+	}
+	return s
+}
+
+// resourceAttributes returns the run ID for the resource, if items are numbered
+func (s *itemStamps) resourceAttributes() []attribute.KeyValue {
+	if s == nil || s.sequence == nil {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String(RunIDAttribute, s.runID)}
+}
+
+// resourceAttributes returns the service instance and attributes the run adds to its
+// resource
+func (l LoadOptions) resourceAttributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if l.InstanceID != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(l.InstanceID))
+	}
+	for _, k := range slices.Sorted(maps.Keys(l.ResourceAttributes)) {
+		attrs = append(attrs, attribute.String(k, l.ResourceAttributes[k]))
+	}
+	return attrs
+}
+
+// next returns the next sequence number, or false when items aren't numbered
+func (s *itemStamps) next() (int64, bool) {
+	if s.sequence == nil {
+		return 0, false
+	}
+	return s.sequence.Add(1) - 1, true
+}
+
+// spanAttributes returns the stamps of a span ending at now
+func (s *itemStamps) spanAttributes(now time.Time) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 2)
+	if s.emitTime {
+		attrs = append(attrs, attribute.Int64(EmitTimeAttribute, now.UnixNano()))
+	}
+	if seq, ok := s.next(); ok {
+		attrs = append(attrs, attribute.Int64(SequenceAttribute, seq))
+	}
+	return attrs
+}
+
+// logAttributes returns the stamps of a log record emitted at now
+func (s *itemStamps) logAttributes(now time.Time) []log.KeyValue {
+	attrs := make([]log.KeyValue, 0, 2)
+	if s.emitTime {
+		attrs = append(attrs, log.Int64(EmitTimeAttribute, now.UnixNano()))
+	}
+	if seq, ok := s.next(); ok {
+		attrs = append(attrs, log.Int64(SequenceAttribute, seq))
+	}
+	return attrs
+}
+
+// appendProto appends the stamps of an item emitted at now to attrs; attrs may be
+// shared with a pooled payload, so it is copied rather than appended to in place
+func (s *itemStamps) appendProto(attrs []*commonpb.KeyValue, now time.Time) []*commonpb.KeyValue {
+	attrs = slices.Clip(attrs)
+	if s.emitTime {
+		attrs = append(attrs, intAttribute(EmitTimeAttribute, now.UnixNano()))
+	}
+	if seq, ok := s.next(); ok {
+		attrs = append(attrs, intAttribute(SequenceAttribute, seq))
+	}
+	return attrs
+}
+
+// stampSpans stamps spans ending at now
+func (s *itemStamps) stampSpans(spans []*tracepb.Span, now time.Time) {
+	for _, span := range spans {
+		span.Attributes = s.appendProto(span.Attributes, now)
+	}
+}
+
+// stampLogRecord stamps a log record emitted at now
+func (s *itemStamps) stampLogRecord(record *logspb.LogRecord, now time.Time) {
+	record.Attributes = s.appendProto(record.Attributes, now)
+}
+
+// intAttribute returns an OTLP integer attribute
+func intAttribute(key string, v int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}}
+}
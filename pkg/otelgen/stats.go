@@ -0,0 +1,220 @@
+package otelgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// StatsOptions configures the rolling stats printed while a run goes on
+type StatsOptions struct {
+	// Interval is the time between stats lines
+	Interval time.Duration
+	// Format is text (the default), a line of prose, or ndjson, a line of JSON
+	Format string
+}
+
+// statsLine is the rolling stats of one interval
+type statsLine struct {
+	Time          time.Time `json:"time"`
+	Signal        string    `json:"signal"`
+	Elapsed       float64   `json:"elapsed_seconds"`
+	Events        int64     `json:"events"`
+	Rate          float64   `json:"rate"`
+	Exports       int64     `json:"exports"`
+	FailedExports int64     `json:"failed_exports"`
+	Bytes         *int64    `json:"bytes_sent,omitempty"`
+	WireBytes     *int64    `json:"wire_bytes_sent,omitempty"`
+	LatencyP50    *float64  `json:"export_latency_p50_seconds,omitempty"`
+	LatencyP95    *float64  `json:"export_latency_p95_seconds,omitempty"`
+	LatencyP99    *float64  `json:"export_latency_p99_seconds,omitempty"`
+}
+
+// statsMonitor prints the achieved rate, exports, bytes sent, uncompressed and on the
+// wire, and export latency of every interval of a run
+type statsMonitor struct {
+	opts   StatsOptions
+	signal string
+	events string
+	// stats times the exports; exports, when set, counts them instead, for signals
+	// that count their own and can't time them
+	stats   probeStats
+	exports func() (int64, int64)
+	// observers count the bytes of the exports, if they go to an OTLP endpoint
+	observers []*exportObserver
+	ticker    *time.Ticker
+
+	start, last  time.Time
+	count        int
+	sent, failed int64
+	bytes, wire  int64
+}
+
+// newStatsMonitor returns the monitor for the run, or nil when it prints no stats;
+// events names what the rate counts, and observers count the bytes sent
+func (l LoadOptions) newStatsMonitor(signal, events string, duration time.Duration, observers ...*exportObserver) (*statsMonitor, error) {
+	if l.Stats == nil {
+		return nil, nil
+	}
+	if l.Stats.Interval <= 0 {
+		return nil, fmt.Errorf("stats interval must be positive")
+	}
+	if l.Stats.Interval >= duration {
+		return nil, fmt.Errorf("stats interval (%s) must be shorter than the duration (%s)", l.Stats.Interval, duration)
+	}
+	switch l.Stats.Format {
+	case "", "text", "ndjson":
+	default:
+		return nil, fmt.Errorf("unknown stats format %q (expected text or ndjson)", l.Stats.Format)
+	}
+	return &statsMonitor{opts: *l.Stats, signal: signal, events: events, observers: observers}, nil
+}
+
+// begin starts the stats interval as generation starts
+func (m *statsMonitor) begin(verbose bool) {
+	if m == nil {
+		return
+	}
+	m.start = time.Now()
+	m.last = m.start
+	m.ticker = time.NewTicker(m.opts.Interval)
+	if verbose {
+		fmt.Printf("[VERBOSE] Printing stats every %s\n", m.opts.Interval)
+	}
+}
+
+// tick delivers the stats times; it never fires without stats
+func (m *statsMonitor) tick() <-chan time.Time {
+	if m == nil {
+		return nil
+	}
+	return m.ticker.C
+}
+
+// stop stops printing stats
+func (m *statsMonitor) stop() {
+	if m != nil && m.ticker != nil {
+		m.ticker.Stop()
+	}
+}
+
+// sentBytes returns the bytes the observers counted, uncompressed and on the wire, or
+// false when there are none
+func (m *statsMonitor) sentBytes() (int64, int64, bool) {
+	if len(m.observers) == 0 {
+		return 0, 0, false
+	}
+	var n, wire int64
+	for _, obs := range m.observers {
+		n += obs.bytes.Load()
+		wire += obs.wireBytes.Load()
+	}
+	return n, wire, true
+}
+
+// report prints the stats of the interval since the last one, given the events
+// generated since the run started
+func (m *statsMonitor) report(events int) {
+	now := time.Now()
+	interval := now.Sub(m.last)
+	line := &statsLine{
+		Time:    now.UTC(),
+		Signal:  m.signal,
+		Elapsed: now.Sub(m.start).Seconds(),
+		Events:  int64(events - m.count),
+		Rate:    float64(events-m.count) / interval.Seconds(),
+	}
+	var latencies []time.Duration
+	if m.exports != nil {
+		sent, failed := m.exports()
+		line.Exports, line.FailedExports = sent-m.sent, failed-m.failed
+		m.sent, m.failed = sent, failed
+	} else {
+		var exports, failed int
+		exports, failed, latencies = m.stats.take()
+		line.Exports, line.FailedExports = int64(exports), int64(failed)
+	}
+	if total, wire, ok := m.sentBytes(); ok {
+		sent, written := total-m.bytes, wire-m.wire
+		line.Bytes, line.WireBytes = &sent, &written
+		m.bytes, m.wire = total, wire
+	}
+	var p50, p95, p99 time.Duration
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		p50, p95, p99 = percentile(latencies, 0.5), percentile(latencies, 0.95), percentile(latencies, 0.99)
+		s50, s95, s99 := p50.Seconds(), p95.Seconds(), p99.Seconds()
+		line.LatencyP50, line.LatencyP95, line.LatencyP99 = &s50, &s95, &s99
+	}
+	m.last, m.count = now, events
+
+	if m.opts.Format == "ndjson" {
+		out, err := json.Marshal(line)
+		if err != nil {
+			fmt.Printf("Warning: failed to encode stats: %v\n", err)
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+	text := fmt.Sprintf("[%s] %.1f %s/s, %d exports (%d failed)", now.Sub(m.start).Round(time.Second), line.Rate, m.events, line.Exports, line.FailedExports)
+	if line.Bytes != nil {
+		text += fmt.Sprintf(", %s sent (%s on the wire)", formatBytes(float64(*line.Bytes)), formatBytes(float64(*line.WireBytes)))
+	}
+	if len(latencies) > 0 {
+		text += fmt.Sprintf(", export latency p50 %s, p95 %s, p99 %s",
+			p50.Round(10*time.Microsecond), p95.Round(10*time.Microsecond), p99.Round(10*time.Microsecond))
+	}
+	fmt.Println(text)
+}
+
+// watch also times the exports of a raw client with its own observer, counting their
+// bytes; it does nothing without stats
+func (m *statsMonitor) watch(raw *rawClient, transport TransportOptions) {
+	if m == nil {
+		return
+	}
+	raw.time(&m.stats)
+	m.observers = append(m.observers, transport.observed(raw.obs)...)
+}
+
+// observed returns obs for counting the bytes sent, unless a payload writer replaces
+// the OTLP endpoint it observes
+func (t TransportOptions) observed(obs *exportObserver) []*exportObserver {
+	if t.Writer != nil {
+		return nil
+	}
+	return []*exportObserver{obs}
+}
+
+// timedLogExporter records the latency and outcome of each export in the stats
+type timedLogExporter struct {
+	sdklog.Exporter
+	stats *probeStats
+}
+
+func (e timedLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.stats.record(len(records), time.Since(start), err)
+	return err
+}
+
+// timedMetricExporter records the latency and outcome of each export in the stats
+type timedMetricExporter struct {
+	sdkmetric.Exporter
+	stats *probeStats
+}
+
+func (e timedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.stats.record(countSDKDataPoints(rm), time.Since(start), err)
+	return err
+}
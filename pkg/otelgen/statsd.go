@@ -0,0 +1,336 @@
+package otelgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsdMaxPacket keeps UDP datagrams under a typical MTU
+const statsdMaxPacket = 1432
+
+// StatsDEndpoint is a parsed StatsD destination
+type StatsDEndpoint struct {
+	Network string // udp or tcp
+	Address string // host:port
+}
+
+// String returns the full endpoint URL
+func (e *StatsDEndpoint) String() string {
+	return fmt.Sprintf("%s://%s", e.Network, e.Address)
+}
+
+// ParseStatsDEndpoint parses host:port, udp://host:port, or tcp://host:port.
+// UDP is the default network and 8125 the default port.
+func ParseStatsDEndpoint(endpoint string) (*StatsDEndpoint, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("endpoint cannot be empty")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "udp://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint: %w", err)
+	}
+
+	network := strings.ToLower(u.Scheme)
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported protocol: %s (supported: udp, tcp)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("host cannot be empty")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "8125"
+	}
+
+	return &StatsDEndpoint{Network: network, Address: net.JoinHostPort(u.Hostname(), port)}, nil
+}
+
+// statsdClient writes DogStatsD lines, batching several per UDP datagram or
+// streaming newline-terminated lines over TCP
+type statsdClient struct {
+	conn    net.Conn
+	stream  bool
+	buf     bytes.Buffer
+	line    []byte // reused to format each line
+	lines   int
+	packets int
+	errors  int
+}
+
+func newStatsDClient(endpoint *StatsDEndpoint) (*statsdClient, error) {
+	conn, err := net.DialTimeout(endpoint.Network, endpoint.Address, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd endpoint: %w", err)
+	}
+	return &statsdClient{conn: conn, stream: endpoint.Network == "tcp"}, nil
+}
+
+// Write queues one metric line with the already joined tags, flushing first if a
+// datagram would grow too large
+func (c *statsdClient) Write(name string, value float64, kind, tags string) {
+	c.line = appendStatsdLine(c.line[:0], name, value, kind, tags)
+	line := c.line
+	if !c.stream && c.buf.Len() > 0 && c.buf.Len()+len(line)+1 > statsdMaxPacket {
+		c.Flush()
+	}
+	if !c.stream && c.buf.Len() > 0 {
+		c.buf.WriteByte('\n')
+	}
+	c.buf.Write(line)
+	if c.stream {
+		c.buf.WriteByte('\n')
+	}
+	c.lines++
+}
+
+// Flush sends the queued lines
+func (c *statsdClient) Flush() {
+	if c.buf.Len() == 0 {
+		return
+	}
+	if _, err := c.conn.Write(c.buf.Bytes()); err != nil {
+		c.errors++
+	} else {
+		c.packets++
+	}
+	c.buf.Reset()
+}
+
+// Close flushes any queued lines and closes the connection
+func (c *statsdClient) Close() error {
+	c.Flush()
+	return c.conn.Close()
+}
+
+// appendStatsdLine appends a DogStatsD metric line to dst
+func appendStatsdLine(dst []byte, name string, value float64, kind, tags string) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, ':')
+	dst = strconv.AppendFloat(dst, value, 'g', -1, 64)
+	dst = append(dst, '|')
+	dst = append(dst, kind...)
+	if tags != "" {
+		dst = append(dst, "|#"...)
+		dst = append(dst, tags...)
+	}
+	return dst
+}
+
+// GenerateStatsD generates the default metric set as DogStatsD counters, gauges, and timers.
+// The gauge pattern, churn, and host count options apply; the OTLP-only options are rejected.
+func GenerateStatsD(endpoint *StatsDEndpoint, serviceName string, rate float64, durationStr string, payloadSize int64, verbose bool, load LoadOptions, opts MetricsOptions) error {
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	duration = load.runDuration(duration)
+	rnd := load.newRandom()
+	if load.Throughput > 0 {
+		return fmt.Errorf("throughput targets are not supported for metrics, whose export size depends on the series rather than the rate")
+	}
+
+	unsupported := !opts.defaultMetricSet() || opts.ResetEvery > 0 || opts.Sparse > 0 ||
+		opts.AdversarialValues || len(opts.HistogramBuckets) > 0 || opts.InconsistentHistograms ||
+		opts.UnitVariety || opts.MetadataEdgeCases ||
+		opts.StalenessMarkers || opts.ConflictRate > 0 || opts.RateUnit == "datapoints" || opts.Backfill > 0
+	if unsupported {
+		return fmt.Errorf("the statsd exporter only supports the pattern, churn, and hosts metrics options")
+	}
+	if load.SelfTelemetry != nil {
+		return fmt.Errorf("self-telemetry records OTLP exports, which the statsd exporter doesn't make")
+	}
+	if load.Report != nil && load.Report.MaxP99Latency > 0 {
+		return fmt.Errorf("statsd packets are written without a response, so their export latency can't be asserted")
+	}
+	if load.Delivery != nil && load.Delivery.Sink == "" {
+		return fmt.Errorf("statsd packets are written without a response, so only a sink can confirm their delivery")
+	}
+
+	if opts.PatternPeriod == 0 {
+		opts.PatternPeriod = time.Minute
+	}
+	if opts.PatternAmplitude == 0 {
+		opts.PatternAmplitude = 50
+	}
+	gauge, err := newWaveform(opts.Pattern, opts.PatternPeriod, 50, opts.PatternAmplitude, rnd.Rand)
+	if err != nil {
+		return err
+	}
+	var churn *dueCounter
+	if opts.Churn > 0 {
+		churn = newDueCounter(opts.Churn)
+	}
+	hosts := opts.Hosts
+	if hosts < 1 {
+		hosts = 1
+	}
+
+	usage := load.newUsageMonitor()
+	defer usage.stop()
+
+	if verbose {
+		fmt.Printf("[VERBOSE] Connecting to statsd endpoint %s\n", endpoint)
+	}
+	client, err := newStatsDClient(endpoint)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	baseTags := []string{"service:" + serviceName, "method:GET", "endpoint:/api/test"}
+	if payloadSize > 0 {
+		baseTags = append(baseTags, "payload.data:"+GeneratePadding(payloadSize))
+	}
+	// Tags are joined once per host rather than on every line
+	hostTags := make([]string, hosts)
+	for i := range hostTags {
+		hostTags[i] = strings.Join(append(append([]string{}, baseTags...), fmt.Sprintf("host:otelgen-host-%03d", i+1)), ",")
+	}
+
+	// A soak checkpoints the packets written, counting them as exports
+	soak, err := load.newSoakMonitor("metrics", "metric events", duration)
+	if err != nil {
+		return err
+	}
+	defer soak.stop()
+	if soak != nil {
+		soak.exports = func() (int64, int64) {
+			return int64(client.packets + client.errors), int64(client.errors)
+		}
+	}
+
+	// Rolling stats count the packets written as exports too; writes aren't timed
+	stats, err := load.newStatsMonitor("metrics", "metric events", duration)
+	if err != nil {
+		return err
+	}
+	defer stats.stop()
+	if stats != nil {
+		stats.exports = func() (int64, int64) {
+			return int64(client.packets + client.errors), int64(client.errors)
+		}
+	}
+
+	// So does the report
+	report, err := load.newRunReport("metrics", "metric events")
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		report.exports = func() (int64, int64) {
+			return int64(client.packets + client.errors), int64(client.errors)
+		}
+	}
+
+	// Only the sink the metrics reach confirms their delivery
+	delivery, err := load.newDeliveryCheck("metrics", nil)
+	if err != nil {
+		return err
+	}
+
+	limiter, err := load.limiter(rate, duration, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer limiter.stop()
+	if verbose {
+		limiter.describe()
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	defer load.endOnStop(timer)()
+	soak.begin(verbose)
+	stats.begin(verbose)
+	report.begin()
+	delivery.begin()
+	load.Events.start("metrics", duration, limiter)
+	bar := load.newProgressBar("metric events", duration)
+	bar.begin(limiter)
+	defer bar.stop()
+
+	// Nothing sent during the warmup is counted in the summary
+	warmupC, stopWarmup := load.warmupTimer()
+	defer stopWarmup()
+
+	count, warmupCount := 0, 0
+	// The client's counts keep running for soak checkpoints; the summary subtracts these
+	var warmupLines, warmupPackets, warmupErrors int
+	for {
+		select {
+		case <-timer.C:
+			bar.stop()
+			load.Events.end("metrics", count, warmupCount)
+			client.Flush()
+			fmt.Printf("Generated %d metric events (%d statsd lines in %d packets)\n", count, client.lines-warmupLines, client.packets-warmupPackets)
+			load.printWarmup(warmupCount, "metric events")
+			limiter.printSummary("metric events")
+			if client.errors > warmupErrors {
+				fmt.Printf("Failed to send %d packets\n", client.errors-warmupErrors)
+			}
+			if churn != nil {
+				fmt.Printf("Introduced %d churned series\n", churn.Count())
+			}
+			return errors.Join(delivery.finish(), report.finish(count, nil, 0))
+		case <-soak.tick():
+			soak.checkpoint(warmupCount + count)
+		case <-usage.exceeded():
+			bar.stop()
+			return usage.err()
+		case <-stats.tick():
+			stats.report(warmupCount + count)
+		case <-bar.tick():
+			bar.update(warmupCount + count)
+		case <-delivery.tick():
+			if delivery.check() {
+				timer.Reset(0)
+			}
+		case <-warmupC:
+			warmupCount, count = count, 0
+			load.Events.warmupEnded("metrics", warmupCount)
+			warmupLines, warmupPackets, warmupErrors = client.lines, client.packets, client.errors
+			endWarmup(limiter, nil, nil, nil)
+			report.endWarmup()
+		case n := <-limiter.C:
+			n, last := load.capCount(n, warmupCount+count)
+			now := time.Now()
+			for i := 0; i < n; i++ {
+				for _, tags := range hostTags {
+					client.Write("otelgen.requests", 1, "c", tags)
+					client.Write("otelgen.duration", rnd.Float64()*1000, "ms", tags)
+					client.Write("otelgen.cpu_usage", gauge.Value(now), "g", tags)
+				}
+				count++
+
+				if verbose && count%5 == 0 {
+					fmt.Printf("[VERBOSE] Generated %d metric events (%d statsd lines, %d packets)\n", count, client.lines, client.packets)
+				}
+			}
+			if churn != nil {
+				from, to := churn.due(now)
+				for i := from; i < to; i++ {
+					client.Write("otelgen.churn.requests", 1, "c", strings.Join(append(append([]string{}, baseTags...),
+						fmt.Sprintf("k8s.pod.name:otelgen-%s-%d", randomString(rnd, 5), i),
+						fmt.Sprintf("request.id:req-%s", randomString(rnd, 16)),
+					), ","))
+				}
+			}
+			client.Flush()
+			load.Progress.add(n)
+			load.Progress.written(client.packets+client.errors, client.errors)
+			if last {
+				timer.Reset(0)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package otelgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// StdoutWriter prints every export request as OTLP/JSON instead of sending it, for
+// inspecting what would be sent
+type StdoutWriter struct {
+	out    io.Writer
+	pretty bool
+
+	mu sync.Mutex
+}
+
+// NewStdoutWriter prints to standard output; format is pretty (indented, one request
+// after another) or json (one request per line, as the collector's file exporter writes)
+func NewStdoutWriter(format string) (*StdoutWriter, error) {
+	if format == "" {
+		format = "pretty"
+	}
+	if format != "pretty" && format != "json" {
+		return nil, fmt.Errorf("unsupported stdout format: %s (supported: pretty, json)", format)
+	}
+	return &StdoutWriter{out: os.Stdout, pretty: format == "pretty"}, nil
+}
+
+// WritePayload prints one export request; protobuf requests are converted to OTLP/JSON
+func (w *StdoutWriter) WritePayload(ctx context.Context, signal string, payload []byte) error {
+	// A protobuf request starts with a field tag, never '{'
+	if len(payload) == 0 || payload[0] != '{' {
+		msg := newRequest(signal)
+		if err := proto.Unmarshal(payload, msg); err != nil {
+			return fmt.Errorf("failed to decode %s request: %w", signal, err)
+		}
+		var err error
+		if payload, err = marshalOTLPJSON(msg); err != nil {
+			return fmt.Errorf("failed to encode request as JSON: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if w.pretty {
+		if err := json.Indent(&buf, payload, "", "  "); err != nil {
+			return fmt.Errorf("failed to format request: %w", err)
+		}
+	} else if err := json.Compact(&buf, payload); err != nil {
+		return fmt.Errorf("failed to format request: %w", err)
+	}
+	buf.WriteByte('\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.out.Write(buf.Bytes())
+	return err
+}
+
+// Close does nothing; standard output stays open
+func (w *StdoutWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,236 @@
+package otelgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogSDID names the structured data element carrying the record's attributes; 32473
+// is the private enterprise number reserved for documentation (RFC 5612)
+const syslogSDID = "otelgen@32473"
+
+// syslogFacility is user-level messages
+const syslogFacility = 1
+
+// SyslogOptions configures the syslog payload writer
+type SyslogOptions struct {
+	// Address is the syslog receiver, as host:port (default port 514, or 6514 for tls)
+	Address string
+	// Network is tcp, udp, or tls
+	Network string
+	// Framing is octet-counting or non-transparent (newline-terminated); it applies to
+	// tcp and tls, since each UDP datagram carries one message
+	Framing string
+	// AppName is the APP-NAME of every message; by default the record's service name
+	AppName string
+	// InsecureSkipVerify and TLSServerName apply to tls
+	InsecureSkipVerify bool
+	TLSServerName      string
+	// DialTimeout bounds each connection attempt
+	DialTimeout time.Duration
+}
+
+// SyslogWriter sends each log record as an RFC 5424 message
+type SyslogWriter struct {
+	framing  string
+	appName  string
+	hostname string
+	procID   string
+
+	mu   sync.Mutex
+	conn socketConn
+	buf  bytes.Buffer
+}
+
+// NewSyslogWriter connects to the syslog receiver
+func NewSyslogWriter(opts SyslogOptions) (*SyslogWriter, error) {
+	network := opts.Network
+	if network == "" {
+		network = "tcp"
+	}
+	port := "514"
+	switch network {
+	case "tcp", "udp":
+	case "tls":
+		port = "6514"
+	default:
+		return nil, fmt.Errorf("unsupported syslog transport: %s (supported: tcp, udp, tls)", network)
+	}
+	framing := opts.Framing
+	if framing == "" {
+		framing = "octet-counting"
+	}
+	if framing != "octet-counting" && framing != "non-transparent" {
+		return nil, fmt.Errorf("unsupported syslog framing: %s (supported: octet-counting, non-transparent)", framing)
+	}
+	address, err := withDefaultPort(opts.Address, port)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	w := &SyslogWriter{
+		framing:  framing,
+		appName:  opts.AppName,
+		hostname: syslogHeaderField(hostname, 255),
+		procID:   strconv.Itoa(os.Getpid()),
+		conn: socketConn{
+			network:     network,
+			address:     address,
+			tlsConfig:   TransportOptions{InsecureSkipVerify: opts.InsecureSkipVerify, TLSServerName: opts.TLSServerName}.tlsConfig(),
+			dialTimeout: opts.DialTimeout,
+		},
+	}
+	if err := w.conn.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WritePayload sends the records of an OTLP logs export request, together in one
+// write over tcp and tls, or one datagram each over udp
+func (w *SyslogWriter) WritePayload(ctx context.Context, signal string, payload []byte) error {
+	entries, err := decodeLogEntries(payload)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Reset()
+	for _, entry := range entries {
+		msg := w.format(entry)
+		if w.conn.network == "udp" {
+			if err := w.conn.write(ctx, msg); err != nil {
+				return err
+			}
+			continue
+		}
+		if w.framing == "octet-counting" {
+			w.buf.WriteString(strconv.Itoa(len(msg)))
+			w.buf.WriteByte(' ')
+			w.buf.Write(msg)
+		} else {
+			w.buf.Write(msg)
+			w.buf.WriteByte('\n')
+		}
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	return w.conn.write(ctx, w.buf.Bytes())
+}
+
+// format renders an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [STRUCTURED-DATA] MSG
+func (w *SyslogWriter) format(entry logEntry) []byte {
+	appName := w.appName
+	if appName == "" {
+		appName = entry.Service
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s - ",
+		syslogFacility*8+syslogSeverity(entry.Severity),
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		w.hostname,
+		syslogHeaderField(appName, 48),
+		w.procID,
+	)
+
+	if len(entry.Attributes) == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteString("[" + syslogSDID)
+		for _, attr := range entry.Attributes {
+			fmt.Fprintf(&b, " %s=\"%s\"", syslogParamName(attr.Key), syslogParamValue(fmt.Sprint(attr.Value)))
+		}
+		b.WriteByte(']')
+	}
+
+	if entry.Body != "" {
+		b.WriteByte(' ')
+		b.WriteString(entry.Body)
+	}
+	return b.Bytes()
+}
+
+// syslogSeverity maps a severity text to its syslog severity, notice for unknown ones
+func syslogSeverity(severity string) int {
+	switch strings.ToUpper(severity) {
+	case "FATAL":
+		return 2
+	case "ERROR":
+		return 3
+	case "WARN", "WARNING":
+		return 4
+	case "INFO":
+		return 6
+	case "DEBUG", "TRACE":
+		return 7
+	default:
+		return 5
+	}
+}
+
+// syslogHeaderField keeps a header field to printable ASCII without spaces, within
+// its length limit; empty fields are written as the nil value "-"
+func syslogHeaderField(s string, limit int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > limit {
+		s = s[:limit]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogParamName drops the characters an SD-PARAM name may not contain
+func syslogParamName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// syslogParamValue escapes '"', '\', and ']' in an SD-PARAM value
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// Close disconnects
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.close()
+}
+
+// Destination describes where the logs go, e.g. tls://syslog.example.com:6514
+func (w *SyslogWriter) Destination() string {
+	return w.conn.String()
+}
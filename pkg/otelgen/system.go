@@ -0,0 +1,171 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// systemMetrics reports the actual host's CPU, memory, disk, and network values.
+// Values are read at collection time, so Record is a no-op.
+type systemMetrics struct {
+	mu       sync.Mutex
+	lastCPU  *cpu.TimesStat
+	warnOnce sync.Once
+}
+
+func newSystemMetrics(meter metric.Meter) (*systemMetrics, error) {
+	m := &systemMetrics{}
+
+	cpuTime, err := meter.Float64ObservableCounter("system.cpu.time",
+		metric.WithDescription("Seconds each logical CPU spent on each mode"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.cpu.time: %w", err)
+	}
+	cpuUtil, err := meter.Float64ObservableGauge("system.cpu.utilization",
+		metric.WithDescription("Difference in system.cpu.time since the last measurement, divided by the elapsed time"), metric.WithUnit("1"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.cpu.utilization: %w", err)
+	}
+	loadAvg, err := meter.Float64ObservableGauge("system.cpu.load_average.1m",
+		metric.WithDescription("Average CPU load over the last minute"), metric.WithUnit("{thread}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.cpu.load_average.1m: %w", err)
+	}
+	memUsage, err := meter.Int64ObservableUpDownCounter("system.memory.usage",
+		metric.WithDescription("Reports memory in use by state"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.memory.usage: %w", err)
+	}
+	memUtil, err := meter.Float64ObservableGauge("system.memory.utilization",
+		metric.WithDescription("Fraction of memory in use by state"), metric.WithUnit("1"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.memory.utilization: %w", err)
+	}
+	diskIO, err := meter.Int64ObservableCounter("system.disk.io",
+		metric.WithDescription("Disk bytes transferred"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.disk.io: %w", err)
+	}
+	diskOps, err := meter.Int64ObservableCounter("system.disk.operations",
+		metric.WithDescription("Disk operations count"), metric.WithUnit("{operation}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.disk.operations: %w", err)
+	}
+	netIO, err := meter.Int64ObservableCounter("system.network.io",
+		metric.WithDescription("Network bytes transferred"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.network.io: %w", err)
+	}
+	netPackets, err := meter.Int64ObservableCounter("system.network.packets",
+		metric.WithDescription("Network packets transferred"), metric.WithUnit("{packet}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system.network.packets: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if times, err := cpu.TimesWithContext(ctx, false); err == nil && len(times) > 0 {
+			t := times[0]
+			modes := cpuModes(&t)
+			for mode, v := range modes {
+				o.ObserveFloat64(cpuTime, v, metric.WithAttributes(attribute.String("cpu.mode", mode)))
+			}
+			if m.lastCPU != nil {
+				last := cpuModes(m.lastCPU)
+				elapsed := t.Total() - m.lastCPU.Total()
+				if elapsed > 0 {
+					for mode, v := range modes {
+						o.ObserveFloat64(cpuUtil, (v-last[mode])/elapsed, metric.WithAttributes(attribute.String("cpu.mode", mode)))
+					}
+				}
+			}
+			m.lastCPU = &t
+		} else {
+			m.warn(err)
+		}
+
+		if avg, err := load.AvgWithContext(ctx); err == nil {
+			o.ObserveFloat64(loadAvg, avg.Load1)
+		}
+
+		if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil && vm.Total > 0 {
+			states := map[string]uint64{
+				"used":     vm.Used,
+				"free":     vm.Free,
+				"cached":   vm.Cached,
+				"buffered": vm.Buffers,
+			}
+			for state, v := range states {
+				attrs := metric.WithAttributes(attribute.String("system.memory.state", state))
+				o.ObserveInt64(memUsage, int64(v), attrs)
+				o.ObserveFloat64(memUtil, float64(v)/float64(vm.Total), attrs)
+			}
+		} else {
+			m.warn(err)
+		}
+
+		if counters, err := disk.IOCountersWithContext(ctx); err == nil {
+			for name, c := range counters {
+				read := metric.WithAttributes(attribute.String("system.device", name), attribute.String("disk.io.direction", "read"))
+				write := metric.WithAttributes(attribute.String("system.device", name), attribute.String("disk.io.direction", "write"))
+				o.ObserveInt64(diskIO, int64(c.ReadBytes), read)
+				o.ObserveInt64(diskIO, int64(c.WriteBytes), write)
+				o.ObserveInt64(diskOps, int64(c.ReadCount), read)
+				o.ObserveInt64(diskOps, int64(c.WriteCount), write)
+			}
+		}
+
+		if counters, err := net.IOCountersWithContext(ctx, true); err == nil {
+			for _, c := range counters {
+				rx := metric.WithAttributes(attribute.String("network.interface.name", c.Name), attribute.String("network.io.direction", "receive"))
+				tx := metric.WithAttributes(attribute.String("network.interface.name", c.Name), attribute.String("network.io.direction", "transmit"))
+				o.ObserveInt64(netIO, int64(c.BytesRecv), rx)
+				o.ObserveInt64(netIO, int64(c.BytesSent), tx)
+				o.ObserveInt64(netPackets, int64(c.PacketsRecv), rx)
+				o.ObserveInt64(netPackets, int64(c.PacketsSent), tx)
+			}
+		}
+
+		return nil
+	}, cpuTime, cpuUtil, loadAvg, memUsage, memUtil, diskIO, diskOps, netIO, netPackets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register system callback: %w", err)
+	}
+
+	return m, nil
+}
+
+// Record is a no-op; host values are read when the reader collects
+func (m *systemMetrics) Record(ctx context.Context) {}
+
+func (m *systemMetrics) warn(err error) {
+	if err == nil {
+		return
+	}
+	m.warnOnce.Do(func() {
+		fmt.Printf("Warning: Failed to read host metrics: %v\n", err)
+	})
+}
+
+func cpuModes(t *cpu.TimesStat) map[string]float64 {
+	return map[string]float64{
+		"user":      t.User,
+		"system":    t.System,
+		"idle":      t.Idle,
+		"nice":      t.Nice,
+		"iowait":    t.Iowait,
+		"interrupt": t.Irq + t.Softirq,
+		"steal":     t.Steal,
+	}
+}
@@ -0,0 +1,53 @@
+package otelgen
+
+import (
+	"fmt"
+	"time"
+)
+
+// throughputTarget steers the rate to send a target number of bytes per second: the
+// rate is the target divided by the average size of the events exported so far
+type throughputTarget struct {
+	bytesPerSecond float64
+	// itemsPerEvent is the spans per trace, or 1 for logs
+	itemsPerEvent float64
+	// itemSize is the estimated size of an item until the first export
+	itemSize float64
+	obs      *exportObserver
+	stats    targetStats
+	// warmupBytes is the bytes sent before the summary starts, after any warmup
+	warmupBytes int64
+}
+
+// newThroughputTarget sizes the rate from the observer's export bytes; payloadSize seeds
+// the item size estimate
+func newThroughputTarget(bytesPerSecond float64, itemsPerEvent float64, payloadSize int64, obs *exportObserver) *throughputTarget {
+	return &throughputTarget{
+		bytesPerSecond: bytesPerSecond,
+		itemsPerEvent:  itemsPerEvent,
+		itemSize:       float64(payloadSize) + 300,
+		obs:            obs,
+	}
+}
+
+// rate returns the events per second that sustain the target
+func (t *throughputTarget) rate() float64 {
+	size := t.itemSize
+	if items := t.stats.items.Load(); items > 0 {
+		size = float64(t.obs.bytes.Load()) / float64(items)
+	}
+	return t.bytesPerSecond / (size * t.itemsPerEvent)
+}
+
+// endWarmup leaves the bytes sent so far out of the summary
+func (t *throughputTarget) endWarmup() {
+	t.warmupBytes = t.obs.bytes.Load()
+}
+
+// printSummary prints the bytes sent and the achieved throughput over the run, or
+// over the part of it after the warmup
+func (t *throughputTarget) printSummary(duration time.Duration) {
+	sent := float64(t.obs.bytes.Load() - t.warmupBytes)
+	fmt.Printf("Sent %s of OTLP in %s (%s/s, target %s/s)\n",
+		formatBytes(sent), duration, formatBytes(sent/duration.Seconds()), formatBytes(t.bytesPerSecond))
+}
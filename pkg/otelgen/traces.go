@@ -2,9 +2,8 @@ package otelgen
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net"
 	"time"
 
@@ -12,43 +11,56 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 )
 
-// GenerateTraces generates trace data and sends it to the specified OTLP endpoint
-func GenerateTraces(endpoint *Endpoint, serviceName string, rate int, durationStr string, payloadSize int64, headers map[string]string, verbose bool, insecureSkip bool) error {
-	duration, err := time.ParseDuration(durationStr)
+// spanWrappers wrap span exporters for the monitors of a trace run
+var spanWrappers = exporterWrappers[sdktrace.SpanExporter]{
+	counting: func(e sdktrace.SpanExporter, stats *targetStats) sdktrace.SpanExporter {
+		return countingSpanExporter{SpanExporter: e, stats: stats}
+	},
+	timing: func(e sdktrace.SpanExporter, stats *probeStats) sdktrace.SpanExporter {
+		return timedSpanExporter{SpanExporter: e, stats: stats}
+	},
+	recording: func(e sdktrace.SpanExporter, self *selfTelemetry) sdktrace.SpanExporter {
+		return selfSpanExporter{SpanExporter: e, self: self}
+	},
+	deadLettering: func(e sdktrace.SpanExporter, dlq *deadLetterQueue) sdktrace.SpanExporter {
+		return deadLetterSpanExporter{SpanExporter: e, dlq: dlq}
+	},
+}
+
+// generateTraces generates trace data and sends it to the specified OTLP endpoint
+func generateTraces(parent context.Context, endpoint *Endpoint, serviceName string, rate float64, duration time.Duration, payloadSize int64, headers map[string]string, verbose bool, transport TransportOptions, load LoadOptions, result *Result) error {
+	duration = load.runDuration(duration)
+	rnd := load.newRandom()
+
+	run, err := newGeneratorRun(parent, "traces", endpoint, verbose, transport, load)
 	if err != nil {
-		return fmt.Errorf("invalid duration: %w", err)
+		return err
 	}
+	defer run.close()
+	ctx, endpoint := run.ctx, run.endpoint
 
-	ctx := context.Background()
-
-	// Create resource
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-		),
-	)
+	stamps := load.itemStamps()
+	res, err := run.newResource(serviceName, stamps)
 	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
+		return err
 	}
 
 	// Test network connectivity first
-	if verbose {
+	if run.exporterVerbose {
 		fmt.Printf("[VERBOSE] Testing network connectivity to %s...\n", endpoint.Address())
-		testCtx, testCancel := context.WithTimeout(ctx, 5*time.Second)
+		testCtx, testCancel := context.WithTimeout(parent, 5*time.Second)
 		defer testCancel()
 
 		dialer := &net.Dialer{}
-		conn, err := dialer.DialContext(testCtx, "tcp", endpoint.Address())
+		conn, err := dialer.DialContext(testCtx, "tcp", transport.resolvedAddress(endpoint.Address()))
 		if err != nil {
 			fmt.Printf("[VERBOSE] WARNING: Cannot establish TCP connection: %v\n", err)
 		} else {
@@ -57,103 +69,32 @@ func GenerateTraces(endpoint *Endpoint, serviceName string, rate int, durationSt
 		}
 	}
 
-	// Create exporter based on protocol
-	var exporter sdktrace.SpanExporter
-
-	// Use context with timeout for exporter creation
-	exporterCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	if endpoint.IsGRPC() {
-		opts := []otlptracegrpc.Option{
-			otlptracegrpc.WithEndpoint(endpoint.Address()),
-		}
-
-		if endpoint.Secure {
-			// Create TLS config with system cert pool
-			tlsConfig := &tls.Config{
-				InsecureSkipVerify: insecureSkip,
-				MinVersion:         tls.VersionTLS12,
-			}
-
-			if verbose {
-				fmt.Printf("[VERBOSE] Using TLS with system certs, InsecureSkipVerify=%v\n", insecureSkip)
-			}
-
-			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
-		} else {
-			if verbose {
-				fmt.Println("[VERBOSE] Using insecure gRPC connection")
-			}
-			opts = append(opts, otlptracegrpc.WithInsecure())
-		}
-
-		if len(headers) > 0 {
-			if verbose {
-				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
-			}
-			opts = append(opts, otlptracegrpc.WithHeaders(headers))
-		}
-
-		// Add gRPC dial options for better debugging and connection management
-		dialOpts := []grpc.DialOption{
-			grpc.WithKeepaliveParams(keepalive.ClientParameters{
-				Time:                10 * time.Second,
-				Timeout:             5 * time.Second,
-				PermitWithoutStream: true,
-			}),
-		}
+	// Partial success totals are printed once the final spans have been flushed
+	obs := run.newObserver(transport.retryEnabled() && !load.Fast)
+	defer obs.printSummary()
+	defer result.observe(obs)
 
-		if verbose {
-			fmt.Printf("[VERBOSE] Adding gRPC keepalive and timeout options\n")
+	// Adaptive pacing slows the rate whenever the observer sees the endpoint push back
+	var pacer *adaptivePacer
+	if load.Adaptive {
+		if transport.Writer != nil {
+			return fmt.Errorf("adaptive pacing needs an OTLP endpoint that can push back")
 		}
-
-		opts = append(opts, otlptracegrpc.WithDialOption(dialOpts...))
-
-		if verbose {
-			fmt.Printf("[VERBOSE] Creating gRPC trace exporter for %s\n", endpoint.Address())
-		}
-		exporter, err = otlptracegrpc.New(exporterCtx, opts...)
-	} else {
-		opts := []otlptracehttp.Option{
-			otlptracehttp.WithEndpoint(endpoint.Address()),
-		}
-
-		if !endpoint.Secure {
-			if verbose {
-				fmt.Println("[VERBOSE] Using insecure HTTP connection")
-			}
-			opts = append(opts, otlptracehttp.WithInsecure())
-		} else {
-			if verbose {
-				fmt.Printf("[VERBOSE] Using HTTPS with system certs, InsecureSkipVerify=%v\n", insecureSkip)
-			}
-			if insecureSkip {
-				opts = append(opts, otlptracehttp.WithTLSClientConfig(&tls.Config{
-					InsecureSkipVerify: true,
-					MinVersion:         tls.VersionTLS12,
-				}))
-			}
-		}
-
-		if len(headers) > 0 {
-			if verbose {
-				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
-			}
-			opts = append(opts, otlptracehttp.WithHeaders(headers))
-		}
-
-		if verbose {
-			fmt.Printf("[VERBOSE] Creating HTTP trace exporter for %s\n", endpoint.Address())
-		}
-		exporter, err = otlptracehttp.New(exporterCtx, opts...)
+		pacer = newAdaptivePacer("spans")
+		obs.pacer = pacer
 	}
 
+	targets, err := transport.targets(endpoint)
 	if err != nil {
-		return fmt.Errorf("failed to create trace exporter: %w", err)
+		return err
 	}
 
-	if verbose {
+	if verbose && !load.Fast {
+		// Create exporter based on protocol
+		exporter, err := newTraceExporter(parent, targets[0].endpoint, headers, targets[0].transport, obs, run.exporterVerbose)
+		if err != nil {
+			return fmt.Errorf("failed to create trace exporter: %w", err)
+		}
 		fmt.Println("[VERBOSE] Trace exporter created successfully")
 		fmt.Println("[VERBOSE] Attempting to export a test span to verify connectivity...")
 
@@ -187,149 +128,450 @@ func GenerateTraces(endpoint *Endpoint, serviceName string, rate int, durationSt
 		defer shutdownCancel()
 		testTP.Shutdown(shutdownCtx)
 
-		// Create a new exporter for actual trace generation since we used this one for testing
+		// Create new exporters for actual trace generation since we used this one for testing
 		fmt.Println("[VERBOSE] Creating new exporter for trace generation...")
-		exporterCtx2, cancel2 := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel2()
+	}
 
-		if endpoint.IsGRPC() {
-			opts := []otlptracegrpc.Option{
-				otlptracegrpc.WithEndpoint(endpoint.Address()),
-			}
+	// Fast mode builds requests directly and sends them over a raw client instead of
+	// the exporters; the wrappers below apply to whichever is in use
+	var exporters []sdktrace.SpanExporter
+	var owners []*target
+	var raw *rawClient
+	var provided bool
+	if load.Fast {
+		if raw, err = newRawClient(targets, headers, transport, obs); err != nil {
+			return err
+		}
+		defer raw.Close()
+		if verbose {
+			fmt.Println("[VERBOSE] Fast mode: building export requests directly, without retries")
+			transport.describeTargets(targets, len(raw.shards), "spans")
+			fmt.Println()
+		}
+	} else {
+		exporters, owners, err = openTargets(targets, transport.connections(), false, func(tg *target, verbose bool) (sdktrace.SpanExporter, error) {
+			return newTraceExporter(parent, tg.endpoint, headers, tg.transport, obs, verbose)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create trace exporter: %w", err)
+		}
+		if verbose {
+			transport.describeTargets(targets, len(exporters), "spans")
+			fmt.Println()
+		}
+		defer closeTargets(exporters, &provided)()
+	}
 
-			if endpoint.Secure {
-				tlsConfig := &tls.Config{
-					InsecureSkipVerify: insecureSkip,
-					MinVersion:         tls.VersionTLS12,
-				}
-				opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
-			} else {
-				opts = append(opts, otlptracegrpc.WithInsecure())
-			}
+	// Per-target totals are printed once the final spans have been flushed
+	if len(targets) > 1 {
+		for i, e := range exporters {
+			exporters[i] = spanWrappers.counting(e, &owners[i].stats)
+		}
+		defer printTargetStats(targets, "traces")
+	}
 
-			if len(headers) > 0 {
-				opts = append(opts, otlptracegrpc.WithHeaders(headers))
-			}
+	// The monitors watch every export
+	mon, err := run.newMonitors("traces", duration, serviceName, obs, verbose)
+	if err != nil {
+		return err
+	}
+	defer mon.stop()
+	spanWrappers.monitor(exporters, raw, mon)
+
+	// Searching for the maximum rate times every export
+	var search *rateSearch
+	if load.FindMax != nil {
+		if search, err = load.newRateSearch(rate, "traces", "spans"); err != nil {
+			return err
+		}
+		spanWrappers.time(exporters, &search.stats)
+		raw.time(&search.stats)
+	}
 
-			dialOpts := []grpc.DialOption{
-				grpc.WithKeepaliveParams(keepalive.ClientParameters{
-					Time:                10 * time.Second,
-					Timeout:             5 * time.Second,
-					PermitWithoutStream: true,
-				}),
-			}
-			opts = append(opts, otlptracegrpc.WithDialOption(dialOpts...))
+	// A throughput target sizes the rate from the spans exported so far
+	var tput *throughputTarget
+	if load.Throughput > 0 {
+		tput = newThroughputTarget(load.Throughput, spansPerTrace, payloadSize, obs)
+		spanWrappers.count(exporters, &tput.stats)
+		raw.count(&tput.stats)
+		defer tput.printSummary(duration - load.Warmup)
+	}
 
-			exporter, err = otlptracegrpc.New(exporterCtx2, opts...)
-		} else {
-			opts := []otlptracehttp.Option{
-				otlptracehttp.WithEndpoint(endpoint.Address()),
-			}
+	// Adaptive pacing compares the spans offered to the endpoint with those it accepted
+	if pacer != nil {
+		spanWrappers.count(exporters, &pacer.stats)
+		raw.count(&pacer.stats)
+		defer pacer.printSummary(duration - load.Warmup)
+	}
 
-			if !endpoint.Secure {
-				opts = append(opts, otlptracehttp.WithInsecure())
-			} else if insecureSkip {
-				opts = append(opts, otlptracehttp.WithTLSClientConfig(&tls.Config{
-					InsecureSkipVerify: true,
-					MinVersion:         tls.VersionTLS12,
-				}))
-			}
+	// Exports that fail for good are kept in the dead-letter directory, if any
+	dlq, err := transport.newDeadLetterQueue("traces")
+	if err != nil {
+		return err
+	}
+	if dlq != nil {
+		spanWrappers.deadLetter(exporters, raw, dlq)
+		defer dlq.printSummary()
+	}
 
-			if len(headers) > 0 {
-				opts = append(opts, otlptracehttp.WithHeaders(headers))
+	// emit generates a trace, and flush sends every span generated so far
+	var emit func()
+	var flush func(context.Context) error
+	if raw != nil {
+		fast := newFastTraceBatcher(raw, res, 512, verbose)
+		defer fast.shutdown()
+		nextTrace := pooled(load.PayloadPool, func() fastTrace { return newFastTrace(newTraceShape(rnd, payloadSize)) }, "trace", verbose)
+		emit = func() {
+			var buf [4]*tracepb.Span
+			now := time.Now()
+			spans := nextTrace().appendSpans(rnd, buf[:0], now)
+			if stamps != nil {
+				stamps.stampSpans(spans, now)
 			}
-
-			exporter, err = otlptracehttp.New(exporterCtx2, opts...)
+			if search != nil {
+				search.stats.ended.Add(int64(len(spans)))
+			}
+			fast.add(spans...)
 		}
+		flush = fast.flush
+	} else {
+		// Create trace provider with configurable timeouts
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithSpanProcessor(newSpanProcessor(exporters, transport.newBalancer(owners),
+				sdktrace.WithBatchTimeout(2*time.Second),
+				sdktrace.WithExportTimeout(transport.exportTimeout()),
+				sdktrace.WithMaxExportBatchSize(512),
+			)),
+			sdktrace.WithResource(res),
+			sdktrace.WithIDGenerator(randomIDs{rnd}),
+		)
+		provided = true
+		defer func() {
+			// Give it time to flush remaining spans
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(shutdownCtx); err != nil {
+				fmt.Printf("Error shutting down trace provider: %v\n", err)
+			}
+		}()
 
-		if err != nil {
-			return fmt.Errorf("failed to create new trace exporter: %w", err)
+		otel.SetTracerProvider(tp)
+		tracer := tp.Tracer("otelgen")
+		if search != nil {
+			tp.RegisterSpanProcessor(endedSpans{stats: &search.stats})
 		}
 
-		fmt.Println()
+		// Span attributes are drawn for every trace, or cycled from a pool drawn up front
+		nextShape := pooled(load.PayloadPool, func() traceShape { return newTraceShape(rnd, payloadSize) }, "trace", verbose)
+		emit = func() {
+			if err := generateTrace(ctx, rnd, tracer, nextShape(), stamps); err != nil {
+				fmt.Printf("Error generating trace: %v\n", err)
+			}
+		}
+		flush = tp.ForceFlush
 	}
 
-	defer exporter.Shutdown(ctx)
-
-	// Create trace provider with configurable timeouts
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter,
-			sdktrace.WithBatchTimeout(2*time.Second),
-			sdktrace.WithExportTimeout(30*time.Second), // Increased timeout for slow connections
-			sdktrace.WithMaxExportBatchSize(512),
-		),
-		sdktrace.WithResource(res),
-	)
-	defer func() {
-		// Give it time to flush remaining spans
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := tp.Shutdown(shutdownCtx); err != nil {
-			fmt.Printf("Error shutting down trace provider: %v\n", err)
+	if search != nil {
+		if verbose {
+			search.describe()
 		}
-	}()
+		count := search.run(emit, flush, transport.exportTimeout())
+		fmt.Printf("Generated %d traces\n", count)
+		return nil
+	}
 
-	otel.SetTracerProvider(tp)
-	tracer := tp.Tracer("otelgen")
+	// Events are emitted on a pool of workers, if asked
+	workers, err := load.newEmitPool(emit)
+	if err != nil {
+		return err
+	}
+	defer workers.stop()
 
 	// Generate traces
-	ticker := time.NewTicker(time.Second / time.Duration(rate))
-	defer ticker.Stop()
+	limiter, err := load.limiter(rate, duration, tput, pacer)
+	if err != nil {
+		return err
+	}
+	defer limiter.stop()
+	if verbose {
+		limiter.describe()
+	}
 
 	timer := time.NewTimer(duration)
 	defer timer.Stop()
-
-	count := 0
+	defer load.endOnStop(timer)()
+	mon.soak.begin(verbose)
+	mon.stats.begin(verbose)
+	mon.report.begin()
+	result.begin()
+	mon.delivery.begin()
+	load.Events.start("traces", duration, limiter)
+	bar := load.newProgressBar("traces", duration)
+	bar.begin(limiter)
+	defer bar.stop()
+
+	// Nothing sent during the warmup is counted in the summary
+	warmupC, stopWarmup := load.warmupTimer()
+	defer stopWarmup()
+
+	count, warmupCount := 0, 0
 	for {
 		select {
 		case <-timer.C:
+			bar.stop()
+			workers.stop()
+			load.Events.end("traces", count, warmupCount)
+			result.generated(count)
 			fmt.Printf("Generated %d traces\n", count)
-			return nil
-		case <-ticker.C:
-			if err := generateTrace(ctx, tracer, payloadSize); err != nil {
-				fmt.Printf("Error generating trace: %v\n", err)
+			load.printWarmup(warmupCount, "traces")
+			limiter.printSummary("traces")
+			// Delivery is judged as the window ends, before the final flush
+			return errors.Join(mon.delivery.finish(), mon.report.finish(count, flush, transport.exportTimeout()))
+		case <-mon.soak.tick():
+			mon.soak.checkpoint(warmupCount + count)
+		case <-run.usage.exceeded():
+			bar.stop()
+			return run.usage.err()
+		case <-mon.stats.tick():
+			mon.stats.report(warmupCount + count)
+		case <-bar.tick():
+			bar.update(warmupCount + count)
+		case <-mon.delivery.tick():
+			if mon.delivery.check() {
+				timer.Reset(0)
+			}
+		case <-warmupC:
+			warmupCount, count = count, 0
+			load.Events.warmupEnded("traces", warmupCount)
+			endWarmup(limiter, obs, targets, tput)
+			pacer.endWarmup()
+			mon.report.endWarmup()
+			result.begin()
+		case n := <-limiter.C:
+			n, last := load.capCount(n, warmupCount+count)
+			workers.emit(n, emit)
+			count += n
+			load.Progress.add(n)
+			if last {
+				timer.Reset(0)
 			}
-			count++
 		}
 	}
 }
 
-func generateTrace(ctx context.Context, tracer trace.Tracer, payloadSize int64) error {
-	// Create attributes list
-	attrs := []attribute.KeyValue{
-		attribute.String("operation.type", "http"),
-		attribute.Int("operation.id", rand.Intn(1000)),
+// newTraceExporter creates an OTLP trace exporter for the endpoint's protocol
+func newTraceExporter(ctx context.Context, endpoint *Endpoint, headers map[string]string, transport TransportOptions, obs *exportObserver, verbose bool) (sdktrace.SpanExporter, error) {
+	// Use context with timeout for exporter creation
+	exporterCtx, cancel := context.WithTimeout(ctx, transport.connectTimeout())
+	defer cancel()
+
+	if err := transport.validate(endpoint); err != nil {
+		return nil, err
 	}
+	if c := transport.compressor(); c != "" && verbose {
+		fmt.Printf("[VERBOSE] Using %s compression\n", c)
+	}
+
+	auth, err := transport.newAuthorization()
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil && verbose {
+		fmt.Println("[VERBOSE] Adding Authorization header to every export")
+	}
+	headers, dynamic, err := transport.newRequestHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	if dynamic != nil && verbose {
+		dynamic.describe()
+	}
+	if verbose {
+		transport.describeRetry()
+	}
+
+	if endpoint.IsGRPC() {
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(endpoint.Address()),
+		}
+
+		if endpoint.Secure {
+			if verbose {
+				transport.describeTLS("TLS")
+			}
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(transport.tlsConfig())))
+		} else {
+			if verbose {
+				fmt.Println("[VERBOSE] Using insecure gRPC connection")
+			}
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		if len(headers) > 0 {
+			if verbose {
+				fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
+			}
+			opts = append(opts, otlptracegrpc.WithHeaders(headers))
+		}
+
+		// Add gRPC dial options for better debugging and connection management
+		dialOpts := []grpc.DialOption{
+			grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                10 * time.Second,
+				Timeout:             5 * time.Second,
+				PermitWithoutStream: true,
+			}),
+		}
+		dialOpts = append(dialOpts, transport.dialOptions(endpoint, auth, dynamic, obs)...)
+
+		if verbose {
+			fmt.Printf("[VERBOSE] Adding gRPC keepalive and timeout options\n")
+		}
+
+		opts = append(opts, otlptracegrpc.WithDialOption(dialOpts...))
+
+		if transport.Retry != nil {
+			opts = append(opts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(*transport.Retry)))
+		}
+
+		opts = append(opts, otlptracegrpc.WithTimeout(transport.grpcTimeout()))
+
+		if verbose {
+			fmt.Printf("[VERBOSE] Creating gRPC trace exporter for %s\n", endpoint.Address())
+		}
+		return otlptracegrpc.New(exporterCtx, opts...)
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Address()),
+	}
+
+	if !endpoint.Secure {
+		if verbose {
+			fmt.Println("[VERBOSE] Using insecure HTTP connection")
+		}
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else {
+		if verbose {
+			transport.describeTLS("HTTPS")
+		}
+	}
+
+	if len(headers) > 0 {
+		if verbose {
+			fmt.Printf("[VERBOSE] Adding headers: %v\n", headers)
+		}
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+
+	if transport.compressor() == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+
+	if transport.HTTPPath != "" {
+		if verbose {
+			fmt.Printf("[VERBOSE] Using URL path %s\n", transport.HTTPPath)
+		}
+		opts = append(opts, otlptracehttp.WithURLPath(transport.HTTPPath))
+	}
+
+	if transport.Retry != nil {
+		opts = append(opts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig(*transport.Retry)))
+	}
+
+	opts = append(opts, otlptracehttp.WithTimeout(transport.exportTimeout()))
+
+	// The client carries the TLS settings and credentials, and observes the responses
+	opts = append(opts, otlptracehttp.WithHTTPClient(transport.httpClient(endpoint, auth, dynamic, obs)))
+
+	if verbose {
+		fmt.Printf("[VERBOSE] Creating HTTP trace exporter for %s\n", endpoint.Address())
+	}
+	return otlptracehttp.New(exporterCtx, opts...)
+}
+
+// spansPerTrace is the average number of spans generateTrace creates: a parent and
+// one to three children
+const spansPerTrace = 3
+
+// childOperations names the child spans, which are at most three
+var childOperations = [...]string{"child-operation-0", "child-operation-1", "child-operation-2"}
+
+// spanShape is the name and attributes of one span of a trace
+type spanShape struct {
+	name  string
+	attrs []attribute.KeyValue
+}
+
+// traceShape is the spans of a trace: a parent and its children
+type traceShape struct {
+	parent   spanShape
+	children []spanShape
+}
+
+// newTraceShape draws the spans of a trace and their attributes
+func newTraceShape(rnd *runRandom, payloadSize int64) traceShape {
+	// Create attributes list, with room for the padding
+	attrs := make([]attribute.KeyValue, 2, 3)
+	attrs[0] = attribute.String("operation.type", "http")
+	attrs[1] = attribute.Int("operation.id", rnd.Intn(1000))
 
 	// Add padding attribute if size is specified
 	if payloadSize > 0 {
 		attrs = append(attrs, attribute.String("payload.data", GeneratePadding(payloadSize)))
 	}
-
-	// Create a parent span
-	ctx, span := tracer.Start(ctx, "parent-operation",
-		trace.WithAttributes(attrs...))
-	defer span.End()
-
-	// Simulate some work
-	time.Sleep(time.Millisecond * time.Duration(rand.Intn(100)))
+	shape := traceShape{parent: spanShape{name: "parent-operation", attrs: attrs}}
 
 	// Create child spans
-	for i := 0; i < rand.Intn(3)+1; i++ {
-		childAttrs := []attribute.KeyValue{
-			attribute.String("child.type", "db"),
-			attribute.Int("child.id", i),
-		}
+	children := rnd.Intn(3) + 1
+	shape.children = make([]spanShape, 0, children)
+	for i := 0; i < children; i++ {
+		childAttrs := make([]attribute.KeyValue, 2, 3)
+		childAttrs[0] = attribute.String("child.type", "db")
+		childAttrs[1] = attribute.Int("child.id", i)
 
 		// Add padding to child spans as well if size is specified
 		if payloadSize > 0 {
 			childAttrs = append(childAttrs, attribute.String("payload.data", GeneratePadding(payloadSize)))
 		}
+		shape.children = append(shape.children, spanShape{name: childOperations[i], attrs: childAttrs})
+	}
+	return shape
+}
+
+// generateTrace emits a trace of the shape, stamping each span as it ends if stamps
+// are set
+func generateTrace(ctx context.Context, rnd *runRandom, tracer trace.Tracer, shape traceShape, stamps *itemStamps) error {
+	// The spans are backdated rather than slept through, so their simulated work
+	// doesn't hold up the next trace. The parent works before its children start,
+	// and ends after the last of them; child i runs from offsets[i] to offsets[i+1]
+	n := 1 + len(shape.children)
+	offsets := append(make([]time.Duration, 0, n+1), 0, time.Millisecond*time.Duration(rnd.Intn(100)))
+	for i := 2; i <= n; i++ {
+		offsets = append(offsets, offsets[i-1]+time.Millisecond*time.Duration(rnd.Intn(50)))
+	}
+	now := time.Now()
+	start := now.Add(-offsets[n])
+
+	// Create a parent span
+	ctx, span := tracer.Start(ctx, shape.parent.name,
+		trace.WithAttributes(shape.parent.attrs...),
+		trace.WithTimestamp(start))
+	defer func() {
+		if stamps != nil {
+			span.SetAttributes(stamps.spanAttributes(time.Now())...)
+		}
+		span.End(trace.WithTimestamp(now))
+	}()
 
-		_, childSpan := tracer.Start(ctx, fmt.Sprintf("child-operation-%d", i),
-			trace.WithAttributes(childAttrs...))
-		time.Sleep(time.Millisecond * time.Duration(rand.Intn(50)))
-		childSpan.End()
+	for i, child := range shape.children {
+		_, childSpan := tracer.Start(ctx, child.name,
+			trace.WithAttributes(child.attrs...),
+			trace.WithTimestamp(start.Add(offsets[i+1])))
+		if stamps != nil {
+			childSpan.SetAttributes(stamps.spanAttributes(time.Now())...)
+		}
+		childSpan.End(trace.WithTimestamp(start.Add(offsets[i+2])))
 	}
 
 	return nil
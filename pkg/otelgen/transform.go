@@ -0,0 +1,183 @@
+package otelgen
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// resourceMetricsToProto converts collected SDK metrics to their OTLP representation
+func resourceMetricsToProto(rm *metricdata.ResourceMetrics) *metricspb.ResourceMetrics {
+	out := &metricspb.ResourceMetrics{
+		Resource:     &resourcepb.Resource{Attributes: attributesToProto(rm.Resource.Attributes())},
+		ScopeMetrics: make([]*metricspb.ScopeMetrics, 0, len(rm.ScopeMetrics)),
+		SchemaUrl:    rm.Resource.SchemaURL(),
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		scope := &metricspb.ScopeMetrics{
+			Scope: &commonpb.InstrumentationScope{
+				Name:    sm.Scope.Name,
+				Version: sm.Scope.Version,
+			},
+			SchemaUrl: sm.Scope.SchemaURL,
+		}
+		for _, m := range sm.Metrics {
+			if pm := metricToProto(m); pm != nil {
+				scope.Metrics = append(scope.Metrics, pm)
+			}
+		}
+		out.ScopeMetrics = append(out.ScopeMetrics, scope)
+	}
+
+	return out
+}
+
+// metricToProto converts one SDK metric, or returns nil for unsupported aggregations
+func metricToProto(m metricdata.Metrics) *metricspb.Metric {
+	out := &metricspb.Metric{
+		Name:        m.Name,
+		Description: m.Description,
+		Unit:        m.Unit,
+	}
+
+	switch d := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		out.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: numberPoints(d.DataPoints)}}
+	case metricdata.Gauge[float64]:
+		out.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: numberPoints(d.DataPoints)}}
+	case metricdata.Sum[int64]:
+		out.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: temporalityToProto(d.Temporality),
+			IsMonotonic:            d.IsMonotonic,
+			DataPoints:             numberPoints(d.DataPoints),
+		}}
+	case metricdata.Sum[float64]:
+		out.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: temporalityToProto(d.Temporality),
+			IsMonotonic:            d.IsMonotonic,
+			DataPoints:             numberPoints(d.DataPoints),
+		}}
+	case metricdata.Histogram[int64]:
+		out.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: temporalityToProto(d.Temporality),
+			DataPoints:             histogramPoints(d.DataPoints),
+		}}
+	case metricdata.Histogram[float64]:
+		out.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: temporalityToProto(d.Temporality),
+			DataPoints:             histogramPoints(d.DataPoints),
+		}}
+	case metricdata.ExponentialHistogram[int64]:
+		out.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			AggregationTemporality: temporalityToProto(d.Temporality),
+			DataPoints:             exponentialHistogramPoints(d.DataPoints),
+		}}
+	case metricdata.ExponentialHistogram[float64]:
+		out.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			AggregationTemporality: temporalityToProto(d.Temporality),
+			DataPoints:             exponentialHistogramPoints(d.DataPoints),
+		}}
+	default:
+		return nil
+	}
+
+	return out
+}
+
+func numberPoints[N int64 | float64](points []metricdata.DataPoint[N]) []*metricspb.NumberDataPoint {
+	out := make([]*metricspb.NumberDataPoint, 0, len(points))
+	for _, p := range points {
+		dp := &metricspb.NumberDataPoint{
+			Attributes:        setToProto(p.Attributes),
+			StartTimeUnixNano: unixNano(p.StartTime),
+			TimeUnixNano:      unixNano(p.Time),
+		}
+		switch v := any(p.Value).(type) {
+		case int64:
+			dp.Value = &metricspb.NumberDataPoint_AsInt{AsInt: v}
+		case float64:
+			dp.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: v}
+		}
+		out = append(out, dp)
+	}
+	return out
+}
+
+func histogramPoints[N int64 | float64](points []metricdata.HistogramDataPoint[N]) []*metricspb.HistogramDataPoint {
+	out := make([]*metricspb.HistogramDataPoint, 0, len(points))
+	for _, p := range points {
+		sum := float64(p.Sum)
+		dp := &metricspb.HistogramDataPoint{
+			Attributes:        setToProto(p.Attributes),
+			StartTimeUnixNano: unixNano(p.StartTime),
+			TimeUnixNano:      unixNano(p.Time),
+			Count:             p.Count,
+			Sum:               &sum,
+			ExplicitBounds:    p.Bounds,
+			BucketCounts:      p.BucketCounts,
+		}
+		if v, ok := p.Min.Value(); ok {
+			min := float64(v)
+			dp.Min = &min
+		}
+		if v, ok := p.Max.Value(); ok {
+			max := float64(v)
+			dp.Max = &max
+		}
+		out = append(out, dp)
+	}
+	return out
+}
+
+func exponentialHistogramPoints[N int64 | float64](points []metricdata.ExponentialHistogramDataPoint[N]) []*metricspb.ExponentialHistogramDataPoint {
+	out := make([]*metricspb.ExponentialHistogramDataPoint, 0, len(points))
+	for _, p := range points {
+		sum := float64(p.Sum)
+		dp := &metricspb.ExponentialHistogramDataPoint{
+			Attributes:        setToProto(p.Attributes),
+			StartTimeUnixNano: unixNano(p.StartTime),
+			TimeUnixNano:      unixNano(p.Time),
+			Count:             p.Count,
+			Sum:               &sum,
+			Scale:             p.Scale,
+			ZeroCount:         p.ZeroCount,
+			ZeroThreshold:     p.ZeroThreshold,
+			Positive: &metricspb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       p.PositiveBucket.Offset,
+				BucketCounts: p.PositiveBucket.Counts,
+			},
+			Negative: &metricspb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       p.NegativeBucket.Offset,
+				BucketCounts: p.NegativeBucket.Counts,
+			},
+		}
+		if v, ok := p.Min.Value(); ok {
+			min := float64(v)
+			dp.Min = &min
+		}
+		if v, ok := p.Max.Value(); ok {
+			max := float64(v)
+			dp.Max = &max
+		}
+		out = append(out, dp)
+	}
+	return out
+}
+
+func temporalityToProto(t metricdata.Temporality) metricspb.AggregationTemporality {
+	switch t {
+	case metricdata.DeltaTemporality:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case metricdata.CumulativeTemporality:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	default:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+	}
+}
+
+func setToProto(set attribute.Set) []*commonpb.KeyValue {
+	return attributesToProto(set.ToSlice())
+}
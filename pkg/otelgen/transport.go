@@ -0,0 +1,319 @@
+package otelgen
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	// defaultConnectTimeout bounds exporter creation and each connection attempt
+	defaultConnectTimeout = 10 * time.Second
+	// defaultExportTimeout bounds each export
+	defaultExportTimeout = 30 * time.Second
+)
+
+// TransportOptions holds the connection settings shared by all exporters
+type TransportOptions struct {
+	// InsecureSkipVerify skips TLS certificate verification
+	InsecureSkipVerify bool
+	// TLSServerName is sent as SNI and checked against the server certificate instead of the endpoint host
+	TLSServerName string
+	// BearerToken is sent as "Authorization: Bearer <token>" on every export
+	BearerToken string
+	// BearerTokenFile holds the bearer token; it is re-read when the file changes
+	BearerTokenFile string
+	// BasicAuth is "user:password", sent as a Basic Authorization header on every export
+	BasicAuth string
+	// OAuth2TokenURL enables the OAuth2 client-credentials flow against this token endpoint
+	OAuth2TokenURL string
+	// OAuth2ClientID is the client ID for the client-credentials flow
+	OAuth2ClientID string
+	// OAuth2ClientSecret is the client secret for the client-credentials flow
+	OAuth2ClientSecret string
+	// OAuth2Scopes are the scopes requested with each token
+	OAuth2Scopes []string
+	// HMACSecret signs every export with HMAC-SHA256 over the request body, sent as
+	// "sha256=<hex>" in HMACHeader
+	HMACSecret string
+	// HMACHeader is the header carrying the signature (default X-Signature)
+	HMACHeader string
+	// Compression is the export compression: none, gzip, or zstd (gRPC only)
+	Compression string
+	// HTTPVersion forces HTTP/1.1 ("1.1") or HTTP/2 ("2") for HTTP endpoints; by
+	// default HTTP/2 is negotiated over TLS and HTTP/1.1 is used in cleartext
+	HTTPVersion string
+	// Encoding is the HTTP export encoding: protobuf or json (OTLP/JSON)
+	Encoding string
+	// HTTPPath replaces the signal's default /v1/<signal> URL path for HTTP endpoints
+	HTTPPath string
+	// GRPCMaxMessageSize is the largest gRPC message sent or received, in bytes (gRPC endpoints only)
+	GRPCMaxMessageSize int64
+	// GRPCTimeout is the deadline for each gRPC export call (gRPC endpoints only)
+	GRPCTimeout time.Duration
+	// ConnectTimeout bounds exporter creation and each connection attempt (default 10s)
+	ConnectTimeout time.Duration
+	// ExportTimeout bounds each export, including its retries (default 30s)
+	ExportTimeout time.Duration
+	// Resolve pins endpoint host:port pairs to the IP addresses to dial instead, keeping
+	// the host for TLS and the Host header, like curl's --resolve; exports are balanced
+	// across the addresses when there are several
+	Resolve map[string][]string
+	// Endpoints are more endpoints that exports are balanced across, along with the
+	// exporter's own
+	Endpoints []*Endpoint
+	// LBStrategy balances exports across endpoints and resolved addresses: round-robin
+	// (default), random, or weighted
+	LBStrategy string
+	// LBWeights are each target's weight for the weighted strategy, in order: the
+	// endpoints in order, each expanded into its resolved addresses
+	LBWeights []int
+	// Connections is the number of independent gRPC channels or HTTP clients that
+	// exports are spread across round-robin (default 1)
+	Connections int
+	// Writer, when set, receives every export request instead of the endpoint
+	Writer PayloadWriter
+	// Dialer, when set, opens every connection to the endpoint in place of the network,
+	// such as the in-memory connections of otelgentest's collector
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+	// Retry overrides the exporters' default retry policy when set
+	Retry *RetryOptions
+	// DeadLetterDir, when set, keeps every export request that failed for good, to be
+	// re-sent later with RetryDeadLetters
+	DeadLetterDir string
+	// ExportLog, when set, receives a line of JSON for every export attempt: a file to
+	// append to, or "-" for stdout
+	ExportLog string
+}
+
+// validate checks the settings that are not checked when parsing flags
+func (t TransportOptions) validate(endpoint *Endpoint) error {
+	switch t.Compression {
+	case "", "none", "gzip":
+	case "zstd":
+		if !endpoint.IsGRPC() {
+			return fmt.Errorf("zstd compression is only supported for gRPC endpoints")
+		}
+	default:
+		return fmt.Errorf("unknown compression %q (supported: none, gzip, zstd)", t.Compression)
+	}
+	if (t.GRPCMaxMessageSize > 0 || t.GRPCTimeout > 0) && !endpoint.IsGRPC() {
+		return fmt.Errorf("gRPC message size and timeout can only be used with gRPC endpoints")
+	}
+	if t.ConnectTimeout < 0 || t.ExportTimeout < 0 {
+		return fmt.Errorf("connect and export timeouts cannot be negative")
+	}
+	if t.Connections < 0 {
+		return fmt.Errorf("connections cannot be negative")
+	}
+	if t.GRPCMaxMessageSize > math.MaxInt32 {
+		return fmt.Errorf("gRPC max message size cannot exceed %d bytes", math.MaxInt32)
+	}
+	if r := t.Retry; r != nil && r.Enabled {
+		if r.InitialInterval <= 0 || r.MaxInterval < r.InitialInterval {
+			return fmt.Errorf("retry intervals must be positive, with the max interval at least the initial interval")
+		}
+		if r.MaxElapsedTime < 0 {
+			return fmt.Errorf("retry max elapsed time cannot be negative")
+		}
+	}
+	switch t.Encoding {
+	case "", "protobuf":
+	case "json":
+		if !endpoint.IsHTTP() {
+			return fmt.Errorf("JSON encoding is only supported for HTTP endpoints")
+		}
+	default:
+		return fmt.Errorf("unknown encoding %q (supported: protobuf, json)", t.Encoding)
+	}
+	switch t.HTTPVersion {
+	case "":
+	case "1.1", "2":
+		if !endpoint.IsHTTP() {
+			return fmt.Errorf("an HTTP version can only be used with HTTP endpoints")
+		}
+	default:
+		return fmt.Errorf("unknown HTTP version %q (supported: 1.1, 2)", t.HTTPVersion)
+	}
+	if t.HTTPPath != "" {
+		if !endpoint.IsHTTP() {
+			return fmt.Errorf("an HTTP path can only be used with HTTP endpoints")
+		}
+		if !strings.HasPrefix(t.HTTPPath, "/") {
+			return fmt.Errorf("HTTP path %q must start with /", t.HTTPPath)
+		}
+	}
+	return nil
+}
+
+// connectTimeout returns the connect timeout, or the default when unset
+func (t TransportOptions) connectTimeout() time.Duration {
+	if t.ConnectTimeout > 0 {
+		return t.ConnectTimeout
+	}
+	return defaultConnectTimeout
+}
+
+// exportTimeout returns the export timeout, or the default when unset
+func (t TransportOptions) exportTimeout() time.Duration {
+	if t.ExportTimeout > 0 {
+		return t.ExportTimeout
+	}
+	return defaultExportTimeout
+}
+
+// grpcTimeout returns the deadline for each gRPC export call; --grpc-timeout
+// overrides the export timeout
+func (t TransportOptions) grpcTimeout() time.Duration {
+	if t.GRPCTimeout > 0 {
+		return t.GRPCTimeout
+	}
+	return t.exportTimeout()
+}
+
+// dialOptions returns the gRPC options for connect timeout, resolve overrides,
+// authorization, per-export headers, compression, message size, and request and
+// response observation; the exporters' WithCompressor only knows gzip, so compression
+// is set as a call option
+func (t TransportOptions) dialOptions(endpoint *Endpoint, auth authorization, dynamic *requestHeaders, obs *exportObserver) []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: t.connectTimeout(),
+		}),
+		grpc.WithChainUnaryInterceptor(obs.interceptor()),
+		grpc.WithStatsHandler(observedStats{obs}),
+	}
+	if opt, ok := t.resolverOption(endpoint); ok {
+		opts = append(opts, opt)
+	}
+	if t.Dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(t.Dialer))
+	}
+	if auth != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(perRPCAuthorization{auth}))
+	}
+	if dynamic != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(dynamic.interceptor()))
+	}
+
+	var callOpts []grpc.CallOption
+	if c := t.compressor(); c != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(c))
+	}
+	if t.GRPCMaxMessageSize > 0 {
+		callOpts = append(callOpts,
+			grpc.MaxCallSendMsgSize(int(t.GRPCMaxMessageSize)),
+			grpc.MaxCallRecvMsgSize(int(t.GRPCMaxMessageSize)),
+		)
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	return opts
+}
+
+// compressor returns the gRPC compressor name, or "" when exports are uncompressed
+func (t TransportOptions) compressor() string {
+	if t.Compression == "none" {
+		return ""
+	}
+	return t.Compression
+}
+
+// tlsConfig returns the TLS settings for secure endpoints, using the system cert pool
+func (t TransportOptions) tlsConfig() *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.TLSServerName,
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// describeTLS prints the TLS settings in verbose mode
+func (t TransportOptions) describeTLS(scheme string) {
+	fmt.Printf("[VERBOSE] Using %s with system certs, InsecureSkipVerify=%v\n", scheme, t.InsecureSkipVerify)
+	if t.TLSServerName != "" {
+		fmt.Printf("[VERBOSE] Using TLS server name %s\n", t.TLSServerName)
+	}
+}
+
+// httpClient returns an HTTP client with the TLS settings, HTTP version, and encoding
+// that adds the Authorization and per-export headers to every request and observes
+// the responses; the exporters' own client can do none of these
+func (t TransportOptions) httpClient(endpoint *Endpoint, auth authorization, dynamic *requestHeaders, obs *exportObserver) *http.Client {
+	rt := t.httpTransport(endpoint)
+	if t.Writer != nil {
+		rt = &writerTransport{writer: t.Writer, signal: obs.signal}
+	}
+	rt = &wireTransport{base: rt, obs: obs}
+	// jsonTransport re-encodes the body, so the signature must be taken after it
+	if dynamic != nil {
+		rt = &requestHeadersTransport{base: rt, headers: dynamic}
+	}
+	if t.Encoding == "json" {
+		rt = &jsonTransport{base: rt, signal: obs.signal}
+	}
+	if auth != nil {
+		rt = &authTransport{base: rt, auth: auth}
+	}
+	return &http.Client{
+		Timeout:   t.exportTimeout(),
+		Transport: &observedTransport{base: rt, obs: obs},
+	}
+}
+
+// httpTransport returns an HTTP transport with the TLS settings, connect timeout, and
+// HTTP version
+func (t TransportOptions) httpTransport(endpoint *Endpoint) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   t.connectTimeout(),
+		KeepAlive: 30 * time.Second,
+	}
+
+	// http.Transport only speaks HTTP/2 when TLS negotiates it, so forced HTTP/2 uses
+	// the http2 transport, which also speaks it in cleartext (h2c)
+	if t.HTTPVersion == "2" {
+		return &http2.Transport{
+			TLSClientConfig: t.tlsConfig(),
+			AllowHTTP:       true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				// cfg carries the original host as the server name
+				addr = t.resolvedAddress(addr)
+				if t.Dialer != nil {
+					conn, err := t.Dialer(ctx, addr)
+					if err != nil || !endpoint.Secure {
+						return conn, err
+					}
+					return tls.Client(conn, cfg), nil
+				}
+				if !endpoint.Secure {
+					return dialer.DialContext(ctx, network, addr)
+				}
+				return (&tls.Dialer{NetDialer: dialer, Config: cfg}).DialContext(ctx, network, addr)
+			},
+		}
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = t.tlsConfig()
+	base.DialContext = t.dialContext(dialer)
+	base.TLSHandshakeTimeout = t.connectTimeout()
+	if t.HTTPVersion == "1.1" {
+		// A non-nil, empty TLSNextProto disables HTTP/2
+		base.ForceAttemptHTTP2 = false
+		base.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		base.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	}
+	return base
+}
@@ -0,0 +1,121 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// unitCase is a unit string and whether it is valid UCUM
+type unitCase struct {
+	unit  string
+	valid bool
+}
+
+// unitCases covers common, compound, annotated, and broken unit strings
+var unitCases = []unitCase{
+	{"By", true},
+	{"KiBy", true},
+	{"MiBy", true},
+	{"kBy", true},
+	{"bit", true},
+	{"By/s", true},
+	{"By.s-1", true},
+	{"10*3.By", true},
+	{"ns", true},
+	{"us", true},
+	{"ms", true},
+	{"s", true},
+	{"min", true},
+	{"h", true},
+	{"d", true},
+	{"1", true},
+	{"%", true},
+	{"{requests}", true},
+	{"{request}/s", true},
+	{"{packet}/min", true},
+	{"s{cpu}", true},
+	{"Cel", true},
+	{"[degF]", true},
+	{"Hz", true},
+	{"W", true},
+	{"kW.h", true},
+	{"m/s2", true},
+	{"mV", true},
+	{"", false},
+	{"bytes", false},
+	{"milliseconds", false},
+	{"µs", false},
+	{"°C", false},
+	{"req per sec", false},
+	{"{unclosed", false},
+	{"By/", false},
+	{"%%", false},
+	{"秒", false},
+	{strings.Repeat("By.", 40) + "s", false},
+}
+
+// unitVarietyRecorder wraps a metric set and cycles through gauges that each use a different unit
+type unitVarietyRecorder struct {
+	metricRecorder
+	gauges []metric.Float64Gauge
+	next   int
+	rnd    *runRandom
+}
+
+func newUnitVarietyRecorder(meter metric.Meter, base metricRecorder, rnd *runRandom) (*unitVarietyRecorder, error) {
+	r := &unitVarietyRecorder{metricRecorder: base, rnd: rnd}
+
+	for i, uc := range unitCases {
+		name := fmt.Sprintf("otelgen.units.%02d_%s", i, unitSlug(uc.unit))
+		gauge, err := meter.Float64Gauge(name,
+			metric.WithDescription(fmt.Sprintf("Value reported in unit %q", uc.unit)),
+			metric.WithUnit(uc.unit),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		r.gauges = append(r.gauges, gauge)
+	}
+
+	return r, nil
+}
+
+// Record records the wrapped metric set, then the next unit gauge in rotation
+func (r *unitVarietyRecorder) Record(ctx context.Context) {
+	r.metricRecorder.Record(ctx)
+
+	uc := unitCases[r.next]
+	r.gauges[r.next].Record(ctx, r.rnd.Float64()*100, metric.WithAttributes(
+		attribute.Bool("unit.valid", uc.valid),
+	))
+	r.next = (r.next + 1) % len(r.gauges)
+}
+
+// unitSlug turns a unit into a metric-name-safe suffix
+func unitSlug(unit string) string {
+	var sb strings.Builder
+	for _, c := range strings.ToLower(unit) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			sb.WriteRune(c)
+		case c == '%':
+			sb.WriteString("percent")
+		case c == '/':
+			sb.WriteString("_per_")
+		default:
+			sb.WriteByte('_')
+		}
+		if sb.Len() >= 32 {
+			break
+		}
+	}
+	slug := strings.Trim(sb.String(), "_")
+	if slug == "" {
+		return "none"
+	}
+	return slug
+}
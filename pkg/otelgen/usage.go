@@ -0,0 +1,155 @@
+package otelgen
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// usageInterval is the time between samples of otelgen's resident memory, for its
+// peak and the memory limit
+const usageInterval = 500 * time.Millisecond
+
+// usageMonitor measures otelgen's own resource usage over a run, to size the machines
+// that run it and to tell whether it, rather than the endpoint, limits a benchmark.
+// Runs going on together in one process share the monitor, as they share the process
+type usageMonitor struct {
+	proc *process.Process
+	// limit, when positive, is the resident memory that aborts the run
+	limit uint64
+
+	start      time.Time
+	cpu        float64
+	numGC      uint32
+	pauseNs    uint64
+	totalAlloc uint64
+
+	mu      sync.Mutex
+	peakRSS uint64
+	over    chan struct{}
+	done    chan struct{}
+	// runs is the number of runs sharing the monitor, guarded by sharedUsageMu
+	runs int
+}
+
+// sharedUsage is the monitor of the runs going on in the process, if any
+var (
+	sharedUsageMu sync.Mutex
+	sharedUsage   *usageMonitor
+)
+
+// newUsageMonitor starts measuring the run's resource usage, or joins the monitor of
+// the runs already going on. Stop it after everything else the run reports, so the
+// usage comes last
+func (l LoadOptions) newUsageMonitor() *usageMonitor {
+	sharedUsageMu.Lock()
+	defer sharedUsageMu.Unlock()
+	if sharedUsage != nil {
+		sharedUsage.runs++
+		return sharedUsage
+	}
+	u := &usageMonitor{
+		runs:  1,
+		limit: uint64(max(l.MemLimit, 0)),
+		start: time.Now(),
+		over:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	u.numGC, u.pauseNs, u.totalAlloc = ms.NumGC, ms.PauseTotalNs, ms.TotalAlloc
+	if proc, err := process.NewProcess(int32(os.Getpid())); err == nil {
+		u.proc = proc
+		if times, err := proc.Times(); err == nil {
+			u.cpu = times.User + times.System
+		}
+		u.sample()
+		go u.watch()
+	}
+	sharedUsage = u
+	return u
+}
+
+// watch samples the resident memory until the run ends
+func (u *usageMonitor) watch() {
+	ticker := time.NewTicker(usageInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.sample()
+		case <-u.done:
+			return
+		}
+	}
+}
+
+// sample records the resident memory, closing over once it passes the limit
+func (u *usageMonitor) sample() {
+	mem, err := u.proc.MemoryInfo()
+	if err != nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if mem.RSS > u.peakRSS {
+		if u.limit > 0 && u.peakRSS <= u.limit && mem.RSS > u.limit {
+			close(u.over)
+		}
+		u.peakRSS = mem.RSS
+	}
+}
+
+// exceeded is closed once otelgen's resident memory passes the limit
+func (u *usageMonitor) exceeded() <-chan struct{} {
+	return u.over
+}
+
+// err returns the error the run aborts with once over the limit
+func (u *usageMonitor) err() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return fmt.Errorf("otelgen's resident memory reached %s, over the %s limit", formatBytes(float64(u.peakRSS)), formatBytes(float64(u.limit)))
+}
+
+// stop leaves the monitor, which stops measuring and prints the resource usage once
+// the last run sharing it ends
+func (u *usageMonitor) stop() {
+	sharedUsageMu.Lock()
+	u.runs--
+	last := u.runs == 0
+	if last {
+		sharedUsage = nil
+	}
+	sharedUsageMu.Unlock()
+	if !last {
+		return
+	}
+	close(u.done)
+	elapsed := time.Since(u.start)
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	var parts []string
+	if u.proc != nil {
+		u.sample()
+		u.mu.Lock()
+		parts = append(parts, "peak RSS "+formatBytes(float64(u.peakRSS)))
+		u.mu.Unlock()
+	}
+	parts = append(parts,
+		formatBytes(float64(ms.TotalAlloc-u.totalAlloc))+" allocated",
+		fmt.Sprintf("%d GCs (%s paused)", ms.NumGC-u.numGC, time.Duration(ms.PauseTotalNs-u.pauseNs).Round(time.Microsecond)))
+	if u.proc != nil {
+		if times, err := u.proc.Times(); err == nil {
+			cpu := times.User + times.System - u.cpu
+			parts = append(parts, fmt.Sprintf("%.2f CPU seconds (%.0f%% of a core)", cpu, 100*cpu/elapsed.Seconds()))
+		}
+	}
+	fmt.Printf("Resource usage: %s\n", strings.Join(parts, ", "))
+}
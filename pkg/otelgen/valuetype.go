@@ -0,0 +1,120 @@
+package otelgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// useInt reports whether an instrument should record Int64 values under the given
+// value type; mixed keeps the instrument's natural type
+func useInt(valueType string, natural bool) bool {
+	switch valueType {
+	case "int":
+		return true
+	case "double":
+		return false
+	default:
+		return natural
+	}
+}
+
+// validValueType checks a --value-type setting
+func validValueType(valueType string) error {
+	switch valueType {
+	case "", "mixed", "int", "double":
+		return nil
+	default:
+		return fmt.Errorf("unknown value type %q (supported: int, double, mixed)", valueType)
+	}
+}
+
+// numberAdder adds a value to an Int64 or Float64 counter; Int64 values are rounded
+type numberAdder func(ctx context.Context, value float64, opts ...metric.AddOption)
+
+// newNumberCounter creates a monotonic counter of the requested number type
+func newNumberCounter(meter metric.Meter, name string, isInt bool, opts ...metric.InstrumentOption) (numberAdder, error) {
+	if isInt {
+		counter, err := meter.Int64Counter(name, convertOptions[metric.Int64CounterOption](opts)...)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, value float64, opts ...metric.AddOption) {
+			counter.Add(ctx, int64(math.Round(value)), opts...)
+		}, nil
+	}
+	counter, err := meter.Float64Counter(name, convertOptions[metric.Float64CounterOption](opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return counter.Add, nil
+}
+
+// newNumberUpDownCounter creates an up-down counter of the requested number type
+func newNumberUpDownCounter(meter metric.Meter, name string, isInt bool, opts ...metric.InstrumentOption) (numberAdder, error) {
+	if isInt {
+		counter, err := meter.Int64UpDownCounter(name, convertOptions[metric.Int64UpDownCounterOption](opts)...)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, value float64, opts ...metric.AddOption) {
+			counter.Add(ctx, int64(math.Round(value)), opts...)
+		}, nil
+	}
+	counter, err := meter.Float64UpDownCounter(name, convertOptions[metric.Float64UpDownCounterOption](opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return counter.Add, nil
+}
+
+// numberCallback reports observations through observe, whatever the instrument's number type
+type numberCallback func(observe func(value float64, opts ...metric.ObserveOption))
+
+// newObservableNumber registers an observable gauge, counter, or updowncounter of the
+// requested number type; Int64 observations are rounded
+func newObservableNumber(meter metric.Meter, kind, name string, isInt bool, cb numberCallback, opts ...metric.InstrumentOption) error {
+	var err error
+	if isInt {
+		callback := func(ctx context.Context, observer metric.Int64Observer) error {
+			cb(func(value float64, opts ...metric.ObserveOption) {
+				observer.Observe(int64(math.Round(value)), opts...)
+			})
+			return nil
+		}
+		switch kind {
+		case "gauge":
+			_, err = meter.Int64ObservableGauge(name, append(convertOptions[metric.Int64ObservableGaugeOption](opts), metric.WithInt64Callback(callback))...)
+		case "counter":
+			_, err = meter.Int64ObservableCounter(name, append(convertOptions[metric.Int64ObservableCounterOption](opts), metric.WithInt64Callback(callback))...)
+		case "updowncounter":
+			_, err = meter.Int64ObservableUpDownCounter(name, append(convertOptions[metric.Int64ObservableUpDownCounterOption](opts), metric.WithInt64Callback(callback))...)
+		}
+		return err
+	}
+
+	callback := func(ctx context.Context, observer metric.Float64Observer) error {
+		cb(observer.Observe)
+		return nil
+	}
+	switch kind {
+	case "gauge":
+		_, err = meter.Float64ObservableGauge(name, append(convertOptions[metric.Float64ObservableGaugeOption](opts), metric.WithFloat64Callback(callback))...)
+	case "counter":
+		_, err = meter.Float64ObservableCounter(name, append(convertOptions[metric.Float64ObservableCounterOption](opts), metric.WithFloat64Callback(callback))...)
+	case "updowncounter":
+		_, err = meter.Float64ObservableUpDownCounter(name, append(convertOptions[metric.Float64ObservableUpDownCounterOption](opts), metric.WithFloat64Callback(callback))...)
+	}
+	return err
+}
+
+// convertOptions adapts generic instrument options to a constructor's option type
+func convertOptions[T any](opts []metric.InstrumentOption) []T {
+	out := make([]T, 0, len(opts))
+	for _, o := range opts {
+		out = append(out, any(o).(T))
+	}
+	return out
+}
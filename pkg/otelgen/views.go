@@ -0,0 +1,93 @@
+package otelgen
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// ViewDefinition customizes how the SDK aggregates and exports matching instruments
+type ViewDefinition struct {
+	// Instrument is the instrument name to match; * and ? wildcards are allowed
+	Instrument string `yaml:"instrument"`
+	// Rename exports the stream under a different name (exact instrument names only)
+	Rename string `yaml:"rename"`
+	// KeepAttributes keeps only these attribute keys
+	KeepAttributes []string `yaml:"keep_attributes"`
+	// DropAttributes removes these attribute keys
+	DropAttributes []string `yaml:"drop_attributes"`
+	// Aggregation is one of default, drop, sum, last_value, histogram, exponential_histogram
+	Aggregation string `yaml:"aggregation"`
+	// Buckets are the boundaries for the histogram aggregation
+	Buckets []float64 `yaml:"buckets"`
+}
+
+// newView converts a view definition to an SDK view
+func newView(def ViewDefinition) (sdkmetric.View, error) {
+	if def.Instrument == "" {
+		return nil, fmt.Errorf("instrument is required")
+	}
+	if def.Rename != "" && strings.ContainsAny(def.Instrument, "*?") {
+		return nil, fmt.Errorf("rename cannot be used with a wildcard instrument")
+	}
+	if len(def.KeepAttributes) > 0 && len(def.DropAttributes) > 0 {
+		return nil, fmt.Errorf("only one of keep_attributes and drop_attributes can be used")
+	}
+
+	stream := sdkmetric.Stream{Name: def.Rename}
+
+	switch def.Aggregation {
+	case "", "default":
+	case "drop":
+		stream.Aggregation = sdkmetric.AggregationDrop{}
+	case "sum":
+		stream.Aggregation = sdkmetric.AggregationSum{}
+	case "last_value":
+		stream.Aggregation = sdkmetric.AggregationLastValue{}
+	case "histogram":
+		agg := sdkmetric.AggregationExplicitBucketHistogram{Boundaries: def.Buckets}
+		if len(def.Buckets) == 0 {
+			agg.Boundaries = defaultHistogramBounds
+		}
+		stream.Aggregation = agg
+	case "exponential_histogram":
+		stream.Aggregation = sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q (supported: default, drop, sum, last_value, histogram, exponential_histogram)", def.Aggregation)
+	}
+	if len(def.Buckets) > 0 && def.Aggregation != "histogram" {
+		return nil, fmt.Errorf("buckets require the histogram aggregation")
+	}
+
+	if len(def.KeepAttributes) > 0 {
+		stream.AttributeFilter = attribute.NewAllowKeysFilter(attributeKeys(def.KeepAttributes)...)
+	}
+	if len(def.DropAttributes) > 0 {
+		stream.AttributeFilter = attribute.NewDenyKeysFilter(attributeKeys(def.DropAttributes)...)
+	}
+
+	return sdkmetric.NewView(sdkmetric.Instrument{Name: def.Instrument}, stream), nil
+}
+
+// newViews converts view definitions to SDK views
+func newViews(defs []ViewDefinition) ([]sdkmetric.View, error) {
+	views := make([]sdkmetric.View, 0, len(defs))
+	for i, def := range defs {
+		view, err := newView(def)
+		if err != nil {
+			return nil, fmt.Errorf("view %d (%s): %w", i+1, def.Instrument, err)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func attributeKeys(keys []string) []attribute.Key {
+	out := make([]attribute.Key, len(keys))
+	for i, k := range keys {
+		out[i] = attribute.Key(k)
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package otelgen
+
+import (
+	"fmt"
+	"sync"
+)
+
+// emitPool emits the events the limiter hands out on a pool of goroutines, for rates
+// a single one cannot reach, e.g. traces, whose spans sleep to simulate work
+type emitPool struct {
+	work chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// newEmitPool starts the workers emitting events, or returns nil to emit them on the
+// generator's own goroutine
+func (l LoadOptions) newEmitPool(emit func()) (*emitPool, error) {
+	if l.Workers < 0 {
+		return nil, fmt.Errorf("workers cannot be negative")
+	}
+	if l.Workers <= 1 {
+		return nil, nil
+	}
+	p := &emitPool{work: make(chan struct{}, l.Workers)}
+	p.wg.Add(l.Workers)
+	for range l.Workers {
+		go func() {
+			defer p.wg.Done()
+			for range p.work {
+				emit()
+			}
+		}()
+	}
+	return p, nil
+}
+
+// emit hands n events to the workers, waiting while they are all busy so the rate
+// doesn't run ahead of them; without a pool it emits them itself
+func (p *emitPool) emit(n int, emit func()) {
+	if p == nil {
+		for range n {
+			emit()
+		}
+		return
+	}
+	for range n {
+		p.work <- struct{}{}
+	}
+}
+
+// stop waits for the events handed out to be emitted, so the final flush sends them
+func (p *emitPool) stop() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		close(p.work)
+		p.wg.Wait()
+	})
+}
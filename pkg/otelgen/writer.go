@@ -0,0 +1,67 @@
+package otelgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PayloadWriter receives every export request in place of an OTLP endpoint. Payloads
+// are uncompressed OTLP requests, protobuf-encoded unless the encoding is json.
+type PayloadWriter interface {
+	WritePayload(ctx context.Context, signal string, payload []byte) error
+	Close() error
+}
+
+// writerEndpoint stands in for the endpoint when exports go to a PayloadWriter; the
+// exporters still speak OTLP/HTTP, but their requests never leave the process
+var writerEndpoint = &Endpoint{Protocol: ProtocolHTTP, Host: "otelgen.invalid", Port: "80"}
+
+// exportEndpoint returns the endpoint the exporters are built for
+func (t TransportOptions) exportEndpoint(endpoint *Endpoint) *Endpoint {
+	if t.Writer != nil {
+		return writerEndpoint
+	}
+	return endpoint
+}
+
+// writerTransport hands each export request body to the payload writer and answers
+// with an empty success response
+type writerTransport struct {
+	writer PayloadWriter
+	signal string
+}
+
+func (t *writerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress request: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress request: %w", err)
+		}
+	}
+
+	if err := t.writer.WritePayload(req.Context(), t.signal, body); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{req.Header.Get("Content-Type")}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package otelgen
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// grpc-go only ships a gzip compressor, so zstd is registered here
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// zstdCompressor is a gRPC compressor for the "zstd" encoding, reusing encoders
+// across messages since creating one is expensive
+type zstdCompressor struct {
+	encoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string {
+	return "zstd"
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if enc, ok := c.encoders.Get().(*zstd.Encoder); ok {
+		enc.Reset(w)
+		return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+	}
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec}, nil
+}
+
+// zstdWriter returns its encoder to the pool once the message is written
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader releases the decoder once the message has been read
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		r.Decoder.Close()
+	}
+	return n, err
+}